@@ -1,40 +1,258 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
 	"vision-bot/config"
-	"vision-bot/internal/container"
 	telegram "vision-bot/internal/api"
+	"vision-bot/internal/container"
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/domain/port"
+	"vision-bot/internal/i18n"
+	"vision-bot/internal/infrastructure/ai"
+	"vision-bot/internal/infrastructure/messenger"
 	"vision-bot/internal/infrastructure/storage"
 	"vision-bot/internal/infrastructure/vision"
+	"vision-bot/internal/logging"
+	"vision-bot/internal/metrics"
+	"vision-bot/internal/ops"
 )
 
+// version задаётся при сборке: -ldflags "-X main.version=..."
+var version = "dev"
+
 func main() {
-	cfg, err := config.Load()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+	cfg, flags, err := config.Load(os.Args[1:])
+	if cfg == nil {
+		// Ошибку разбора флагов уже напечатал пакет flag
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+
+	if flags.PrintConfig {
+		cfg.Print(os.Stdout)
+		exitOnConfigError(err)
+		return
+	}
+
+	// Режим health check для docker compose: образ не содержит curl
+	if flags.HealthCheck {
+		if err := ops.Probe(context.Background(), cfg.Observability.OpsAddr, "/readyz"); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
+	exitOnConfigError(err)
 
-	if cfg.TelegramToken == "" {
-		log.Fatal("TELEGRAM_TOKEN is required")
+	logger, err := logging.New(os.Stderr, cfg.Observability.LogFormat, cfg.Observability.LogLevel)
+	if err != nil {
+		log.Fatalf("Failed to configure logging: %v", err)
 	}
+	slog.SetDefault(logger)
+
+	// Останавливаемся по SIGINT/SIGTERM (в том числе при docker compose down)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Создаём хранилище пользователей
+	// Создаём хранилища пользователей и истории проверок
 	userRepo := storage.NewMemoryUserRepository()
+	inspectionRepo := storage.NewMemoryInspectionRepository()
+	inspectionRepo.SetRetention(cfg.Storage.Retention)
+
+	// Каталог сообщений встроен в бинарник
+	catalog, err := i18n.Default()
+	if err != nil {
+		fatal("Failed to load message catalog", "err", err)
+	}
+
+	// Метрики собираются декораторами вокруг портов
+	registry := metrics.NewRegistry()
+	botMetrics := metrics.New(registry)
 
 	// Собираем сервисы приложения
-	detector := vision.NewGoCVDetector(0)
-	appContainer := container.New(userRepo, detector, nil)
+	profile, err := detectorProfile(cfg.Vision)
+	if err != nil {
+		fatal("Failed to load detector profile", "err", err)
+	}
+	slog.Info("Detector profile loaded", "profile", profile.Name, "version", profile.Version)
+	profiles := vision.NewProfileStore(cfg.Vision.ProfileDir, profile)
+	detector := botMetrics.InstrumentDetector(profiles)
+	describer := newDescriber(cfg.Describer, catalog, botMetrics)
+	appContainer := container.New(userRepo, inspectionRepo, detector, describer, entity.SessionPolicy{
+		MaxChecks: cfg.Bot.Session.MaxChecks,
+		TTL:       cfg.Bot.Session.TTL,
+	}, entity.IdlePolicy{
+		RemindAfter: cfg.Bot.Idle.Reminder,
+		ExpireAfter: cfg.Bot.Idle.Timeout,
+	}, entity.AccessPolicy{
+		Admins:       cfg.Bot.Access.AdminIDs,
+		AllowedUsers: cfg.Bot.Access.AllowedUserIDs,
+		AllowedChats: cfg.Bot.Access.AllowedChatIDs,
+		DefaultRole:  entity.Role(cfg.Bot.Access.DefaultRole),
+	}, rateLimitPolicy(cfg.Bot.Limits))
+	// Калибровка идёт мимо метрик детектора: это не проверка детали.
+	appContainer.InspectionService.SetCalibrator(profiles)
+	botMetrics.ObserveRateLimiter(appContainer.RateLimiter)
+	if appContainer.AccessService.Policy().Open() {
+		slog.Warn("Access control is disabled: set ADMIN_IDS or an allowlist to restrict the bot")
+	}
 
-	// Создаём бота
-	bot, err := telegram.NewBot(cfg.TelegramToken, appContainer)
+	// Подключаемся к Telegram
+	api, err := tgbotapi.NewBotAPI(cfg.Bot.Token)
 	if err != nil {
-		log.Fatalf("Failed to create bot: %v", err)
+		fatal("Failed to create bot", "err", err)
+	}
+	slog.Info("Authorized", "account", api.Self.UserName)
+
+	// Создаём бота
+	bot := telegram.NewBot(api, botMetrics.InstrumentMessenger(messenger.NewTelegramMessenger(api)), appContainer, telegram.Options{
+		Mode: cfg.Bot.Mode,
+		Webhook: telegram.WebhookOptions{
+			ListenAddr:  cfg.Bot.Webhook.ListenAddr,
+			Path:        cfg.Bot.Webhook.Path,
+			URL:         cfg.Bot.Webhook.URL,
+			SecretToken: cfg.Bot.Webhook.SecretToken,
+			CertFile:    cfg.Bot.Webhook.CertFile,
+			KeyFile:     cfg.Bot.Webhook.KeyFile,
+		},
+		ShutdownTimeout: cfg.Bot.ShutdownTimeout,
+		MaxImageSize:    int64(cfg.Bot.MaxImageSizeMB) << 20,
+		AlbumWindow:     cfg.Bot.AlbumWindow,
+		Catalog:         catalog,
+	})
+
+	if cfg.Observability.OpsAddr != "" {
+		server := ops.NewServer(ops.Options{
+			Version:   version,
+			BuildTags: buildTags(),
+			Profiles:  profiles.Versions,
+			Queue:     appContainer.RateLimiter.Stats,
+			Metrics:   registry.Handler(),
+			Checks: []ops.Check{
+				{Name: "telegram", Run: func(context.Context) error { _, err := api.GetMe(); return err }},
+				{Name: "repositories", Run: appContainer.Ping},
+				ops.DescriberCheck(describer),
+				// Самопроверка идёт мимо метрик, чтобы не смешиваться с проверками операторов.
+				ops.DetectorCheck(profiles),
+			},
+		})
+		go func() {
+			if err := server.Run(ctx, cfg.Observability.OpsAddr); err != nil {
+				slog.Error("Ops server error", "err", err)
+			}
+		}()
 	}
 
-	log.Println("Bot is running...")
-	if err := bot.Run(); err != nil {
-		log.Fatalf("Bot error: %v", err)
+	// Профиль перечитывается при изменении файла и по SIGHUP
+	if cfg.Vision.ProfileDir != "" {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+		go profiles.Watch(ctx, cfg.Vision.ReloadInterval, hup, func(reload vision.ProfileReload) {
+			switch {
+			case reload.Err != nil:
+				slog.Error("Detector profile rejected", "profile", reload.Profile.Name, "version", reload.Profile.Version, "err", reload.Err)
+				bot.ReportProfileRejected(ctx, reload.Profile.Name, reload.Profile.Version, reload.Err)
+			case reload.Changed():
+				slog.Info("Detector profile reloaded", "profile", reload.Profile.Name, "version", reload.Profile.Version, "previous", reload.Previous)
+			default:
+				slog.Info("Detector profile unchanged", "profile", reload.Profile.Name, "version", reload.Profile.Version)
+			}
+		})
+	}
+
+	slog.Info("Bot is running", "mode", cfg.Bot.Mode)
+	if err := bot.Run(ctx); err != nil {
+		slog.Error("Bot error", "err", err)
+	}
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := appContainer.Close(flushCtx); err != nil {
+		slog.Error("Failed to flush repositories", "err", err)
+	}
+
+	slog.Info("Bot stopped")
+}
+
+// exitOnConfigError печатает все ошибки конфигурации разом и завершает процесс.
+func exitOnConfigError(err error) {
+	if err == nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+	os.Exit(1)
+}
+
+// detectorProfile загружает профиль детектора или берёт встроенные пороги.
+func detectorProfile(cfg config.VisionConfig) (vision.Profile, error) {
+	profile := vision.BuiltinProfile(cfg.DefaultProfile)
+	if cfg.ProfileDir != "" {
+		var err error
+		if profile, err = vision.LoadProfile(cfg.ProfileDir, cfg.DefaultProfile); err != nil {
+			return vision.Profile{}, err
+		}
+	}
+	if cfg.DebugDump != "" {
+		if err := os.MkdirAll(cfg.DebugDump, 0o755); err != nil {
+			return vision.Profile{}, fmt.Errorf("create debug dump directory: %w", err)
+		}
+		profile.Detector.DebugDumpDir = cfg.DebugDump
+	}
+	return profile, nil
+}
+
+// newDescriber выбирает описатель дефектов. Языковая модель работает
+// с шаблонами как запасным вариантом, её отказы видны в метриках.
+func newDescriber(cfg config.DescriberConfig, catalog *i18n.Catalog, m *metrics.Metrics) port.DefectDescriber {
+	template := ai.NewTemplateDescriber(catalog)
+	switch cfg.Provider {
+	case "none":
+		return nil
+	case "ollama":
+		return ai.NewFallbackDescriber(m.InstrumentDescriber(ai.NewOllamaDescriber(cfg.URL, cfg.Model, cfg.Timeout)), template)
+	default:
+		return m.InstrumentDescriber(template)
+	}
+}
+
+// buildTags перечисляет теги сборки, влияющие на возможности бота.
+func buildTags() []string {
+	if vision.Enabled {
+		return []string{"gocv"}
+	}
+	return []string{}
+}
+
+// fatal пишет ошибку запуска и завершает процесс.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// rateLimitPolicy переводит ограничения из конфигурации в доменную политику.
+func rateLimitPolicy(cfg config.RateLimitConfig) entity.RateLimitPolicy {
+	policy := entity.RateLimitPolicy{
+		Roles:         make(map[entity.Role]entity.RateLimit),
+		Chat:          entity.RateLimit{Events: cfg.Chat.Events, Per: cfg.Chat.Per},
+		MaxConcurrent: cfg.MaxConcurrent,
+	}
+	for role, limit := range cfg.Roles {
+		policy.Roles[entity.Role(role)] = entity.RateLimit{Events: limit.Events, Per: limit.Per}
 	}
+	return policy
 }
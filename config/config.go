@@ -1,22 +1,369 @@
+// Package config собирает настройки бота из нескольких слоёв: значения по
+// умолчанию, затем YAML-файл, затем переменные окружения, затем флаги
+// командной строки. Каждый следующий слой переопределяет предыдущий.
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
 	"os"
+	"runtime"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Config — все настройки бота, сгруппированные по подсистемам.
 type Config struct {
-	TelegramToken string
+	Bot           BotConfig
+	Storage       StorageConfig
+	Vision        VisionConfig
+	Describer     DescriberConfig
+	Observability ObservabilityConfig
 }
 
-func Load() (*Config, error) {
+// BotConfig — работа с Telegram и ограничения диалога.
+type BotConfig struct {
+	Token           string
+	Mode            string // polling или webhook
+	Webhook         WebhookConfig
+	ShutdownTimeout time.Duration
+	// MaxImageSizeMB ограничивает размер изображений, присланных файлом.
+	MaxImageSizeMB int
+	// AlbumWindow — сколько ждать остальные фото альбома.
+	AlbumWindow time.Duration
+	Session     SessionConfig
+	Idle        IdleConfig
+	// Access задаёт, кто может пользоваться ботом.
+	Access AccessConfig
+	// Limits ограничивает поток фото и число одновременных проверок.
+	Limits RateLimitConfig
+}
+
+// WebhookConfig описывает приём обновлений через вебхук.
+type WebhookConfig struct {
+	ListenAddr  string
+	Path        string
+	URL         string
+	SecretToken string
+	CertFile    string
+	KeyFile     string
+}
+
+// SessionConfig ограничивает серию проверок с одним эталоном.
+type SessionConfig struct {
+	// MaxChecks — сколько деталей можно проверить с одним эталоном (0 — без ограничения).
+	MaxChecks int
+	// TTL — сколько эталон остаётся активным (0 — без ограничения).
+	TTL time.Duration
+}
+
+// IdleConfig задаёт реакцию на брошенные проверки.
+type IdleConfig struct {
+	// Reminder — через сколько бездействия напомнить о начатой проверке (0 — не напоминать).
+	Reminder time.Duration
+	// Timeout — через сколько бездействия отменить проверку и удалить фото (0 — никогда).
+	Timeout time.Duration
+}
+
+// AccessConfig описывает списки доступа. Если все списки пусты, бот открыт для всех.
+type AccessConfig struct {
+	// AdminIDs — администраторы, назначаемые при запуске.
+	AdminIDs []int64
+	// AllowedUserIDs — пользователи, допущенные без выдачи роли.
+	AllowedUserIDs []int64
+	// AllowedChatIDs — группы, все участники которых допущены.
+	AllowedChatIDs []int64
+	// DefaultRole — роль допущенных по спискам: operator, inspector или admin.
+	DefaultRole string
+}
+
+// RateLimitConfig описывает ограничения нагрузки на детектор.
+type RateLimitConfig struct {
+	// Roles — ограничение на пользователя по роли: operator, inspector, admin.
+	Roles map[string]RateLimitSpec
+	// Chat — ограничение на групповой чат целиком.
+	Chat RateLimitSpec
+	// MaxConcurrent — одновременных проверок на весь бот (0 — без ограничения).
+	MaxConcurrent int
+}
+
+// RateLimitSpec — не больше Events фото за Per; нулевое значение — без ограничения.
+type RateLimitSpec struct {
+	Events int
+	Per    time.Duration
+}
+
+// StorageConfig — где хранятся пользователи и история проверок.
+type StorageConfig struct {
+	// Driver — реализация хранилища; сейчас доступна только memory.
+	Driver string
+	// DSN — строка подключения для внешних хранилищ.
+	DSN string
+	// Retention — сколько хранить историю проверок вместе со снимками (0 — бессрочно).
+	Retention time.Duration
+}
+
+// VisionConfig — профили детектора и отладка.
+type VisionConfig struct {
+	// ProfileDir — каталог с YAML-профилями порогов (пусто — встроенные значения).
+	ProfileDir string
+	// DefaultProfile — имя профиля без расширения.
+	DefaultProfile string
+	// DebugDump — каталог для промежуточных масок каждой проверки (пусто — не сохранять).
+	DebugDump string
+	// ReloadInterval — как часто проверять файл профиля на изменения
+	// (0 — только по SIGHUP).
+	ReloadInterval time.Duration
+}
+
+// DescriberConfig — кто пишет текстовое описание дефектов.
+type DescriberConfig struct {
+	// Provider — template (шаблоны каталога), ollama (языковая модель
+	// с шаблонами как запасным вариантом) или none (без описания).
+	Provider string
+	URL      string
+	Model    string
+	// Timeout ограничивает один запрос к модели.
+	Timeout time.Duration
+}
+
+// ObservabilityConfig — логи и служебный сервер.
+type ObservabilityConfig struct {
+	// LogLevel — debug, info, warn или error.
+	LogLevel string
+	// LogFormat — text для чтения глазами или json для сборщиков логов.
+	LogFormat string
+	// OpsAddr — адрес служебного сервера: /healthz, /readyz, /status, /metrics
+	// (пусто — не запускать).
+	OpsAddr string
+}
+
+// Flags — режимы запуска, которые задаются только в командной строке.
+type Flags struct {
+	// File — путь к YAML-файлу конфигурации (также CONFIG_FILE).
+	File string
+	// PrintConfig — напечатать итоговую конфигурацию без секретов и выйти.
+	PrintConfig bool
+	// HealthCheck — опросить /readyz запущенного бота и выйти.
+	HealthCheck bool
+}
+
+// Defaults возвращает конфигурацию по умолчанию.
+func Defaults() *Config {
+	return &Config{
+		Bot: BotConfig{
+			Mode: "polling",
+			Webhook: WebhookConfig{
+				ListenAddr: ":8443",
+				Path:       "/telegram/webhook",
+			},
+			ShutdownTimeout: 30 * time.Second,
+			MaxImageSizeMB:  20,
+			AlbumWindow:     1500 * time.Millisecond,
+			Session:         SessionConfig{MaxChecks: 20, TTL: 30 * time.Minute},
+			Idle:            IdleConfig{Reminder: 5 * time.Minute, Timeout: 15 * time.Minute},
+			Access:          AccessConfig{DefaultRole: "operator"},
+			// Ограничения частоты по умолчанию: фото на проверку за минуту.
+			Limits: RateLimitConfig{
+				Roles: map[string]RateLimitSpec{
+					"operator":  {Events: 20, Per: time.Minute},
+					"inspector": {Events: 40, Per: time.Minute},
+					"admin":     {},
+				},
+				Chat:          RateLimitSpec{Events: 60, Per: time.Minute},
+				MaxConcurrent: runtime.NumCPU(),
+			},
+		},
+		// История хранит снимки, поэтому по умолчанию живёт сутки, а не бессрочно.
+		Storage: StorageConfig{Driver: "memory", Retention: 24 * time.Hour},
+		Vision:  VisionConfig{DefaultProfile: "default", ReloadInterval: 5 * time.Second},
+		Describer: DescriberConfig{
+			Provider: "template",
+			URL:      "http://localhost:11434",
+			Model:    "qwen2.5:7b",
+			Timeout:  20 * time.Second,
+		},
+		Observability: ObservabilityConfig{
+			LogLevel:  "info",
+			LogFormat: "text",
+			OpsAddr:   ":9090",
+		},
+	}
+}
+
+// Load собирает конфигурацию из слоёв по порядку: значения по умолчанию,
+// YAML-файл (флаг -config или CONFIG_FILE), окружение (в том числе .env),
+// флаги args. Ошибки всех слоёв и проверки значений собираются вместе.
+// Конфигурация возвращается и при ошибках проверки, чтобы её можно было
+// напечатать; nil — только если не разобрались сами флаги.
+func Load(args []string) (*Config, Flags, error) {
 	// Загружаем .env файл (игнорируем ошибку если файла нет)
 	_ = godotenv.Load()
 
-	cfg := &Config{
-		TelegramToken: os.Getenv("TELEGRAM_TOKEN"),
+	cfg := Defaults()
+	settings := cfg.settings()
+
+	var flags Flags
+	overrides := make(map[string]string)
+	fs := flag.NewFlagSet("vision-bot", flag.ContinueOnError)
+	fs.StringVar(&flags.File, "config", os.Getenv("CONFIG_FILE"), "path to the YAML config file (env CONFIG_FILE)")
+	fs.BoolVar(&flags.PrintConfig, "print-config", false, "print the effective config with secrets redacted and exit")
+	fs.BoolVar(&flags.HealthCheck, "healthcheck", false, "probe /readyz of the running bot and exit")
+	for _, s := range settings {
+		key := s.key
+		fs.Func(key, s.usage, func(raw string) error {
+			overrides[key] = raw
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, flags, err
 	}
 
-	return cfg, nil
+	var errs []error
+	if flags.File != "" {
+		errs = append(errs, loadFile(flags.File, settings)...)
+	}
+	for _, s := range settings {
+		if s.env == "" {
+			continue
+		}
+		if raw := os.Getenv(s.env); raw != "" {
+			if err := s.value.Set(raw); err != nil {
+				errs = append(errs, fmt.Errorf("env %s: %w", s.env, err))
+			}
+		}
+	}
+	for _, s := range settings {
+		if raw, ok := overrides[s.key]; ok {
+			if err := s.value.Set(raw); err != nil {
+				errs = append(errs, fmt.Errorf("flag -%s: %w", s.key, err))
+			}
+		}
+	}
+
+	errs = append(errs, cfg.validate()...)
+	return cfg, flags, errors.Join(errs...)
+}
+
+// validate проверяет значения и связи между ними.
+func (c *Config) validate() []error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Bot.Token == "" {
+		fail("bot.token is required (env TELEGRAM_TOKEN)")
+	}
+	switch c.Bot.Mode {
+	case "polling":
+	case "webhook":
+		if c.Bot.Webhook.URL == "" {
+			fail("bot.webhook.url is required in webhook mode")
+		}
+		// Без секрета вебхук принял бы поддельные обновления от любого отправителя.
+		if !validSecretToken(c.Bot.Webhook.SecretToken) {
+			fail("bot.webhook.secret_token is required in webhook mode: 1-256 characters A-Z, a-z, 0-9, _ or - (env WEBHOOK_SECRET_TOKEN)")
+		}
+		if (c.Bot.Webhook.CertFile == "") != (c.Bot.Webhook.KeyFile == "") {
+			fail("bot.webhook.cert_file and bot.webhook.key_file must be set together")
+		}
+	default:
+		fail("bot.mode %q: expected polling or webhook", c.Bot.Mode)
+	}
+	if c.Bot.ShutdownTimeout <= 0 {
+		fail("bot.shutdown_timeout must be positive")
+	}
+	if c.Bot.MaxImageSizeMB <= 0 {
+		fail("bot.max_image_size_mb must be positive")
+	}
+	if c.Bot.Session.MaxChecks < 0 || c.Bot.Session.TTL < 0 {
+		fail("bot.session limits must not be negative")
+	}
+	if c.Bot.Idle.Reminder < 0 || c.Bot.Idle.Timeout < 0 {
+		fail("bot.idle durations must not be negative")
+	}
+	if c.Bot.Idle.Reminder > 0 && c.Bot.Idle.Timeout > 0 && c.Bot.Idle.Reminder >= c.Bot.Idle.Timeout {
+		fail("bot.idle.reminder must be shorter than bot.idle.timeout")
+	}
+	switch c.Bot.Access.DefaultRole {
+	case "operator", "inspector", "admin":
+	default:
+		fail("bot.access.default_role %q: expected operator, inspector or admin", c.Bot.Access.DefaultRole)
+	}
+	if c.Bot.Limits.MaxConcurrent < 0 {
+		fail("bot.limits.max_concurrent must not be negative")
+	}
+
+	switch c.Storage.Driver {
+	case "memory":
+		if c.Storage.DSN != "" {
+			fail("storage.dsn is not used by the memory driver")
+		}
+	default:
+		fail("storage.driver %q: only memory is available", c.Storage.Driver)
+	}
+	if c.Storage.Retention < 0 {
+		fail("storage.retention must not be negative")
+	}
+
+	if c.Vision.ProfileDir != "" {
+		if info, err := os.Stat(c.Vision.ProfileDir); err != nil || !info.IsDir() {
+			fail("vision.profile_dir %q is not a directory", c.Vision.ProfileDir)
+		}
+		if c.Vision.DefaultProfile == "" {
+			fail("vision.default_profile is required with vision.profile_dir")
+		}
+	}
+	if c.Vision.ReloadInterval < 0 {
+		fail("vision.reload_interval must not be negative")
+	}
+
+	switch c.Describer.Provider {
+	case "template", "none":
+	case "ollama":
+		if u, err := url.Parse(c.Describer.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			fail("describer.url %q: expected an absolute URL", c.Describer.URL)
+		}
+		if c.Describer.Model == "" {
+			fail("describer.model is required for the ollama provider")
+		}
+		if c.Describer.Timeout <= 0 {
+			fail("describer.timeout must be positive")
+		}
+	default:
+		fail("describer.provider %q: expected template, ollama or none", c.Describer.Provider)
+	}
+
+	switch c.Observability.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		fail("observability.log_level %q: expected debug, info, warn or error", c.Observability.LogLevel)
+	}
+	switch c.Observability.LogFormat {
+	case "text", "json":
+	default:
+		fail("observability.log_format %q: expected text or json", c.Observability.LogFormat)
+	}
+	return errs
+}
+
+// validSecretToken проверяет секрет вебхука по правилам Telegram:
+// от 1 до 256 символов A-Z, a-z, 0-9, _ и -.
+func validSecretToken(token string) bool {
+	if token == "" || len(token) > 256 {
+		return false
+	}
+	for _, r := range token {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
 }
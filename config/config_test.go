@@ -0,0 +1,127 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_LayersOverrideInOrder(t *testing.T) {
+	path := writeFile(t, `
+bot:
+  token: from-file
+  mode: polling
+  session:
+    max_checks: 5
+  access:
+    admin_ids: [1, 2]
+  limits:
+    operator: 10/1m
+storage:
+  retention: 72h
+`)
+	t.Setenv("TELEGRAM_TOKEN", "from-env")
+	t.Setenv("SESSION_MAX_CHECKS", "7")
+
+	cfg, flags, err := Load([]string{"-config", path, "-bot.session.max_checks=9"})
+	require.NoError(t, err)
+
+	assert.Equal(t, path, flags.File)
+	assert.Equal(t, "from-env", cfg.Bot.Token)
+	assert.Equal(t, 9, cfg.Bot.Session.MaxChecks)
+	assert.Equal(t, []int64{1, 2}, cfg.Bot.Access.AdminIDs)
+	assert.Equal(t, RateLimitSpec{Events: 10, Per: time.Minute}, cfg.Bot.Limits.Roles["operator"])
+	assert.Equal(t, 72*time.Hour, cfg.Storage.Retention)
+	// Не заданное ни в одном слое остаётся по умолчанию
+	assert.Equal(t, 30*time.Minute, cfg.Bot.Session.TTL)
+}
+
+func TestLoad_CollectsAllErrors(t *testing.T) {
+	t.Setenv("TELEGRAM_TOKEN", "")
+	path := writeFile(t, `
+bot:
+  mode: carrier-pigeon
+  sesion:
+    ttl: 1m
+storage:
+  driver: postgres
+`)
+
+	cfg, _, err := Load([]string{"-config", path, "-describer.timeout=soon"})
+	require.Error(t, err)
+	require.NotNil(t, cfg)
+
+	for _, want := range []string{
+		`unknown setting "bot.sesion.ttl"`,
+		"flag -describer.timeout",
+		"bot.token is required",
+		`bot.mode "carrier-pigeon"`,
+		`storage.driver "postgres"`,
+	} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestConfig_PrintRedactsSecrets(t *testing.T) {
+	cfg := Defaults()
+	cfg.Bot.Token = "123:secret"
+	cfg.Bot.Access.AdminIDs = []int64{42}
+
+	var out bytes.Buffer
+	cfg.Print(&out)
+
+	assert.NotContains(t, out.String(), "123:secret")
+	assert.Contains(t, out.String(), "bot.token = <redacted>\n")
+	assert.Contains(t, out.String(), "bot.webhook.secret_token = \n")
+	assert.Contains(t, out.String(), "bot.access.admin_ids = 42\n")
+	assert.Contains(t, out.String(), "bot.limits.operator = 20/1m0s\n")
+}
+
+func TestDefaults_HistoryExpires(t *testing.T) {
+	// История хранит снимки деталей, поэтому бессрочное хранение нужно включать явно.
+	assert.Equal(t, 24*time.Hour, Defaults().Storage.Retention)
+}
+
+func TestLoad_ShutdownTimeoutAndLogCase(t *testing.T) {
+	t.Setenv("TELEGRAM_TOKEN", "token")
+	t.Setenv("LOG_LEVEL", "DEBUG")
+
+	cfg, _, err := Load([]string{"-bot.shutdown_timeout=0s", "-observability.log_format=JSON"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bot.shutdown_timeout must be positive")
+	// Регистр приводится при разборе, а не при проверке.
+	assert.Equal(t, "debug", cfg.Observability.LogLevel)
+	assert.Equal(t, "json", cfg.Observability.LogFormat)
+
+	cfg = Defaults()
+	cfg.Observability.LogLevel = "INFO"
+	assert.NotEmpty(t, cfg.validate())
+	assert.Equal(t, "INFO", cfg.Observability.LogLevel)
+}
+
+func TestValidate_WebhookRequiresSecret(t *testing.T) {
+	cfg := Defaults()
+	cfg.Bot.Token = "token"
+	cfg.Bot.Mode = "webhook"
+	cfg.Bot.Webhook.URL = "https://bot.example.com/telegram/webhook"
+	require.ErrorContains(t, errors.Join(cfg.validate()...), "bot.webhook.secret_token is required")
+
+	cfg.Bot.Webhook.SecretToken = "not a token!"
+	require.ErrorContains(t, errors.Join(cfg.validate()...), "bot.webhook.secret_token is required")
+
+	cfg.Bot.Webhook.SecretToken = "s3cret_Token-1"
+	require.Empty(t, cfg.validate())
+}
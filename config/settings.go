@@ -0,0 +1,266 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// setting связывает поле конфигурации с его ключом в YAML и флаге,
+// переменной окружения и способом разбора.
+type setting struct {
+	key    string // путь в YAML и имя флага, например bot.mode
+	env    string // переменная окружения; пусто — только файл и флаг
+	usage  string
+	secret bool // при печати значение скрывается
+	value  flag.Value
+}
+
+// settings перечисляет все настройки в порядке вывода -print-config.
+func (c *Config) settings() []setting {
+	limits := c.Bot.Limits.Roles
+	return []setting{
+		{"bot.token", "TELEGRAM_TOKEN", "Telegram bot token", true, (*stringValue)(&c.Bot.Token)},
+		{"bot.mode", "BOT_MODE", "update delivery: polling or webhook", false, (*stringValue)(&c.Bot.Mode)},
+		{"bot.webhook.listen_addr", "WEBHOOK_LISTEN_ADDR", "webhook listen address", false, (*stringValue)(&c.Bot.Webhook.ListenAddr)},
+		{"bot.webhook.path", "WEBHOOK_PATH", "webhook URL path", false, (*stringValue)(&c.Bot.Webhook.Path)},
+		{"bot.webhook.url", "WEBHOOK_URL", "public webhook URL", false, (*stringValue)(&c.Bot.Webhook.URL)},
+		{"bot.webhook.secret_token", "WEBHOOK_SECRET_TOKEN", "webhook secret token", true, (*stringValue)(&c.Bot.Webhook.SecretToken)},
+		{"bot.webhook.cert_file", "WEBHOOK_CERT_FILE", "self-signed TLS certificate", false, (*stringValue)(&c.Bot.Webhook.CertFile)},
+		{"bot.webhook.key_file", "WEBHOOK_KEY_FILE", "self-signed TLS key", false, (*stringValue)(&c.Bot.Webhook.KeyFile)},
+		{"bot.shutdown_timeout", "SHUTDOWN_TIMEOUT", "how long to wait for running checks on stop", false, (*durationValue)(&c.Bot.ShutdownTimeout)},
+		{"bot.max_image_size_mb", "MAX_IMAGE_SIZE_MB", "max size of an image sent as a file", false, (*intValue)(&c.Bot.MaxImageSizeMB)},
+		{"bot.album_window", "ALBUM_WINDOW", "how long to wait for the rest of an album", false, (*durationValue)(&c.Bot.AlbumWindow)},
+		{"bot.session.max_checks", "SESSION_MAX_CHECKS", "parts per reference, 0 = unlimited", false, (*intValue)(&c.Bot.Session.MaxChecks)},
+		{"bot.session.ttl", "SESSION_TTL", "reference lifetime, 0 = unlimited", false, (*durationValue)(&c.Bot.Session.TTL)},
+		{"bot.idle.reminder", "IDLE_REMINDER", "remind about an abandoned check after, 0 = never", false, (*durationValue)(&c.Bot.Idle.Reminder)},
+		{"bot.idle.timeout", "IDLE_TIMEOUT", "cancel an abandoned check after, 0 = never", false, (*durationValue)(&c.Bot.Idle.Timeout)},
+		{"bot.access.admin_ids", "ADMIN_IDS", "admin Telegram IDs, comma-separated", false, (*idsValue)(&c.Bot.Access.AdminIDs)},
+		{"bot.access.allowed_user_ids", "ALLOWED_USER_IDS", "allowed user IDs, comma-separated", false, (*idsValue)(&c.Bot.Access.AllowedUserIDs)},
+		{"bot.access.allowed_chat_ids", "ALLOWED_CHAT_IDS", "allowed group IDs, comma-separated", false, (*idsValue)(&c.Bot.Access.AllowedChatIDs)},
+		{"bot.access.default_role", "DEFAULT_ROLE", "role of allowlisted users", false, (*stringValue)(&c.Bot.Access.DefaultRole)},
+		{"bot.limits.operator", "RATE_LIMIT_OPERATOR", "operator photo rate, <count>/<duration> or 0", false, roleLimit(limits, "operator")},
+		{"bot.limits.inspector", "RATE_LIMIT_INSPECTOR", "inspector photo rate, <count>/<duration> or 0", false, roleLimit(limits, "inspector")},
+		{"bot.limits.admin", "RATE_LIMIT_ADMIN", "admin photo rate, <count>/<duration> or 0", false, roleLimit(limits, "admin")},
+		{"bot.limits.chat", "RATE_LIMIT_CHAT", "group chat photo rate, <count>/<duration> or 0", false, (*rateValue)(&c.Bot.Limits.Chat)},
+		{"bot.limits.max_concurrent", "MAX_CONCURRENT_CHECKS", "concurrent checks, 0 = unlimited", false, (*intValue)(&c.Bot.Limits.MaxConcurrent)},
+		{"storage.driver", "STORAGE_DRIVER", "storage driver: memory", false, (*stringValue)(&c.Storage.Driver)},
+		{"storage.dsn", "STORAGE_DSN", "storage connection string", true, (*stringValue)(&c.Storage.DSN)},
+		{"storage.retention", "STORAGE_RETENTION", "inspection history retention with images, 0 = forever", false, (*durationValue)(&c.Storage.Retention)},
+		{"vision.profile_dir", "VISION_PROFILE_DIR", "directory with detector profiles", false, (*stringValue)(&c.Vision.ProfileDir)},
+		{"vision.default_profile", "VISION_DEFAULT_PROFILE", "detector profile name", false, (*stringValue)(&c.Vision.DefaultProfile)},
+		{"vision.reload_interval", "VISION_RELOAD_INTERVAL", "how often to check the profile file for changes, 0 = on SIGHUP only", false, (*durationValue)(&c.Vision.ReloadInterval)},
+		{"vision.debug_dump", "VISION_DEBUG_DUMP", "directory for intermediate detector masks", false, (*stringValue)(&c.Vision.DebugDump)},
+		{"describer.provider", "DESCRIBER_PROVIDER", "defect describer: template, ollama or none", false, (*stringValue)(&c.Describer.Provider)},
+		{"describer.url", "OLLAMA_URL", "Ollama server URL", false, (*stringValue)(&c.Describer.URL)},
+		{"describer.model", "OLLAMA_MODEL", "Ollama model", false, (*stringValue)(&c.Describer.Model)},
+		{"describer.timeout", "DESCRIBER_TIMEOUT", "describer request timeout", false, (*durationValue)(&c.Describer.Timeout)},
+		{"observability.log_level", "LOG_LEVEL", "log level: debug, info, warn or error", false, (*lowerValue)(&c.Observability.LogLevel)},
+		{"observability.log_format", "LOG_FORMAT", "log format: text or json", false, (*lowerValue)(&c.Observability.LogFormat)},
+		{"observability.ops_addr", "OPS_ADDR", "ops server address, empty = disabled", false, (*stringValue)(&c.Observability.OpsAddr)},
+	}
+}
+
+// Print выводит итоговую конфигурацию; секреты заменяются на <redacted>.
+func (c *Config) Print(w io.Writer) {
+	for _, s := range c.settings() {
+		value := s.value.String()
+		if s.secret && value != "" {
+			value = "<redacted>"
+		}
+		fmt.Fprintf(w, "%s = %s\n", s.key, value)
+	}
+}
+
+// loadFile применяет YAML-файл. Вложенные разделы превращаются в ключи
+// через точку; неизвестный ключ — ошибка, чтобы опечатка не терялась.
+func loadFile(path string, settings []setting) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{fmt.Errorf("config file: %w", err)}
+	}
+	var tree map[string]any
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return []error{fmt.Errorf("config file %s: %w", path, err)}
+	}
+
+	values := make(map[string]string)
+	flatten("", tree, values)
+	byKey := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+
+	var errs []error
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s, ok := byKey[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("config file %s: unknown setting %q", path, key))
+			continue
+		}
+		if err := s.value.Set(values[key]); err != nil {
+			errs = append(errs, fmt.Errorf("config file %s: %s: %w", path, key, err))
+		}
+	}
+	return errs
+}
+
+func flatten(prefix string, node map[string]any, out map[string]string) {
+	for name, value := range node {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		switch v := value.(type) {
+		case map[string]any:
+			flatten(key, v, out)
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			out[key] = strings.Join(items, ",")
+		case nil:
+			out[key] = ""
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+type stringValue string
+
+func (v *stringValue) Set(raw string) error { *v = stringValue(strings.TrimSpace(raw)); return nil }
+func (v *stringValue) String() string       { return string(*v) }
+
+// lowerValue — строка без учёта регистра: LOG_LEVEL=DEBUG и debug равнозначны.
+type lowerValue string
+
+func (v *lowerValue) Set(raw string) error {
+	*v = lowerValue(strings.ToLower(strings.TrimSpace(raw)))
+	return nil
+}
+func (v *lowerValue) String() string { return string(*v) }
+
+type intValue int
+
+func (v *intValue) Set(raw string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("%q: expected integer", raw)
+	}
+	*v = intValue(n)
+	return nil
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+type durationValue time.Duration
+
+func (v *durationValue) Set(raw string) error {
+	d, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("%q: expected duration, e.g. 30s or 5m", raw)
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+// idsValue — список Telegram ID через запятую.
+type idsValue []int64
+
+func (v *idsValue) Set(raw string) error {
+	var ids []int64
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q: expected comma-separated Telegram IDs", raw)
+		}
+		ids = append(ids, id)
+	}
+	*v = ids
+	return nil
+}
+
+func (v *idsValue) String() string {
+	parts := make([]string, len(*v))
+	for i, id := range *v {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// rateValue — ограничение вида «20/1m» (20 фото в минуту); «0» снимает ограничение.
+type rateValue RateLimitSpec
+
+func (v *rateValue) Set(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if raw == "0" {
+		*v = rateValue{}
+		return nil
+	}
+
+	events, period, ok := strings.Cut(raw, "/")
+	if !ok {
+		return fmt.Errorf("%q: expected <count>/<duration>, e.g. 20/1m", raw)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(events))
+	if err != nil || count <= 0 {
+		return fmt.Errorf("%q: expected positive count", raw)
+	}
+	per, err := time.ParseDuration(strings.TrimSpace(period))
+	if err != nil || per <= 0 {
+		return fmt.Errorf("%q: expected positive duration", raw)
+	}
+	*v = rateValue{Events: count, Per: per}
+	return nil
+}
+
+func (v *rateValue) String() string {
+	if v.Events <= 0 || v.Per <= 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%d/%s", v.Events, v.Per)
+}
+
+// roleLimitValue — ограничение роли внутри карты Roles.
+type roleLimitValue struct {
+	limits map[string]RateLimitSpec
+	role   string
+}
+
+func roleLimit(limits map[string]RateLimitSpec, role string) *roleLimitValue {
+	return &roleLimitValue{limits: limits, role: role}
+}
+
+func (v *roleLimitValue) Set(raw string) error {
+	spec := rateValue(v.limits[v.role])
+	if err := spec.Set(raw); err != nil {
+		return err
+	}
+	v.limits[v.role] = RateLimitSpec(spec)
+	return nil
+}
+
+func (v *roleLimitValue) String() string {
+	spec := rateValue(v.limits[v.role])
+	return spec.String()
+}
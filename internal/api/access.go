@@ -0,0 +1,218 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	app "vision-bot/internal/application"
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/domain/port"
+	"vision-bot/internal/i18n"
+)
+
+// commandPermissions — права, которые нужны для команд сверх доступа к боту.
+var commandPermissions = map[string]entity.Permission{
+	cmdNewRef: entity.PermManageReference,
+	cmdGrant:  entity.PermManageUsers,
+	cmdRevoke: entity.PermManageUsers,
+	cmdStats:  entity.PermViewStats,
+	cmdZones:  entity.PermManageReference,
+
+	cmdCalibrate: entity.PermManageReference,
+}
+
+// callbackPermissions — права, которые нужны для кнопок сверх доступа к боту.
+// Кнопки без прав не показываются, но старые сообщения могут их содержать.
+var callbackPermissions = map[string]entity.Permission{
+	cbNewRef:        entity.PermManageReference,
+	cbFalsePositive: entity.PermMarkFalsePositive,
+	cbApprove:       entity.PermManageReference,
+	cbZones:         entity.PermManageReference,
+	cbZoneCell:      entity.PermManageReference,
+	cbZonesClear:    entity.PermManageReference,
+	cbZonesDone:     entity.PermManageReference,
+}
+
+type roleKey struct{}
+
+// withRole сохраняет в ctx роль автора обновления.
+func withRole(ctx context.Context, role entity.Role) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// roleFrom возвращает роль автора обновления. Вне обработки обновления
+// (например, в фоновых уведомлениях) роли нет.
+func roleFrom(ctx context.Context) (entity.Role, bool) {
+	role, ok := ctx.Value(roleKey{}).(entity.Role)
+	return role, ok
+}
+
+// authorize — middleware перед обработкой обновления: пропускает только
+// пользователей с правом запускать проверки и кладёт их роль в ctx.
+func (b *Bot) authorize(ctx context.Context, from *tgbotapi.User, key entity.DialogueKey, update tgbotapi.Update) (context.Context, bool) {
+	if from == nil {
+		if update.CallbackQuery != nil {
+			b.answerCallback(ctx, update.CallbackQuery.ID, "")
+		}
+		return ctx, false
+	}
+
+	role, err := b.container.AccessService.Role(ctx, key.UserID, key.ChatID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Resolve role error", "err", err)
+		return ctx, false
+	}
+	if role.Can(entity.PermRunCheck) {
+		return withRole(ctx, role), true
+	}
+
+	slog.InfoContext(ctx, "Access denied")
+	text := t(ctx, msgAccessDenied, i18n.Args{"id": strconv.FormatInt(key.UserID, 10)})
+	if update.CallbackQuery != nil {
+		b.answerCallback(ctx, update.CallbackQuery.ID, text)
+		return ctx, false
+	}
+	b.sendMessage(ctx, key, text)
+	return ctx, false
+}
+
+// can сообщает, есть ли у автора обновления право на действие.
+func can(ctx context.Context, perm entity.Permission) bool {
+	role, ok := roleFrom(ctx)
+	return !ok || role.Can(perm)
+}
+
+// canUploadReference проверяет, может ли автор обновления загрузить эталон.
+// /check и кнопка «Новая проверка» тоже принимают эталон, поэтому без права
+// управлять эталоном оператор проверяет детали только по уже загруженному.
+func (b *Bot) canUploadReference(ctx context.Context, key entity.DialogueKey) bool {
+	if can(ctx, entity.PermManageReference) {
+		return true
+	}
+	slog.InfoContext(ctx, "Reference upload denied")
+	b.sendKeyboard(ctx, key, t(ctx, msgReferenceDenied), mainMenuKeyboard(i18n.FromContext(ctx)))
+	return false
+}
+
+// visibleKeyboard убирает кнопки, на которые у автора обновления нет прав.
+func visibleKeyboard(ctx context.Context, keyboard port.Keyboard) port.Keyboard {
+	if _, ok := roleFrom(ctx); !ok {
+		return keyboard
+	}
+
+	visible := make(port.Keyboard, 0, len(keyboard))
+	for _, row := range keyboard {
+		var buttons []port.Button
+		for _, button := range row {
+			action, _ := parseCallbackData(button.Data)
+			if perm, ok := callbackPermissions[action]; ok && !can(ctx, perm) {
+				continue
+			}
+			buttons = append(buttons, button)
+		}
+		if len(buttons) > 0 {
+			visible = append(visible, buttons)
+		}
+	}
+	return visible
+}
+
+// handleGrant обрабатывает /grant <ID> <роль> и /revoke <ID>.
+func (b *Bot) handleGrant(ctx context.Context, key entity.DialogueKey, msg *tgbotapi.Message) {
+	args := strings.Fields(msg.CommandArguments())
+	revoke := msg.Command() == cmdRevoke
+	if (revoke && len(args) != 1) || (!revoke && len(args) != 2) {
+		b.sendMessage(ctx, key, t(ctx, msgGrantUsage))
+		return
+	}
+
+	targetID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		b.sendMessage(ctx, key, t(ctx, msgGrantUsage))
+		return
+	}
+
+	role := entity.RoleNone
+	if !revoke {
+		if role, err = entity.ParseRole(args[1]); err != nil {
+			b.sendMessage(ctx, key, t(ctx, msgGrantUsage))
+			return
+		}
+	}
+
+	access := b.container.AccessService
+	if _, err := access.Grant(ctx, key.UserID, key.ChatID, targetID, role); err != nil {
+		b.reportAccessError(ctx, key, "Grant", err)
+		return
+	}
+	slog.InfoContext(ctx, "Role changed", "target_id", targetID, "role", role)
+
+	id := strconv.FormatInt(targetID, 10)
+	if revoke {
+		// Пользователь может остаться допущенным по спискам из конфигурации.
+		effective, err := access.Role(ctx, targetID, targetID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Resolve role error", "target_id", targetID, "err", err)
+		}
+		b.sendMessage(ctx, key, t(ctx, msgRoleRevoked, i18n.Args{"id": id, "role": roleName(ctx, effective)}))
+		return
+	}
+	b.sendMessage(ctx, key, t(ctx, msgRoleGranted, i18n.Args{"id": id, "role": roleName(ctx, role)}))
+}
+
+// handleStats обрабатывает /stats.
+func (b *Bot) handleStats(ctx context.Context, key entity.DialogueKey, msg *tgbotapi.Message) {
+	stats, err := b.container.StatsService.Collect(ctx, key.UserID, key.ChatID)
+	if err != nil {
+		b.reportAccessError(ctx, key, "Stats", err)
+		return
+	}
+
+	maxConcurrent := "∞"
+	if limit := stats.RateLimits.MaxConcurrent; limit > 0 {
+		maxConcurrent = strconv.Itoa(limit)
+	}
+	b.sendMessage(ctx, key, t(ctx, msgStats, i18n.Args{
+		"users":           stats.Users,
+		"admins":          stats.Roles[entity.RoleAdmin],
+		"inspectors":      stats.Roles[entity.RoleInspector],
+		"operators":       stats.Roles[entity.RoleOperator],
+		"denied":          stats.Roles[entity.RoleNone],
+		"pending":         stats.PendingChecks,
+		"sessions":        stats.ActiveSessions,
+		"total":           stats.Inspections.Total,
+		"defective":       stats.Inspections.WithDefects,
+		"false_positives": stats.Inspections.FalsePositives,
+		"throttled_users": stats.RateLimits.ThrottledUsers,
+		"throttled_chats": stats.RateLimits.ThrottledChats,
+		"running":         stats.RateLimits.Running,
+		"queued":          stats.RateLimits.Queued,
+		"max_concurrent":  maxConcurrent,
+	}))
+}
+
+// reportAccessError сообщает об ошибке команды администратора.
+func (b *Bot) reportAccessError(ctx context.Context, key entity.DialogueKey, op string, err error) {
+	slog.ErrorContext(ctx, "Admin command failed", "op", op, "err", err)
+	switch {
+	case errors.Is(err, app.ErrAccessDenied):
+		b.sendMessage(ctx, key, t(ctx, msgPermissionDenied))
+	case errors.Is(err, app.ErrBootstrapAdmin):
+		b.sendMessage(ctx, key, t(ctx, msgRoleBootstrapAdmin))
+	default:
+		b.sendMessage(ctx, key, t(ctx, msgProcessingError))
+	}
+}
+
+// roleName возвращает название роли на языке пользователя.
+func roleName(ctx context.Context, role entity.Role) string {
+	if role == entity.RoleNone {
+		return t(ctx, "role_none")
+	}
+	return t(ctx, "role_"+string(role))
+}
@@ -0,0 +1,257 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	app "vision-bot/internal/application"
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/domain/port"
+	"vision-bot/internal/i18n"
+)
+
+// defaultAlbumWindow — сколько ждать следующее фото альбома, прежде чем считать его полным.
+const defaultAlbumWindow = 1500 * time.Millisecond
+
+// albumPurpose определяет, что делать с собранным альбомом.
+type albumPurpose int
+
+const (
+	// albumReference — альбом прислан вместо эталона. Инспектор собирает из
+	// него модель годных деталей, у остальных используется только первое фото.
+	albumReference albumPurpose = iota
+	// albumBatch — партия деталей, каждая сравнивается с эталоном.
+	albumBatch
+	// albumQuick — альбом прислан на проверку без эталона: проверяется только первое фото.
+	albumQuick
+)
+
+// pendingAlbum накапливает фото одной медиагруппы.
+type pendingAlbum struct {
+	key     entity.DialogueKey
+	purpose albumPurpose
+	photos  [][]byte
+	ctx     context.Context // язык, роль и сообщение для ответа после сбора альбома
+	timer   *time.Timer
+}
+
+// albumCollector собирает сообщения с общим MediaGroupID: Telegram присылает
+// каждое фото альбома отдельным обновлением.
+type albumCollector struct {
+	mu     sync.Mutex
+	window time.Duration
+	albums map[string]*pendingAlbum
+}
+
+func newAlbumCollector(window time.Duration) *albumCollector {
+	if window <= 0 {
+		window = defaultAlbumWindow
+	}
+	return &albumCollector{
+		window: window,
+		albums: make(map[string]*pendingAlbum),
+	}
+}
+
+// start регистрирует новый альбом; onComplete вызывается после паузы в window.
+func (c *albumCollector) start(groupID string, album *pendingAlbum, onComplete func(*pendingAlbum)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.albums[groupID] = album
+	album.timer = time.AfterFunc(c.window, func() {
+		c.mu.Lock()
+		delete(c.albums, groupID)
+		c.mu.Unlock()
+		onComplete(album)
+	})
+}
+
+// add добавляет фото в уже собираемый альбом и продлевает ожидание.
+func (c *albumCollector) add(groupID string, photo []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	album, ok := c.albums[groupID]
+	if !ok {
+		return false
+	}
+	// Если таймер уже сработал, альбом обрабатывается: новое фото опоздало.
+	if !album.timer.Stop() {
+		return false
+	}
+	album.photos = append(album.photos, photo)
+	album.timer.Reset(c.window)
+	return true
+}
+
+// has сообщает, собирается ли сейчас альбом с таким ID.
+func (c *albumCollector) has(groupID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.albums[groupID]
+	return ok
+}
+
+// handleAlbumPart добавляет очередное фото к собираемому альбому.
+// Каждое фото альбома расходует лимит частоты, как отдельное сообщение.
+func (b *Bot) handleAlbumPart(ctx context.Context, key entity.DialogueKey, msg *tgbotapi.Message) {
+	if b.throttled(ctx, key, msg) {
+		return
+	}
+	photoData, err := b.extractImage(ctx, msg)
+	if err != nil {
+		b.reportImageError(ctx, key, err)
+		return
+	}
+	if len(photoData) == 0 {
+		return
+	}
+	if !b.albums.add(msg.MediaGroupID, photoData) {
+		slog.WarnContext(ctx, "Album part arrived too late", "media_group_id", msg.MediaGroupID)
+	}
+}
+
+// startAlbum начинает сбор альбома. Пока альбом не обработан, бот считает его
+// фоновой задачей, чтобы остановка дождалась результата.
+func (b *Bot) startAlbum(groupID string, album *pendingAlbum) {
+	b.jobs.Add(1)
+	b.albums.start(groupID, album, func(completed *pendingAlbum) {
+		defer b.jobs.Done()
+		b.completeAlbum(completed.ctx, completed)
+	})
+}
+
+// completeAlbum обрабатывает собранный альбом в зависимости от его назначения.
+func (b *Bot) completeAlbum(ctx context.Context, album *pendingAlbum) {
+	switch album.purpose {
+	case albumReference:
+		if len(album.photos) > 1 {
+			b.buildReferenceModel(ctx, album.key, album.photos)
+		}
+	case albumBatch:
+		b.processBatch(ctx, album.key, album.photos)
+	case albumQuick:
+		if len(album.photos) > 1 {
+			b.sendMessage(ctx, album.key, t(ctx, msgAlbumQuickFirstOnly))
+		}
+	}
+}
+
+// buildReferenceModel собирает модель эталона из годных деталей альбома.
+// Если модель не строится, эталоном остаётся первое фото.
+func (b *Bot) buildReferenceModel(ctx context.Context, key entity.DialogueKey, photos [][]byte) {
+	service := b.container.InspectionService
+	if !service.SupportsGoldenModel() || !can(ctx, entity.PermManageReference) {
+		b.sendMessage(ctx, key, t(ctx, msgAlbumReferenceFirstOnly))
+		return
+	}
+
+	release, err := b.container.RateLimiter.Acquire(ctx, key.UserID)
+	if err != nil {
+		slog.WarnContext(ctx, "Inspection slot not acquired", "err", err)
+		b.sendMessage(ctx, key, t(ctx, msgAlbumReferenceFirstOnly))
+		return
+	}
+	defer release()
+
+	model, err := service.BuildReferenceModel(ctx, key, photos)
+	if err != nil {
+		slog.WarnContext(ctx, "BuildReferenceModel failed", "photos", len(photos), "reason", app.ClassifyInspectionError(err), "err", err)
+		b.sendMessage(ctx, key, t(ctx, msgGoldenModelFailed))
+		return
+	}
+	b.sendMessage(ctx, key, t(ctx, msgGoldenModelBuilt, i18n.Args{"count": model.Samples}))
+}
+
+// processBatch сравнивает все детали партии с эталоном и отправляет сводку.
+func (b *Bot) processBatch(ctx context.Context, key entity.DialogueKey, photos [][]byte) {
+	release, ok := b.acquireSlot(ctx, key)
+	if !ok {
+		return
+	}
+	defer release()
+
+	items, err := b.container.InspectionService.ProcessBatchDiff(ctx, key, photos, i18n.FromContext(ctx).Lang())
+	inSession := b.completeCheck(ctx, key)
+	if err != nil && len(items) == 0 {
+		slog.ErrorContext(ctx, "ProcessBatch failed", "reason", app.ClassifyInspectionError(err), "err", err)
+		b.sendMessage(ctx, key, t(ctx, msgProcessingError))
+		return
+	}
+
+	var failing []port.Photo
+	for _, item := range items {
+		// Детали сверх лимита сессии не проверялись: это не сбой.
+		if errors.Is(item.Err, app.ErrSessionLimit) {
+			continue
+		}
+		if !item.Succeeded() {
+			slog.ErrorContext(ctx, "ProcessBatch item failed",
+				"part", item.Index,
+				"reason", app.ClassifyInspectionError(item.Err),
+				"err", item.Err,
+			)
+			continue
+		}
+		if item.Output.Result.HasDefects && len(item.Output.Highlighted) > 0 {
+			failing = append(failing, port.Photo{
+				Data:    item.Output.Highlighted,
+				Caption: t(ctx, msgBatchPartCaption, i18n.Args{"index": item.Index}),
+			})
+		}
+	}
+
+	slog.InfoContext(ctx, "ProcessBatch completed",
+		"parts", len(photos),
+		"processed", len(items),
+		"failing", len(failing),
+	)
+
+	tr := i18n.FromContext(ctx)
+	b.sendKeyboard(ctx, key, formatBatchSummary(tr, items, len(photos)), batchKeyboard(tr, inSession))
+	if len(failing) > 0 {
+		if err := b.messenger.SendAlbum(ctx, recipient(ctx, key), failing); err != nil {
+			slog.ErrorContext(ctx, "Error sending album", "err", err)
+		}
+	}
+}
+
+// formatBatchSummary строит таблицу «деталь — вердикт — число дефектов».
+func formatBatchSummary(tr i18n.Translator, items []app.BatchItem, total int) string {
+	var sb strings.Builder
+	sb.WriteString(tr.T(msgBatchHeader, i18n.Args{"count": total}))
+	sb.WriteString("\n")
+
+	passed, defective, failed := 0, 0, 0
+	for _, item := range items {
+		switch {
+		case errors.Is(item.Err, app.ErrSessionLimit):
+			failed++
+			sb.WriteString("\n" + tr.T(msgBatchItemLimit, i18n.Args{"index": item.Index}))
+		case !item.Succeeded():
+			failed++
+			sb.WriteString("\n" + tr.T(msgBatchItemError, i18n.Args{"index": item.Index}))
+		case item.Output.Result.HasDefects:
+			defective++
+			sb.WriteString("\n" + tr.N(msgBatchItemDefects, len(item.Output.Result.Defects), i18n.Args{"index": item.Index}))
+		default:
+			passed++
+			sb.WriteString("\n" + tr.T(msgBatchItemOK, i18n.Args{"index": item.Index}))
+		}
+	}
+	if skipped := total - len(items); skipped > 0 {
+		failed += skipped
+	}
+
+	sb.WriteString("\n\n")
+	sb.WriteString(tr.T(msgBatchTotals, i18n.Args{"passed": passed, "defective": defective, "failed": failed}))
+	return sb.String()
+}
@@ -2,318 +2,557 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
+	"log/slog"
 	"strings"
+	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
+	app "vision-bot/internal/application"
 	"vision-bot/internal/container"
 	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/domain/port"
+	"vision-bot/internal/i18n"
+	"vision-bot/internal/logging"
 )
 
+// Режимы получения обновлений.
+const (
+	ModePolling = "polling"
+	ModeWebhook = "webhook"
+)
+
+// Options задаёт параметры работы бота.
+type Options struct {
+	// Mode — способ получения обновлений: ModePolling или ModeWebhook.
+	Mode string
+	// Webhook используется в режиме ModeWebhook.
+	Webhook WebhookOptions
+	// ShutdownTimeout ограничивает ожидание незавершённых проверок при остановке.
+	ShutdownTimeout time.Duration
+	// MaxImageSize ограничивает размер изображений, присланных файлом (в байтах).
+	MaxImageSize int64
+	// AlbumWindow — пауза, после которой альбом (медиагруппа) считается полным.
+	AlbumWindow time.Duration
+	// IdleSweepInterval — как часто искать брошенные проверки.
+	IdleSweepInterval time.Duration
+	// Catalog — каталог сообщений; по умолчанию встроенный i18n.MustDefault().
+	Catalog *i18n.Catalog
+	// Username — имя бота без @ для распознавания обращений в группах;
+	// по умолчанию берётся из getMe.
+	Username string
+}
+
+// defectLogSampler прореживает подробные строки о каждом дефекте.
+var defectLogSampler = logging.Sampler{First: 5, Thereafter: 10}
+
+// updateSource поставляет обновления Telegram в общий цикл обработки.
+type updateSource interface {
+	Start(ctx context.Context) (<-chan incomingUpdate, error)
+	Stop(ctx context.Context) error
+}
+
 // Bot представляет Telegram-бота и хранит доступ к сервисам приложения.
+// Входящие обновления приходят через api, исходящие сообщения уходят через messenger.
 type Bot struct {
 	api       *tgbotapi.BotAPI
+	messenger port.Messenger
 	container *container.Container
+	options   Options
+
+	// jobs отслеживает фоновые проверки, которые нужно дождаться при остановке.
+	jobs   sync.WaitGroup
+	jobCtx context.Context
+
+	albums   *albumCollector
+	catalog  *i18n.Catalog
+	username string
 }
 
 // NewBot создаёт нового бота и подключает контейнер сервисов.
-func NewBot(token string, container *container.Container) (*Bot, error) {
-	api, err := tgbotapi.NewBotAPI(token)
-	if err != nil {
-		return nil, err
+func NewBot(api *tgbotapi.BotAPI, messenger port.Messenger, container *container.Container, options Options) *Bot {
+	catalog := options.Catalog
+	if catalog == nil {
+		catalog = i18n.MustDefault()
+	}
+	username := options.Username
+	if username == "" && api != nil {
+		username = api.Self.UserName
 	}
-
-	log.Printf("Authorized on account %s", api.Self.UserName)
-
 	return &Bot{
 		api:       api,
+		messenger: messenger,
 		container: container,
-	}, nil
+		options:   options,
+		jobCtx:    context.Background(),
+		albums:    newAlbumCollector(options.AlbumWindow),
+		catalog:   catalog,
+		username:  username,
+	}
 }
 
 // Run запускает основной цикл обработки сообщений от Telegram.
-func (b *Bot) Run() error {
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+// Цикл завершается при отмене ctx: бот перестаёт получать обновления
+// и ждёт незавершённые проверки не дольше ShutdownTimeout.
+func (b *Bot) Run(ctx context.Context) error {
+	source, err := b.newUpdateSource()
+	if err != nil {
+		return err
+	}
 
-	updates := b.api.GetUpdatesChan(u)
+	updates, err := source.Start(ctx)
+	if err != nil {
+		return err
+	}
 
-	ctx := context.Background()
+	// Фоновые проверки не должны обрываться сразу по сигналу остановки,
+	// поэтому их контекст отменяется отдельно, после истечения таймаута.
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+	b.jobCtx = jobCtx
 
-	for update := range updates {
-		if update.Message == nil {
-			continue
+	if b.container.IdleService.Enabled() {
+		go b.runIdleSweeper(ctx)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return b.shutdown(source, cancelJobs)
+		case update, ok := <-updates:
+			if !ok {
+				return b.shutdown(source, cancelJobs)
+			}
+			b.handleUpdate(ctx, update)
 		}
+	}
+}
 
-		b.handleMessage(ctx, update.Message)
+// newUpdateSource выбирает транспорт обновлений по режиму работы.
+func (b *Bot) newUpdateSource() (updateSource, error) {
+	switch b.options.Mode {
+	case "", ModePolling:
+		return newPollingSource(b.api), nil
+	case ModeWebhook:
+		return newWebhookSource(b.api, b.options.Webhook), nil
+	default:
+		return nil, fmt.Errorf("unknown bot mode %q", b.options.Mode)
 	}
+}
 
-	return nil
+// handleUpdate — общая точка входа для обновлений из любого транспорта.
+func (b *Bot) handleUpdate(ctx context.Context, update incomingUpdate) {
+	from, key := updateAuthor(update)
+	if from != nil {
+		ctx = logging.With(ctx, "user_id", key.UserID, "chat_id", key.ChatID)
+		if key.ThreadID != 0 {
+			ctx = logging.With(ctx, "thread_id", key.ThreadID)
+		}
+	}
+	// В группах бот молчит в ответ на чужие разговоры, в том числе о доступе.
+	if from != nil && !b.addressed(ctx, key, update.Message) {
+		return
+	}
+
+	ctx = b.withLanguage(ctx, from)
+	ctx, ok := b.authorize(ctx, from, key, update.Update)
+	if !ok {
+		return
+	}
+
+	switch {
+	case update.Message != nil:
+		if isGroup(update.Message.Chat) {
+			ctx = withReplyTo(ctx, update.Message.MessageID)
+		}
+		b.handleMessage(ctx, key, update.Message)
+	case update.CallbackQuery != nil:
+		b.handleCallback(ctx, key, update.CallbackQuery)
+	}
+}
+
+// updateAuthor возвращает автора обновления и диалог, к которому оно относится:
+// чат, пользователя и тему форума.
+func updateAuthor(update incomingUpdate) (*tgbotapi.User, entity.DialogueKey) {
+	var from *tgbotapi.User
+	var chat *tgbotapi.Chat
+	switch {
+	case update.Message != nil:
+		from, chat = update.Message.From, update.Message.Chat
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil:
+		from, chat = update.CallbackQuery.From, update.CallbackQuery.Message.Chat
+	}
+	if from == nil || chat == nil {
+		return nil, entity.DialogueKey{}
+	}
+	return from, entity.DialogueKey{ChatID: chat.ID, UserID: from.ID, ThreadID: update.ThreadID}
+}
+
+// shutdown останавливает приём обновлений и дожидается фоновых проверок.
+func (b *Bot) shutdown(source updateSource, cancelJobs context.CancelFunc) error {
+	stopCtx, cancel := context.WithTimeout(context.Background(), b.options.ShutdownTimeout)
+	defer cancel()
+	if err := source.Stop(stopCtx); err != nil {
+		slog.Error("Error stopping update source", "err", err)
+	}
+
+	return b.drain(cancelJobs)
+}
+
+// drain ждёт завершения фоновых проверок и отменяет их по истечении таймаута.
+func (b *Bot) drain(cancelJobs context.CancelFunc) error {
+	slog.Info("Stopping bot, waiting for in-flight inspections")
+
+	done := make(chan struct{})
+	go func() {
+		b.jobs.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(b.options.ShutdownTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		cancelJobs()
+		return errors.New("shutdown timeout exceeded, in-flight inspections cancelled")
+	}
 }
 
 // handleMessage выбирает сценарий в зависимости от состояния пользователя.
-func (b *Bot) handleMessage(ctx context.Context, msg *tgbotapi.Message) {
-	user, err := b.container.UserService.Get(ctx, msg.From.ID, msg.Chat.ID)
+func (b *Bot) handleMessage(ctx context.Context, key entity.DialogueKey, msg *tgbotapi.Message) {
+	// Остальные фото альбома приходят уже после смены состояния пользователя.
+	if msg.MediaGroupID != "" && b.albums.has(msg.MediaGroupID) {
+		b.handleAlbumPart(ctx, key, msg)
+		return
+	}
+
+	// Команды управления эталоном и администрирования доступны на любом шаге.
+	if msg.IsCommand() {
+		if perm, ok := commandPermissions[msg.Command()]; ok && !can(ctx, perm) {
+			slog.InfoContext(ctx, "Command denied", "command", msg.Command())
+			b.sendMessage(ctx, key, t(ctx, msgPermissionDenied))
+			return
+		}
+
+		switch msg.Command() {
+		case cmdNewRef:
+			b.newReference(ctx, key)
+			return
+		case cmdDone:
+			b.endSession(ctx, key)
+			return
+		case cmdQuick:
+			b.beginQuick(ctx, key)
+			return
+		case cmdLang:
+			b.handleLanguageCommand(ctx, key, msg)
+			return
+		case cmdGrant, cmdRevoke:
+			b.handleGrant(ctx, key, msg)
+			return
+		case cmdStats:
+			b.handleStats(ctx, key, msg)
+			return
+		case cmdZones:
+			b.beginZones(ctx, key)
+			return
+		case cmdCalibrate:
+			b.handleCalibrate(ctx, key, msg)
+			return
+		}
+	}
+
+	// JSON с зонами эталона принимается на любом шаге, как и команды управления эталоном.
+	if isZonesDocument(msg.Document) && can(ctx, entity.PermManageReference) {
+		b.importZones(ctx, key, msg.Document)
+		return
+	}
+
+	user, err := b.container.UserService.Get(ctx, key)
 	if err != nil {
-		log.Printf("Error getting user: %v", err)
-		b.sendMessage(msg.Chat.ID, msgProcessingError)
+		slog.ErrorContext(ctx, "Error getting user", "err", err)
+		b.sendMessage(ctx, key, t(ctx, msgProcessingError))
 		return
 	}
 
 	switch user.State {
 	case entity.StateMainMenu:
-		b.handleMainMenu(ctx, msg)
+		b.handleMainMenu(ctx, key, msg)
 		return
 	case entity.StateAwaitingOriginalPhoto:
-		b.handleAwaitingOriginal(ctx, msg)
+		b.handleAwaitingOriginal(ctx, key, msg)
 		return
 	case entity.StateAwaitingDefectPhoto:
-		b.handleAwaitingDefect(ctx, msg)
+		b.handleAwaitingDefect(ctx, key, msg)
+		return
+	case entity.StateAwaitingQuickPhoto:
+		b.handleAwaitingQuick(ctx, key, msg)
+		return
+	case entity.StateProcessing:
+		b.sendMessage(ctx, key, t(ctx, msgStillProcessing))
 		return
 	default:
-		_, _ = b.container.UserService.Cancel(ctx, msg.From.ID, msg.Chat.ID)
-		b.sendMessage(msg.Chat.ID, msgStart)
+		// Неизвестное состояние возможно только после повреждения хранилища.
+		slog.WarnContext(ctx, "Unknown user state, resetting to main menu", "state", user.State)
+		if _, err := b.container.UserService.Reset(ctx, key); err != nil {
+			slog.ErrorContext(ctx, "Reset error", "err", err)
+		}
+		b.sendKeyboard(ctx, key, t(ctx, msgStart), mainMenuKeyboard(i18n.FromContext(ctx)))
 		return
 	}
 }
 
-// sendMessage отправляет текстовое сообщение в чат.
-func (b *Bot) sendMessage(chatID int64, text string) {
-	msg := tgbotapi.NewMessage(chatID, text)
-	if _, err := b.api.Send(msg); err != nil {
-		log.Printf("Error sending message: %v", err)
+// reportDialogueError сообщает пользователю, почему действие не выполнено.
+// Недопустимый переход — штатная ситуация (например, кнопка из старого сообщения).
+func (b *Bot) reportDialogueError(ctx context.Context, key entity.DialogueKey, op string, err error) {
+	var transitionErr *entity.TransitionError
+	if errors.As(err, &transitionErr) {
+		slog.InfoContext(ctx, "Dialogue action rejected", "op", op, "err", err)
+		if transitionErr.From == entity.StateProcessing {
+			b.sendMessage(ctx, key, t(ctx, msgStillProcessing))
+			return
+		}
+		b.sendMessage(ctx, key, t(ctx, msgActionUnavailable))
+		return
+	}
+
+	slog.ErrorContext(ctx, "Dialogue action failed", "op", op, "err", err)
+	b.sendMessage(ctx, key, t(ctx, msgProcessingError))
+}
+
+// completeCheck завершает обработку в диалоге и сообщает, продолжается ли сессия с эталоном.
+func (b *Bot) completeCheck(ctx context.Context, key entity.DialogueKey) bool {
+	user, err := b.container.InspectionService.CompleteCheck(ctx, key)
+	if err != nil {
+		slog.ErrorContext(ctx, "CompleteCheck error", "err", err)
+		return false
+	}
+	return user.State == entity.StateAwaitingDefectPhoto
+}
+
+// sendMessage отправляет текстовое сообщение в диалог.
+func (b *Bot) sendMessage(ctx context.Context, key entity.DialogueKey, text string) {
+	if _, err := b.messenger.SendText(ctx, recipient(ctx, key), text); err != nil {
+		slog.ErrorContext(ctx, "Error sending message", "err", err)
+	}
+}
+
+// sendKeyboard отправляет сообщение с inline-клавиатурой.
+func (b *Bot) sendKeyboard(ctx context.Context, key entity.DialogueKey, text string, keyboard port.Keyboard) {
+	if _, err := b.messenger.SendKeyboard(ctx, recipient(ctx, key), text, visibleKeyboard(ctx, keyboard)); err != nil {
+		slog.ErrorContext(ctx, "Error sending keyboard", "err", err)
 	}
 }
 
-// sendPhoto отправляет изображение в чат.
-func (b *Bot) sendPhoto(chatID int64, imageData []byte) {
-	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{
-		Name:  "result.jpg",
-		Bytes: imageData,
-	})
-	if _, err := b.api.Send(photo); err != nil {
-		log.Printf("Error sending photo: %v", err)
+// sendPhoto отправляет изображение в диалог.
+func (b *Bot) sendPhoto(ctx context.Context, key entity.DialogueKey, imageData []byte) {
+	if _, err := b.messenger.SendPhoto(ctx, recipient(ctx, key), imageData, ""); err != nil {
+		slog.ErrorContext(ctx, "Error sending photo", "err", err)
 	}
 }
 
 // handleMainMenu обрабатывает сообщения в состоянии главного меню.
-func (b *Bot) handleMainMenu(ctx context.Context, msg *tgbotapi.Message) {
+func (b *Bot) handleMainMenu(ctx context.Context, key entity.DialogueKey, msg *tgbotapi.Message) {
 	if msg.IsCommand() {
 		switch msg.Command() {
-		case cmdStart:
-			b.sendMessage(msg.Chat.ID, msgStart)
-			return
 		case cmdHelp:
-			b.sendMessage(msg.Chat.ID, msgHelp)
+			b.sendMessage(ctx, key, t(ctx, msgHelp))
 			return
 		case cmdCheck:
-			if _, err := b.container.UserService.BeginCheck(ctx, msg.From.ID, msg.Chat.ID); err != nil {
-				log.Printf("BeginCheck error: %v", err)
-				b.sendMessage(msg.Chat.ID, msgProcessingError)
-				return
-			}
-			b.sendMessage(msg.Chat.ID, msgAwaitingOriginal)
-			return
-		default:
-			b.sendMessage(msg.Chat.ID, msgStart)
+			b.beginCheck(ctx, key)
 			return
 		}
 	}
 
-	b.sendMessage(msg.Chat.ID, msgStart)
+	b.sendKeyboard(ctx, key, t(ctx, msgStart), mainMenuKeyboard(i18n.FromContext(ctx)))
 }
 
 // handleAwaitingOriginal обрабатывает сообщения при ожидании оригинального фото.
-func (b *Bot) handleAwaitingOriginal(ctx context.Context, msg *tgbotapi.Message) {
-	if msg.IsCommand() {
-		if msg.Command() == cmdCancel {
-			if _, err := b.container.UserService.Cancel(ctx, msg.From.ID, msg.Chat.ID); err != nil {
-				log.Printf("Cancel error: %v", err)
-				b.sendMessage(msg.Chat.ID, msgProcessingError)
-				return
-			}
-			b.sendMessage(msg.Chat.ID, msgCancelled)
+func (b *Bot) handleAwaitingOriginal(ctx context.Context, key entity.DialogueKey, msg *tgbotapi.Message) {
+	if msg.IsCommand() && msg.Command() == cmdCancel {
+		b.cancelCheck(ctx, key)
+		return
+	}
+	// Сюда попадают и после истечения сессии, поэтому право проверяется ещё раз.
+	if !can(ctx, entity.PermManageReference) {
+		if _, err := b.container.UserService.Cancel(ctx, key); err != nil {
+			b.reportDialogueError(ctx, key, "Cancel", err)
 			return
 		}
-		b.sendMessage(msg.Chat.ID, msgOnlyCancel)
+		b.canUploadReference(ctx, key)
+		return
+	}
+
+	if b.throttled(ctx, key, msg) {
 		return
 	}
+	ctx = logging.WithInspectionID(ctx, logging.NewInspectionID())
 
-	photoData, err := b.extractPhoto(msg)
+	photoData, err := b.extractImage(ctx, msg)
 	if err != nil {
-		log.Printf("Error downloading original photo: %v", err)
-		b.sendMessage(msg.Chat.ID, msgProcessingError)
+		b.reportImageError(ctx, key, err)
 		return
 	}
 	if len(photoData) == 0 {
-		b.sendMessage(msg.Chat.ID, msgAwaitingOriginal)
+		b.sendKeyboard(ctx, key, t(ctx, msgAwaitingOriginal), cancelKeyboard(i18n.FromContext(ctx)))
 		return
 	}
+	slog.InfoContext(ctx, "Reference photo received", "bytes", len(photoData), "media_group_id", msg.MediaGroupID)
 
-	if _, err := b.container.InspectionService.AcceptOriginalPhoto(ctx, msg.From.ID, msg.Chat.ID, photoData); err != nil {
-		log.Printf("AcceptOriginalPhoto error: %v", err)
-		b.sendMessage(msg.Chat.ID, msgProcessingError)
+	if _, err := b.container.InspectionService.AcceptOriginalPhoto(ctx, key, photoData); err != nil {
+		b.reportDialogueError(ctx, key, "AcceptOriginalPhoto", err)
 		return
 	}
+	if msg.MediaGroupID != "" {
+		b.startAlbum(msg.MediaGroupID, &pendingAlbum{
+			key:     key,
+			purpose: albumReference,
+			ctx:     detach(b.jobCtx, ctx),
+			photos:  [][]byte{photoData},
+		})
+	}
 
-	b.sendMessage(msg.Chat.ID, msgAwaitingDefect)
+	b.sendKeyboard(ctx, key, t(ctx, msgAwaitingDefect), cancelKeyboard(i18n.FromContext(ctx)))
 }
 
 // handleAwaitingDefect обрабатывает сообщения при ожидании фото дефекта.
-func (b *Bot) handleAwaitingDefect(ctx context.Context, msg *tgbotapi.Message) {
-	if msg.IsCommand() {
-		if msg.Command() == cmdCancel {
-			if _, err := b.container.UserService.Cancel(ctx, msg.From.ID, msg.Chat.ID); err != nil {
-				log.Printf("Cancel error: %v", err)
-				b.sendMessage(msg.Chat.ID, msgProcessingError)
-				return
-			}
-			b.sendMessage(msg.Chat.ID, msgCancelled)
-			return
-		}
-		b.sendMessage(msg.Chat.ID, msgOnlyCancel)
+func (b *Bot) handleAwaitingDefect(ctx context.Context, key entity.DialogueKey, msg *tgbotapi.Message) {
+	if msg.IsCommand() && msg.Command() == cmdCancel {
+		b.cancelCheck(ctx, key)
 		return
 	}
 
-	photoData, err := b.extractPhoto(msg)
+	if b.throttled(ctx, key, msg) {
+		return
+	}
+	ctx = logging.WithInspectionID(ctx, logging.NewInspectionID())
+
+	photoData, err := b.extractImage(ctx, msg)
 	if err != nil {
-		log.Printf("Error downloading defect photo: %v", err)
-		b.sendMessage(msg.Chat.ID, msgProcessingError)
+		b.reportImageError(ctx, key, err)
 		return
 	}
 	if len(photoData) == 0 {
-		b.sendMessage(msg.Chat.ID, msgAwaitingDefect)
+		b.sendKeyboard(ctx, key, t(ctx, msgAwaitingDefect), cancelKeyboard(i18n.FromContext(ctx)))
+		return
+	}
+	slog.InfoContext(ctx, "Part photo received", "bytes", len(photoData), "media_group_id", msg.MediaGroupID)
+
+	if _, err := b.container.InspectionService.AcceptDefectPhoto(ctx, key); err != nil {
+		if errors.Is(err, app.ErrSessionExpired) {
+			b.sendKeyboard(ctx, key, t(ctx, msgSessionExpired), cancelKeyboard(i18n.FromContext(ctx)))
+			return
+		}
+		b.reportDialogueError(ctx, key, "AcceptDefectPhoto", err)
 		return
 	}
 
-	if _, err := b.container.InspectionService.AcceptDefectPhoto(ctx, msg.From.ID, msg.Chat.ID, photoData); err != nil {
-		log.Printf("AcceptDefectPhoto error: %v", err)
-		b.sendMessage(msg.Chat.ID, msgProcessingError)
+	b.sendMessage(ctx, key, t(ctx, msgProcessing))
+	if msg.MediaGroupID != "" {
+		b.startAlbum(msg.MediaGroupID, &pendingAlbum{
+			key:     key,
+			purpose: albumBatch,
+			ctx:     detach(b.jobCtx, ctx),
+			photos:  [][]byte{photoData},
+		})
 		return
 	}
 
-	b.sendMessage(msg.Chat.ID, msgProcessing)
-	go b.processDefectPhoto(msg.From.ID, msg.Chat.ID, photoData)
+	jobCtx := detach(b.jobCtx, ctx)
+	b.jobs.Add(1)
+	go func() {
+		defer b.jobs.Done()
+		b.processDefectPhoto(jobCtx, key, photoData)
+	}()
 }
 
 // processDefectPhoto запускает детектор дефектов и отправляет результат.
-func (b *Bot) processDefectPhoto(userID int64, chatID int64, photo []byte) {
-	result, err := b.container.InspectionService.ProcessDefectPhotoDiff(context.Background(), userID, photo)
+func (b *Bot) processDefectPhoto(ctx context.Context, key entity.DialogueKey, photo []byte) {
+	release, ok := b.acquireSlot(ctx, key)
+	if !ok {
+		return
+	}
+	defer release()
+
+	result, err := b.container.InspectionService.ProcessDefectPhotoDiff(ctx, key, photo, i18n.FromContext(ctx).Lang())
+	inSession := b.completeCheck(ctx, key)
 	if err != nil {
-		log.Printf(
-			"ProcessDefectPhoto failed user_id=%d chat_id=%d reason=%s err=%v",
-			userID,
-			chatID,
-			classifyInspectionError(err),
-			err,
-		)
-		b.sendMessage(chatID, msgProcessingError)
+		slog.ErrorContext(ctx, "ProcessDefectPhoto failed", "reason", app.ClassifyInspectionError(err), "err", err)
+		if app.ClassifyInspectionError(err) == "decode" {
+			b.sendMessage(ctx, key, t(ctx, msgImageNotDecoded))
+			return
+		}
+		b.sendMessage(ctx, key, t(ctx, msgProcessingError))
 		return
 	}
 	if result == nil || result.Result == nil {
-		log.Printf(
-			"ProcessDefectPhoto failed user_id=%d chat_id=%d reason=empty_result",
-			userID,
-			chatID,
-		)
-		b.sendMessage(chatID, msgProcessingError)
+		slog.ErrorContext(ctx, "ProcessDefectPhoto failed", "reason", "empty_result")
+		b.sendMessage(ctx, key, t(ctx, msgProcessingError))
 		return
 	}
 
-	log.Printf(
-		"ProcessDefectPhoto completed user_id=%d chat_id=%d has_defects=%t defects=%d",
-		userID,
-		chatID,
-		result.Result.HasDefects,
-		len(result.Result.Defects),
+	slog.InfoContext(ctx, "ProcessDefectPhoto completed",
+		"record_id", result.RecordID,
+		"has_defects", result.Result.HasDefects,
+		"defects", len(result.Result.Defects),
 	)
 	for i, defect := range result.Result.Defects {
-		reason := defect.Reason
-		if reason == "" {
-			reason = "not_set"
+		if defectLogSampler.Keep(i) {
+			slog.DebugContext(ctx, "ProcessDefectPhoto defect", "idx", i, "defect", defect)
 		}
-		log.Printf(
-			"ProcessDefectPhoto defect user_id=%d chat_id=%d idx=%d bbox=(x=%d y=%d w=%d h=%d area=%d) reason=%s",
-			userID,
-			chatID,
-			i,
-			defect.X,
-			defect.Y,
-			defect.Width,
-			defect.Height,
-			defect.Area,
-			reason,
-		)
 	}
 
+	tr := i18n.FromContext(ctx)
+	approvable := inSession && b.container.InspectionService.SupportsGoldenModel()
+	keyboard := resultKeyboard(tr, result.RecordID, result.Result.HasDefects, inSession, approvable)
+	text := tr.T(msgNoDefects)
 	if result.Result.HasDefects {
-		b.sendMessage(chatID, msgDefectsFound)
-		if len(result.Highlighted) > 0 {
-			b.sendPhoto(chatID, result.Highlighted)
+		text = tr.N(msgDefectsFound, len(result.Result.Defects))
+		if result.Description != "" {
+			text += "\n" + result.Description
 		}
-		return
 	}
-
-	b.sendMessage(chatID, msgNoDefects)
-}
-
-// extractPhoto извлекает фото из сообщения и скачивает его.
-func (b *Bot) extractPhoto(msg *tgbotapi.Message) ([]byte, error) {
-	if msg.Photo == nil || len(msg.Photo) == 0 {
-		return nil, nil
+	// Обзор снимка с несколькими деталями нужен и без брака: по нему видно номера деталей.
+	if len(result.Highlighted) > 0 && (result.Result.HasDefects || len(result.Result.Parts) > 0) {
+		b.sendPhoto(ctx, key, result.Highlighted)
 	}
-
-	photo := msg.Photo[len(msg.Photo)-1]
-	return b.downloadFile(photo.FileID)
-}
-
-// downloadFile скачивает файл из Telegram по его ID.
-func (b *Bot) downloadFile(fileID string) ([]byte, error) {
-	file, err := b.api.GetFile(tgbotapi.FileConfig{FileID: fileID})
-	if err != nil {
-		return nil, fmt.Errorf("get file: %w", err)
+	if len(result.Result.Parts) > 0 {
+		text += "\n\n" + partsVerdict(tr, result.Result)
 	}
-
-	fileURL := file.Link(b.api.Token)
-
-	resp, err := http.Get(fileURL)
-	if err != nil {
-		return nil, fmt.Errorf("download file: %w", err)
+	if tolerated := len(result.Result.Tolerated); tolerated > 0 {
+		text += "\n" + tr.N(msgWithinTolerance, tolerated)
 	}
-	defer resp.Body.Close()
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read file: %w", err)
+	if inSession {
+		text += "\n\n" + tr.T(msgSessionNext)
 	}
 
-	return data, nil
+	b.sendKeyboard(ctx, key, text, keyboard)
 }
 
-func classifyInspectionError(err error) string {
-	if err == nil {
-		return "none"
-	}
-
-	msg := strings.ToLower(err.Error())
-	switch {
-	case strings.Contains(msg, "quality gate failed"):
-		return "quality_gate"
-	case strings.Contains(msg, "alignment failed"):
-		return "alignment"
-	case strings.Contains(msg, "failed to decode"):
-		return "decode"
-	case strings.Contains(msg, "original photo is not found"):
-		return "missing_original"
-	case strings.Contains(msg, "detector is not configured"):
-		return "detector_not_configured"
-	case strings.Contains(msg, "empty image"):
-		return "empty_image"
-	default:
-		return "unknown"
+// partsVerdict — итог по каждой детали снимка с несколькими деталями.
+func partsVerdict(tr i18n.Translator, result *entity.InspectionResult) string {
+	lines := make([]string, 0, len(result.Parts)+1)
+	defective := 0
+	for _, part := range result.Parts {
+		args := i18n.Args{"index": part.Index}
+		defects := len(result.PartDefects(part.Index))
+		switch {
+		case !part.Matched:
+			lines = append(lines, tr.T(msgPartMismatch, args))
+		case defects > 0:
+			lines = append(lines, tr.N(msgPartDefects, defects, args))
+		default:
+			lines = append(lines, tr.T(msgPartOK, args))
+			continue
+		}
+		defective++
 	}
+	summary := tr.N(msgPartsSummary, len(result.Parts), i18n.Args{"defective": defective})
+	return summary + "\n" + strings.Join(lines, "\n")
 }
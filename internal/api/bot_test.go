@@ -0,0 +1,1229 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/require"
+
+	"vision-bot/internal/container"
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/domain/port"
+	"vision-bot/internal/i18n"
+	"vision-bot/internal/infrastructure/ai"
+	"vision-bot/internal/infrastructure/messenger"
+	"vision-bot/internal/infrastructure/storage"
+)
+
+const (
+	testUserID int64 = 100500
+	testChatID int64 = 100500
+)
+
+// Тестовый пользователь не передаёт LanguageCode, поэтому бот отвечает на языке по умолчанию.
+var (
+	ru = i18n.MustDefault().Translator("ru")
+	en = i18n.MustDefault().Translator("en")
+)
+
+// fakeDetector возвращает заранее заданный результат сравнения.
+type fakeDetector struct {
+	result *entity.InspectionResult
+	err    error
+	zones  []entity.Zone // зоны последнего сравнения с эталоном
+}
+
+func (d *fakeDetector) Inspect(ctx context.Context, imageData []byte) (*entity.InspectionResult, error) {
+	return d.result, d.err
+}
+
+func (d *fakeDetector) InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	d.zones = zones
+	return d.result, d.err
+}
+
+func (d *fakeDetector) HighlightDefects(imageData []byte, result *entity.InspectionResult) ([]byte, error) {
+	return []byte("highlighted"), nil
+}
+
+// fakeGoldenDetector дополнительно строит модель эталона, считая образцы.
+type fakeGoldenDetector struct {
+	fakeDetector
+}
+
+func (d *fakeGoldenDetector) BuildGoldenModel(ctx context.Context, samples [][]byte) (*entity.GoldenModel, error) {
+	return &entity.GoldenModel{Samples: len(samples)}, nil
+}
+
+func (d *fakeGoldenDetector) ExtendGoldenModel(ctx context.Context, model *entity.GoldenModel, sample []byte) (*entity.GoldenModel, error) {
+	return &entity.GoldenModel{Samples: model.Samples + 1}, nil
+}
+
+func (d *fakeGoldenDetector) InspectGolden(ctx context.Context, model *entity.GoldenModel, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	return d.result, d.err
+}
+
+type botHarness struct {
+	bot       *Bot
+	messenger *messenger.FakeMessenger
+	container *container.Container
+}
+
+// newBotHarness собирает бота, у которого сессия с эталоном заканчивается после одной проверки.
+func newBotHarness(t *testing.T, detector *fakeDetector) *botHarness {
+	t.Helper()
+	return newSessionHarness(t, detector, entity.SessionPolicy{MaxChecks: 1})
+}
+
+func newSessionHarness(t *testing.T, detector port.DefectDetector, policy entity.SessionPolicy) *botHarness {
+	t.Helper()
+
+	fake := messenger.NewFakeMessenger()
+	c := container.New(storage.NewMemoryUserRepository(), storage.NewMemoryInspectionRepository(), detector, nil, policy, entity.IdlePolicy{}, entity.AccessPolicy{}, entity.RateLimitPolicy{})
+	bot := NewBot(nil, fake, c, Options{ShutdownTimeout: time.Second, AlbumWindow: 20 * time.Millisecond})
+
+	return &botHarness{bot: bot, messenger: fake, container: c}
+}
+
+// send передаёт боту сообщение и дожидается фоновых проверок.
+func (h *botHarness) send(msg *tgbotapi.Message) {
+	h.bot.handleUpdate(context.Background(), incomingUpdate{Update: tgbotapi.Update{Message: msg}})
+	h.bot.jobs.Wait()
+}
+
+func (h *botHarness) command(name string) {
+	text := "/" + name
+	h.send(&tgbotapi.Message{
+		From:     &tgbotapi.User{ID: testUserID},
+		Chat:     &tgbotapi.Chat{ID: testChatID},
+		Text:     text,
+		Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(text)}},
+	})
+}
+
+func (h *botHarness) text(text string) {
+	h.send(&tgbotapi.Message{
+		From: &tgbotapi.User{ID: testUserID},
+		Chat: &tgbotapi.Chat{ID: testChatID},
+		Text: text,
+	})
+}
+
+func (h *botHarness) photo(fileID string) {
+	h.send(&tgbotapi.Message{
+		From: &tgbotapi.User{ID: testUserID},
+		Chat: &tgbotapi.Chat{ID: testChatID},
+		Photo: []tgbotapi.PhotoSize{
+			{FileID: fileID + "-thumb", Width: 90, Height: 68},
+			{FileID: fileID, Width: 1280, Height: 960},
+		},
+	})
+}
+
+// album присылает фото одной медиагруппы подряд, как это делает Telegram.
+func (h *botHarness) album(groupID string, fileIDs ...string) {
+	for _, fileID := range fileIDs {
+		h.bot.handleUpdate(context.Background(), incomingUpdate{Update: tgbotapi.Update{Message: &tgbotapi.Message{
+			From:         &tgbotapi.User{ID: testUserID},
+			Chat:         &tgbotapi.Chat{ID: testChatID},
+			MediaGroupID: groupID,
+			Photo:        []tgbotapi.PhotoSize{{FileID: fileID, Width: 1280, Height: 960}},
+		}}})
+	}
+	h.bot.jobs.Wait()
+}
+
+func (h *botHarness) document(fileID, mimeType string, size int) {
+	h.send(&tgbotapi.Message{
+		From: &tgbotapi.User{ID: testUserID},
+		Chat: &tgbotapi.Chat{ID: testChatID},
+		Document: &tgbotapi.Document{
+			FileID:   fileID,
+			FileName: fileID,
+			MimeType: mimeType,
+			FileSize: size,
+		},
+	})
+}
+
+// press имитирует нажатие inline-кнопки под последним сообщением.
+func (h *botHarness) press(data string) {
+	h.bot.handleUpdate(context.Background(), incomingUpdate{Update: tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "cb-" + data,
+		From:    &tgbotapi.User{ID: testUserID},
+		Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: testChatID}},
+		Data:    data,
+	}}})
+	h.bot.jobs.Wait()
+}
+
+// lastKeyboard возвращает клавиатуру последнего сообщения с кнопками.
+func (h *botHarness) lastKeyboard(t *testing.T) port.Keyboard {
+	t.Helper()
+	sent := h.messenger.Sent()
+	for i := len(sent) - 1; i >= 0; i-- {
+		if sent[i].Kind == messenger.KindKeyboard {
+			return sent[i].Keyboard
+		}
+	}
+	t.Fatal("no keyboard was sent")
+	return nil
+}
+
+func (h *botHarness) state(t *testing.T) entity.UserState {
+	t.Helper()
+	user, err := h.container.UserService.Get(context.Background(), entity.DialogueKey{ChatID: testChatID, UserID: testUserID})
+	require.NoError(t, err)
+	return user.State
+}
+
+func TestBot_CheckDialogueWithDefects(t *testing.T) {
+	h := newBotHarness(t, &fakeDetector{result: &entity.InspectionResult{
+		Defects:    []entity.DefectArea{{X: 1, Y: 2, Width: 3, Height: 4, Area: 12}},
+		HasDefects: true,
+	}})
+	h.messenger.AddFile("original", []byte("original-bytes"))
+	h.messenger.AddFile("current", []byte("current-bytes"))
+
+	h.command(cmdCheck)
+	require.Equal(t, entity.StateAwaitingOriginalPhoto, h.state(t))
+
+	h.photo("original")
+	require.Equal(t, entity.StateAwaitingDefectPhoto, h.state(t))
+
+	h.photo("current")
+	require.Equal(t, entity.StateMainMenu, h.state(t))
+
+	require.Equal(t, []string{
+		ru.T(msgAwaitingOriginal),
+		ru.T(msgAwaitingDefect),
+		ru.T(msgProcessing),
+		ru.N(msgDefectsFound, 1),
+	}, h.messenger.Texts(testChatID))
+
+	sent := h.messenger.Sent()
+	photo, result := sent[len(sent)-2], sent[len(sent)-1]
+	require.Equal(t, messenger.KindPhoto, photo.Kind)
+	require.Equal(t, []byte("highlighted"), photo.Photos[0].Data)
+	require.Equal(t, messenger.KindKeyboard, result.Kind)
+	require.Len(t, result.Keyboard, 3)
+}
+
+func TestBot_CheckDialogueWithoutDefects(t *testing.T) {
+	h := newBotHarness(t, &fakeDetector{result: &entity.InspectionResult{}})
+	h.messenger.AddFile("original", []byte("original-bytes"))
+	h.messenger.AddFile("current", []byte("current-bytes"))
+
+	h.command(cmdCheck)
+	h.photo("original")
+	h.photo("current")
+
+	texts := h.messenger.Texts(testChatID)
+	require.Equal(t, ru.T(msgNoDefects), texts[len(texts)-1])
+	for _, msg := range h.messenger.Sent() {
+		require.NotEqual(t, messenger.KindPhoto, msg.Kind)
+	}
+}
+
+func TestBot_CancelWhileAwaitingPhotos(t *testing.T) {
+	h := newBotHarness(t, &fakeDetector{result: &entity.InspectionResult{}})
+	h.messenger.AddFile("original", []byte("original-bytes"))
+
+	h.command(cmdCheck)
+	h.command(cmdCancel)
+	require.Equal(t, entity.StateMainMenu, h.state(t))
+
+	h.command(cmdCheck)
+	h.photo("original")
+	h.command(cmdCancel)
+	require.Equal(t, entity.StateMainMenu, h.state(t))
+
+	require.Equal(t, []string{
+		ru.T(msgAwaitingOriginal),
+		ru.T(msgCancelled),
+		ru.T(msgAwaitingOriginal),
+		ru.T(msgAwaitingDefect),
+		ru.T(msgCancelled),
+	}, h.messenger.Texts(testChatID))
+}
+
+func TestBot_PromptsAgainOnTextInsteadOfPhoto(t *testing.T) {
+	h := newBotHarness(t, &fakeDetector{result: &entity.InspectionResult{}})
+
+	h.command(cmdCheck)
+	h.text("вот деталь")
+	h.command(cmdHelp)
+
+	require.Equal(t, entity.StateAwaitingOriginalPhoto, h.state(t))
+	require.Equal(t, []string{
+		ru.T(msgAwaitingOriginal),
+		ru.T(msgAwaitingOriginal),
+		ru.T(msgAwaitingOriginal),
+	}, h.messenger.Texts(testChatID))
+}
+
+func TestBot_DetectorErrorReportsProcessingError(t *testing.T) {
+	h := newBotHarness(t, &fakeDetector{err: errors.New("quality gate failed for current image: image is blurry")})
+	h.messenger.AddFile("original", []byte("original-bytes"))
+	h.messenger.AddFile("current", []byte("current-bytes"))
+
+	h.command(cmdCheck)
+	h.photo("original")
+	h.photo("current")
+
+	texts := h.messenger.Texts(testChatID)
+	require.Equal(t, ru.T(msgProcessingError), texts[len(texts)-1])
+}
+
+func TestBot_DownloadErrorKeepsState(t *testing.T) {
+	h := newBotHarness(t, &fakeDetector{result: &entity.InspectionResult{}})
+
+	h.command(cmdCheck)
+	h.photo("missing")
+
+	require.Equal(t, entity.StateAwaitingOriginalPhoto, h.state(t))
+	texts := h.messenger.Texts(testChatID)
+	require.Equal(t, ru.T(msgProcessingError), texts[len(texts)-1])
+}
+
+func TestBot_AcceptsImageDocuments(t *testing.T) {
+	h := newBotHarness(t, &fakeDetector{result: &entity.InspectionResult{}})
+	h.messenger.AddFile("original.png", []byte("png-bytes"))
+	h.messenger.AddFile("current.tiff", []byte("tiff-bytes"))
+
+	h.command(cmdCheck)
+	h.document("original.png", "image/png", 9)
+	require.Equal(t, entity.StateAwaitingDefectPhoto, h.state(t))
+
+	h.document("current.tiff", "image/tiff", 10)
+	texts := h.messenger.Texts(testChatID)
+	require.Equal(t, ru.T(msgNoDefects), texts[len(texts)-1])
+}
+
+func TestBot_RejectsUnsupportedOrLargeDocuments(t *testing.T) {
+	h := newBotHarness(t, &fakeDetector{result: &entity.InspectionResult{}})
+	h.bot.options.MaxImageSize = 1 << 20
+	h.messenger.AddFile("report.pdf", []byte("pdf"))
+	h.messenger.AddFile("huge.png", []byte("png"))
+
+	h.command(cmdCheck)
+	h.document("report.pdf", "application/pdf", 3)
+	h.document("huge.png", "image/png", 2<<20)
+
+	require.Equal(t, entity.StateAwaitingOriginalPhoto, h.state(t))
+	require.Equal(t, []string{
+		ru.T(msgAwaitingOriginal),
+		ru.T(msgUnsupportedDocument),
+		ru.T(msgImageTooLarge, i18n.Args{"size": 1}),
+	}, h.messenger.Texts(testChatID))
+}
+
+// sequenceDetector возвращает результаты по очереди — по одному на каждую деталь.
+type sequenceDetector struct {
+	fakeDetector
+	mu      sync.Mutex
+	results []*entity.InspectionResult
+	errs    []error
+}
+
+func (d *sequenceDetector) InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result, err := d.results[0], d.errs[0]
+	d.results, d.errs = d.results[1:], d.errs[1:]
+	return result, err
+}
+
+func TestBot_AlbumBatchAgainstOneReference(t *testing.T) {
+	detector := &sequenceDetector{
+		results: []*entity.InspectionResult{
+			{},
+			{Defects: make([]entity.DefectArea, 2), HasDefects: true},
+			nil,
+		},
+		errs: []error{nil, nil, errors.New("alignment failed: no overlap after transform")},
+	}
+	fake := messenger.NewFakeMessenger()
+	c := container.New(storage.NewMemoryUserRepository(), storage.NewMemoryInspectionRepository(), detector, nil, entity.SessionPolicy{}, entity.IdlePolicy{}, entity.AccessPolicy{}, entity.RateLimitPolicy{})
+	h := &botHarness{
+		bot:       NewBot(nil, fake, c, Options{ShutdownTimeout: time.Second, AlbumWindow: 20 * time.Millisecond}),
+		messenger: fake,
+		container: c,
+	}
+	for _, id := range []string{"original", "part-1", "part-2", "part-3"} {
+		fake.AddFile(id, []byte(id))
+	}
+
+	h.command(cmdCheck)
+	h.photo("original")
+	h.album("tray-1", "part-1", "part-2", "part-3")
+
+	texts := fake.Texts(testChatID)
+	summary := texts[len(texts)-1]
+	require.Contains(t, summary, ru.T(msgBatchHeader, i18n.Args{"count": 3}))
+	require.Contains(t, summary, ru.T(msgBatchItemOK, i18n.Args{"index": 1}))
+	require.Contains(t, summary, ru.N(msgBatchItemDefects, 2, i18n.Args{"index": 2}))
+	require.Contains(t, summary, ru.T(msgBatchItemError, i18n.Args{"index": 3}))
+	require.Contains(t, summary, ru.T(msgBatchTotals, i18n.Args{"passed": 1, "defective": 1, "failed": 1}))
+
+	// Подсвеченные изображения отправляются только для деталей с дефектами.
+	sent := fake.Sent()
+	last := sent[len(sent)-1]
+	require.Equal(t, messenger.KindAlbum, last.Kind)
+	require.Len(t, last.Photos, 1)
+	require.Equal(t, ru.T(msgBatchPartCaption, i18n.Args{"index": 2}), last.Photos[0].Caption)
+}
+
+func TestBot_AlbumBatchCountsAgainstSessionLimit(t *testing.T) {
+	h := newSessionHarness(t, &fakeDetector{result: &entity.InspectionResult{}}, entity.SessionPolicy{MaxChecks: 3})
+	for _, id := range []string{"original", "part-1", "part-2", "part-3"} {
+		h.messenger.AddFile(id, []byte(id))
+	}
+
+	h.command(cmdCheck)
+	h.photo("original")
+	h.photo("part-1")
+	// Каждая деталь альбома — отдельная проверка: в лимит из трёх помещаются две.
+	h.album("tray-1", "part-2", "part-3", "part-1")
+
+	texts := h.messenger.Texts(testChatID)
+	summary := texts[len(texts)-1]
+	require.Contains(t, summary, ru.T(msgBatchItemOK, i18n.Args{"index": 2}))
+	require.Contains(t, summary, ru.T(msgBatchItemLimit, i18n.Args{"index": 3}))
+	require.Contains(t, summary, ru.T(msgBatchTotals, i18n.Args{"passed": 2, "defective": 0, "failed": 1}))
+	require.Nil(t, h.container.InspectionService.ActiveSession(entity.DialogueKey{ChatID: testChatID, UserID: testUserID}))
+	require.Equal(t, entity.StateMainMenu, h.state(t))
+}
+
+func TestBot_AlbumAsReferenceUsesFirstPhoto(t *testing.T) {
+	h := newBotHarness(t, &fakeDetector{result: &entity.InspectionResult{}})
+	h.messenger.AddFile("ref-1", []byte("ref-1"))
+	h.messenger.AddFile("ref-2", []byte("ref-2"))
+
+	h.command(cmdCheck)
+	h.album("refs", "ref-1", "ref-2")
+
+	require.Equal(t, entity.StateAwaitingDefectPhoto, h.state(t))
+	require.Equal(t, []string{
+		ru.T(msgAwaitingOriginal),
+		ru.T(msgAwaitingDefect),
+		ru.T(msgAlbumReferenceFirstOnly),
+	}, h.messenger.Texts(testChatID))
+}
+
+func TestBot_AlbumAsReferenceBuildsGoldenModel(t *testing.T) {
+	h := newSessionHarness(t, &fakeGoldenDetector{fakeDetector{result: &entity.InspectionResult{}}}, entity.SessionPolicy{})
+	for _, id := range []string{"ref-1", "ref-2", "ref-3", "part"} {
+		h.messenger.AddFile(id, []byte(id))
+	}
+
+	h.command(cmdCheck)
+	h.album("refs", "ref-1", "ref-2", "ref-3")
+	texts := h.messenger.Texts(testChatID)
+	require.Equal(t, ru.T(msgGoldenModelBuilt, i18n.Args{"count": 3}), texts[len(texts)-1])
+
+	// Годную деталь можно добавить в модель кнопкой под результатом.
+	h.photo("part")
+	keyboard := h.lastKeyboard(t)
+	approve := keyboard[0][0].Data
+	action, _ := parseCallbackData(approve)
+	require.Equal(t, cbApprove, action)
+
+	// Нажатие подтверждается сразу, итог приходит сообщением после фоновой сборки модели.
+	h.press(approve)
+	callbacks := h.messenger.Callbacks()
+	require.Equal(t, ru.T(msgApproving), callbacks[len(callbacks)-1].Text)
+	texts = h.messenger.Texts(testChatID)
+	require.Equal(t, ru.T(msgApproved, i18n.Args{"count": 4}), texts[len(texts)-1])
+
+	h.press(cbHistory)
+	texts = h.messenger.Texts(testChatID)
+	require.Contains(t, texts[len(texts)-1], ru.T(msgHistoryApproved))
+}
+
+// slowGoldenDetector не отдаёт расширенную модель, пока тест её не отпустит.
+type slowGoldenDetector struct {
+	fakeGoldenDetector
+	release chan struct{}
+}
+
+func (d *slowGoldenDetector) ExtendGoldenModel(ctx context.Context, model *entity.GoldenModel, sample []byte) (*entity.GoldenModel, error) {
+	<-d.release
+	return d.fakeGoldenDetector.ExtendGoldenModel(ctx, model, sample)
+}
+
+func TestBot_ApproveDoesNotBlockUpdates(t *testing.T) {
+	detector := &slowGoldenDetector{fakeGoldenDetector{fakeDetector{result: &entity.InspectionResult{}}}, make(chan struct{})}
+	h := newSessionHarness(t, detector, entity.SessionPolicy{})
+	for _, id := range []string{"ref-1", "ref-2", "part"} {
+		h.messenger.AddFile(id, []byte(id))
+	}
+	h.command(cmdCheck)
+	h.album("refs", "ref-1", "ref-2")
+	h.photo("part")
+	approve := h.lastKeyboard(t)[0][0].Data
+
+	// Пока модель собирается, нажатие уже подтверждено и бот отвечает на другие апдейты.
+	h.bot.handleUpdate(context.Background(), incomingUpdate{Update: tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "cb-approve",
+		From:    &tgbotapi.User{ID: testUserID},
+		Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: testChatID}},
+		Data:    approve,
+	}}})
+	callbacks := h.messenger.Callbacks()
+	require.Equal(t, ru.T(msgApproving), callbacks[len(callbacks)-1].Text)
+	h.bot.handleUpdate(context.Background(), incomingUpdate{Update: tgbotapi.Update{Message: &tgbotapi.Message{
+		From:     &tgbotapi.User{ID: testUserID},
+		Chat:     &tgbotapi.Chat{ID: testChatID},
+		Text:     "/help",
+		Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 5}},
+	}}})
+
+	close(detector.release)
+	h.bot.jobs.Wait()
+	texts := h.messenger.Texts(testChatID)
+	require.Equal(t, ru.T(msgApproved, i18n.Args{"count": 3}), texts[len(texts)-1])
+}
+
+func TestBot_ButtonDrivenDialogue(t *testing.T) {
+	h := newBotHarness(t, &fakeDetector{result: &entity.InspectionResult{
+		Defects:    []entity.DefectArea{{X: 1, Y: 2, Width: 3, Height: 4, Area: 12}},
+		HasDefects: true,
+	}})
+	h.messenger.AddFile("original", []byte("original-bytes"))
+	h.messenger.AddFile("current", []byte("current-bytes"))
+
+	h.text("привет")
+	require.Equal(t, mainMenuKeyboard(ru), h.lastKeyboard(t))
+
+	h.press(cbNewCheck)
+	require.Equal(t, entity.StateAwaitingOriginalPhoto, h.state(t))
+	require.Equal(t, cancelKeyboard(ru), h.lastKeyboard(t))
+
+	h.photo("original")
+	h.photo("current")
+	require.Equal(t, entity.StateMainMenu, h.state(t))
+
+	// Под результатом — кнопки «ещё деталь», «ложное срабатывание» и «сравнение».
+	keyboard := h.lastKeyboard(t)
+	require.Len(t, keyboard, 3)
+	fp := keyboard[1][0].Data
+	cmp := keyboard[2][0].Data
+
+	h.press(fp)
+	h.press(cmp)
+	callbacks := h.messenger.Callbacks()
+	require.Equal(t, ru.T(msgMarkedFalsePositive), callbacks[len(callbacks)-2].Text)
+
+	sent := h.messenger.Sent()
+	album := sent[len(sent)-2]
+	require.Equal(t, messenger.KindAlbum, album.Kind)
+	require.Equal(t, []byte("original-bytes"), album.Photos[0].Data)
+	require.Equal(t, []byte("highlighted"), album.Photos[1].Data)
+
+	h.press(cbReuse)
+	require.Equal(t, entity.StateAwaitingDefectPhoto, h.state(t))
+
+	h.press(cbCancel)
+	require.Equal(t, entity.StateMainMenu, h.state(t))
+
+	h.press(cbHistory)
+	texts := h.messenger.Texts(testChatID)
+	require.Contains(t, texts[len(texts)-1], ru.T(msgHistoryFalsePositive))
+	require.Len(t, h.messenger.Callbacks(), 6)
+}
+
+func TestBot_ReuseWithoutReference(t *testing.T) {
+	h := newBotHarness(t, &fakeDetector{result: &entity.InspectionResult{}})
+
+	h.press(cbReuse)
+	h.press(callbackData(cbCompare, "unknown"))
+
+	require.Equal(t, entity.StateMainMenu, h.state(t))
+	require.Equal(t, []string{ru.T(msgNoReference)}, h.messenger.Texts(testChatID))
+	callbacks := h.messenger.Callbacks()
+	require.Equal(t, ru.T(msgRecordNotFound), callbacks[len(callbacks)-1].Text)
+}
+
+func TestBot_QuickCheckWithoutReference(t *testing.T) {
+	h := newBotHarness(t, &fakeDetector{result: &entity.InspectionResult{
+		Defects:    []entity.DefectArea{{X: 1, Y: 2, Width: 3, Height: 4, Area: 12}},
+		HasDefects: true,
+	}})
+	h.messenger.AddFile("part", []byte("part-bytes"))
+
+	h.command(cmdQuick)
+	require.Equal(t, entity.StateAwaitingQuickPhoto, h.state(t))
+	require.Equal(t, cancelKeyboard(ru), h.lastKeyboard(t))
+
+	h.photo("part")
+	require.Equal(t, entity.StateMainMenu, h.state(t))
+	texts := h.messenger.Texts(testChatID)
+	require.Equal(t, ru.N(msgDefectsFound, 1)+"\n\n"+ru.T(msgQuickNote), texts[len(texts)-1])
+
+	// Под результатом — «ещё проверка», «ложное срабатывание» и переход к проверке с эталоном.
+	keyboard := h.lastKeyboard(t)
+	require.Len(t, keyboard, 3)
+	require.Equal(t, cbQuick, keyboard[0][0].Data)
+	action, _ := parseCallbackData(keyboard[1][0].Data)
+	require.Equal(t, cbFalsePositive, action)
+
+	// Эталона у записи нет, поэтому «ещё деталь» его не находит.
+	h.press(cbReuse)
+	texts = h.messenger.Texts(testChatID)
+	require.Equal(t, ru.T(msgNoReference), texts[len(texts)-1])
+
+	h.press(cbHistory)
+	texts = h.messenger.Texts(testChatID)
+	require.Contains(t, texts[len(texts)-1], ru.T(msgHistoryQuick))
+}
+
+func TestBot_ZonesGridAndJSONImport(t *testing.T) {
+	detector := &fakeDetector{result: &entity.InspectionResult{}}
+	h := newSessionHarness(t, detector, entity.SessionPolicy{})
+	h.messenger.AddFile("original", pngBytes(t))
+	h.messenger.AddFile("part", []byte("part-bytes"))
+
+	// Без эталона размечать нечего.
+	h.command(cmdZones)
+	texts := h.messenger.Texts(testChatID)
+	require.Equal(t, ru.T(msgNoReference), texts[len(texts)-1])
+
+	h.command(cmdCheck)
+	h.photo("original")
+	h.command(cmdZones)
+	require.Equal(t, zonesKeyboard(ru, nil), h.lastKeyboard(t))
+
+	// Два нажатия на ячейку: «не проверять», затем «строже». Клавиатура меняется на месте.
+	h.press(callbackData(cbZoneCell, "B3"))
+	h.press(callbackData(cbZoneCell, "B3"))
+	sent := h.messenger.Sent()
+	edit := sent[len(sent)-2]
+	require.Equal(t, messenger.KindEdit, edit.Kind)
+	require.Equal(t, "❗B3", edit.Keyboard[1][2].Text)
+	require.Equal(t, ru.T(msgZoneCellChanged, i18n.Args{"cell": "B3", "kind": ru.T(msgZoneKindCritical)}), sent[len(sent)-1].Text)
+
+	h.photo("part")
+	require.Len(t, detector.zones, 1)
+	require.Equal(t, entity.ZoneCritical, detector.zones[0].Kind)
+
+	// Точные зоны присылаются JSON-файлом и заменяют разметку по сетке.
+	h.messenger.AddFile("zones.json", []byte(`{"width": 200, "height": 100, "zones": [
+		{"name": "serial", "kind": "ignore", "polygon": [[0, 0], [100, 0], [100, 50]]},
+		{"kind": "critical", "sensitivity": 3, "polygon": [[100, 50], [200, 50], [200, 100]]}
+	]}`))
+	h.document("zones.json", "application/json", 256)
+	texts = h.messenger.Texts(testChatID)
+	require.Equal(t, ru.T(msgZonesImported, i18n.Args{"count": 2}), texts[len(texts)-1])
+
+	h.press(cbZonesDone)
+	texts = h.messenger.Texts(testChatID)
+	require.Equal(t, ru.T(msgZonesSaved, i18n.Args{"ignored": 1, "critical": 1}), texts[len(texts)-1])
+
+	h.photo("part")
+	require.Len(t, detector.zones, 2)
+	require.Equal(t, entity.ZonePoint{X: 0.5, Y: 0.5}, detector.zones[0].Polygon[2])
+
+	h.messenger.AddFile("broken.json", []byte(`{"zones": []}`))
+	h.document("broken.json", "application/json", 16)
+	texts = h.messenger.Texts(testChatID)
+	require.Contains(t, texts[len(texts)-1], "no zones")
+}
+
+func TestBot_SessionKeepsReferenceForSeveralChecks(t *testing.T) {
+	h := newSessionHarness(t, &fakeDetector{result: &entity.InspectionResult{}}, entity.SessionPolicy{MaxChecks: 2})
+	h.messenger.AddFile("original", pngBytes(t))
+	h.messenger.AddFile("part", []byte("part-bytes"))
+
+	h.command(cmdCheck)
+	h.photo("original")
+	h.photo("part")
+	require.Equal(t, entity.StateAwaitingDefectPhoto, h.state(t))
+	texts := h.messenger.Texts(testChatID)
+	require.Equal(t, ru.T(msgNoDefects)+"\n\n"+ru.T(msgSessionNext), texts[len(texts)-1])
+
+	// Вторая деталь исчерпывает лимит сессии.
+	h.photo("part")
+	require.Equal(t, entity.StateMainMenu, h.state(t))
+
+	// «Ещё деталь» начинает новую серию с тем же эталоном и показывает его превью.
+	h.messenger.Reset()
+	h.press(cbReuse)
+	require.Equal(t, entity.StateAwaitingDefectPhoto, h.state(t))
+	sent := h.messenger.Sent()
+	require.Equal(t, messenger.KindPhoto, sent[0].Kind)
+	require.Contains(t, sent[1].Text, ru.T(msgSessionPrompt, i18n.Args{"age": ru.T(msgAgeJustNow), "checks": ru.T(msgChecksOf, i18n.Args{"count": 0, "max": 2})}))
+	require.Equal(t, sessionKeyboard(ru), sent[1].Keyboard)
+
+	h.command(cmdNewRef)
+	require.Equal(t, entity.StateAwaitingOriginalPhoto, h.state(t))
+
+	h.command(cmdDone)
+	require.Equal(t, entity.StateMainMenu, h.state(t))
+	h.command(cmdCheck)
+	require.Equal(t, entity.StateAwaitingOriginalPhoto, h.state(t))
+}
+
+func TestBot_CheckWithActiveSessionSkipsOriginal(t *testing.T) {
+	h := newSessionHarness(t, &fakeDetector{result: &entity.InspectionResult{}}, entity.SessionPolicy{})
+	h.messenger.AddFile("original", []byte("original-bytes"))
+	h.messenger.AddFile("part", []byte("part-bytes"))
+
+	h.command(cmdCheck)
+	h.photo("original")
+	h.photo("part")
+	h.command(cmdCancel)
+	require.Equal(t, entity.StateMainMenu, h.state(t))
+
+	h.command(cmdCheck)
+	require.Equal(t, entity.StateAwaitingDefectPhoto, h.state(t))
+	texts := h.messenger.Texts(testChatID)
+	require.Contains(t, texts[len(texts)-1], ru.T(msgSessionPrompt, i18n.Args{"age": ru.T(msgAgeJustNow), "checks": "1"}))
+}
+
+// blockingDetector держит проверку, пока тест не закроет release.
+type blockingDetector struct {
+	fakeDetector
+	release chan struct{}
+}
+
+func (d *blockingDetector) InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	<-d.release
+	return &entity.InspectionResult{}, nil
+}
+
+func TestBot_RejectsActionsWhileProcessing(t *testing.T) {
+	detector := &blockingDetector{release: make(chan struct{})}
+	fake := messenger.NewFakeMessenger()
+	c := container.New(storage.NewMemoryUserRepository(), storage.NewMemoryInspectionRepository(), detector, nil, entity.SessionPolicy{MaxChecks: 1}, entity.IdlePolicy{}, entity.AccessPolicy{}, entity.RateLimitPolicy{})
+	h := &botHarness{
+		bot:       NewBot(nil, fake, c, Options{ShutdownTimeout: time.Second}),
+		messenger: fake,
+		container: c,
+	}
+	fake.AddFile("original", []byte("original"))
+	fake.AddFile("current", []byte("current"))
+
+	h.command(cmdCheck)
+	h.photo("original")
+	// Фото детали отправляем без ожидания: проверка висит в фоне.
+	h.bot.handleUpdate(context.Background(), incomingUpdate{Update: tgbotapi.Update{Message: &tgbotapi.Message{
+		From:  &tgbotapi.User{ID: testUserID},
+		Chat:  &tgbotapi.Chat{ID: testChatID},
+		Photo: []tgbotapi.PhotoSize{{FileID: "current", Width: 1280, Height: 960}},
+	}}})
+	require.Equal(t, entity.StateProcessing, h.state(t))
+
+	h.bot.handleUpdate(context.Background(), incomingUpdate{Update: tgbotapi.Update{Message: &tgbotapi.Message{
+		From: &tgbotapi.User{ID: testUserID},
+		Chat: &tgbotapi.Chat{ID: testChatID},
+		Text: "ну что там?",
+	}}})
+	h.bot.handleUpdate(context.Background(), incomingUpdate{Update: tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "cb",
+		From:    &tgbotapi.User{ID: testUserID},
+		Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: testChatID}},
+		Data:    cbCancel,
+	}}})
+	require.Equal(t, entity.StateProcessing, h.state(t))
+
+	close(detector.release)
+	h.bot.jobs.Wait()
+	require.Equal(t, entity.StateMainMenu, h.state(t))
+
+	texts := fake.Texts(testChatID)
+	require.Equal(t, []string{ru.T(msgStillProcessing), ru.T(msgStillProcessing)}, texts[3:5])
+	require.Equal(t, ru.T(msgNoDefects), texts[len(texts)-1])
+}
+
+// TestBot_PhotoDuringSlowCheck присылает вторую деталь, пока первая ещё проверяется,
+// и завершает проверку одновременно с новыми сообщениями. Гонку ловит go test -race.
+func TestBot_PhotoDuringSlowCheck(t *testing.T) {
+	detector := &blockingDetector{release: make(chan struct{})}
+	h := newSessionHarness(t, detector, entity.SessionPolicy{})
+	for _, id := range []string{"original", "part-1", "part-2"} {
+		h.messenger.AddFile(id, []byte(id))
+	}
+	// Между шагами не ждём фоновых проверок: иначе обработка и новые апдейты не пересекутся.
+	photo := func(fileID string) {
+		h.bot.handleUpdate(context.Background(), incomingUpdate{Update: tgbotapi.Update{Message: &tgbotapi.Message{
+			From:  &tgbotapi.User{ID: testUserID},
+			Chat:  &tgbotapi.Chat{ID: testChatID},
+			Photo: []tgbotapi.PhotoSize{{FileID: fileID, Width: 1280, Height: 960}},
+		}}})
+	}
+
+	h.command(cmdCheck)
+	h.photo("original")
+	photo("part-1")
+	photo("part-2")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 20 {
+			photo("part-2")
+		}
+	}()
+	close(detector.release)
+	wg.Wait()
+	h.bot.jobs.Wait()
+
+	require.Contains(t, h.messenger.Texts(testChatID), ru.T(msgStillProcessing))
+	require.Equal(t, entity.StateAwaitingDefectPhoto, h.state(t))
+}
+
+func TestBot_IdleSweepRemindsAndExpires(t *testing.T) {
+	fake := messenger.NewFakeMessenger()
+	c := container.New(storage.NewMemoryUserRepository(), storage.NewMemoryInspectionRepository(), &fakeDetector{}, nil,
+		entity.SessionPolicy{}, entity.IdlePolicy{RemindAfter: time.Minute, ExpireAfter: time.Hour}, entity.AccessPolicy{}, entity.RateLimitPolicy{})
+	h := &botHarness{bot: NewBot(nil, fake, c, Options{}), messenger: fake, container: c}
+
+	h.command(cmdCheck)
+	now := time.Now()
+
+	h.bot.sweepIdle(context.Background(), now.Add(2*time.Minute))
+	h.bot.sweepIdle(context.Background(), now.Add(2*time.Hour))
+
+	require.Equal(t, entity.StateMainMenu, h.state(t))
+	require.Equal(t, []string{
+		ru.T(msgAwaitingOriginal),
+		ru.T(msgIdleReminderOriginal),
+		ru.T(msgIdleExpired),
+	}, fake.Texts(testChatID))
+}
+
+func TestFormatAge(t *testing.T) {
+	require.Equal(t, ru.T(msgAgeJustNow), formatAge(ru, 30*time.Second))
+	require.Equal(t, "5 минут назад", formatAge(ru, 5*time.Minute))
+	require.Equal(t, "1 минуту назад", formatAge(ru, time.Minute))
+	require.Equal(t, "3 minutes ago", formatAge(en, 3*time.Minute))
+	require.Equal(t, ru.T(msgAgeHours, i18n.Args{"hours": 2, "minutes": 3}), formatAge(ru, 2*time.Hour+3*time.Minute))
+}
+
+// pngBytes возвращает маленькое настоящее изображение, из которого можно сделать превью.
+func pngBytes(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 16, 16))))
+	return buf.Bytes()
+}
+
+func TestBot_LanguageFromTelegramClient(t *testing.T) {
+	h := newBotHarness(t, &fakeDetector{result: &entity.InspectionResult{}})
+
+	h.send(&tgbotapi.Message{
+		From: &tgbotapi.User{ID: testUserID, LanguageCode: "en-GB"},
+		Chat: &tgbotapi.Chat{ID: testChatID},
+		Text: "hello",
+	})
+
+	require.Equal(t, []string{en.T(msgStart)}, h.messenger.Texts(testChatID))
+	require.Equal(t, mainMenuKeyboard(en), h.lastKeyboard(t))
+}
+
+func TestBot_LanguageOverride(t *testing.T) {
+	detector := &fakeDetector{result: &entity.InspectionResult{
+		ImageWidth:  300,
+		ImageHeight: 300,
+		Defects:     []entity.DefectArea{{X: 10, Y: 10, Width: 20, Height: 10}},
+		HasDefects:  true,
+	}}
+	fake := messenger.NewFakeMessenger()
+	c := container.New(storage.NewMemoryUserRepository(), storage.NewMemoryInspectionRepository(), detector,
+		ai.NewTemplateDescriber(i18n.MustDefault()), entity.SessionPolicy{MaxChecks: 1}, entity.IdlePolicy{}, entity.AccessPolicy{}, entity.RateLimitPolicy{})
+	h := &botHarness{
+		bot:       NewBot(nil, fake, c, Options{ShutdownTimeout: time.Second}),
+		messenger: fake,
+		container: c,
+	}
+	h.messenger.AddFile("original", []byte("original-bytes"))
+	h.messenger.AddFile("current", []byte("current-bytes"))
+
+	h.command(cmdLang)
+	require.Equal(t, languageKeyboard(i18n.MustDefault()), h.lastKeyboard(t))
+
+	h.press(callbackData(cbLanguage, "en"))
+	callbacks := h.messenger.Callbacks()
+	require.Equal(t, en.T(msgLanguageChanged, i18n.Args{"language": en.T(msgLanguageName)}), callbacks[len(callbacks)-1].Text)
+
+	// Явный выбор важнее языка клиента Telegram.
+	h.send(&tgbotapi.Message{
+		From: &tgbotapi.User{ID: testUserID, LanguageCode: "ru"},
+		Chat: &tgbotapi.Chat{ID: testChatID},
+		Text: "hello",
+	})
+	h.command(cmdCheck)
+	h.photo("original")
+	h.photo("current")
+
+	texts := h.messenger.Texts(testChatID)
+	require.Equal(t, en.T(msgStart), texts[1])
+	require.Equal(t, en.N(msgDefectsFound, 1)+"\n1. top left: 20×10 px, centre (20, 15)", texts[len(texts)-1])
+
+	h.press(callbackData(cbLanguage, "xx"))
+	callbacks = h.messenger.Callbacks()
+	require.Equal(t, en.T(msgActionUnavailable), callbacks[len(callbacks)-1].Text)
+}
+
+func TestBot_AccessControl(t *testing.T) {
+	const operatorID int64 = 7
+	fake := messenger.NewFakeMessenger()
+	c := container.New(storage.NewMemoryUserRepository(), storage.NewMemoryInspectionRepository(), &fakeDetector{result: &entity.InspectionResult{}}, nil,
+		entity.SessionPolicy{}, entity.IdlePolicy{}, entity.AccessPolicy{Admins: []int64{testUserID}}, entity.RateLimitPolicy{})
+	h := &botHarness{bot: NewBot(nil, fake, c, Options{}), messenger: fake, container: c}
+	sendAs := func(userID int64, text string) {
+		msg := &tgbotapi.Message{From: &tgbotapi.User{ID: userID}, Chat: &tgbotapi.Chat{ID: userID}, Text: text}
+		if text[0] == '/' {
+			msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(strings.Fields(text)[0])}}
+		}
+		h.send(msg)
+	}
+
+	// Неизвестный пользователь получает свой ID, диалог не начинается.
+	sendAs(operatorID, "/check")
+	require.Equal(t, []string{ru.T(msgAccessDenied, i18n.Args{"id": "7"})}, fake.Texts(operatorID))
+
+	sendAs(testUserID, "/grant 7 boss")
+	sendAs(testUserID, "/grant 7 operator")
+	require.Equal(t, []string{
+		ru.T(msgGrantUsage),
+		ru.T(msgRoleGranted, i18n.Args{"id": "7", "role": "оператор"}),
+	}, fake.Texts(testChatID))
+
+	// Оператор проверяет детали, но не загружает эталон и не видит кнопку смены эталона.
+	sendAs(operatorID, "/check")
+	require.Equal(t, ru.T(msgReferenceDenied), fake.Texts(operatorID)[1])
+	sendAs(operatorID, "/newref")
+	require.Equal(t, ru.T(msgPermissionDenied), fake.Texts(operatorID)[2])
+	sendAs(operatorID, "/stats")
+	require.Equal(t, ru.T(msgPermissionDenied), fake.Texts(operatorID)[3])
+	require.Equal(t, port.Keyboard{}, visibleKeyboard(withRole(context.Background(), entity.RoleOperator), port.Keyboard{
+		{{Text: "new", Data: cbNewRef}},
+	}))
+
+	sendAs(testUserID, "/stats")
+	texts := fake.Texts(testChatID)
+	require.Contains(t, texts[len(texts)-1], "Пользователей: 2")
+
+	sendAs(testUserID, "/revoke 7")
+	sendAs(operatorID, "/cancel")
+	texts = fake.Texts(operatorID)
+	require.Equal(t, ru.T(msgAccessDenied, i18n.Args{"id": "7"}), texts[len(texts)-1])
+}
+
+func TestBot_OperatorCannotReplaceReference(t *testing.T) {
+	const operatorID int64 = 7
+	fake := messenger.NewFakeMessenger()
+	c := container.New(storage.NewMemoryUserRepository(), storage.NewMemoryInspectionRepository(), &fakeDetector{result: &entity.InspectionResult{}}, nil,
+		entity.SessionPolicy{}, entity.IdlePolicy{}, entity.AccessPolicy{Admins: []int64{testUserID}}, entity.RateLimitPolicy{})
+	h := &botHarness{bot: NewBot(nil, fake, c, Options{}), messenger: fake, container: c}
+	fake.AddFile("original", []byte("original"))
+	fake.AddFile("other", []byte("other"))
+	ctx := context.Background()
+	key := entity.PrivateDialogue(operatorID)
+	from := &tgbotapi.User{ID: operatorID}
+	chat := &tgbotapi.Chat{ID: operatorID}
+	photo := func(fileID string) {
+		h.send(&tgbotapi.Message{From: from, Chat: chat, Photo: []tgbotapi.PhotoSize{{FileID: fileID, Width: 1280, Height: 960}}})
+	}
+	press := func(data string) {
+		h.bot.handleUpdate(ctx, incomingUpdate{Update: tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+			ID: "cb-" + data, From: from, Message: &tgbotapi.Message{Chat: chat}, Data: data,
+		}}})
+		h.bot.jobs.Wait()
+	}
+
+	// Эталон загрузил инспектор, потом его понизили до оператора.
+	_, err := c.AccessService.Grant(ctx, testUserID, testUserID, operatorID, entity.RoleInspector)
+	require.NoError(t, err)
+	press(cbNewCheck)
+	photo("original")
+	_, err = c.AccessService.Grant(ctx, testUserID, testUserID, operatorID, entity.RoleOperator)
+	require.NoError(t, err)
+
+	// «Новая проверка» продолжает сессию: следующее фото проверяется как деталь.
+	press(cbNewCheck)
+	photo("other")
+	require.Equal(t, []byte("original"), c.InspectionService.ActiveSession(key).Reference)
+
+	// Если бот ждёт эталон (например, сессия истекла), фото оператора эталоном не становится.
+	_, err = c.UserService.BeginCheck(ctx, key)
+	require.NoError(t, err)
+	photo("other")
+	texts := fake.Texts(operatorID)
+	require.Equal(t, ru.T(msgReferenceDenied), texts[len(texts)-1])
+	require.Equal(t, []byte("original"), c.InspectionService.ActiveSession(key).Reference)
+	user, err := c.UserService.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, entity.StateMainMenu, user.State)
+}
+
+func TestBot_ReportProfileRejectedToAdmins(t *testing.T) {
+	const grantedAdminID int64 = 8
+	fake := messenger.NewFakeMessenger()
+	c := container.New(storage.NewMemoryUserRepository(), storage.NewMemoryInspectionRepository(), &fakeDetector{}, nil,
+		entity.SessionPolicy{}, entity.IdlePolicy{}, entity.AccessPolicy{Admins: []int64{testUserID}}, entity.RateLimitPolicy{})
+	bot := NewBot(nil, fake, c, Options{})
+	ctx := context.Background()
+	_, err := c.AccessService.Grant(ctx, testUserID, testUserID, grantedAdminID, entity.RoleAdmin)
+	require.NoError(t, err)
+	_, err = c.UserService.SetLanguage(ctx, grantedAdminID, "en")
+	require.NoError(t, err)
+
+	bot.ReportProfileRejected(ctx, "default", "3f9a1c0e2b7d", errors.New("unknown field"))
+
+	args := i18n.Args{"profile": "default", "version": "3f9a1c0e2b7d", "error": "unknown field"}
+	require.Equal(t, []string{ru.T(msgProfileRejected, args)}, fake.Texts(testUserID))
+	require.Equal(t, []string{en.T(msgProfileRejected, args)}, fake.Texts(grantedAdminID))
+}
+
+func TestBot_GroupChatDialoguesPerUserAndTopic(t *testing.T) {
+	const (
+		groupID    int64 = -1001987654321
+		operatorID int64 = 7
+		topicID          = 57
+	)
+	fake := messenger.NewFakeMessenger()
+	c := container.New(storage.NewMemoryUserRepository(), storage.NewMemoryInspectionRepository(), &fakeDetector{result: &entity.InspectionResult{}}, nil,
+		entity.SessionPolicy{MaxChecks: 1}, entity.IdlePolicy{}, entity.AccessPolicy{}, entity.RateLimitPolicy{})
+	h := &botHarness{bot: NewBot(nil, fake, c, Options{Username: "vision_qc_bot"}), messenger: fake, container: c}
+	fake.AddFile("original", []byte("original"))
+	fake.AddFile("current", []byte("current"))
+
+	messageID := 0
+	sendAs := func(userID int64, threadID int, msg *tgbotapi.Message) {
+		messageID++
+		msg.MessageID = messageID
+		msg.From = &tgbotapi.User{ID: userID}
+		msg.Chat = &tgbotapi.Chat{ID: groupID, Type: "supergroup"}
+		if strings.HasPrefix(msg.Text, "/") {
+			msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(strings.Fields(msg.Text)[0])}}
+		}
+		h.bot.handleUpdate(context.Background(), incomingUpdate{Update: tgbotapi.Update{Message: msg}, ThreadID: threadID})
+		h.bot.jobs.Wait()
+	}
+	photo := func(fileID string) *tgbotapi.Message {
+		return &tgbotapi.Message{Photo: []tgbotapi.PhotoSize{{FileID: fileID, Width: 1280, Height: 960}}}
+	}
+
+	// Разговоры и фото тех, кто не проходит проверку, бот пропускает.
+	sendAs(operatorID, 0, &tgbotapi.Message{Text: "коллеги, привет"})
+	sendAs(operatorID, 0, photo("original"))
+	sendAs(operatorID, 0, &tgbotapi.Message{Text: "/check@other_bot"})
+	require.Empty(t, fake.Sent())
+
+	sendAs(testUserID, topicID, &tgbotapi.Message{Text: "/check@vision_qc_bot"})
+	sendAs(operatorID, 0, &tgbotapi.Message{Text: "/check"})
+	sendAs(testUserID, topicID, photo("original"))
+	sendAs(testUserID, topicID, photo("current"))
+
+	operator, err := c.UserService.Get(context.Background(), entity.DialogueKey{ChatID: groupID, UserID: operatorID})
+	require.NoError(t, err)
+	require.Equal(t, entity.StateAwaitingOriginalPhoto, operator.State)
+	inspector, err := c.UserService.Get(context.Background(), entity.DialogueKey{ChatID: groupID, UserID: testUserID, ThreadID: topicID})
+	require.NoError(t, err)
+	require.Equal(t, entity.StateMainMenu, inspector.State)
+
+	sent := fake.Sent()
+	last := sent[len(sent)-1]
+	require.Equal(t, ru.T(msgNoDefects), last.Text)
+	require.Equal(t, topicID, last.ThreadID)
+	require.Equal(t, messageID, last.ReplyTo)
+	require.Equal(t, 0, sent[1].ThreadID, "ответ оператору ушёл в общий чат")
+	require.Equal(t, 5, sent[1].ReplyTo)
+
+	// Упоминание бота доходит до диалога: оператору снова напоминают прислать эталон.
+	sendAs(operatorID, 0, &tgbotapi.Message{
+		Text:     "@vision_qc_bot отмена",
+		Entities: []tgbotapi.MessageEntity{{Type: "mention", Offset: 0, Length: 14}},
+	})
+	require.Equal(t, ru.T(msgAwaitingOriginal), fake.Sent()[len(fake.Sent())-1].Text)
+}
+
+func TestBot_RateLimitAsksToSlowDown(t *testing.T) {
+	fake := messenger.NewFakeMessenger()
+	c := container.New(storage.NewMemoryUserRepository(), storage.NewMemoryInspectionRepository(), &fakeDetector{result: &entity.InspectionResult{}}, nil,
+		entity.SessionPolicy{}, entity.IdlePolicy{}, entity.AccessPolicy{}, entity.RateLimitPolicy{
+			Roles:         map[entity.Role]entity.RateLimit{entity.OpenRole: {Events: 2, Per: time.Minute}},
+			MaxConcurrent: 1,
+		})
+	h := &botHarness{bot: NewBot(nil, fake, c, Options{ShutdownTimeout: time.Second}), messenger: fake, container: c}
+	fake.AddFile("original", []byte("original"))
+	fake.AddFile("current", []byte("current"))
+
+	h.command(cmdCheck)
+	h.photo("original")
+	h.photo("current")
+	h.photo("current")
+	h.photo("current")
+
+	texts := fake.Texts(testChatID)
+	require.True(t, strings.HasPrefix(texts[len(texts)-2], ru.T(msgNoDefects)))
+	require.Equal(t, ru.T(msgSlowDown, i18n.Args{"seconds": 30}), texts[len(texts)-1])
+	require.Equal(t, entity.StateAwaitingDefectPhoto, h.state(t))
+	require.Equal(t, 2, c.RateLimiter.Stats().ThrottledUsers)
+}
+
+func TestBot_RateLimitCountsEveryAlbumPart(t *testing.T) {
+	fake := messenger.NewFakeMessenger()
+	c := container.New(storage.NewMemoryUserRepository(), storage.NewMemoryInspectionRepository(), &fakeDetector{result: &entity.InspectionResult{}}, nil,
+		entity.SessionPolicy{}, entity.IdlePolicy{}, entity.AccessPolicy{}, entity.RateLimitPolicy{
+			Roles: map[entity.Role]entity.RateLimit{entity.OpenRole: {Events: 3, Per: time.Minute}},
+		})
+	h := &botHarness{bot: NewBot(nil, fake, c, Options{ShutdownTimeout: time.Second, AlbumWindow: 20 * time.Millisecond}), messenger: fake, container: c}
+	for _, id := range []string{"original", "part-1", "part-2", "part-3", "part-4"} {
+		fake.AddFile(id, []byte(id))
+	}
+
+	h.command(cmdCheck)
+	h.photo("original")
+	// После эталона в лимит помещаются две детали альбома, остальные отбрасываются.
+	h.album("tray-1", "part-1", "part-2", "part-3", "part-4")
+
+	texts := fake.Texts(testChatID)
+	require.Contains(t, texts, ru.T(msgSlowDown, i18n.Args{"seconds": 20}))
+	require.Contains(t, texts[len(texts)-1], ru.T(msgBatchHeader, i18n.Args{"count": 2}))
+	require.Equal(t, 2, c.RateLimiter.Stats().ThrottledUsers)
+}
+
+// fakeCalibrator меряет деталь длиной 100 пикселей на эталоне 1280×960.
+type fakeCalibrator struct{}
+
+func (fakeCalibrator) CalibrateByPart(ctx context.Context, image []byte, lengthMM float64) (*entity.Calibration, error) {
+	return &entity.Calibration{MMPerPixel: lengthMM / 100, ImageWidth: 1280, ImageHeight: 960, Source: entity.CalibrationManual}, nil
+}
+
+func (fakeCalibrator) CalibrateByMarker(ctx context.Context, image []byte) (*entity.Calibration, error) {
+	return nil, nil
+}
+
+func (fakeCalibrator) Tolerance() entity.Tolerance {
+	return entity.Tolerance{MinLengthMM: 1}
+}
+
+// slowCalibrator не отдаёт масштаб, пока тест его не отпустит.
+type slowCalibrator struct {
+	fakeCalibrator
+	release chan struct{}
+}
+
+func (c slowCalibrator) CalibrateByPart(ctx context.Context, image []byte, lengthMM float64) (*entity.Calibration, error) {
+	<-c.release
+	return c.fakeCalibrator.CalibrateByPart(ctx, image, lengthMM)
+}
+
+func TestBot_CalibrateDoesNotBlockUpdates(t *testing.T) {
+	h := newBotHarness(t, &fakeDetector{result: &entity.InspectionResult{}})
+	calibrator := slowCalibrator{release: make(chan struct{})}
+	h.container.InspectionService.SetCalibrator(calibrator)
+	h.messenger.AddFile("original", []byte("original"))
+	h.command(cmdCheck)
+	h.photo("original")
+
+	// Пока деталь измеряется, бот отвечает на другие апдейты.
+	text := "/" + cmdCalibrate + " 20"
+	h.bot.handleUpdate(context.Background(), incomingUpdate{Update: tgbotapi.Update{Message: &tgbotapi.Message{
+		From:     &tgbotapi.User{ID: testUserID},
+		Chat:     &tgbotapi.Chat{ID: testChatID},
+		Text:     text,
+		Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(cmdCalibrate) + 1}},
+	}}})
+	h.bot.handleUpdate(context.Background(), incomingUpdate{Update: tgbotapi.Update{Message: &tgbotapi.Message{
+		From:     &tgbotapi.User{ID: testUserID},
+		Chat:     &tgbotapi.Chat{ID: testChatID},
+		Text:     "/" + cmdCalibrate,
+		Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(cmdCalibrate) + 1}},
+	}}})
+	texts := h.messenger.Texts(testChatID)
+	require.Equal(t, ru.T(msgCalibrationNone)+"\n"+ru.T(msgCalibrateUsage), texts[len(texts)-1])
+
+	close(calibrator.release)
+	h.bot.jobs.Wait()
+	texts = h.messenger.Texts(testChatID)
+	require.Equal(t, ru.T(msgCalibrated, i18n.Args{"scale": 0.2, "source": ru.T("calibration_source_manual")}), texts[len(texts)-1])
+}
+
+func TestBot_CalibrateAndTolerance(t *testing.T) {
+	h := newBotHarness(t, &fakeDetector{result: &entity.InspectionResult{
+		ImageWidth:  1280,
+		ImageHeight: 960,
+		Defects:     []entity.DefectArea{{X: 10, Y: 10, Width: 4, Height: 2, Area: 8}},
+		HasDefects:  true,
+	}})
+	h.container.InspectionService.SetCalibrator(fakeCalibrator{})
+	h.messenger.AddFile("original", []byte("original"))
+	h.messenger.AddFile("current", []byte("current"))
+	calibrate := func(args string) {
+		text := "/" + cmdCalibrate + args
+		h.send(&tgbotapi.Message{
+			From:     &tgbotapi.User{ID: testUserID},
+			Chat:     &tgbotapi.Chat{ID: testChatID},
+			Text:     text,
+			Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(cmdCalibrate) + 1}},
+		})
+	}
+	last := func() string {
+		texts := h.messenger.Texts(testChatID)
+		return texts[len(texts)-1]
+	}
+
+	calibrate(" 20")
+	require.Equal(t, ru.T(msgNoReference), last())
+
+	h.command(cmdCheck)
+	h.photo("original")
+	calibrate("")
+	require.Equal(t, ru.T(msgCalibrationNone)+"\n"+ru.T(msgCalibrateUsage), last())
+	calibrate(" -3")
+	require.Equal(t, ru.T(msgCalibrateUsage), last())
+
+	// Деталь 20 мм на 100 пикселях: 0,2 мм/пикс.
+	calibrate(" 20,0")
+	require.Equal(t, ru.T(msgCalibrated, i18n.Args{"scale": 0.2, "source": ru.T("calibration_source_manual")}), last())
+
+	// Скол 0,8 мм меньше допуска 1 мм: деталь годна, бот упоминает отклонение.
+	h.photo("current")
+	require.Equal(t, ru.T(msgNoDefects)+"\n"+ru.N(msgWithinTolerance, 1), last())
+}
+
+func TestBot_MultiPartVerdicts(t *testing.T) {
+	h := newBotHarness(t, &fakeDetector{result: &entity.InspectionResult{
+		ImageWidth:  1280,
+		ImageHeight: 960,
+		Defects: []entity.DefectArea{
+			{X: 500, Y: 100, Width: 10, Height: 10, Area: 100, Part: 2},
+			{X: 900, Y: 100, Width: 200, Height: 200, Area: 40000, Reason: "part_mismatch", Part: 3},
+		},
+		HasDefects: true,
+		Parts: []entity.PartInspection{
+			{Index: 1, X: 50, Y: 50, Width: 300, Height: 300, Matched: true},
+			{Index: 2, X: 450, Y: 50, Width: 300, Height: 300, Matched: true},
+			{Index: 3, X: 850, Y: 50, Width: 300, Height: 300},
+		},
+	}})
+	h.messenger.AddFile("original", []byte("original"))
+	h.messenger.AddFile("current", []byte("current"))
+
+	h.command(cmdCheck)
+	h.photo("original")
+	h.photo("current")
+
+	sent := h.messenger.Sent()
+	require.Equal(t, messenger.KindPhoto, sent[len(sent)-2].Kind, "overview is sent before the verdict")
+	texts := h.messenger.Texts(testChatID)
+	require.Contains(t, texts[len(texts)-1], ru.N(msgPartsSummary, 3, i18n.Args{"defective": 2})+"\n"+
+		ru.T(msgPartOK, i18n.Args{"index": 1})+"\n"+
+		ru.N(msgPartDefects, 1, i18n.Args{"index": 2})+"\n"+
+		ru.T(msgPartMismatch, i18n.Args{"index": 3}))
+}
@@ -0,0 +1,90 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	app "vision-bot/internal/application"
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/i18n"
+)
+
+// handleCalibrate обрабатывает /calibrate <мм>: калибрует эталон по длине детали.
+// Без аргумента показывает текущий масштаб эталона.
+func (b *Bot) handleCalibrate(ctx context.Context, key entity.DialogueKey, msg *tgbotapi.Message) {
+	service := b.container.InspectionService
+	args := strings.Fields(msg.CommandArguments())
+	if len(args) == 0 {
+		b.showCalibration(ctx, key)
+		return
+	}
+
+	lengthMM, err := strconv.ParseFloat(strings.Replace(args[0], ",", ".", 1), 64)
+	if err != nil || lengthMM <= 0 || math.IsInf(lengthMM, 0) {
+		b.sendMessage(ctx, key, t(ctx, msgCalibrateUsage))
+		return
+	}
+	if !service.HasReference(key) {
+		b.sendMessage(ctx, key, t(ctx, msgNoReference))
+		return
+	}
+
+	// Измерение детали нагружает детектор, поэтому идёт в фоне, как проверка.
+	b.sendMessage(ctx, key, t(ctx, msgProcessing))
+	jobCtx := detach(b.jobCtx, ctx)
+	b.jobs.Add(1)
+	go func() {
+		defer b.jobs.Done()
+		b.calibrateReference(jobCtx, key, lengthMM)
+	}()
+}
+
+// calibrateReference измеряет деталь на эталоне и сообщает масштаб.
+// Занимает слот проверки, но состояние диалога не меняет.
+func (b *Bot) calibrateReference(ctx context.Context, key entity.DialogueKey, lengthMM float64) {
+	release, err := b.container.RateLimiter.Acquire(ctx, key.UserID)
+	if err != nil {
+		slog.WarnContext(ctx, "Inspection slot not acquired", "err", err)
+		b.sendMessage(ctx, key, t(ctx, msgProcessingError))
+		return
+	}
+	defer release()
+
+	calibration, err := b.container.InspectionService.CalibrateReference(ctx, key, lengthMM)
+	switch {
+	case errors.Is(err, app.ErrNoReference), errors.Is(err, app.ErrReferenceChanged):
+		b.sendMessage(ctx, key, t(ctx, msgNoReference))
+		return
+	case err != nil:
+		slog.WarnContext(ctx, "CalibrateReference error", "length_mm", lengthMM, "err", err)
+		b.sendMessage(ctx, key, t(ctx, msgCalibrationFailed))
+		return
+	}
+	b.sendMessage(ctx, key, t(ctx, msgCalibrated, calibrationArgs(i18n.FromContext(ctx), calibration)))
+}
+
+// showCalibration сообщает масштаб эталона и подсказывает, как его задать.
+func (b *Bot) showCalibration(ctx context.Context, key entity.DialogueKey) {
+	tr := i18n.FromContext(ctx)
+	calibration := b.container.InspectionService.Calibration(key)
+	if calibration == nil {
+		b.sendMessage(ctx, key, tr.T(msgCalibrationNone)+"\n"+tr.T(msgCalibrateUsage))
+		return
+	}
+	b.sendMessage(ctx, key, tr.T(msgCalibrationStatus, calibrationArgs(tr, calibration)))
+}
+
+// calibrationArgs — масштаб с четырьмя значащими цифрами и способ калибровки.
+func calibrationArgs(tr i18n.Translator, calibration *entity.Calibration) i18n.Args {
+	scale, _ := strconv.ParseFloat(strconv.FormatFloat(calibration.MMPerPixel, 'g', 4, 64), 64)
+	return i18n.Args{
+		"scale":  scale,
+		"source": tr.T("calibration_source_" + string(calibration.Source)),
+	}
+}
@@ -0,0 +1,283 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	app "vision-bot/internal/application"
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/domain/port"
+	"vision-bot/internal/i18n"
+)
+
+// historyLimit — сколько последних проверок показывать в истории.
+const historyLimit = 10
+
+// handleCallback обрабатывает нажатия inline-кнопок. Действия те же, что и у
+// команд, и проходят через те же методы сервисов. Автор и сообщение уже
+// проверены middleware authorize.
+func (b *Bot) handleCallback(ctx context.Context, key entity.DialogueKey, query *tgbotapi.CallbackQuery) {
+	action, arg := parseCallbackData(query.Data)
+	if perm, ok := callbackPermissions[action]; ok && !can(ctx, perm) {
+		slog.InfoContext(ctx, "Callback denied", "action", action)
+		b.answerCallback(ctx, query.ID, t(ctx, msgPermissionDenied))
+		return
+	}
+
+	// Telegram ждёт ответа на каждое нажатие, иначе кнопка «зависает» с часиками.
+	answer := ""
+	switch action {
+	case cbNewCheck:
+		b.beginCheck(ctx, key)
+	case cbQuick:
+		b.beginQuick(ctx, key)
+	case cbReuse:
+		b.reuseReference(ctx, key)
+	case cbHistory:
+		b.showHistory(ctx, key)
+	case cbSettings:
+		b.showSettings(ctx, key)
+	case cbLanguage:
+		answer = b.setLanguage(ctx, key, arg)
+	case cbCancel:
+		b.cancelCheck(ctx, key)
+	case cbNewRef:
+		b.newReference(ctx, key)
+	case cbDone:
+		b.endSession(ctx, key)
+	case cbFalsePositive:
+		answer = b.markFalsePositive(ctx, key.UserID, arg)
+	case cbApprove:
+		answer = b.approveSample(ctx, key, arg)
+	case cbCompare:
+		answer = b.showComparison(ctx, key, arg)
+	case cbZones:
+		b.beginZones(ctx, key)
+	case cbZoneCell:
+		answer = b.toggleZoneCell(ctx, key, query, arg)
+	case cbZonesClear:
+		b.clearZones(ctx, key)
+	case cbZonesDone:
+		b.finishZones(ctx, key)
+	default:
+		slog.WarnContext(ctx, "Unknown callback", "data", query.Data)
+	}
+
+	b.answerCallback(ctx, query.ID, answer)
+}
+
+// answerCallback подтверждает нажатие кнопки.
+func (b *Bot) answerCallback(ctx context.Context, callbackID, text string) {
+	if err := b.messenger.AnswerCallback(ctx, callbackID, text); err != nil {
+		slog.ErrorContext(ctx, "Error answering callback", "err", err)
+	}
+}
+
+// beginCheck начинает новую проверку: с активным эталоном сразу ждём деталь,
+// иначе — оригинальное фото.
+func (b *Bot) beginCheck(ctx context.Context, key entity.DialogueKey) {
+	if b.container.InspectionService.ActiveSession(key) == nil && !b.canUploadReference(ctx, key) {
+		return
+	}
+	_, session, err := b.container.InspectionService.StartCheck(ctx, key)
+	if err != nil {
+		b.reportDialogueError(ctx, key, "StartCheck", err)
+		return
+	}
+	if session != nil {
+		b.sendSessionPrompt(ctx, key, session)
+		return
+	}
+	b.sendKeyboard(ctx, key, t(ctx, msgAwaitingOriginal), cancelKeyboard(i18n.FromContext(ctx)))
+}
+
+// newReference просит загрузить новый эталон вместо текущего.
+func (b *Bot) newReference(ctx context.Context, key entity.DialogueKey) {
+	if _, err := b.container.InspectionService.NewReference(ctx, key); err != nil {
+		b.reportDialogueError(ctx, key, "NewReference", err)
+		return
+	}
+	b.sendKeyboard(ctx, key, t(ctx, msgAwaitingOriginal), cancelKeyboard(i18n.FromContext(ctx)))
+}
+
+// endSession завершает серию проверок с эталоном.
+func (b *Bot) endSession(ctx context.Context, key entity.DialogueKey) {
+	if _, err := b.container.InspectionService.EndSession(ctx, key); err != nil {
+		b.reportDialogueError(ctx, key, "EndSession", err)
+		return
+	}
+	b.sendKeyboard(ctx, key, t(ctx, msgSessionDone), mainMenuKeyboard(i18n.FromContext(ctx)))
+}
+
+// sendSessionPrompt показывает превью активного эталона, его возраст и число проверок.
+func (b *Bot) sendSessionPrompt(ctx context.Context, key entity.DialogueKey, session *entity.Session) {
+	if len(session.Thumbnail) > 0 {
+		b.sendPhoto(ctx, key, session.Thumbnail)
+	}
+
+	tr := i18n.FromContext(ctx)
+	policy := b.container.InspectionService.SessionPolicy()
+	checks := tr.Number(session.Checks)
+	if policy.MaxChecks > 0 {
+		checks = tr.T(msgChecksOf, i18n.Args{"count": session.Checks, "max": policy.MaxChecks})
+	}
+	text := tr.T(msgSessionPrompt, i18n.Args{"age": formatAge(tr, time.Since(session.UploadedAt)), "checks": checks}) +
+		"\n" + tr.T(msgSessionNext)
+	b.sendKeyboard(ctx, key, text, sessionKeyboard(tr))
+}
+
+// formatAge описывает, как давно загружен эталон.
+func formatAge(tr i18n.Translator, age time.Duration) string {
+	switch {
+	case age < time.Minute:
+		return tr.T(msgAgeJustNow)
+	case age < time.Hour:
+		return tr.N(msgAgeMinutes, int(age.Minutes()))
+	default:
+		return tr.T(msgAgeHours, i18n.Args{"hours": int(age.Hours()), "minutes": int(age.Minutes()) % 60})
+	}
+}
+
+// cancelCheck отменяет текущую проверку и возвращает в главное меню.
+func (b *Bot) cancelCheck(ctx context.Context, key entity.DialogueKey) {
+	if _, err := b.container.UserService.Cancel(ctx, key); err != nil {
+		b.reportDialogueError(ctx, key, "Cancel", err)
+		return
+	}
+	b.sendKeyboard(ctx, key, t(ctx, msgCancelled), mainMenuKeyboard(i18n.FromContext(ctx)))
+}
+
+// reuseReference начинает проверку с ранее сохранённым эталоном.
+func (b *Bot) reuseReference(ctx context.Context, key entity.DialogueKey) {
+	_, session, err := b.container.InspectionService.ReuseReference(ctx, key)
+	if errors.Is(err, app.ErrNoReference) {
+		b.sendKeyboard(ctx, key, t(ctx, msgNoReference), mainMenuKeyboard(i18n.FromContext(ctx)))
+		return
+	}
+	if err != nil {
+		b.reportDialogueError(ctx, key, "ReuseReference", err)
+		return
+	}
+	b.sendSessionPrompt(ctx, key, session)
+}
+
+// showHistory отправляет список последних проверок пользователя.
+func (b *Bot) showHistory(ctx context.Context, key entity.DialogueKey) {
+	records, err := b.container.InspectionService.History(ctx, key.UserID, historyLimit)
+	if err != nil {
+		slog.ErrorContext(ctx, "History error", "err", err)
+		b.sendMessage(ctx, key, t(ctx, msgProcessingError))
+		return
+	}
+	if len(records) == 0 {
+		b.sendKeyboard(ctx, key, t(ctx, msgHistoryEmpty), mainMenuKeyboard(i18n.FromContext(ctx)))
+		return
+	}
+
+	tr := i18n.FromContext(ctx)
+	b.sendKeyboard(ctx, key, formatHistory(tr, records), historyKeyboard(tr, records))
+}
+
+// markFalsePositive помечает проверку как ложное срабатывание и возвращает текст ответа на нажатие.
+func (b *Bot) markFalsePositive(ctx context.Context, userID int64, recordID string) string {
+	if _, err := b.container.InspectionService.MarkFalsePositive(ctx, userID, recordID); err != nil {
+		slog.ErrorContext(ctx, "MarkFalsePositive error", "record_id", recordID, "err", err)
+		return t(ctx, msgRecordNotFound)
+	}
+	slog.InfoContext(ctx, "Inspection marked as false positive", "record_id", recordID)
+	return t(ctx, msgMarkedFalsePositive)
+}
+
+// approveSample запускает добавление проверенной детали в модель эталона и
+// возвращает текст ответа на нажатие. Совмещение детали нагружает детектор
+// так же, как проверка, поэтому идёт в фоне и занимает её слот, но состояние
+// диалога не меняет. Итог приходит отдельным сообщением.
+func (b *Bot) approveSample(ctx context.Context, key entity.DialogueKey, recordID string) string {
+	jobCtx := detach(b.jobCtx, ctx)
+	b.jobs.Add(1)
+	go func() {
+		defer b.jobs.Done()
+		b.sendMessage(jobCtx, key, b.extendModel(jobCtx, key, recordID))
+	}()
+	return t(ctx, msgApproving)
+}
+
+// extendModel добавляет деталь в модель эталона и возвращает текст итога.
+func (b *Bot) extendModel(ctx context.Context, key entity.DialogueKey, recordID string) string {
+	release, err := b.container.RateLimiter.Acquire(ctx, key.UserID)
+	if err != nil {
+		slog.WarnContext(ctx, "Inspection slot not acquired", "err", err)
+		return t(ctx, msgApproveFailed)
+	}
+	defer release()
+
+	model, err := b.container.InspectionService.ApproveSample(ctx, key, recordID)
+	switch {
+	case errors.Is(err, app.ErrReferenceChanged):
+		return t(ctx, msgApproveReferenceChanged)
+	case errors.Is(err, app.ErrRecordNotFound):
+		return t(ctx, msgRecordNotFound)
+	case err != nil:
+		slog.ErrorContext(ctx, "ApproveSample error", "record_id", recordID, "reason", app.ClassifyInspectionError(err), "err", err)
+		return t(ctx, msgApproveFailed)
+	}
+	return t(ctx, msgApproved, i18n.Args{"count": model.Samples})
+}
+
+// showComparison отправляет эталон и проверенную деталь одним альбомом.
+func (b *Bot) showComparison(ctx context.Context, key entity.DialogueKey, recordID string) string {
+	record, err := b.container.InspectionService.Record(ctx, key.UserID, recordID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Record error", "record_id", recordID, "err", err)
+		return t(ctx, msgRecordNotFound)
+	}
+
+	current := record.Highlighted
+	if len(current) == 0 {
+		current = record.Current
+	}
+	// Проверку без эталона сравнивать не с чем: показываем только деталь.
+	if len(record.Reference) == 0 {
+		b.sendPhoto(ctx, key, current)
+		return ""
+	}
+	photos := []port.Photo{
+		{Data: record.Reference, Caption: t(ctx, msgComparisonReference)},
+		{Data: current, Caption: t(ctx, msgComparisonCurrent)},
+	}
+	if err := b.messenger.SendAlbum(ctx, recipient(ctx, key), photos); err != nil {
+		slog.ErrorContext(ctx, "Error sending album", "err", err)
+	}
+	return ""
+}
+
+// formatHistory строит список «время — вердикт» для последних проверок.
+func formatHistory(tr i18n.Translator, records []*entity.InspectionRecord) string {
+	var sb strings.Builder
+	sb.WriteString(tr.T(msgHistoryHeader))
+	sb.WriteString("\n")
+
+	for i, record := range records {
+		args := i18n.Args{"index": i + 1, "time": record.CreatedAt.Format("02.01 15:04")}
+		line := tr.T(msgHistoryItemOK, args)
+		if record.Result != nil && record.Result.HasDefects {
+			line = tr.N(msgHistoryItemDefects, len(record.Result.Defects), args)
+		}
+		sb.WriteString("\n" + line)
+		if record.FalsePositive {
+			sb.WriteString(tr.T(msgHistoryFalsePositive))
+		}
+		if len(record.Reference) == 0 {
+			sb.WriteString(tr.T(msgHistoryQuick))
+		}
+		if record.Approved {
+			sb.WriteString(tr.T(msgHistoryApproved))
+		}
+	}
+	return sb.String()
+}
@@ -4,5 +4,15 @@ const (
 	cmdStart  = "start"
 	cmdHelp   = "help"
 	cmdCheck  = "check"
+	cmdQuick  = "quick"
 	cmdCancel = "cancel"
+	cmdNewRef = "newref"
+	cmdDone   = "done"
+	cmdLang   = "lang"
+	cmdGrant  = "grant"
+	cmdRevoke = "revoke"
+	cmdStats  = "stats"
+	cmdZones  = "zones"
+
+	cmdCalibrate = "calibrate"
 )
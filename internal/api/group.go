@@ -0,0 +1,120 @@
+package telegram
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/domain/port"
+	"vision-bot/internal/i18n"
+	"vision-bot/internal/logging"
+)
+
+type replyToKey struct{}
+
+// withReplyTo запоминает сообщение, вызвавшее ответ. В группах бот отвечает
+// на него, чтобы было видно, к кому из операторов относится результат.
+func withReplyTo(ctx context.Context, messageID int) context.Context {
+	return context.WithValue(ctx, replyToKey{}, messageID)
+}
+
+// replyToFrom возвращает сообщение для ответа или 0, если отвечать не на что.
+func replyToFrom(ctx context.Context) int {
+	messageID, _ := ctx.Value(replyToKey{}).(int)
+	return messageID
+}
+
+// recipient строит адресата ответа: чат, тему форума и сообщение для ответа.
+func recipient(ctx context.Context, key entity.DialogueKey) port.Recipient {
+	return port.Recipient{ChatID: key.ChatID, ThreadID: key.ThreadID, ReplyTo: replyToFrom(ctx)}
+}
+
+// detach переносит язык, роль, сообщение для ответа и атрибуты логов из
+// обработки обновления в фоновый контекст: проверка переживает обработку апдейта.
+func detach(base, request context.Context) context.Context {
+	ctx := i18n.WithTranslator(logging.Carry(base, request), i18n.FromContext(request))
+	if role, ok := roleFrom(request); ok {
+		ctx = withRole(ctx, role)
+	}
+	if messageID := replyToFrom(request); messageID != 0 {
+		ctx = withReplyTo(ctx, messageID)
+	}
+	return ctx
+}
+
+// isGroup сообщает, что чат — группа или супергруппа (в том числе форум).
+func isGroup(chat *tgbotapi.Chat) bool {
+	return chat != nil && (chat.IsGroup() || chat.IsSuperGroup())
+}
+
+// addressed решает, относится ли сообщение в группе к боту. В личном чате
+// бот отвечает на всё. В группе — только на команды (без @ или со своим
+// @username), упоминания и ответы на свои сообщения, а также на фото от
+// участников, которые сейчас проходят проверку.
+func (b *Bot) addressed(ctx context.Context, key entity.DialogueKey, msg *tgbotapi.Message) bool {
+	if msg == nil || !isGroup(msg.Chat) {
+		return true
+	}
+
+	if msg.IsCommand() {
+		command := msg.CommandWithAt()
+		at := strings.IndexByte(command, '@')
+		return at < 0 || strings.EqualFold(command[at+1:], b.username)
+	}
+	if b.mentioned(msg) {
+		return true
+	}
+	if msg.MediaGroupID != "" && b.albums.has(msg.MediaGroupID) {
+		return true
+	}
+	if msg.Photo == nil && msg.Document == nil {
+		return false
+	}
+
+	user, err := b.container.UserService.Get(ctx, key)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting user", "err", err)
+		return false
+	}
+	switch user.State {
+	case entity.StateAwaitingOriginalPhoto, entity.StateAwaitingDefectPhoto, entity.StateAwaitingQuickPhoto:
+		return true
+	}
+	return false
+}
+
+// mentioned сообщает, упомянут ли бот в тексте или подписи либо является ли
+// сообщение ответом на сообщение бота.
+func (b *Bot) mentioned(msg *tgbotapi.Message) bool {
+	if b.username == "" {
+		return false
+	}
+	if reply := msg.ReplyToMessage; reply != nil && reply.From != nil && strings.EqualFold(reply.From.UserName, b.username) {
+		return true
+	}
+
+	mention := "@" + b.username
+	check := func(text string, entities []tgbotapi.MessageEntity) bool {
+		for _, item := range entities {
+			if item.Type == "mention" && strings.EqualFold(entityText(text, item), mention) {
+				return true
+			}
+		}
+		return false
+	}
+	return check(msg.Text, msg.Entities) || check(msg.Caption, msg.CaptionEntities)
+}
+
+// entityText вырезает текст сущности. Смещения Telegram считаются в UTF-16.
+func entityText(text string, entity tgbotapi.MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	end := entity.Offset + entity.Length
+	if entity.Offset < 0 || entity.Length < 0 || end > len(units) {
+		return ""
+	}
+	return string(utf16.Decode(units[entity.Offset:end]))
+}
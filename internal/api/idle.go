@@ -0,0 +1,59 @@
+package telegram
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"vision-bot/internal/domain/entity"
+)
+
+// defaultIdleSweepInterval — как часто искать брошенные проверки.
+const defaultIdleSweepInterval = 30 * time.Second
+
+// runIdleSweeper периодически напоминает о брошенных проверках и сбрасывает их.
+// Завершается при отмене ctx.
+func (b *Bot) runIdleSweeper(ctx context.Context) {
+	interval := b.options.IdleSweepInterval
+	if interval <= 0 {
+		interval = defaultIdleSweepInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			b.sweepIdle(ctx, now)
+		}
+	}
+}
+
+// sweepIdle выполняет один обход и уведомляет пользователей.
+func (b *Bot) sweepIdle(ctx context.Context, now time.Time) {
+	report, err := b.container.IdleService.Sweep(ctx, now)
+	if err != nil {
+		slog.ErrorContext(ctx, "Idle sweep error", "err", err)
+	}
+
+	for _, user := range report.Reminded {
+		tr := b.profileTranslator(ctx, user.ID)
+		b.sendKeyboard(ctx, user.Key(), tr.T(idleReminderText(user.State)), cancelKeyboard(tr))
+	}
+	for _, user := range report.Expired {
+		slog.InfoContext(ctx, "Check expired after inactivity", "user_id", user.ID, "chat_id", user.ChatID, "state", user.State)
+		tr := b.profileTranslator(ctx, user.ID)
+		b.sendKeyboard(ctx, user.Key(), tr.T(msgIdleExpired), mainMenuKeyboard(tr))
+	}
+}
+
+// idleReminderText выбирает напоминание по шагу, на котором остановился пользователь.
+func idleReminderText(state entity.UserState) string {
+	if state == entity.StateAwaitingOriginalPhoto {
+		return msgIdleReminderOriginal
+	}
+	return msgIdleReminderPart
+}
@@ -0,0 +1,131 @@
+package telegram
+
+import (
+	"strings"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/domain/port"
+	"vision-bot/internal/i18n"
+)
+
+// Данные callback-кнопок. Для кнопок, относящихся к конкретной проверке,
+// после двоеточия передаётся ID записи истории.
+const (
+	cbNewCheck      = "new"
+	cbQuick         = "quick"
+	cbReuse         = "reuse"
+	cbHistory       = "history"
+	cbSettings      = "settings"
+	cbCancel        = "cancel"
+	cbFalsePositive = "fp"
+	cbApprove       = "approve"
+	cbCompare       = "cmp"
+	cbNewRef        = "newref"
+	cbDone          = "done"
+	cbLanguage      = "lang"
+	cbZones         = "zones"
+	cbZoneCell      = "zone"
+	cbZonesClear    = "zclear"
+	cbZonesDone     = "zdone"
+)
+
+// callbackData склеивает действие и его аргумент.
+func callbackData(action, arg string) string {
+	return action + ":" + arg
+}
+
+// parseCallbackData разбирает данные кнопки на действие и аргумент.
+func parseCallbackData(data string) (action, arg string) {
+	action, arg, _ = strings.Cut(data, ":")
+	return action, arg
+}
+
+// mainMenuKeyboard — клавиатура главного меню.
+func mainMenuKeyboard(tr i18n.Translator) port.Keyboard {
+	return port.Keyboard{
+		{{Text: tr.T(btnNewCheck), Data: cbNewCheck}},
+		{{Text: tr.T(btnQuickCheck), Data: cbQuick}},
+		{{Text: tr.T(btnReuseReference), Data: cbReuse}},
+		{{Text: tr.T(btnHistory), Data: cbHistory}, {Text: tr.T(btnSettings), Data: cbSettings}},
+	}
+}
+
+// cancelKeyboard показывается на каждом шаге ожидания фото.
+func cancelKeyboard(tr i18n.Translator) port.Keyboard {
+	return port.Keyboard{
+		{{Text: tr.T(btnCancel), Data: cbCancel}},
+	}
+}
+
+// sessionKeyboard показывается, пока эталон активен и бот ждёт следующую деталь.
+func sessionKeyboard(tr i18n.Translator) port.Keyboard {
+	return port.Keyboard{
+		{{Text: tr.T(btnNewReference), Data: cbNewRef}, {Text: tr.T(btnDone), Data: cbDone}},
+		{{Text: tr.T(btnZones), Data: cbZones}},
+	}
+}
+
+// resultKeyboard показывается под результатом проверки. Если сессия с эталоном
+// продолжается, вместо «ещё деталь» показываются кнопки управления сессией.
+// approvable — деталь можно добавить в модель эталона как годную.
+func resultKeyboard(tr i18n.Translator, recordID string, hasDefects, inSession, approvable bool) port.Keyboard {
+	var keyboard port.Keyboard
+	if !inSession {
+		keyboard = append(keyboard, []port.Button{{Text: tr.T(btnCheckAnother), Data: cbReuse}})
+	}
+	if hasDefects {
+		keyboard = append(keyboard, []port.Button{{Text: tr.T(btnFalsePositive), Data: callbackData(cbFalsePositive, recordID)}})
+	}
+	if approvable {
+		keyboard = append(keyboard, []port.Button{{Text: tr.T(btnApprove), Data: callbackData(cbApprove, recordID)}})
+	}
+	keyboard = append(keyboard, []port.Button{{Text: tr.T(btnCompare), Data: callbackData(cbCompare, recordID)}})
+	if inSession {
+		keyboard = append(keyboard, sessionKeyboard(tr)...)
+	}
+	return keyboard
+}
+
+// quickResultKeyboard показывается под результатом проверки без эталона.
+// Сравнивать не с чем, поэтому вместо сравнения — переход к проверке с эталоном.
+func quickResultKeyboard(tr i18n.Translator, recordID string, hasDefects bool) port.Keyboard {
+	keyboard := port.Keyboard{{{Text: tr.T(btnQuickAgain), Data: cbQuick}}}
+	if hasDefects {
+		keyboard = append(keyboard, []port.Button{{Text: tr.T(btnFalsePositive), Data: callbackData(cbFalsePositive, recordID)}})
+	}
+	return append(keyboard, []port.Button{{Text: tr.T(btnNewCheck), Data: cbNewCheck}})
+}
+
+// batchKeyboard показывается под сводкой по партии.
+func batchKeyboard(tr i18n.Translator, inSession bool) port.Keyboard {
+	if inSession {
+		return sessionKeyboard(tr)
+	}
+	return port.Keyboard{
+		{{Text: tr.T(btnCheckAnother), Data: cbReuse}},
+	}
+}
+
+// historyKeyboard содержит кнопку сравнения для каждой записи истории.
+func historyKeyboard(tr i18n.Translator, records []*entity.InspectionRecord) port.Keyboard {
+	keyboard := make(port.Keyboard, 0, len(records))
+	for i, record := range records {
+		keyboard = append(keyboard, []port.Button{{
+			Text: tr.T(btnHistoryCompare, i18n.Args{"index": i + 1}),
+			Data: callbackData(cbCompare, record.ID),
+		}})
+	}
+	return keyboard
+}
+
+// languageKeyboard предлагает все языки каталога; каждый подписан на своём языке.
+func languageKeyboard(catalog *i18n.Catalog) port.Keyboard {
+	var keyboard port.Keyboard
+	for _, lang := range catalog.Languages() {
+		keyboard = append(keyboard, []port.Button{{
+			Text: catalog.Translator(lang).T(msgLanguageName),
+			Data: callbackData(cbLanguage, lang),
+		}})
+	}
+	return keyboard
+}
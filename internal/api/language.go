@@ -0,0 +1,77 @@
+package telegram
+
+import (
+	"context"
+	"log/slog"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/i18n"
+)
+
+// withLanguage определяет язык автора обновления и кладёт переводчик в ctx.
+// Язык клиента Telegram запоминается, чтобы фоновые уведомления шли на нём же.
+func (b *Bot) withLanguage(ctx context.Context, from *tgbotapi.User) context.Context {
+	if from == nil {
+		return i18n.WithTranslator(ctx, b.catalog.Translator(b.catalog.Match()))
+	}
+
+	user, err := b.container.UserService.Identify(ctx, from.ID, from.LanguageCode)
+	if err != nil {
+		slog.ErrorContext(ctx, "Identify user error", "err", err)
+		return i18n.WithTranslator(ctx, b.catalog.Translator(b.catalog.Match(from.LanguageCode)))
+	}
+	return i18n.WithTranslator(ctx, b.translatorFor(user))
+}
+
+// profileTranslator возвращает переводчик по профилю пользователя — для
+// сообщений вне обработки обновления.
+func (b *Bot) profileTranslator(ctx context.Context, userID int64) i18n.Translator {
+	profile, err := b.container.UserService.Profile(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Load profile error", "user_id", userID, "err", err)
+		return b.catalog.Translator(b.catalog.Match())
+	}
+	return b.translatorFor(profile)
+}
+
+// translatorFor выбирает язык пользователя: сначала явный выбор через /lang,
+// затем язык клиента, иначе язык по умолчанию.
+func (b *Bot) translatorFor(user *entity.User) i18n.Translator {
+	return b.catalog.Translator(b.catalog.Match(user.Language, user.ClientLanguage))
+}
+
+// handleLanguageCommand обрабатывает /lang: без аргумента показывает выбор языка,
+// с аргументом (например, /lang en) сразу переключает его.
+func (b *Bot) handleLanguageCommand(ctx context.Context, key entity.DialogueKey, msg *tgbotapi.Message) {
+	if lang := msg.CommandArguments(); lang != "" {
+		b.sendMessage(ctx, key, b.setLanguage(ctx, key, lang))
+		return
+	}
+	b.sendKeyboard(ctx, key, t(ctx, msgLanguagePrompt), languageKeyboard(b.catalog))
+}
+
+// setLanguage сохраняет выбранный язык и возвращает подтверждение уже на нём.
+func (b *Bot) setLanguage(ctx context.Context, key entity.DialogueKey, lang string) string {
+	if !b.catalog.Has(lang) {
+		return t(ctx, msgActionUnavailable)
+	}
+
+	user, err := b.container.UserService.SetLanguage(ctx, key.UserID, lang)
+	if err != nil {
+		slog.ErrorContext(ctx, "SetLanguage error", "lang", lang, "err", err)
+		return t(ctx, msgProcessingError)
+	}
+
+	slog.InfoContext(ctx, "Language changed", "lang", lang)
+	tr := b.translatorFor(user)
+	return tr.T(msgLanguageChanged, i18n.Args{"language": tr.T(msgLanguageName)})
+}
+
+// showSettings показывает текущие настройки и выбор языка.
+func (b *Bot) showSettings(ctx context.Context, key entity.DialogueKey) {
+	tr := i18n.FromContext(ctx)
+	text := tr.T(msgSettings, i18n.Args{"language": tr.T(msgLanguageName)})
+	b.sendKeyboard(ctx, key, text, languageKeyboard(b.catalog))
+}
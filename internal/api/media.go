@@ -0,0 +1,90 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/i18n"
+)
+
+// defaultMaxImageSize — предел Bot API на скачивание файлов ботом (20 МБ).
+const defaultMaxImageSize = 20 << 20
+
+var (
+	errUnsupportedDocument = errors.New("unsupported document type")
+	errImageTooLarge       = errors.New("image is too large")
+)
+
+// supportedImageTypes — MIME-типы файлов, которые принимаются как изображения.
+// HEIC/HEIF принимаются, но декодируются только если их поддерживает сборка OpenCV.
+var supportedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/tiff": true,
+	"image/webp": true,
+	"image/heic": true,
+	"image/heif": true,
+}
+
+// extractImage извлекает изображение из сообщения и скачивает его.
+// Файл-документ предпочтительнее сжатого фото: Telegram пережимает фото до ~1280px.
+func (b *Bot) extractImage(ctx context.Context, msg *tgbotapi.Message) ([]byte, error) {
+	if msg.Document != nil {
+		return b.extractDocumentImage(ctx, msg.Document)
+	}
+	if len(msg.Photo) == 0 {
+		return nil, nil
+	}
+
+	photo := msg.Photo[len(msg.Photo)-1]
+	return b.messenger.GetFile(ctx, photo.FileID)
+}
+
+// extractDocumentImage проверяет тип и размер файла и скачивает оригинал.
+func (b *Bot) extractDocumentImage(ctx context.Context, doc *tgbotapi.Document) ([]byte, error) {
+	mimeType := strings.ToLower(doc.MimeType)
+	if !supportedImageTypes[mimeType] {
+		return nil, fmt.Errorf("%w: %q", errUnsupportedDocument, doc.MimeType)
+	}
+
+	limit := b.maxImageSize()
+	if int64(doc.FileSize) > limit {
+		return nil, fmt.Errorf("%w: %d bytes", errImageTooLarge, doc.FileSize)
+	}
+
+	data, err := b.messenger.GetFile(ctx, doc.FileID)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: %d bytes", errImageTooLarge, len(data))
+	}
+	return data, nil
+}
+
+func (b *Bot) maxImageSize() int64 {
+	if b.options.MaxImageSize > 0 {
+		return b.options.MaxImageSize
+	}
+	return defaultMaxImageSize
+}
+
+// reportImageError объясняет пользователю, почему изображение не принято.
+func (b *Bot) reportImageError(ctx context.Context, key entity.DialogueKey, err error) {
+	slog.WarnContext(ctx, "Error extracting image", "err", err)
+
+	switch {
+	case errors.Is(err, errUnsupportedDocument):
+		b.sendMessage(ctx, key, t(ctx, msgUnsupportedDocument))
+	case errors.Is(err, errImageTooLarge):
+		b.sendMessage(ctx, key, t(ctx, msgImageTooLarge, i18n.Args{"size": b.maxImageSize() >> 20}))
+	default:
+		b.sendMessage(ctx, key, t(ctx, msgProcessingError))
+	}
+}
@@ -1,36 +1,137 @@
 package telegram
 
+import (
+	"context"
+
+	"vision-bot/internal/i18n"
+)
+
+// Ключи сообщений каталога i18n (тексты — в internal/i18n/locales).
 const (
-	msgStart = `👋 Привет! Я бот для поиска дефектов на фотографиях деталей.
-
-📸 Отправьте мне фото детали, и я попробую найти и описать дефекты.
-
-📋 Команды:
-/check — начать проверку детали
-/help — справка
-/cancel — отменить текущую операцию`
-
-	msgHelp = `ℹ️ Как пользоваться ботом:
-
-1️⃣ Отправьте фото детали
-2️⃣ Бот проанализирует изображение
-3️⃣ Вы получите результат: текст + фото с подсветкой дефектов
-
-💡 Рекомендации:
-• Снимайте при хорошем освещении
-• Используйте однотонный фон
-• Фото должно быть чётким
-
-📋 Команды:
-/check — начать проверку
-/cancel — отменить операцию`
-
-	msgCancelled        = "❌ Операция отменена. Отправьте /check для новой проверки."
-	msgProcessing       = "⏳ Обрабатываю изображение..."
-	msgDefectsFound     = "⚠️ Обнаружены дефекты."
-	msgNoDefects        = "✅ Дефекты не обнаружены."
-	msgProcessingError  = "⚠️ Не удалось обработать изображение. Попробуйте сделать другое фото."
-	msgAwaitingOriginal = "📸 Отправьте оригинальное фото детали."
-	msgAwaitingDefect   = "📸 Отправьте фото дефекта (или участка с дефектом)."
-	msgOnlyCancel       = "Сейчас доступна только команда /cancel."
+	msgStart            = "start"
+	msgHelp             = "help"
+	msgCancelled        = "cancelled"
+	msgProcessing       = "processing"
+	msgDefectsFound     = "defects_found"
+	msgNoDefects        = "no_defects"
+	msgProcessingError  = "processing_error"
+	msgAwaitingOriginal = "awaiting_original"
+	msgAwaitingDefect   = "awaiting_defect"
+	msgAwaitingQuick    = "awaiting_quick"
+	msgQuickNote        = "quick_note"
+	msgQuickPartMissing = "quick_part_not_found"
+
+	msgAlbumReferenceFirstOnly = "album_reference_first_only"
+	msgAlbumQuickFirstOnly     = "album_quick_first_only"
+	msgGoldenModelBuilt        = "golden_model_built"
+	msgGoldenModelFailed       = "golden_model_failed"
+	msgBatchHeader             = "batch_header"
+	msgBatchItemOK             = "batch_item_ok"
+	msgBatchItemDefects        = "batch_item_defects"
+	msgBatchItemLimit          = "batch_item_limit"
+	msgBatchItemError          = "batch_item_error"
+	msgBatchTotals             = "batch_totals"
+	msgBatchPartCaption        = "batch_part_caption"
+
+	msgNoReference             = "no_reference"
+	msgSettings                = "settings"
+	msgHistoryEmpty            = "history_empty"
+	msgHistoryHeader           = "history_header"
+	msgHistoryItemOK           = "history_item_ok"
+	msgHistoryItemDefects      = "history_item_defects"
+	msgHistoryFalsePositive    = "history_false_positive"
+	msgMarkedFalsePositive     = "marked_false_positive"
+	msgHistoryApproved         = "history_approved"
+	msgHistoryQuick            = "history_quick"
+	msgApproving               = "approving"
+	msgApproved                = "approved"
+	msgApproveFailed           = "approve_failed"
+	msgApproveReferenceChanged = "approve_reference_changed"
+	msgRecordNotFound          = "record_not_found"
+	msgComparisonReference     = "comparison_reference"
+	msgComparisonCurrent       = "comparison_current"
+
+	btnNewCheck       = "btn_new_check"
+	btnReuseReference = "btn_reuse_reference"
+	btnQuickCheck     = "btn_quick_check"
+	btnQuickAgain     = "btn_quick_again"
+	btnHistory        = "btn_history"
+	btnSettings       = "btn_settings"
+	btnCancel         = "btn_cancel"
+	btnCheckAnother   = "btn_check_another"
+	btnFalsePositive  = "btn_false_positive"
+	btnApprove        = "btn_approve"
+	btnCompare        = "btn_compare"
+	btnHistoryCompare = "btn_history_compare"
+
+	msgStillProcessing   = "still_processing"
+	msgActionUnavailable = "action_unavailable"
+	msgSlowDown          = "slow_down"
+	msgSlowDownChat      = "slow_down_chat"
+
+	msgIdleReminderOriginal = "idle_reminder_original"
+	msgIdleReminderPart     = "idle_reminder_part"
+	msgIdleExpired          = "idle_expired"
+
+	msgSessionPrompt  = "session_prompt"
+	msgSessionNext    = "session_next"
+	msgSessionDone    = "session_done"
+	msgSessionExpired = "session_expired"
+	msgAgeJustNow     = "age_just_now"
+	msgAgeMinutes     = "age_minutes"
+	msgAgeHours       = "age_hours"
+	msgChecksOf       = "checks_of"
+
+	btnNewReference = "btn_new_reference"
+	btnDone         = "btn_done"
+
+	msgUnsupportedDocument = "unsupported_document"
+	msgImageTooLarge       = "image_too_large"
+	msgImageNotDecoded     = "image_not_decoded"
+
+	msgLanguageName    = "language_name"
+	msgLanguagePrompt  = "language_prompt"
+	msgLanguageChanged = "language_changed"
+
+	msgAccessDenied       = "access_denied"
+	msgPermissionDenied   = "permission_denied"
+	msgReferenceDenied    = "reference_denied"
+	msgGrantUsage         = "grant_usage"
+	msgRoleGranted        = "role_granted"
+	msgRoleRevoked        = "role_revoked"
+	msgRoleBootstrapAdmin = "role_bootstrap_admin"
+	msgStats              = "stats"
+
+	msgProfileRejected = "profile_rejected"
+
+	msgZonesPrompt      = "zones_prompt"
+	msgZonesSaved       = "zones_saved"
+	msgZonesCleared     = "zones_cleared"
+	msgZonesImported    = "zones_imported"
+	msgZonesInvalid     = "zones_invalid"
+	msgZoneCellChanged  = "zone_cell_changed"
+	msgZoneKindNormal   = "zone_kind_normal"
+	msgZoneKindIgnore   = "zone_kind_ignore"
+	msgZoneKindCritical = "zone_kind_critical"
+
+	msgCalibrateUsage    = "calibrate_usage"
+	msgCalibrated        = "calibrated"
+	msgCalibrationStatus = "calibration_status"
+	msgCalibrationNone   = "calibration_none"
+	msgCalibrationFailed = "calibration_failed"
+	msgWithinTolerance   = "within_tolerance"
+
+	msgPartsSummary = "parts_summary"
+	msgPartOK       = "part_ok"
+	msgPartDefects  = "part_defects"
+	msgPartMismatch = "part_mismatch"
+
+	btnZones      = "btn_zones"
+	btnZonesClear = "btn_zones_clear"
+	btnZonesDone  = "btn_zones_done"
 )
+
+// t переводит сообщение на язык пользователя, обрабатывающего запрос.
+func t(ctx context.Context, key string, args ...i18n.Args) string {
+	return i18n.FromContext(ctx).T(key, args...)
+}
@@ -0,0 +1,32 @@
+package telegram
+
+import (
+	"context"
+	"log/slog"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/i18n"
+)
+
+// NotifyAdmins пишет администраторам в личный чат сообщение key на языке
+// каждого из них. Используется для служебных событий вне диалогов.
+func (b *Bot) NotifyAdmins(ctx context.Context, key string, args i18n.Args) {
+	admins, err := b.container.AccessService.Admins(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "List admins error", "err", err)
+	}
+	for _, id := range admins {
+		tr := b.profileTranslator(ctx, id)
+		b.sendMessage(ctx, entity.PrivateDialogue(id), tr.T(key, args))
+	}
+}
+
+// ReportProfileRejected сообщает администраторам, что файл профиля детектора
+// не прошёл проверку и действует прежняя версия.
+func (b *Bot) ReportProfileRejected(ctx context.Context, profile, version string, err error) {
+	b.NotifyAdmins(ctx, msgProfileRejected, i18n.Args{
+		"profile": profile,
+		"version": version,
+		"error":   err.Error(),
+	})
+}
@@ -0,0 +1,110 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// pollingTimeout — сколько Telegram держит запрос getUpdates без новых обновлений.
+	pollingTimeout = 60
+	// pollingRetryDelay — пауза после ошибки getUpdates.
+	pollingRetryDelay = 3 * time.Second
+)
+
+// pollingSource получает обновления через long polling (getUpdates).
+// Ответ разбирается самостоятельно: GetUpdatesChan теряет поля тем форума.
+type pollingSource struct {
+	api    *tgbotapi.BotAPI
+	cancel context.CancelFunc
+}
+
+func newPollingSource(api *tgbotapi.BotAPI) *pollingSource {
+	return &pollingSource{api: api}
+}
+
+// Start снимает вебхук (иначе getUpdates не работает) и запускает опрос.
+func (s *pollingSource) Start(ctx context.Context) (<-chan incomingUpdate, error) {
+	if _, err := s.api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		return nil, fmt.Errorf("delete webhook: %w", err)
+	}
+
+	pollCtx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+
+	updates := make(chan incomingUpdate, 100)
+	go s.poll(pollCtx, updates)
+	return updates, nil
+}
+
+// Stop прекращает опрос Telegram. Уже начатый запрос getUpdates
+// завершится сам, его результат будет отброшен.
+func (s *pollingSource) Stop(ctx context.Context) error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return nil
+}
+
+// poll запрашивает обновления, пока не отменён ctx.
+func (s *pollingSource) poll(ctx context.Context, updates chan<- incomingUpdate) {
+	defer close(updates)
+
+	offset := 0
+	for ctx.Err() == nil {
+		batch, err := s.fetch(offset)
+		if err != nil {
+			slog.WarnContext(ctx, "Get updates error", "err", err, "retry_in", pollingRetryDelay)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollingRetryDelay):
+			}
+			continue
+		}
+
+		for _, update := range batch {
+			if update.UpdateID >= offset {
+				offset = update.UpdateID + 1
+			}
+			select {
+			case updates <- update:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// fetch выполняет один запрос getUpdates.
+func (s *pollingSource) fetch(offset int) ([]incomingUpdate, error) {
+	params := tgbotapi.Params{}
+	params.AddNonZero("offset", offset)
+	params.AddNonZero("timeout", pollingTimeout)
+
+	resp, err := s.api.MakeRequest("getUpdates", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(resp.Result, &raw); err != nil {
+		return nil, fmt.Errorf("decode updates: %w", err)
+	}
+
+	updates := make([]incomingUpdate, 0, len(raw))
+	for _, data := range raw {
+		update, err := decodeUpdate(data)
+		if err != nil {
+			slog.Warn("Skipping malformed update", "err", err)
+			continue
+		}
+		updates = append(updates, update)
+	}
+	return updates, nil
+}
@@ -0,0 +1,126 @@
+package telegram
+
+import (
+	"context"
+	"log/slog"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	app "vision-bot/internal/application"
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/i18n"
+	"vision-bot/internal/logging"
+)
+
+// beginQuick начинает проверку без эталона: бот ждёт один снимок детали.
+func (b *Bot) beginQuick(ctx context.Context, key entity.DialogueKey) {
+	if _, err := b.container.InspectionService.StartQuick(ctx, key); err != nil {
+		b.reportDialogueError(ctx, key, "StartQuick", err)
+		return
+	}
+	b.sendKeyboard(ctx, key, t(ctx, msgAwaitingQuick), cancelKeyboard(i18n.FromContext(ctx)))
+}
+
+// handleAwaitingQuick обрабатывает сообщения при ожидании фото для проверки без эталона.
+func (b *Bot) handleAwaitingQuick(ctx context.Context, key entity.DialogueKey, msg *tgbotapi.Message) {
+	if msg.IsCommand() && msg.Command() == cmdCancel {
+		b.cancelCheck(ctx, key)
+		return
+	}
+
+	if b.throttled(ctx, key, msg) {
+		return
+	}
+	ctx = logging.WithInspectionID(ctx, logging.NewInspectionID())
+
+	photoData, err := b.extractImage(ctx, msg)
+	if err != nil {
+		b.reportImageError(ctx, key, err)
+		return
+	}
+	if len(photoData) == 0 {
+		b.sendKeyboard(ctx, key, t(ctx, msgAwaitingQuick), cancelKeyboard(i18n.FromContext(ctx)))
+		return
+	}
+	slog.InfoContext(ctx, "Quick photo received", "bytes", len(photoData), "media_group_id", msg.MediaGroupID)
+
+	if _, err := b.container.InspectionService.AcceptQuickPhoto(ctx, key); err != nil {
+		b.reportDialogueError(ctx, key, "AcceptQuickPhoto", err)
+		return
+	}
+	if msg.MediaGroupID != "" {
+		b.startAlbum(msg.MediaGroupID, &pendingAlbum{
+			key:     key,
+			purpose: albumQuick,
+			ctx:     detach(b.jobCtx, ctx),
+			photos:  [][]byte{photoData},
+		})
+	}
+
+	b.sendMessage(ctx, key, t(ctx, msgProcessing))
+	jobCtx := detach(b.jobCtx, ctx)
+	b.jobs.Add(1)
+	go func() {
+		defer b.jobs.Done()
+		b.processQuickPhoto(jobCtx, key, photoData)
+	}()
+}
+
+// processQuickPhoto проверяет деталь без эталона и отправляет результат.
+func (b *Bot) processQuickPhoto(ctx context.Context, key entity.DialogueKey, photo []byte) {
+	release, err := b.container.RateLimiter.Acquire(ctx, key.UserID)
+	if err != nil {
+		slog.WarnContext(ctx, "Inspection slot not acquired", "err", err)
+		b.completeQuick(ctx, key)
+		b.sendMessage(ctx, key, t(ctx, msgProcessingError))
+		return
+	}
+	defer release()
+
+	result, err := b.container.InspectionService.ProcessQuickPhoto(ctx, key, photo, i18n.FromContext(ctx).Lang())
+	b.completeQuick(ctx, key)
+	if err != nil {
+		reason := app.ClassifyInspectionError(err)
+		slog.ErrorContext(ctx, "ProcessQuickPhoto failed", "reason", reason, "err", err)
+		switch reason {
+		case "decode":
+			b.sendMessage(ctx, key, t(ctx, msgImageNotDecoded))
+		case "part_not_detected":
+			b.sendMessage(ctx, key, t(ctx, msgQuickPartMissing))
+		default:
+			b.sendMessage(ctx, key, t(ctx, msgProcessingError))
+		}
+		return
+	}
+
+	slog.InfoContext(ctx, "ProcessQuickPhoto completed",
+		"record_id", result.RecordID,
+		"has_defects", result.Result.HasDefects,
+		"defects", len(result.Result.Defects),
+	)
+
+	tr := i18n.FromContext(ctx)
+	text := tr.T(msgNoDefects)
+	if result.Result.HasDefects {
+		text = tr.N(msgDefectsFound, len(result.Result.Defects))
+		if result.Description != "" {
+			text += "\n" + result.Description
+		}
+		if len(result.Highlighted) > 0 {
+			b.sendPhoto(ctx, key, result.Highlighted)
+		}
+	}
+	if tolerated := len(result.Result.Tolerated); tolerated > 0 {
+		text += "\n" + tr.N(msgWithinTolerance, tolerated)
+	}
+	text += "\n\n" + tr.T(msgQuickNote)
+
+	b.sendKeyboard(ctx, key, text, quickResultKeyboard(tr, result.RecordID, result.Result.HasDefects))
+}
+
+// completeQuick возвращает пользователя в главное меню после проверки без эталона.
+func (b *Bot) completeQuick(ctx context.Context, key entity.DialogueKey) {
+	if _, err := b.container.InspectionService.CompleteQuick(ctx, key); err != nil {
+		slog.ErrorContext(ctx, "CompleteQuick error", "err", err)
+	}
+}
@@ -0,0 +1,56 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	app "vision-bot/internal/application"
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/i18n"
+)
+
+// throttled учитывает присланное на проверку фото в ограничении частоты.
+// Если лимит исчерпан, просит подождать (один раз за серию отказов)
+// и возвращает true — фото не обрабатывается.
+func (b *Bot) throttled(ctx context.Context, key entity.DialogueKey, msg *tgbotapi.Message) bool {
+	if msg.Photo == nil && msg.Document == nil {
+		return false
+	}
+
+	role, _ := roleFrom(ctx)
+	var limitErr *app.RateLimitError
+	if err := b.container.RateLimiter.Allow(key, role); !errors.As(err, &limitErr) {
+		return false
+	}
+
+	slog.InfoContext(ctx, "Rate limited", "scope", limitErr.Scope, "retry_after", limitErr.RetryAfter)
+	if !limitErr.Notify {
+		return true
+	}
+
+	text := msgSlowDown
+	if limitErr.Scope == app.RateScopeChat {
+		text = msgSlowDownChat
+	}
+	seconds := int(math.Max(1, math.Ceil(limitErr.RetryAfter.Seconds())))
+	b.sendMessage(ctx, key, t(ctx, text, i18n.Args{"seconds": seconds}))
+	return true
+}
+
+// acquireSlot ждёт свободного слота для проверки. Если ожидание прервано
+// остановкой бота, завершает проверку в диалоге и сообщает об ошибке.
+func (b *Bot) acquireSlot(ctx context.Context, key entity.DialogueKey) (func(), bool) {
+	release, err := b.container.RateLimiter.Acquire(ctx, key.UserID)
+	if err == nil {
+		return release, true
+	}
+
+	slog.WarnContext(ctx, "Inspection slot not acquired", "err", err)
+	b.completeCheck(ctx, key)
+	b.sendMessage(ctx, key, t(ctx, msgProcessingError))
+	return nil, false
+}
@@ -0,0 +1,57 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// incomingUpdate — обновление Telegram вместе с полями, которых нет в tgbotapi v5.5.1.
+type incomingUpdate struct {
+	tgbotapi.Update
+	// ThreadID — тема форума, в которой написано сообщение (или сообщение
+	// с нажатой кнопкой); 0 — чат без тем.
+	ThreadID int
+}
+
+// topicFields — поля тем форума из Bot API 6.3.
+type topicFields struct {
+	MessageThreadID int  `json:"message_thread_id"`
+	IsTopicMessage  bool `json:"is_topic_message"`
+}
+
+// threadID возвращает тему форума. В обычных супергруппах message_thread_id
+// бывает и у ответов, но отправлять в него можно только в форумах.
+func (f *topicFields) threadID() int {
+	if f == nil || !f.IsTopicMessage {
+		return 0
+	}
+	return f.MessageThreadID
+}
+
+// decodeUpdate разбирает обновление из JSON Bot API.
+func decodeUpdate(data []byte) (incomingUpdate, error) {
+	var update incomingUpdate
+	if err := json.Unmarshal(data, &update.Update); err != nil {
+		return update, fmt.Errorf("decode update: %w", err)
+	}
+
+	var topics struct {
+		Message       *topicFields `json:"message"`
+		CallbackQuery *struct {
+			Message *topicFields `json:"message"`
+		} `json:"callback_query"`
+	}
+	if err := json.Unmarshal(data, &topics); err != nil {
+		return update, fmt.Errorf("decode update topics: %w", err)
+	}
+
+	switch {
+	case topics.Message != nil:
+		update.ThreadID = topics.Message.threadID()
+	case topics.CallbackQuery != nil:
+		update.ThreadID = topics.CallbackQuery.Message.threadID()
+	}
+	return update, nil
+}
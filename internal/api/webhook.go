@@ -0,0 +1,148 @@
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// secretTokenHeader — заголовок, в котором Telegram передаёт секрет вебхука.
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// maxWebhookBodySize ограничивает размер тела входящего обновления.
+const maxWebhookBodySize = 1 << 20
+
+// WebhookOptions задаёт параметры приёма обновлений через вебхук.
+type WebhookOptions struct {
+	// ListenAddr — адрес, на котором слушает HTTP-сервер (например, ":8443").
+	ListenAddr string
+	// Path — путь обработчика вебхука.
+	Path string
+	// URL — публичный адрес вебхука, который регистрируется в Telegram.
+	URL string
+	// SecretToken проверяется в каждом входящем запросе.
+	SecretToken string
+	// CertFile и KeyFile включают TLS; сертификат загружается в Telegram (self-signed).
+	CertFile string
+	KeyFile  string
+}
+
+// webhookSource принимает обновления через HTTP-сервер.
+type webhookSource struct {
+	api     *tgbotapi.BotAPI
+	options WebhookOptions
+	server  *http.Server
+}
+
+func newWebhookSource(api *tgbotapi.BotAPI, options WebhookOptions) *webhookSource {
+	return &webhookSource{api: api, options: options}
+}
+
+// Start регистрирует вебхук в Telegram и запускает HTTP-сервер.
+func (s *webhookSource) Start(ctx context.Context) (<-chan incomingUpdate, error) {
+	if err := s.register(); err != nil {
+		return nil, err
+	}
+
+	updates := make(chan incomingUpdate, 100)
+	mux := http.NewServeMux()
+	mux.Handle(s.options.Path, newWebhookHandler(s.options.SecretToken, updates))
+
+	listener, err := net.Listen("tcp", s.options.ListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("listen webhook: %w", err)
+	}
+
+	s.server = &http.Server{
+		Handler:     mux,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		var err error
+		if s.options.CertFile != "" {
+			err = s.server.ServeTLS(listener, s.options.CertFile, s.options.KeyFile)
+		} else {
+			err = s.server.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Webhook server error", "err", err)
+		}
+	}()
+
+	slog.Info("Webhook is listening", "addr", s.options.ListenAddr, "path", s.options.Path)
+	return updates, nil
+}
+
+// Stop останавливает HTTP-сервер; вебхук в Telegram остаётся зарегистрированным,
+// чтобы обновления копились до следующего запуска.
+func (s *webhookSource) Stop(ctx context.Context) error {
+	if s.server == nil {
+		return nil
+	}
+	return s.server.Shutdown(ctx)
+}
+
+// register вызывает setWebhook с секретом и, при необходимости, сертификатом.
+func (s *webhookSource) register() error {
+	params := tgbotapi.Params{"url": s.options.URL}
+	params.AddNonEmpty("secret_token", s.options.SecretToken)
+
+	var err error
+	if s.options.CertFile != "" {
+		_, err = s.api.UploadFiles("setWebhook", params, []tgbotapi.RequestFile{{
+			Name: "certificate",
+			Data: tgbotapi.FilePath(s.options.CertFile),
+		}})
+	} else {
+		_, err = s.api.MakeRequest("setWebhook", params)
+	}
+	if err != nil {
+		return fmt.Errorf("set webhook: %w", err)
+	}
+	return nil
+}
+
+// newWebhookHandler проверяет секрет, разбирает обновление и передаёт его в общий цикл.
+// Без секрета обработчик отклоняет все запросы: иначе любой, кто видит порт,
+// мог бы прислать обновление от чужого имени в обход списка доступа.
+func newWebhookHandler(secretToken string, updates chan<- incomingUpdate) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		got := r.Header.Get(secretTokenHeader)
+		if secretToken == "" || subtle.ConstantTimeCompare([]byte(got), []byte(secretToken)) != 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		update, err := decodeUpdate(body)
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case updates <- update:
+			w.WriteHeader(http.StatusOK)
+		case <-r.Context().Done():
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		}
+	})
+}
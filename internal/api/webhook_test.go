@@ -0,0 +1,114 @@
+package telegram
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func postUpdate(t *testing.T, handler http.Handler, fixture string, secret string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body, err := os.ReadFile(fixture)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/telegram/webhook", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(secretTokenHeader, secret)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWebhookHandler_CommandUpdate(t *testing.T) {
+	updates := make(chan incomingUpdate, 1)
+	handler := newWebhookHandler("s3cret", updates)
+
+	rec := postUpdate(t, handler, "testdata/update_command.json", "s3cret")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	update := <-updates
+	require.Equal(t, 840213577, update.UpdateID)
+	require.NotNil(t, update.Message)
+	require.True(t, update.Message.IsCommand())
+	require.Equal(t, cmdCheck, update.Message.Command())
+	require.Equal(t, int64(100500), update.Message.From.ID)
+}
+
+func TestWebhookHandler_PhotoUpdate(t *testing.T) {
+	updates := make(chan incomingUpdate, 1)
+	handler := newWebhookHandler("s3cret", updates)
+
+	rec := postUpdate(t, handler, "testdata/update_photo.json", "s3cret")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	update := <-updates
+	require.Len(t, update.Message.Photo, 2)
+	require.Equal(t, "AgACAgIAAxkBAAIBnWZ-large", update.Message.Photo[1].FileID)
+}
+
+func TestWebhookHandler_ForumTopicUpdate(t *testing.T) {
+	updates := make(chan incomingUpdate, 1)
+	handler := newWebhookHandler("s3cret", updates)
+
+	rec := postUpdate(t, handler, "testdata/update_topic_command.json", "s3cret")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	update := <-updates
+	require.Equal(t, 57, update.ThreadID)
+	require.True(t, update.Message.Chat.IsSuperGroup())
+	require.Equal(t, cmdCheck, update.Message.Command())
+}
+
+func TestDecodeUpdate_ReplyOutsideForumHasNoTopic(t *testing.T) {
+	update, err := decodeUpdate([]byte(`{"update_id": 1, "message": {"message_id": 5, "message_thread_id": 3,
+		"chat": {"id": -100, "type": "supergroup"}, "text": "ok"}}`))
+	require.NoError(t, err)
+	require.Equal(t, 0, update.ThreadID)
+	require.Equal(t, tgbotapi.Update{UpdateID: 1, Message: update.Message}, update.Update)
+}
+
+func TestWebhookHandler_RejectsWrongSecret(t *testing.T) {
+	updates := make(chan incomingUpdate, 1)
+	handler := newWebhookHandler("s3cret", updates)
+
+	rec := postUpdate(t, handler, "testdata/update_command.json", "wrong")
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = postUpdate(t, handler, "testdata/update_command.json", "")
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Empty(t, updates)
+}
+
+func TestWebhookHandler_RejectsInvalidRequests(t *testing.T) {
+	updates := make(chan incomingUpdate, 1)
+	handler := newWebhookHandler("s3cret", updates)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/telegram/webhook", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/telegram/webhook", bytes.NewBufferString("{not json"))
+	req.Header.Set(secretTokenHeader, "s3cret")
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Empty(t, updates)
+}
+
+func TestWebhookHandler_RejectsAllWithoutSecret(t *testing.T) {
+	updates := make(chan incomingUpdate, 1)
+	handler := newWebhookHandler("", updates)
+
+	rec := postUpdate(t, handler, "testdata/update_command.json", "")
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Empty(t, updates)
+}
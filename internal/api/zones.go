@@ -0,0 +1,205 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	app "vision-bot/internal/application"
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/domain/port"
+	"vision-bot/internal/i18n"
+)
+
+// Размер сетки, которой зоны размечаются в диалоге.
+const (
+	zoneGridRows = 4
+	zoneGridCols = 4
+)
+
+// maxZonesFileSize — предел размера JSON-файла с зонами.
+const maxZonesFileSize = 256 << 10
+
+// isZonesDocument сообщает, похож ли документ на JSON с зонами эталона.
+func isZonesDocument(doc *tgbotapi.Document) bool {
+	return doc != nil && (strings.EqualFold(doc.MimeType, "application/json") ||
+		strings.HasSuffix(strings.ToLower(doc.FileName), ".json"))
+}
+
+// beginZones показывает превью эталона и сетку для разметки зон.
+func (b *Bot) beginZones(ctx context.Context, key entity.DialogueKey) {
+	service := b.container.InspectionService
+	if !service.HasReference(key) {
+		b.sendMessage(ctx, key, t(ctx, msgNoReference))
+		return
+	}
+	if session := service.ActiveSession(key); session != nil && len(session.Thumbnail) > 0 {
+		b.sendPhoto(ctx, key, session.Thumbnail)
+	}
+
+	tr := i18n.FromContext(ctx)
+	b.sendKeyboard(ctx, key, tr.T(msgZonesPrompt), zonesKeyboard(tr, service.Zones(key)))
+}
+
+// toggleZoneCell переключает режим ячейки сетки и обновляет клавиатуру на месте.
+// Возвращает текст ответа на нажатие.
+func (b *Bot) toggleZoneCell(ctx context.Context, key entity.DialogueKey, query *tgbotapi.CallbackQuery, cell string) string {
+	row, col, ok := parseGridCell(cell)
+	if !ok {
+		slog.WarnContext(ctx, "Invalid zone cell", "cell", cell)
+		return ""
+	}
+
+	zones, err := b.container.InspectionService.CycleZoneCell(ctx, key, row, col, zoneGridRows, zoneGridCols)
+	if errors.Is(err, app.ErrNoReference) {
+		return t(ctx, msgNoReference)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "CycleZoneCell error", "cell", cell, "err", err)
+		return t(ctx, msgProcessingError)
+	}
+
+	tr := i18n.FromContext(ctx)
+	if query.Message != nil {
+		keyboard := visibleKeyboard(ctx, zonesKeyboard(tr, zones))
+		if err := b.messenger.EditKeyboard(ctx, key.ChatID, query.Message.MessageID, tr.T(msgZonesPrompt), keyboard); err != nil {
+			slog.ErrorContext(ctx, "Error editing zones keyboard", "err", err)
+		}
+	}
+	return tr.T(msgZoneCellChanged, i18n.Args{"cell": cell, "kind": zoneKindName(tr, zoneKindAt(zones, cell))})
+}
+
+// clearZones снимает все зоны эталона.
+func (b *Bot) clearZones(ctx context.Context, key entity.DialogueKey) {
+	if err := b.container.InspectionService.SetZones(ctx, key, nil); err != nil {
+		b.reportZonesError(ctx, key, err)
+		return
+	}
+	b.sendMessage(ctx, key, t(ctx, msgZonesCleared))
+}
+
+// finishZones подводит итог разметки: сколько участков не проверяется и сколько проверяется строже.
+func (b *Bot) finishZones(ctx context.Context, key entity.DialogueKey) {
+	service := b.container.InspectionService
+	if !service.HasReference(key) {
+		b.sendMessage(ctx, key, t(ctx, msgNoReference))
+		return
+	}
+
+	ignored, critical := 0, 0
+	for _, zone := range service.Zones(key) {
+		switch zone.Kind {
+		case entity.ZoneIgnore:
+			ignored++
+		case entity.ZoneCritical:
+			critical++
+		}
+	}
+	b.sendMessage(ctx, key, t(ctx, msgZonesSaved, i18n.Args{"ignored": ignored, "critical": critical}))
+}
+
+// importZones загружает зоны эталона из JSON-документа.
+func (b *Bot) importZones(ctx context.Context, key entity.DialogueKey, doc *tgbotapi.Document) {
+	if doc.FileSize > maxZonesFileSize {
+		b.sendMessage(ctx, key, t(ctx, msgZonesInvalid, i18n.Args{"error": fmt.Sprintf("file is larger than %d KB", maxZonesFileSize>>10)}))
+		return
+	}
+	data, err := b.messenger.GetFile(ctx, doc.FileID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error downloading zones file", "err", err)
+		b.sendMessage(ctx, key, t(ctx, msgProcessingError))
+		return
+	}
+
+	zones, err := app.ParseZones(data)
+	if err != nil {
+		slog.InfoContext(ctx, "Zones file rejected", "err", err)
+		b.sendMessage(ctx, key, t(ctx, msgZonesInvalid, i18n.Args{"error": err.Error()}))
+		return
+	}
+	if err := b.container.InspectionService.SetZones(ctx, key, zones); err != nil {
+		b.reportZonesError(ctx, key, err)
+		return
+	}
+	b.sendMessage(ctx, key, t(ctx, msgZonesImported, i18n.Args{"count": len(zones)}))
+}
+
+// reportZonesError сообщает, почему зоны не сохранены.
+func (b *Bot) reportZonesError(ctx context.Context, key entity.DialogueKey, err error) {
+	if errors.Is(err, app.ErrNoReference) {
+		b.sendMessage(ctx, key, t(ctx, msgNoReference))
+		return
+	}
+	slog.ErrorContext(ctx, "SetZones error", "err", err)
+	b.sendMessage(ctx, key, t(ctx, msgProcessingError))
+}
+
+// zonesKeyboard — сетка ячеек с отметкой режима и кнопки «очистить» и «готово».
+func zonesKeyboard(tr i18n.Translator, zones []entity.Zone) port.Keyboard {
+	keyboard := make(port.Keyboard, 0, zoneGridRows+1)
+	for row := range zoneGridRows {
+		buttons := make([]port.Button, 0, zoneGridCols)
+		for col := range zoneGridCols {
+			cell := entity.GridCellName(row, col)
+			buttons = append(buttons, port.Button{
+				Text: zoneKindMark(zoneKindAt(zones, cell)) + cell,
+				Data: callbackData(cbZoneCell, cell),
+			})
+		}
+		keyboard = append(keyboard, buttons)
+	}
+	return append(keyboard, []port.Button{
+		{Text: tr.T(btnZonesClear), Data: cbZonesClear},
+		{Text: tr.T(btnZonesDone), Data: cbZonesDone},
+	})
+}
+
+// zoneKindAt возвращает режим зоны с именем ячейки; пустая строка — ячейка не размечена.
+func zoneKindAt(zones []entity.Zone, cell string) entity.ZoneKind {
+	for _, zone := range zones {
+		if zone.Name == cell {
+			return zone.Kind
+		}
+	}
+	return ""
+}
+
+// zoneKindMark — значок режима ячейки на кнопке.
+func zoneKindMark(kind entity.ZoneKind) string {
+	switch kind {
+	case entity.ZoneIgnore:
+		return "🚫"
+	case entity.ZoneCritical:
+		return "❗"
+	default:
+		return ""
+	}
+}
+
+// zoneKindName — название режима ячейки для ответа на нажатие.
+func zoneKindName(tr i18n.Translator, kind entity.ZoneKind) string {
+	switch kind {
+	case entity.ZoneIgnore:
+		return tr.T(msgZoneKindIgnore)
+	case entity.ZoneCritical:
+		return tr.T(msgZoneKindCritical)
+	default:
+		return tr.T(msgZoneKindNormal)
+	}
+}
+
+// parseGridCell разбирает имя ячейки вида «B3» в номер ряда и столбца.
+func parseGridCell(cell string) (row, col int, ok bool) {
+	if len(cell) != 2 {
+		return 0, 0, false
+	}
+	row, col = int(cell[0]-'A'), int(cell[1]-'1')
+	if row < 0 || row >= zoneGridRows || col < 0 || col >= zoneGridCols {
+		return 0, 0, false
+	}
+	return row, col, true
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"vision-bot/internal/domain/entity"
+)
+
+var (
+	// ErrAccessDenied возвращается, если у пользователя нет прав на действие.
+	ErrAccessDenied = errors.New("access denied")
+	// ErrBootstrapAdmin возвращается при попытке изменить роль администратора из конфигурации.
+	ErrBootstrapAdmin = errors.New("role of configured admin cannot be changed")
+)
+
+// AccessService решает, кто и что может делать в боте. Роли, выданные
+// командами, хранятся у пользователя в репозитории, остальное задаёт политика.
+type AccessService struct {
+	users  *UserService
+	policy entity.AccessPolicy
+}
+
+// NewAccessService создаёт сервис контроля доступа.
+func NewAccessService(users *UserService, policy entity.AccessPolicy) *AccessService {
+	return &AccessService{users: users, policy: policy}
+}
+
+// Policy возвращает политику доступа.
+func (s *AccessService) Policy() entity.AccessPolicy {
+	return s.policy
+}
+
+// Role возвращает действующую роль пользователя в чате: старшую из выданной
+// командой и назначенной политикой.
+func (s *AccessService) Role(ctx context.Context, userID, chatID int64) (entity.Role, error) {
+	role := s.policy.RoleFor(userID, chatID)
+	if s.policy.Open() || role == entity.RoleAdmin {
+		return role, nil
+	}
+
+	profile, err := s.users.Profile(ctx, userID)
+	if err != nil {
+		return entity.RoleNone, err
+	}
+	return role.Max(profile.Role), nil
+}
+
+// Authorize проверяет, что пользователь может выполнить действие.
+func (s *AccessService) Authorize(ctx context.Context, userID, chatID int64, perm entity.Permission) error {
+	role, err := s.Role(ctx, userID, chatID)
+	if err != nil {
+		return err
+	}
+	if !role.Can(perm) {
+		return fmt.Errorf("%w: user_id=%d role=%q permission=%s", ErrAccessDenied, userID, role, perm)
+	}
+	return nil
+}
+
+// Grant выдаёт пользователю роль. Роль хранится в профиле и действует во всех чатах;
+// профиль пользователя, ещё не писавшего боту, создаётся.
+func (s *AccessService) Grant(ctx context.Context, adminID, adminChatID, targetID int64, role entity.Role) (*entity.User, error) {
+	if err := s.Authorize(ctx, adminID, adminChatID, entity.PermManageUsers); err != nil {
+		return nil, err
+	}
+	if s.policy.IsAdmin(targetID) {
+		return nil, ErrBootstrapAdmin
+	}
+	return s.users.SetRole(ctx, targetID, role)
+}
+
+// Admins возвращает ID администраторов: из конфигурации и получивших роль командой.
+// Им бот пишет о служебных событиях в личный чат.
+func (s *AccessService) Admins(ctx context.Context) ([]int64, error) {
+	admins := slices.Clone(s.policy.Admins)
+	users, err := s.users.List(ctx)
+	if err != nil {
+		return admins, err
+	}
+	for _, user := range users {
+		if user.Role == entity.RoleAdmin {
+			admins = append(admins, user.ID)
+		}
+	}
+	slices.Sort(admins)
+	return slices.Compact(admins), nil
+}
+
+// Revoke отзывает выданную роль. Доступ по спискам политики при этом сохраняется.
+func (s *AccessService) Revoke(ctx context.Context, adminID, adminChatID, targetID int64) (*entity.User, error) {
+	return s.Grant(ctx, adminID, adminChatID, targetID, entity.RoleNone)
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/infrastructure/storage"
+)
+
+func TestAccessService_GrantAndRevoke(t *testing.T) {
+	users := NewUserService(storage.NewMemoryUserRepository())
+	access := NewAccessService(users, entity.AccessPolicy{Admins: []int64{1}, AllowedUsers: []int64{3}})
+	ctx := context.Background()
+
+	role, err := access.Role(ctx, 2, 2)
+	require.NoError(t, err)
+	require.Equal(t, entity.RoleNone, role)
+	require.ErrorIs(t, access.Authorize(ctx, 2, 2, entity.PermRunCheck), ErrAccessDenied)
+
+	// Выдавать роли может только администратор.
+	_, err = access.Grant(ctx, 3, 3, 2, entity.RoleInspector)
+	require.ErrorIs(t, err, ErrAccessDenied)
+
+	_, err = access.Grant(ctx, 1, 1, 2, entity.RoleInspector)
+	require.NoError(t, err)
+	require.NoError(t, access.Authorize(ctx, 2, 2, entity.PermMarkFalsePositive))
+
+	_, err = access.Revoke(ctx, 1, 1, 2)
+	require.NoError(t, err)
+	role, err = access.Role(ctx, 2, 2)
+	require.NoError(t, err)
+	require.Equal(t, entity.RoleNone, role)
+
+	// Отзыв не лишает доступа, выданного списком из конфигурации.
+	_, err = access.Revoke(ctx, 1, 1, 3)
+	require.NoError(t, err)
+	role, err = access.Role(ctx, 3, 3)
+	require.NoError(t, err)
+	require.Equal(t, entity.RoleOperator, role)
+
+	_, err = access.Revoke(ctx, 1, 1, 1)
+	require.ErrorIs(t, err, ErrBootstrapAdmin)
+}
+
+func TestAccessService_Admins(t *testing.T) {
+	users := NewUserService(storage.NewMemoryUserRepository())
+	access := NewAccessService(users, entity.AccessPolicy{Admins: []int64{5, 1}})
+	ctx := context.Background()
+
+	_, err := access.Grant(ctx, 1, 1, 3, entity.RoleAdmin)
+	require.NoError(t, err)
+	_, err = access.Grant(ctx, 1, 1, 4, entity.RoleInspector)
+	require.NoError(t, err)
+
+	admins, err := access.Admins(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 3, 5}, admins)
+}
+
+func TestStatsService_Collect(t *testing.T) {
+	users := NewUserService(storage.NewMemoryUserRepository())
+	inspections := NewInspectionService(users, storage.NewMemoryInspectionRepository(), nil, nil, entity.SessionPolicy{})
+	access := NewAccessService(users, entity.AccessPolicy{Admins: []int64{1}})
+	stats := NewStatsService(users, inspections, access, NewRateLimiter(entity.RateLimitPolicy{}))
+	ctx := context.Background()
+
+	_, err := users.BeginCheck(ctx, entity.DialogueKey{ChatID: 1, UserID: 1})
+	require.NoError(t, err)
+	_, err = access.Grant(ctx, 1, 1, 2, entity.RoleOperator)
+	require.NoError(t, err)
+
+	_, err = stats.Collect(ctx, 2, 2)
+	require.ErrorIs(t, err, ErrAccessDenied)
+
+	got, err := stats.Collect(ctx, 1, 1)
+	require.NoError(t, err)
+	require.Equal(t, 2, got.Users)
+	require.Equal(t, 1, got.Roles[entity.RoleAdmin])
+	require.Equal(t, 1, got.Roles[entity.RoleOperator])
+	require.Equal(t, 1, got.PendingChecks)
+	require.Equal(t, 0, got.Inspections.Total)
+}
@@ -0,0 +1,126 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/domain/port"
+)
+
+// ErrCalibrationUnsupported возвращается, если калибровщик не подключён.
+var ErrCalibrationUnsupported = errors.New("calibration is not supported")
+
+// SetCalibrator подключает перевод пикселей в миллиметры. Без него размеры
+// дефектов остаются в пикселях, а допуск профиля не применяется.
+func (s *InspectionService) SetCalibrator(calibrator port.Calibrator) {
+	s.calibrator = calibrator
+}
+
+// Calibration возвращает масштаб эталона; nil — эталон не откалиброван.
+func (s *InspectionService) Calibration(key entity.DialogueKey) *entity.Calibration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if session, ok := s.sessions[key]; ok && session.Calibration != nil {
+		calibration := *session.Calibration
+		return &calibration
+	}
+	return nil
+}
+
+// CalibrateReference калибрует эталон по известной длине детали в миллиметрах:
+// деталь на эталоне измеряется по большей стороне.
+func (s *InspectionService) CalibrateReference(ctx context.Context, key entity.DialogueKey, lengthMM float64) (*entity.Calibration, error) {
+	if s.calibrator == nil {
+		return nil, ErrCalibrationUnsupported
+	}
+	reference := s.reference(key)
+	if len(reference) == 0 {
+		return nil, ErrNoReference
+	}
+
+	calibration, err := s.calibrator.CalibrateByPart(ctx, reference, lengthMM)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storeCalibration(key, reference, calibration); err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Reference calibrated", "source", calibration.Source, "mm_per_px", calibration.MMPerPixel)
+	return calibration, nil
+}
+
+// storeCalibration сохраняет масштаб в сессию, если эталон за это время не сменился.
+func (s *InspectionService) storeCalibration(key entity.DialogueKey, reference []byte, calibration *entity.Calibration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[key]
+	if !ok || !bytes.Equal(session.Reference, reference) {
+		return ErrReferenceChanged
+	}
+	session.Calibration = calibration
+	session.MarkerSearched = true
+	return nil
+}
+
+// referenceCalibration возвращает масштаб эталона. Если его ещё нет, на эталоне
+// один раз ищется печатный маркер: найденный масштаб остаётся в сессии.
+func (s *InspectionService) referenceCalibration(ctx context.Context, key entity.DialogueKey, reference []byte) *entity.Calibration {
+	if s.calibrator == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	session, ok := s.sessions[key]
+	if !ok || !bytes.Equal(session.Reference, reference) {
+		s.mu.Unlock()
+		return nil
+	}
+	if session.Calibration != nil || session.MarkerSearched {
+		calibration := session.Calibration
+		s.mu.Unlock()
+		return calibration
+	}
+	session.MarkerSearched = true
+	s.mu.Unlock()
+
+	calibration := s.markerCalibration(ctx, reference)
+	if calibration == nil {
+		return nil
+	}
+	if err := s.storeCalibration(key, reference, calibration); err != nil {
+		return nil
+	}
+	slog.InfoContext(ctx, "Reference calibrated", "source", calibration.Source, "mm_per_px", calibration.MMPerPixel)
+	return calibration
+}
+
+// markerCalibration ищет маркер на снимке. Ошибка поиска не роняет проверку:
+// размеры просто остаются в пикселях.
+func (s *InspectionService) markerCalibration(ctx context.Context, image []byte) *entity.Calibration {
+	if s.calibrator == nil {
+		return nil
+	}
+	calibration, err := s.calibrator.CalibrateByMarker(ctx, image)
+	if err != nil {
+		slog.WarnContext(ctx, "Calibration marker search failed", "err", err)
+		return nil
+	}
+	return calibration
+}
+
+// measure переводит размеры дефектов в миллиметры и применяет допуск профиля.
+func (s *InspectionService) measure(ctx context.Context, result *entity.InspectionResult, calibration *entity.Calibration) {
+	if calibration == nil || s.calibrator == nil {
+		return
+	}
+	calibration.Measure(result)
+	s.calibrator.Tolerance().Apply(result)
+	if len(result.Tolerated) > 0 {
+		slog.DebugContext(ctx, "Defects within tolerance", "tolerated", len(result.Tolerated), "defects", len(result.Defects))
+	}
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/infrastructure/storage"
+)
+
+// sizedDetector находит в кадре 1000×500 скол 4×4 и царапину 100×2 пикселя.
+type sizedDetector struct {
+	goldenDetector
+}
+
+func (d *sizedDetector) result() *entity.InspectionResult {
+	return &entity.InspectionResult{
+		ImageWidth:  1000,
+		ImageHeight: 500,
+		Defects:     []entity.DefectArea{{Width: 4, Height: 4, Area: 16}, {Width: 100, Height: 2, Area: 150}},
+		HasDefects:  true,
+	}
+}
+
+func (d *sizedDetector) Inspect(ctx context.Context, imageData []byte) (*entity.InspectionResult, error) {
+	return d.result(), nil
+}
+
+func (d *sizedDetector) InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	return d.result(), nil
+}
+
+// fakeCalibrator меряет деталь длиной 400 пикселей на снимке 2000×1000
+// и находит маркер только на снимке "marker".
+type fakeCalibrator struct {
+	markerSearches int
+	tolerance      entity.Tolerance
+}
+
+func (c *fakeCalibrator) CalibrateByPart(ctx context.Context, image []byte, lengthMM float64) (*entity.Calibration, error) {
+	return &entity.Calibration{MMPerPixel: lengthMM / 400, ImageWidth: 2000, ImageHeight: 1000, Source: entity.CalibrationManual}, nil
+}
+
+func (c *fakeCalibrator) CalibrateByMarker(ctx context.Context, image []byte) (*entity.Calibration, error) {
+	c.markerSearches++
+	if string(image) != "marker" {
+		return nil, nil
+	}
+	return &entity.Calibration{MMPerPixel: 0.1, ImageWidth: 1000, ImageHeight: 500, Source: entity.CalibrationAruco}, nil
+}
+
+func (c *fakeCalibrator) Tolerance() entity.Tolerance {
+	return c.tolerance
+}
+
+func TestInspectionService_CalibrateReference(t *testing.T) {
+	svc := NewInspectionService(NewUserService(storage.NewMemoryUserRepository()), storage.NewMemoryInspectionRepository(), &sizedDetector{}, nil, entity.SessionPolicy{})
+	ctx := context.Background()
+
+	_, err := svc.CalibrateReference(ctx, testKey, 20)
+	require.ErrorIs(t, err, ErrCalibrationUnsupported)
+
+	calibrator := &fakeCalibrator{tolerance: entity.Tolerance{MinLengthMM: 0.5}}
+	svc.SetCalibrator(calibrator)
+	_, err = svc.CalibrateReference(ctx, testKey, 20)
+	require.ErrorIs(t, err, ErrNoReference)
+
+	_, err = svc.users.BeginCheck(ctx, testKey)
+	require.NoError(t, err)
+	_, err = svc.AcceptOriginalPhoto(ctx, testKey, []byte("orig"))
+	require.NoError(t, err)
+
+	// Без маркера и ввода размеры остаются в пикселях.
+	output, err := svc.ProcessDefectPhotoDiff(ctx, testKey, []byte("part"), "ru")
+	require.NoError(t, err)
+	require.False(t, output.Result.Defects[0].Size.Measured())
+
+	// Деталь длиной 20 мм занимает 400 пикселей эталона 2000×1000: 0,05 мм/пикс,
+	// а в кадре проверки 1000×500 — 0,1 мм/пикс.
+	calibration, err := svc.CalibrateReference(ctx, testKey, 20)
+	require.NoError(t, err)
+	require.InDelta(t, 0.05, calibration.MMPerPixel, 1e-9)
+	require.Equal(t, calibration, svc.Calibration(testKey))
+
+	output, err = svc.ProcessDefectPhotoDiff(ctx, testKey, []byte("part"), "ru")
+	require.NoError(t, err)
+	// Скол 0,4 мм укладывается в допуск 0,5 мм, царапина 10 мм — нет.
+	require.Len(t, output.Result.Defects, 1)
+	require.InDelta(t, 10, output.Result.Defects[0].Size.LengthMM, 1e-9)
+	require.InDelta(t, 1.5, output.Result.Defects[0].Size.AreaMM2, 1e-9)
+	require.Len(t, output.Result.Tolerated, 1)
+	require.True(t, output.Result.HasDefects)
+	require.Equal(t, 1, calibrator.markerSearches, "marker is searched once per reference")
+
+	// Новый эталон калибруется заново.
+	_, err = svc.NewReference(ctx, testKey)
+	require.NoError(t, err)
+	_, err = svc.AcceptOriginalPhoto(ctx, testKey, []byte("marker"))
+	require.NoError(t, err)
+	require.Nil(t, svc.Calibration(testKey))
+	_, err = svc.ProcessDefectPhotoDiff(ctx, testKey, []byte("part"), "ru")
+	require.NoError(t, err)
+	require.Equal(t, entity.CalibrationAruco, svc.Calibration(testKey).Source)
+}
+
+func TestInspectionService_QuickCheckUsesMarkerInFrame(t *testing.T) {
+	svc := NewInspectionService(NewUserService(storage.NewMemoryUserRepository()), storage.NewMemoryInspectionRepository(), &sizedDetector{}, nil, entity.SessionPolicy{})
+	svc.SetCalibrator(&fakeCalibrator{tolerance: entity.Tolerance{MinAreaMM2: 1}})
+	ctx := context.Background()
+
+	output, err := svc.ProcessQuickPhoto(ctx, testKey, []byte("marker"), "ru")
+	require.NoError(t, err)
+	require.Len(t, output.Result.Defects, 1)
+	require.InDelta(t, 0.16, output.Result.Tolerated[0].Size.AreaMM2, 1e-9)
+
+	output, err = svc.ProcessQuickPhoto(ctx, testKey, []byte("part"), "ru")
+	require.NoError(t, err)
+	require.Len(t, output.Result.Defects, 2)
+	require.Empty(t, output.Result.Tolerated)
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"vision-bot/internal/domain/entity"
+)
+
+// IdleReport — итог одного обхода брошенных проверок.
+type IdleReport struct {
+	Reminded []entity.User // кому нужно напомнить о проверке (состояние на момент напоминания)
+	Expired  []entity.User // чьи проверки сброшены (состояние до сброса)
+}
+
+// IdleService находит брошенные проверки: сначала напоминает о них,
+// потом сбрасывает диалог и освобождает сохранённые фото.
+type IdleService struct {
+	users       *UserService
+	inspections *InspectionService
+	policy      entity.IdlePolicy
+}
+
+// NewIdleService создаёт сервис контроля бездействия.
+func NewIdleService(users *UserService, inspections *InspectionService, policy entity.IdlePolicy) *IdleService {
+	return &IdleService{
+		users:       users,
+		inspections: inspections,
+		policy:      policy,
+	}
+}
+
+// Enabled сообщает, включён ли хотя бы один шаг контроля бездействия.
+func (s *IdleService) Enabled() bool {
+	return s.policy.RemindAfter > 0 || s.policy.ExpireAfter > 0
+}
+
+// policyFor возвращает правила бездействия для диалога. Между деталями
+// активной сессии с эталоном напоминать не о чем, а сроком эталона управляет
+// SESSION_TTL; только сессия без срока сбрасывается по таймауту бездействия.
+func (s *IdleService) policyFor(user *entity.User) entity.IdlePolicy {
+	policy := s.policy
+	if user.State != entity.StateAwaitingDefectPhoto || s.inspections.ActiveSession(user.Key()) == nil {
+		return policy
+	}
+	policy.RemindAfter = 0
+	if s.inspections.SessionPolicy().TTL > 0 {
+		policy.ExpireAfter = 0
+	}
+	return policy
+}
+
+// Sweep обходит пользователей с незавершённой проверкой.
+// Ошибка по одному пользователю не прерывает обход остальных.
+func (s *IdleService) Sweep(ctx context.Context, now time.Time) (IdleReport, error) {
+	var report IdleReport
+
+	users, err := s.users.List(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	var errs []error
+	for _, candidate := range users {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		action, snapshot, err := s.users.checkIdle(ctx, candidate.Key(), now, s.policyFor(candidate))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		switch action {
+		case idleRemind:
+			report.Reminded = append(report.Reminded, snapshot)
+		case idleExpire:
+			// Эталон больше не нужен: освобождаем память.
+			s.inspections.forgetSession(snapshot.Key())
+			report.Expired = append(report.Expired, snapshot)
+		}
+	}
+
+	return report, errors.Join(errs...)
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/infrastructure/storage"
+)
+
+func TestIdleService_RemindsThenExpires(t *testing.T) {
+	users := NewUserService(storage.NewMemoryUserRepository())
+	inspections := NewInspectionService(users, storage.NewMemoryInspectionRepository(), nil, nil, entity.SessionPolicy{})
+	idle := NewIdleService(users, inspections, entity.IdlePolicy{RemindAfter: 5 * time.Minute, ExpireAfter: 15 * time.Minute})
+	ctx := context.Background()
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	users.now = func() time.Time { return start }
+	inspections.now = users.now
+
+	_, err := users.BeginCheck(ctx, testKey)
+	require.NoError(t, err)
+	// Второй пользователь в главном меню не должен попасть в отчёт.
+	_, err = users.Get(ctx, entity.DialogueKey{ChatID: 20, UserID: 2})
+	require.NoError(t, err)
+
+	report, err := idle.Sweep(ctx, start.Add(time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, report.Reminded)
+	require.Empty(t, report.Expired)
+
+	report, err = idle.Sweep(ctx, start.Add(6*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, report.Reminded, 1)
+	require.Equal(t, entity.StateAwaitingOriginalPhoto, report.Reminded[0].State)
+
+	// Напоминание отправляется один раз на состояние.
+	report, err = idle.Sweep(ctx, start.Add(7*time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, report.Reminded)
+
+	report, err = idle.Sweep(ctx, start.Add(16*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, report.Expired, 1)
+	require.Equal(t, int64(10), report.Expired[0].ChatID)
+
+	user, err := users.Get(ctx, testKey)
+	require.NoError(t, err)
+	require.Equal(t, entity.StateMainMenu, user.State)
+}
+
+func TestIdleService_SessionFollowsItsTTL(t *testing.T) {
+	users := NewUserService(storage.NewMemoryUserRepository())
+	inspections := NewInspectionService(users, storage.NewMemoryInspectionRepository(), nil, nil, entity.SessionPolicy{TTL: 30 * time.Minute})
+	idle := NewIdleService(users, inspections, entity.IdlePolicy{RemindAfter: 5 * time.Minute, ExpireAfter: 15 * time.Minute})
+	ctx := context.Background()
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	users.now = func() time.Time { return start }
+	inspections.now = users.now
+
+	_, err := users.BeginCheck(ctx, testKey)
+	require.NoError(t, err)
+	_, err = inspections.AcceptOriginalPhoto(ctx, testKey, []byte("orig"))
+	require.NoError(t, err)
+
+	// Между деталями сессии бот не напоминает и не забывает эталон раньше SESSION_TTL.
+	report, err := idle.Sweep(ctx, start.Add(16*time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, report.Reminded)
+	require.Empty(t, report.Expired)
+	require.True(t, inspections.HasReference(testKey))
+
+	// Сессия истекла: диалог сбрасывается, эталон освобождается.
+	now := start.Add(31 * time.Minute)
+	inspections.now = func() time.Time { return now }
+	report, err = idle.Sweep(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, report.Expired, 1)
+	require.False(t, inspections.HasReference(testKey))
+}
+
+func TestIdleService_SessionWithoutTTLExpiresByIdleTimeout(t *testing.T) {
+	users := NewUserService(storage.NewMemoryUserRepository())
+	inspections := NewInspectionService(users, storage.NewMemoryInspectionRepository(), nil, nil, entity.SessionPolicy{})
+	idle := NewIdleService(users, inspections, entity.IdlePolicy{RemindAfter: 5 * time.Minute, ExpireAfter: 15 * time.Minute})
+	ctx := context.Background()
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	users.now = func() time.Time { return start }
+	inspections.now = users.now
+
+	_, err := users.BeginCheck(ctx, testKey)
+	require.NoError(t, err)
+	_, err = inspections.AcceptOriginalPhoto(ctx, testKey, []byte("orig"))
+	require.NoError(t, err)
+
+	report, err := idle.Sweep(ctx, start.Add(6*time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, report.Reminded)
+
+	report, err = idle.Sweep(ctx, start.Add(16*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, report.Expired, 1)
+	require.False(t, inspections.HasReference(testKey))
+}
+
+func TestIdleService_SkipsProcessing(t *testing.T) {
+	users := NewUserService(storage.NewMemoryUserRepository())
+	inspections := NewInspectionService(users, storage.NewMemoryInspectionRepository(), nil, nil, entity.SessionPolicy{})
+	idle := NewIdleService(users, inspections, entity.IdlePolicy{RemindAfter: 5 * time.Minute, ExpireAfter: 15 * time.Minute})
+	ctx := context.Background()
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	users.now = func() time.Time { return start }
+	inspections.now = users.now
+
+	_, err := users.BeginCheck(ctx, testKey)
+	require.NoError(t, err)
+	_, err = inspections.AcceptOriginalPhoto(ctx, testKey, []byte("orig"))
+	require.NoError(t, err)
+	_, err = inspections.AcceptDefectPhoto(ctx, testKey)
+	require.NoError(t, err)
+
+	// Долгая партия не сбрасывается: эталон нужен фоновой задаче до конца.
+	report, err := idle.Sweep(ctx, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, report.Reminded)
+	require.Empty(t, report.Expired)
+	require.True(t, inspections.HasReference(testKey))
+	user, err := inspections.CompleteCheck(ctx, testKey)
+	require.NoError(t, err)
+	require.Equal(t, entity.StateAwaitingDefectPhoto, user.State)
+}
@@ -1,95 +1,640 @@
 package app
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
 	"sync"
+	"time"
 
 	"vision-bot/internal/domain/entity"
 	"vision-bot/internal/domain/port"
+	"vision-bot/internal/logging"
+	"vision-bot/pkg/imgutil"
+)
+
+// thumbnailSize — большая сторона превью эталона в подсказках.
+const thumbnailSize = 320
+
+var (
+	// ErrNoReference возвращается, если у пользователя нет сохранённого эталона.
+	ErrNoReference = errors.New("reference photo is not saved")
+	// ErrRecordNotFound возвращается, если запись истории не найдена или принадлежит другому пользователю.
+	ErrRecordNotFound = errors.New("inspection record is not found")
+	// ErrSessionExpired возвращается, если эталон исчерпал лимит проверок или времени.
+	ErrSessionExpired = errors.New("reference session expired")
+	// ErrGoldenUnsupported возвращается, если детектор не умеет строить модель из нескольких годных деталей.
+	ErrGoldenUnsupported = errors.New("detector does not support golden models")
+	// ErrReferenceChanged возвращается, если эталон сменился, пока шла работа со старым.
+	ErrReferenceChanged = errors.New("reference has changed")
+	// ErrSessionLimit отмечает детали партии, которые не уместились в лимит проверок сессии.
+	ErrSessionLimit = errors.New("session check limit reached")
 )
 
 type InspectionService struct {
-	users     *UserService
-	detector  port.DefectDetector
-	describer port.DefectDescriber
-	originals map[int64][]byte
-	mu        sync.RWMutex
+	users    *UserService
+	history  port.InspectionRepository
+	detector port.DefectDetector
+	golden   port.GoldenModelDetector // nil, если детектор не строит модели годных деталей
+	// calibrator переводит пиксели в миллиметры; nil — размеры только в пикселях.
+	calibrator port.Calibrator
+	describer  port.DefectDescriber
+	policy     entity.SessionPolicy
+	sessions   map[entity.DialogueKey]*entity.Session
+	mu         sync.RWMutex
+	// modelMu не даёт двум одобрениям дополнить одну и ту же модель параллельно.
+	modelMu sync.Mutex
+	now     func() time.Time
 }
 
 // InspectionOutput содержит результат поиска дефектов и картинку с подсветкой.
 type InspectionOutput struct {
+	RecordID    string // ID записи в истории проверок
 	Result      *entity.InspectionResult
 	Highlighted []byte
+	Description string // описание дефектов на языке пользователя; пусто, если описателя нет
+}
+
+// BatchItem содержит результат проверки одной детали из партии.
+type BatchItem struct {
+	Index  int // номер детали в партии, начиная с 1
+	Output *InspectionOutput
+	Err    error
+}
+
+// Succeeded сообщает, что деталь проверена и результат можно показать.
+// Пустой результат без ошибки считается сбоем проверки.
+func (i BatchItem) Succeeded() bool {
+	return i.Err == nil && i.Output != nil && i.Output.Result != nil
 }
 
 // NewInspectionService создаёт сервис, который управляет проверкой дефектов.
-func NewInspectionService(users *UserService, detector port.DefectDetector, describer port.DefectDescriber) *InspectionService {
+func NewInspectionService(users *UserService, history port.InspectionRepository, detector port.DefectDetector, describer port.DefectDescriber, policy entity.SessionPolicy) *InspectionService {
+	golden, _ := detector.(port.GoldenModelDetector)
 	return &InspectionService{
 		users:     users,
+		history:   history,
 		detector:  detector,
+		golden:    golden,
 		describer: describer,
-		originals: make(map[int64][]byte),
+		policy:    policy,
+		sessions:  make(map[entity.DialogueKey]*entity.Session),
+		now:       time.Now,
+	}
+}
+
+// StartCheck начинает проверку. Если сессия с эталоном ещё активна,
+// пользователь сразу переходит к отправке детали и получает копию сессии;
+// иначе бот попросит новый эталон, а сессия будет nil.
+func (s *InspectionService) StartCheck(ctx context.Context, key entity.DialogueKey) (*entity.User, *entity.Session, error) {
+	if session := s.ActiveSession(key); session != nil {
+		user, err := s.users.Fire(ctx, key, entity.EventReuseReference, s.guards(key))
+		if err != nil {
+			return user, nil, err
+		}
+		return user, session, nil
+	}
+
+	user, err := s.users.BeginCheck(ctx, key)
+	return user, nil, err
+}
+
+// NewReference просит новый эталон; текущая сессия заменится, когда он придёт.
+func (s *InspectionService) NewReference(ctx context.Context, key entity.DialogueKey) (*entity.User, error) {
+	return s.users.BeginCheck(ctx, key)
+}
+
+// EndSession завершает сессию, забывает эталон и возвращает пользователя в главное меню.
+func (s *InspectionService) EndSession(ctx context.Context, key entity.DialogueKey) (*entity.User, error) {
+	user, err := s.users.Cancel(ctx, key)
+	if err != nil {
+		return user, err
 	}
+
+	s.forgetSession(key)
+	return user, nil
 }
 
-// AcceptOriginalPhoto принимает оригинальное фото и переводит пользователя дальше по сценарию.
-func (s *InspectionService) AcceptOriginalPhoto(ctx context.Context, userID, chatID int64, photo []byte) (*entity.User, error) {
-	// Сохраняем оригинал в памяти, чтобы сравнить со следующей фотографией.
+// AcceptOriginalPhoto принимает оригинальное фото и начинает с ним новую сессию.
+func (s *InspectionService) AcceptOriginalPhoto(ctx context.Context, key entity.DialogueKey, photo []byte) (*entity.User, error) {
+	// Превью нужно только для подсказок, поэтому ошибку декодирования не считаем фатальной:
+	// форматы вроде TIFF или HEIC стандартная библиотека не читает.
+	user, err := s.users.Fire(ctx, key, entity.EventOriginalReceived, entity.GuardContext{})
+	if err != nil {
+		return user, err
+	}
+
+	thumbnail, _ := imgutil.Thumbnail(photo, thumbnailSize)
+
 	s.mu.Lock()
-	s.originals[userID] = photo
+	s.sessions[key] = entity.NewSession(photo, thumbnail, s.now())
 	s.mu.Unlock()
-	return s.users.SetState(ctx, userID, chatID, entity.StateAwaitingDefectPhoto)
+	return user, nil
 }
 
-// AcceptDefectPhoto принимает фото дефекта и возвращает пользователя в главное меню.
-func (s *InspectionService) AcceptDefectPhoto(ctx context.Context, userID, chatID int64, photo []byte) (*entity.User, error) {
-	_ = photo
-	return s.users.SetState(ctx, userID, chatID, entity.StateMainMenu)
+// AcceptDefectPhoto переводит пользователя в обработку и засчитывает проверку детали в сессии.
+// Если сессия истекла до прихода фото, бот снова просит эталон и возвращает ErrSessionExpired.
+func (s *InspectionService) AcceptDefectPhoto(ctx context.Context, key entity.DialogueKey) (*entity.User, error) {
+	s.mu.RLock()
+	session, ok := s.sessions[key]
+	expired := ok && !session.Active(s.now(), s.policy)
+	s.mu.RUnlock()
+	if expired {
+		if _, err := s.users.BeginCheck(ctx, key); err != nil {
+			return nil, err
+		}
+		return nil, ErrSessionExpired
+	}
+
+	user, err := s.users.Fire(ctx, key, entity.EventCurrentReceived, s.guards(key))
+	if err != nil {
+		return user, err
+	}
+
+	s.mu.Lock()
+	session.Checks++
+	s.mu.Unlock()
+	return user, nil
+}
+
+// StartQuick начинает проверку без эталона: бот ждёт один снимок детали.
+func (s *InspectionService) StartQuick(ctx context.Context, key entity.DialogueKey) (*entity.User, error) {
+	return s.users.Fire(ctx, key, entity.EventBeginQuick, s.guards(key))
+}
+
+// AcceptQuickPhoto переводит пользователя в обработку снимка без эталона.
+// Проверка в сессии с эталоном не засчитывается.
+func (s *InspectionService) AcceptQuickPhoto(ctx context.Context, key entity.DialogueKey) (*entity.User, error) {
+	return s.users.Fire(ctx, key, entity.EventQuickReceived, s.guards(key))
+}
+
+// CompleteQuick завершает проверку без эталона и возвращает в главное меню.
+func (s *InspectionService) CompleteQuick(ctx context.Context, key entity.DialogueKey) (*entity.User, error) {
+	return s.users.Fire(ctx, key, entity.EventQuickDone, s.guards(key))
+}
+
+// CompleteCheck завершает обработку: пока сессия активна, пользователь ждёт
+// следующую деталь, иначе возвращается в главное меню.
+func (s *InspectionService) CompleteCheck(ctx context.Context, key entity.DialogueKey) (*entity.User, error) {
+	return s.users.Fire(ctx, key, entity.EventProcessingDone, s.guards(key))
+}
+
+// ActiveSession возвращает копию активной сессии пользователя или nil.
+func (s *InspectionService) ActiveSession(key entity.DialogueKey) *entity.Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[key]
+	if !ok || !session.Active(s.now(), s.policy) {
+		return nil
+	}
+	copied := *session
+	return &copied
+}
+
+// ActiveSessions возвращает число диалогов с активным эталоном.
+func (s *InspectionService) ActiveSessions() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := s.now()
+	active := 0
+	for _, session := range s.sessions {
+		if session.Active(now, s.policy) {
+			active++
+		}
+	}
+	return active
+}
+
+// Stats возвращает сводку по истории проверок.
+func (s *InspectionService) Stats(ctx context.Context) (entity.InspectionStats, error) {
+	return s.history.Stats(ctx)
+}
+
+// SessionPolicy возвращает ограничения сессии с одним эталоном.
+func (s *InspectionService) SessionPolicy() entity.SessionPolicy {
+	return s.policy
+}
+
+// HasReference сообщает, есть ли у пользователя сохранённый эталон.
+func (s *InspectionService) HasReference(key entity.DialogueKey) bool {
+	return len(s.reference(key)) > 0
+}
+
+// ReuseReference начинает новую серию проверок с уже сохранённым эталоном:
+// пользователь сразу переходит к отправке фото детали.
+func (s *InspectionService) ReuseReference(ctx context.Context, key entity.DialogueKey) (*entity.User, *entity.Session, error) {
+	s.mu.RLock()
+	session, ok := s.sessions[key]
+	s.mu.RUnlock()
+	if !ok || len(session.Reference) == 0 {
+		return nil, nil, ErrNoReference
+	}
+
+	user, err := s.users.Fire(ctx, key, entity.EventReuseReference, s.guards(key))
+	if err != nil {
+		return user, nil, err
+	}
+
+	s.mu.Lock()
+	session.Restart(s.now())
+	copied := *session
+	s.mu.Unlock()
+	return user, &copied, nil
+}
+
+// forgetSession удаляет сессию и сохранённые в ней фото.
+func (s *InspectionService) forgetSession(key entity.DialogueKey) {
+	s.mu.Lock()
+	delete(s.sessions, key)
+	s.mu.Unlock()
+}
+
+// guards собирает факты для условных переходов диалога.
+func (s *InspectionService) guards(key entity.DialogueKey) entity.GuardContext {
+	return entity.GuardContext{
+		HasReference:  s.HasReference(key),
+		SessionActive: s.ActiveSession(key) != nil,
+	}
+}
+
+// referenceModel возвращает эталон, модель годных деталей (если она собрана)
+// и зоны эталона, даже если сессия уже истекла.
+func (s *InspectionService) referenceModel(key entity.DialogueKey) ([]byte, *entity.GoldenModel, []entity.Zone) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if session, ok := s.sessions[key]; ok {
+		return session.Reference, session.Model, session.Zones
+	}
+	return nil, nil, nil
+}
+
+// SupportsGoldenModel сообщает, умеет ли детектор строить модель из нескольких годных деталей.
+func (s *InspectionService) SupportsGoldenModel() bool {
+	return s.golden != nil
+}
+
+// BuildReferenceModel собирает модель из годных деталей samples; первая из
+// них должна быть текущим эталоном. Дальше детали сессии сравниваются с моделью.
+func (s *InspectionService) BuildReferenceModel(ctx context.Context, key entity.DialogueKey, samples [][]byte) (*entity.GoldenModel, error) {
+	if s.golden == nil {
+		return nil, ErrGoldenUnsupported
+	}
+
+	s.modelMu.Lock()
+	defer s.modelMu.Unlock()
+
+	model, err := s.golden.BuildGoldenModel(ctx, samples)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storeModel(key, samples[0], model); err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Reference model built", "samples", model.Samples)
+	return model, nil
+}
+
+// ApproveSample добавляет проверенную деталь в модель эталона как годную.
+// Если модели ещё нет, она собирается из эталона и этой детали.
+func (s *InspectionService) ApproveSample(ctx context.Context, key entity.DialogueKey, recordID string) (*entity.GoldenModel, error) {
+	if s.golden == nil {
+		return nil, ErrGoldenUnsupported
+	}
+	record, err := s.Record(ctx, key.UserID, recordID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.modelMu.Lock()
+	defer s.modelMu.Unlock()
+
+	reference, model, _ := s.referenceModel(key)
+	if len(reference) == 0 || !bytes.Equal(reference, record.Reference) {
+		return nil, ErrReferenceChanged
+	}
+	if model == nil {
+		model, err = s.golden.BuildGoldenModel(ctx, [][]byte{reference, record.Current})
+	} else {
+		model, err = s.golden.ExtendGoldenModel(ctx, model, record.Current)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storeModel(key, reference, model); err != nil {
+		return nil, err
+	}
+
+	record.Approved = true
+	if err := s.history.Save(ctx, record); err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Sample approved into reference model", "record_id", recordID, "samples", model.Samples)
+	return model, nil
+}
+
+// storeModel сохраняет модель в сессию, если эталон за это время не сменился.
+func (s *InspectionService) storeModel(key entity.DialogueKey, reference []byte, model *entity.GoldenModel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[key]
+	if !ok || !bytes.Equal(session.Reference, reference) {
+		return ErrReferenceChanged
+	}
+	session.Model = model
+	return nil
+}
+
+// reference возвращает сохранённый эталон пользователя, даже если сессия уже истекла.
+func (s *InspectionService) reference(key entity.DialogueKey) []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if session, ok := s.sessions[key]; ok {
+		return session.Reference
+	}
+	return nil
 }
 
 // ProcessDefectPhotoDiff сравнивает эталон и текущее фото и возвращает результат.
-func (s *InspectionService) ProcessDefectPhotoDiff(ctx context.Context, userID int64, current []byte) (*InspectionOutput, error) {
+// Описание дефектов генерируется на языке locale.
+func (s *InspectionService) ProcessDefectPhotoDiff(ctx context.Context, key entity.DialogueKey, current []byte, locale string) (*InspectionOutput, error) {
 	if s.detector == nil {
 		return nil, errors.New("detector is not configured")
 	}
 
-	s.mu.RLock()
-	base, ok := s.originals[userID]
-	s.mu.RUnlock()
-	if !ok || len(base) == 0 {
+	base, model, zones := s.referenceModel(key)
+	if len(base) == 0 {
 		return nil, errors.New("original photo is not found")
 	}
 
-	result, err := s.detector.InspectDiff(ctx, base, current)
+	started := s.now()
+	var result *entity.InspectionResult
+	var err error
+	if model != nil && s.golden != nil {
+		result, err = s.golden.InspectGolden(ctx, model, current, zones)
+	} else {
+		result, err = s.detector.InspectDiff(ctx, base, current, zones)
+	}
+	if err == nil && result == nil {
+		err = errors.New("detector returned no result")
+	}
 	if err != nil {
+		slog.DebugContext(ctx, "Inspection failed", "duration", s.now().Sub(started), "err", err)
 		return nil, err
 	}
+	s.measure(ctx, result, s.referenceCalibration(ctx, key, base))
+	slog.DebugContext(ctx, "Inspection finished",
+		"duration", s.now().Sub(started),
+		"has_defects", result.HasDefects,
+		"defects", len(result.Defects),
+		"rotation", result.Trace.Rotation,
+		"flipped", result.Trace.Flipped,
+		"profile", result.Trace.Profile,
+		"profile_version", result.Trace.ProfileVersion,
+	)
 
+	// Координаты дефектов — в кадре эталона. Развёрнутую деталь подсвечиваем
+	// на эталоне: на исходном снимке рамки легли бы мимо.
+	canvas := current
+	if result.Trace.Rotation != 0 || result.Trace.Flipped {
+		canvas = base
+	}
+	// Снимок с несколькими деталями получает обзор с номерами деталей, даже если все годны.
 	var highlighted []byte
-	if result.HasDefects {
-		highlighted, _ = s.detector.HighlightDefects(current, result)
+	if result.HasDefects || len(result.Parts) > 0 {
+		highlighted, _ = s.detector.HighlightDefects(canvas, result)
+	}
+
+	record := &entity.InspectionRecord{
+		ID:          newRecordID(),
+		UserID:      key.UserID,
+		CreatedAt:   s.now(),
+		Result:      result,
+		Reference:   base,
+		Current:     current,
+		Highlighted: highlighted,
+	}
+	if err := s.history.Save(ctx, record); err != nil {
+		return nil, fmt.Errorf("save inspection record: %w", err)
+	}
+
+	return &InspectionOutput{
+		RecordID:    record.ID,
+		Result:      result,
+		Highlighted: highlighted,
+		Description: s.describe(ctx, result, locale),
+	}, nil
+}
+
+// ProcessBatchDiff сравнивает каждое фото партии с одним сохранённым эталоном.
+// Ошибка проверки отдельной детали не прерывает партию и возвращается в BatchItem.
+func (s *InspectionService) ProcessBatchDiff(ctx context.Context, key entity.DialogueKey, photos [][]byte, locale string) ([]BatchItem, error) {
+	if s.detector == nil {
+		return nil, errors.New("detector is not configured")
+	}
+
+	base := s.reference(key)
+	if len(base) == 0 {
+		return nil, errors.New("original photo is not found")
+	}
+
+	items := make([]BatchItem, 0, len(photos))
+	allowed := s.reserveBatch(key, len(photos))
+	if allowed < len(photos) {
+		slog.InfoContext(ctx, "Batch exceeds session limit", "parts", len(photos), "allowed", allowed)
+	}
+	for i, photo := range photos {
+		if i >= allowed {
+			items = append(items, BatchItem{Index: i + 1, Err: ErrSessionLimit})
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return items, err
+		}
+		// Части партии различаются суффиксом к общему идентификатору проверки.
+		partCtx := logging.WithInspectionID(ctx, fmt.Sprintf("%s-%d", logging.InspectionID(ctx), i+1))
+		output, err := s.ProcessDefectPhotoDiff(partCtx, key, photo, locale)
+		items = append(items, BatchItem{Index: i + 1, Output: output, Err: err})
 	}
+	return items, nil
+}
+
+// reserveBatch засчитывает в сессии детали партии и возвращает, сколько из них
+// укладывается в лимит проверок. Первую деталь уже засчитал AcceptDefectPhoto.
+func (s *InspectionService) reserveBatch(key entity.DialogueKey, parts int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[key]
+	if !ok || parts <= 1 {
+		return parts
+	}
+	allowed := parts
+	if s.policy.MaxChecks > 0 {
+		allowed = max(1, min(parts, s.policy.MaxChecks-session.Checks+1))
+	}
+	session.Checks += allowed - 1
+	return allowed
+}
+
+// History возвращает последние проверки пользователя, новые первыми.
+func (s *InspectionService) History(ctx context.Context, userID int64, limit int) ([]*entity.InspectionRecord, error) {
+	return s.history.ListByUser(ctx, userID, limit)
+}
+
+// Record возвращает запись истории, если она принадлежит пользователю.
+func (s *InspectionService) Record(ctx context.Context, userID int64, recordID string) (*entity.InspectionRecord, error) {
+	record, err := s.history.Get(ctx, recordID)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil || record.UserID != userID {
+		return nil, ErrRecordNotFound
+	}
+	return record, nil
+}
+
+// MarkFalsePositive помечает результат проверки как ложное срабатывание.
+func (s *InspectionService) MarkFalsePositive(ctx context.Context, userID int64, recordID string) (*entity.InspectionRecord, error) {
+	record, err := s.Record(ctx, userID, recordID)
+	if err != nil {
+		return nil, err
+	}
+
+	record.FalsePositive = true
+	if err := s.history.Save(ctx, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
 
-	_ = s.describer
-	return &InspectionOutput{Result: result, Highlighted: highlighted}, nil
+// Flush сбрасывает историю проверок в хранилище перед остановкой.
+func (s *InspectionService) Flush(ctx context.Context) error {
+	return s.history.Flush(ctx)
 }
-// ProcessDefectPhoto запускает детектор и возвращает результат с подсветкой.
-func (s *InspectionService) ProcessDefectPhoto(ctx context.Context, photo []byte) (*InspectionOutput, error) {
+
+// Ping проверяет, что хранилище истории доступно.
+func (s *InspectionService) Ping(ctx context.Context) error {
+	return s.history.Ping(ctx)
+}
+
+// ProcessQuickPhoto проверяет деталь по одному снимку, без эталона, и сохраняет
+// результат в историю. Запись без эталона сравнивать не с чем.
+func (s *InspectionService) ProcessQuickPhoto(ctx context.Context, key entity.DialogueKey, photo []byte, locale string) (*InspectionOutput, error) {
 	if s.detector == nil {
 		return nil, errors.New("detector is not configured")
 	}
 
+	started := s.now()
 	result, err := s.detector.Inspect(ctx, photo)
+	if err == nil && result == nil {
+		err = errors.New("detector returned no result")
+	}
 	if err != nil {
+		slog.DebugContext(ctx, "Quick inspection failed", "duration", s.now().Sub(started), "err", err)
 		return nil, err
 	}
+	// Эталона нет, поэтому маркер ищется на самом снимке детали.
+	s.measure(ctx, result, s.markerCalibration(ctx, photo))
+	slog.DebugContext(ctx, "Quick inspection finished",
+		"duration", s.now().Sub(started),
+		"has_defects", result.HasDefects,
+		"defects", len(result.Defects),
+		"profile", result.Trace.Profile,
+		"profile_version", result.Trace.ProfileVersion,
+	)
 
 	var highlighted []byte
 	if result.HasDefects {
 		highlighted, _ = s.detector.HighlightDefects(photo, result)
 	}
 
-	_ = s.describer
-	return &InspectionOutput{Result: result, Highlighted: highlighted}, nil
+	record := &entity.InspectionRecord{
+		ID:          newRecordID(),
+		UserID:      key.UserID,
+		CreatedAt:   s.now(),
+		Result:      result,
+		Current:     photo,
+		Highlighted: highlighted,
+	}
+	if err := s.history.Save(ctx, record); err != nil {
+		return nil, fmt.Errorf("save inspection record: %w", err)
+	}
+
+	return &InspectionOutput{
+		RecordID:    record.ID,
+		Result:      result,
+		Highlighted: highlighted,
+		Description: s.describe(ctx, result, locale),
+	}, nil
+}
+
+// describe запрашивает описание дефектов. Сбой описателя не должен ронять проверку,
+// поэтому ошибка только логируется.
+func (s *InspectionService) describe(ctx context.Context, result *entity.InspectionResult, locale string) string {
+	if s.describer == nil || result == nil || !result.HasDefects {
+		return ""
+	}
+	description, err := s.describer.Describe(ctx, result, locale)
+	if err != nil {
+		slog.WarnContext(ctx, "Describe defects error", "locale", locale, "err", err)
+		return ""
+	}
+	if description == nil {
+		return ""
+	}
+	return description.Text
+}
+
+// ClassifyInspectionError сводит ошибку проверки к короткой категории для
+// логов и метрик: quality_gate, alignment, decode, cancelled и т. д.
+func ClassifyInspectionError(err error) string {
+	if err == nil {
+		return "none"
+	}
+	if errors.Is(err, context.Canceled) {
+		return "cancelled"
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	if errors.Is(err, ErrSessionLimit) {
+		return "session_limit"
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "quality gate failed"):
+		return "quality_gate"
+	case strings.Contains(msg, "alignment failed"):
+		return "alignment"
+	case strings.Contains(msg, "failed to decode"):
+		return "decode"
+	case strings.Contains(msg, "original photo is not found"):
+		return "missing_original"
+	case strings.Contains(msg, "detector is not configured"):
+		return "detector_not_configured"
+	case strings.Contains(msg, "empty image"):
+		return "empty_image"
+	case strings.Contains(msg, "part is not detected"):
+		return "part_not_detected"
+	default:
+		return "unknown"
+	}
+}
+
+// newRecordID генерирует короткий ID записи, который помещается в callback-данные кнопки.
+func newRecordID() string {
+	buf := make([]byte, 6)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
 }
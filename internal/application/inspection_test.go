@@ -2,7 +2,9 @@ package app
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -10,13 +12,22 @@ import (
 	"vision-bot/internal/infrastructure/storage"
 )
 
+// testKey — личный чат тестового пользователя 1.
+var testKey = entity.DialogueKey{ChatID: 10, UserID: 1}
+
 func TestInspectionService_AcceptOriginalPhoto(t *testing.T) {
 	repo := storage.NewMemoryUserRepository()
 	userSvc := NewUserService(repo)
-	svc := NewInspectionService(userSvc, nil, nil)
+	svc := NewInspectionService(userSvc, storage.NewMemoryInspectionRepository(), nil, nil, entity.SessionPolicy{})
 	ctx := context.Background()
 
-	user, err := svc.AcceptOriginalPhoto(ctx, 1, 10, []byte("orig"))
+	_, err := svc.AcceptOriginalPhoto(ctx, testKey, []byte("orig"))
+	require.ErrorIs(t, err, entity.ErrInvalidTransition)
+	require.False(t, svc.HasReference(testKey))
+
+	_, err = userSvc.BeginCheck(ctx, testKey)
+	require.NoError(t, err)
+	user, err := svc.AcceptOriginalPhoto(ctx, testKey, []byte("orig"))
 	require.NoError(t, err)
 	require.Equal(t, entity.StateAwaitingDefectPhoto, user.State)
 }
@@ -24,20 +35,326 @@ func TestInspectionService_AcceptOriginalPhoto(t *testing.T) {
 func TestInspectionService_AcceptDefectPhoto(t *testing.T) {
 	repo := storage.NewMemoryUserRepository()
 	userSvc := NewUserService(repo)
-	svc := NewInspectionService(userSvc, nil, nil)
+	svc := NewInspectionService(userSvc, storage.NewMemoryInspectionRepository(), nil, nil, entity.SessionPolicy{})
 	ctx := context.Background()
 
-	user, err := svc.AcceptDefectPhoto(ctx, 1, 10, []byte("defect"))
+	_, err := userSvc.BeginCheck(ctx, testKey)
 	require.NoError(t, err)
-	require.Equal(t, entity.StateMainMenu, user.State)
+	_, err = svc.AcceptOriginalPhoto(ctx, testKey, []byte("orig"))
+	require.NoError(t, err)
+
+	user, err := svc.AcceptDefectPhoto(ctx, testKey)
+	require.NoError(t, err)
+	require.Equal(t, entity.StateProcessing, user.State)
+
+	// Сессия без ограничений продолжается: ждём следующую деталь.
+	user, err = svc.CompleteCheck(ctx, testKey)
+	require.NoError(t, err)
+	require.Equal(t, entity.StateAwaitingDefectPhoto, user.State)
 }
 
 func TestInspectionService_ProcessDefectPhotoDiff_NoOriginal(t *testing.T) {
 	repo := storage.NewMemoryUserRepository()
 	userSvc := NewUserService(repo)
-	svc := NewInspectionService(userSvc, nil, nil)
+	svc := NewInspectionService(userSvc, storage.NewMemoryInspectionRepository(), nil, nil, entity.SessionPolicy{})
 	ctx := context.Background()
 
-	_, err := svc.ProcessDefectPhotoDiff(ctx, 1, []byte("current"))
+	_, err := svc.ProcessDefectPhotoDiff(ctx, testKey, []byte("current"), "ru")
 	require.Error(t, err)
 }
+
+func TestInspectionService_ProcessBatchDiff_NoOriginal(t *testing.T) {
+	repo := storage.NewMemoryUserRepository()
+	userSvc := NewUserService(repo)
+	svc := NewInspectionService(userSvc, storage.NewMemoryInspectionRepository(), nil, nil, entity.SessionPolicy{})
+	ctx := context.Background()
+
+	_, err := svc.ProcessBatchDiff(ctx, testKey, [][]byte{[]byte("part-1"), []byte("part-2")}, "ru")
+	require.Error(t, err)
+}
+
+func TestInspectionService_ReuseReference(t *testing.T) {
+	repo := storage.NewMemoryUserRepository()
+	userSvc := NewUserService(repo)
+	svc := NewInspectionService(userSvc, storage.NewMemoryInspectionRepository(), nil, nil, entity.SessionPolicy{})
+	ctx := context.Background()
+
+	_, _, err := svc.ReuseReference(ctx, testKey)
+	require.ErrorIs(t, err, ErrNoReference)
+
+	_, err = userSvc.BeginCheck(ctx, testKey)
+	require.NoError(t, err)
+	_, err = svc.AcceptOriginalPhoto(ctx, testKey, []byte("orig"))
+	require.NoError(t, err)
+	_, err = userSvc.Cancel(ctx, testKey)
+	require.NoError(t, err)
+
+	user, session, err := svc.ReuseReference(ctx, testKey)
+	require.NoError(t, err)
+	require.Equal(t, entity.StateAwaitingDefectPhoto, user.State)
+	require.Zero(t, session.Checks)
+}
+
+func TestInspectionService_SessionLimits(t *testing.T) {
+	svc := NewInspectionService(NewUserService(storage.NewMemoryUserRepository()), storage.NewMemoryInspectionRepository(), nil, nil,
+		entity.SessionPolicy{MaxChecks: 2, TTL: 10 * time.Minute})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, err := svc.users.BeginCheck(ctx, testKey)
+	require.NoError(t, err)
+	_, err = svc.AcceptOriginalPhoto(ctx, testKey, []byte("orig"))
+	require.NoError(t, err)
+
+	// Первая деталь: сессия продолжается.
+	_, err = svc.AcceptDefectPhoto(ctx, testKey)
+	require.NoError(t, err)
+	user, err := svc.CompleteCheck(ctx, testKey)
+	require.NoError(t, err)
+	require.Equal(t, entity.StateAwaitingDefectPhoto, user.State)
+	require.Equal(t, 1, svc.ActiveSession(testKey).Checks)
+
+	// Время сессии вышло: бот снова просит эталон.
+	now = now.Add(11 * time.Minute)
+	_, err = svc.AcceptDefectPhoto(ctx, testKey)
+	require.ErrorIs(t, err, ErrSessionExpired)
+	require.Nil(t, svc.ActiveSession(testKey))
+	user, err = svc.users.Get(ctx, testKey)
+	require.NoError(t, err)
+	require.Equal(t, entity.StateAwaitingOriginalPhoto, user.State)
+
+	// StartCheck без активной сессии просит оригинал, EndSession забывает эталон.
+	_, session, err := svc.StartCheck(ctx, testKey)
+	require.NoError(t, err)
+	require.Nil(t, session)
+	_, err = svc.EndSession(ctx, testKey)
+	require.NoError(t, err)
+	require.False(t, svc.HasReference(testKey))
+}
+
+func TestInspectionService_RecordOwnership(t *testing.T) {
+	history := storage.NewMemoryInspectionRepository()
+	svc := NewInspectionService(NewUserService(storage.NewMemoryUserRepository()), history, nil, nil, entity.SessionPolicy{})
+	ctx := context.Background()
+
+	require.NoError(t, history.Save(ctx, &entity.InspectionRecord{ID: "abc", UserID: 1}))
+
+	_, err := svc.MarkFalsePositive(ctx, 2, "abc")
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	record, err := svc.MarkFalsePositive(ctx, 1, "abc")
+	require.NoError(t, err)
+	require.True(t, record.FalsePositive)
+}
+
+// goldenDetector считает образцы модели и отмечает, чем и с какими зонами сравнивалась деталь.
+type goldenDetector struct {
+	branch string
+	zones  []entity.Zone
+}
+
+func (d *goldenDetector) Inspect(ctx context.Context, imageData []byte) (*entity.InspectionResult, error) {
+	return &entity.InspectionResult{}, nil
+}
+
+func (d *goldenDetector) InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	d.branch, d.zones = "diff", zones
+	return &entity.InspectionResult{}, nil
+}
+
+func (d *goldenDetector) HighlightDefects(imageData []byte, result *entity.InspectionResult) ([]byte, error) {
+	return nil, nil
+}
+
+func (d *goldenDetector) BuildGoldenModel(ctx context.Context, samples [][]byte) (*entity.GoldenModel, error) {
+	return &entity.GoldenModel{Samples: len(samples), Anchor: samples[0]}, nil
+}
+
+func (d *goldenDetector) ExtendGoldenModel(ctx context.Context, model *entity.GoldenModel, sample []byte) (*entity.GoldenModel, error) {
+	next := *model
+	next.Samples++
+	return &next, nil
+}
+
+func (d *goldenDetector) InspectGolden(ctx context.Context, model *entity.GoldenModel, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	d.branch, d.zones = "golden", zones
+	return &entity.InspectionResult{}, nil
+}
+
+func TestInspectionService_GoldenModel(t *testing.T) {
+	detector := &goldenDetector{}
+	svc := NewInspectionService(NewUserService(storage.NewMemoryUserRepository()), storage.NewMemoryInspectionRepository(), detector, nil, entity.SessionPolicy{})
+	ctx := context.Background()
+	require.True(t, svc.SupportsGoldenModel())
+
+	_, err := svc.users.BeginCheck(ctx, testKey)
+	require.NoError(t, err)
+	_, err = svc.AcceptOriginalPhoto(ctx, testKey, []byte("orig"))
+	require.NoError(t, err)
+
+	// Пока модели нет, деталь сравнивается с одним эталоном.
+	output, err := svc.ProcessDefectPhotoDiff(ctx, testKey, []byte("part-1"), "ru")
+	require.NoError(t, err)
+	require.Equal(t, "diff", detector.branch)
+
+	// Одобрение годной детали собирает модель из эталона и этой детали.
+	model, err := svc.ApproveSample(ctx, testKey, output.RecordID)
+	require.NoError(t, err)
+	require.Equal(t, 2, model.Samples)
+	record, err := svc.Record(ctx, testKey.UserID, output.RecordID)
+	require.NoError(t, err)
+	require.True(t, record.Approved)
+
+	output, err = svc.ProcessDefectPhotoDiff(ctx, testKey, []byte("part-2"), "ru")
+	require.NoError(t, err)
+	require.Equal(t, "golden", detector.branch)
+	model, err = svc.ApproveSample(ctx, testKey, output.RecordID)
+	require.NoError(t, err)
+	require.Equal(t, 3, model.Samples)
+
+	// Деталь, проверенная со старым эталоном, в новый не попадает.
+	_, err = svc.NewReference(ctx, testKey)
+	require.NoError(t, err)
+	_, err = svc.AcceptOriginalPhoto(ctx, testKey, []byte("orig-2"))
+	require.NoError(t, err)
+	_, err = svc.ApproveSample(ctx, testKey, output.RecordID)
+	require.ErrorIs(t, err, ErrReferenceChanged)
+
+	model, err = svc.BuildReferenceModel(ctx, testKey, [][]byte{[]byte("orig-2"), []byte("good-1"), []byte("good-2")})
+	require.NoError(t, err)
+	require.Equal(t, 3, model.Samples)
+	require.Equal(t, 3, svc.ActiveSession(testKey).Model.Samples)
+}
+
+func TestInspectionService_GoldenModelUnsupported(t *testing.T) {
+	svc := NewInspectionService(NewUserService(storage.NewMemoryUserRepository()), storage.NewMemoryInspectionRepository(), nil, nil, entity.SessionPolicy{})
+
+	require.False(t, svc.SupportsGoldenModel())
+	_, err := svc.BuildReferenceModel(context.Background(), testKey, [][]byte{[]byte("a"), []byte("b")})
+	require.ErrorIs(t, err, ErrGoldenUnsupported)
+}
+
+func TestInspectionService_QuickCheckKeepsReference(t *testing.T) {
+	detector := &goldenDetector{}
+	svc := NewInspectionService(NewUserService(storage.NewMemoryUserRepository()), storage.NewMemoryInspectionRepository(), detector, nil, entity.SessionPolicy{})
+	ctx := context.Background()
+
+	_, err := svc.users.BeginCheck(ctx, testKey)
+	require.NoError(t, err)
+	_, err = svc.AcceptOriginalPhoto(ctx, testKey, []byte("orig"))
+	require.NoError(t, err)
+
+	user, err := svc.StartQuick(ctx, testKey)
+	require.NoError(t, err)
+	require.Equal(t, entity.StateAwaitingQuickPhoto, user.State)
+	_, err = svc.AcceptQuickPhoto(ctx, testKey)
+	require.NoError(t, err)
+	output, err := svc.ProcessQuickPhoto(ctx, testKey, []byte("part"), "ru")
+	require.NoError(t, err)
+	user, err = svc.CompleteQuick(ctx, testKey)
+	require.NoError(t, err)
+	require.Equal(t, entity.StateMainMenu, user.State)
+
+	// Снимок без эталона не засчитан в сессию и не годится в модель эталона.
+	require.Zero(t, svc.ActiveSession(testKey).Checks)
+	_, err = svc.ApproveSample(ctx, testKey, output.RecordID)
+	require.ErrorIs(t, err, ErrReferenceChanged)
+}
+
+func TestClassifyInspectionError(t *testing.T) {
+	require.Equal(t, "none", ClassifyInspectionError(nil))
+	require.Equal(t, "quality_gate", ClassifyInspectionError(errors.New("quality gate failed for base image: empty image")))
+	require.Equal(t, "session_limit", ClassifyInspectionError(ErrSessionLimit))
+	require.Equal(t, "missing_original", ClassifyInspectionError(errors.New("original photo is not found")))
+	require.Equal(t, "cancelled", ClassifyInspectionError(context.Canceled))
+	require.Equal(t, "part_not_detected", ClassifyInspectionError(errors.New("part is not detected")))
+	require.Equal(t, "unknown", ClassifyInspectionError(errors.New("boom")))
+}
+
+// rotatedDetector сообщает, что деталь развернули на 90°, и подсвечивает дефекты на присланном снимке.
+type rotatedDetector struct {
+	goldenDetector
+}
+
+func (d *rotatedDetector) InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	return &entity.InspectionResult{
+		Defects:    []entity.DefectArea{{Width: 10, Height: 10, Area: 100}},
+		HasDefects: true,
+		Trace:      entity.InspectionTrace{Branch: "diff", Rotation: 90},
+	}, nil
+}
+
+func (d *rotatedDetector) HighlightDefects(imageData []byte, result *entity.InspectionResult) ([]byte, error) {
+	return imageData, nil
+}
+
+func TestInspectionService_RotatedPartHighlightedOnReference(t *testing.T) {
+	svc := NewInspectionService(NewUserService(storage.NewMemoryUserRepository()), storage.NewMemoryInspectionRepository(), &rotatedDetector{}, nil, entity.SessionPolicy{})
+	ctx := context.Background()
+
+	_, err := svc.users.BeginCheck(ctx, testKey)
+	require.NoError(t, err)
+	_, err = svc.AcceptOriginalPhoto(ctx, testKey, []byte("orig"))
+	require.NoError(t, err)
+
+	// Координаты дефектов — в кадре эталона, поэтому рамки рисуются на нём.
+	output, err := svc.ProcessDefectPhotoDiff(ctx, testKey, []byte("part"), "ru")
+	require.NoError(t, err)
+	require.Equal(t, []byte("orig"), output.Highlighted)
+}
+
+// partsDetector находит на снимке две годные детали.
+type partsDetector struct {
+	rotatedDetector
+}
+
+func (d *partsDetector) InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	return &entity.InspectionResult{
+		Parts: []entity.PartInspection{{Index: 1, Matched: true}, {Index: 2, Matched: true}},
+		Trace: entity.InspectionTrace{Branch: "multi_part"},
+	}, nil
+}
+
+func TestInspectionService_MultiPartOverviewWithoutDefects(t *testing.T) {
+	svc := NewInspectionService(NewUserService(storage.NewMemoryUserRepository()), storage.NewMemoryInspectionRepository(), &partsDetector{}, nil, entity.SessionPolicy{})
+	ctx := context.Background()
+
+	_, err := svc.users.BeginCheck(ctx, testKey)
+	require.NoError(t, err)
+	_, err = svc.AcceptOriginalPhoto(ctx, testKey, []byte("orig"))
+	require.NoError(t, err)
+
+	// Обзор с номерами деталей строится, даже если все детали годны.
+	output, err := svc.ProcessDefectPhotoDiff(ctx, testKey, []byte("parts"), "ru")
+	require.NoError(t, err)
+	require.False(t, output.Result.HasDefects)
+	require.Equal(t, []byte("parts"), output.Highlighted)
+}
+
+// emptyDetector возвращает пустой результат без ошибки.
+type emptyDetector struct {
+	goldenDetector
+}
+
+func (d *emptyDetector) InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	return nil, nil
+}
+
+func TestInspectionService_BatchEmptyResultIsFailure(t *testing.T) {
+	svc := NewInspectionService(NewUserService(storage.NewMemoryUserRepository()), storage.NewMemoryInspectionRepository(), &emptyDetector{}, nil, entity.SessionPolicy{})
+	ctx := context.Background()
+
+	_, err := svc.users.BeginCheck(ctx, testKey)
+	require.NoError(t, err)
+	_, err = svc.AcceptOriginalPhoto(ctx, testKey, []byte("orig"))
+	require.NoError(t, err)
+
+	// Пустой ответ детектора считается сбоем детали, а не годной деталью.
+	items, err := svc.ProcessBatchDiff(ctx, testKey, [][]byte{[]byte("part-1")}, "ru")
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.Error(t, items[0].Err)
+	require.False(t, items[0].Succeeded())
+	require.False(t, BatchItem{Index: 2, Output: &InspectionOutput{}}.Succeeded())
+}
@@ -0,0 +1,276 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"vision-bot/internal/domain/entity"
+)
+
+// Области ограничения частоты.
+const (
+	RateScopeUser = "user"
+	RateScopeChat = "chat"
+)
+
+// RateLimitError возвращается, когда фото приходят чаще, чем разрешено.
+type RateLimitError struct {
+	Scope      string        // RateScopeUser или RateScopeChat
+	RetryAfter time.Duration // через сколько можно прислать следующее фото
+	// Notify — первый отказ подряд: о нём стоит сообщить, об остальных — нет,
+	// чтобы ответы на поток фото сами не превращались в поток.
+	Notify bool
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded for %s, retry after %s", e.Scope, e.RetryAfter)
+}
+
+// RateLimitStats — состояние ограничителя для статистики и метрик.
+type RateLimitStats struct {
+	ThrottledUsers int // отказов по ограничению пользователя
+	ThrottledChats int // отказов по ограничению группового чата
+	Running        int // проверок выполняется сейчас
+	Queued         int // проверок ждут свободного слота
+	MaxConcurrent  int // предел одновременных проверок; 0 — без ограничения
+}
+
+// RateLimiter ограничивает нагрузку на детектор: частоту фото от
+// пользователя и от группового чата, а также число одновременных проверок.
+type RateLimiter struct {
+	policy entity.RateLimitPolicy
+	now    func() time.Time
+
+	mu        sync.Mutex
+	users     map[int64]*rateBucket
+	chats     map[int64]*rateBucket
+	throttled map[string]int
+
+	slots *fairSlots
+}
+
+// NewRateLimiter создаёт ограничитель с политикой policy.
+func NewRateLimiter(policy entity.RateLimitPolicy) *RateLimiter {
+	return &RateLimiter{
+		policy:    policy,
+		now:       time.Now,
+		users:     make(map[int64]*rateBucket),
+		chats:     make(map[int64]*rateBucket),
+		throttled: make(map[string]int),
+		slots:     newFairSlots(policy.MaxConcurrent),
+	}
+}
+
+// Policy возвращает действующие ограничения.
+func (l *RateLimiter) Policy() entity.RateLimitPolicy {
+	return l.policy
+}
+
+// Allow учитывает очередное фото в диалоге key от пользователя с ролью role.
+// При превышении возвращает *RateLimitError. Групповой чат ограничивается
+// отдельно: в личном чате достаточно ограничения пользователя.
+func (l *RateLimiter) Allow(key entity.DialogueKey, role entity.Role) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	userLimit := l.policy.ForRole(role)
+	chatLimit := l.policy.Chat
+	inGroup := key.ChatID != key.UserID
+
+	// Сначала проверяем оба ведра и только потом списываем токены,
+	// чтобы отказ по чату не съедал лимит пользователя.
+	if err := l.check(l.users, key.UserID, userLimit, RateScopeUser, now); err != nil {
+		return err
+	}
+	if inGroup {
+		if err := l.check(l.chats, key.ChatID, chatLimit, RateScopeChat, now); err != nil {
+			return err
+		}
+	}
+
+	take(l.users, key.UserID, userLimit, now)
+	if inGroup {
+		take(l.chats, key.ChatID, chatLimit, now)
+	}
+	return nil
+}
+
+// Acquire ждёт свободного слота для проверки. Слоты раздаются по кругу
+// между пользователями, поэтому партия одного не задерживает остальных.
+// Возвращённую функцию нужно вызвать по завершении проверки.
+func (l *RateLimiter) Acquire(ctx context.Context, userID int64) (func(), error) {
+	if err := l.slots.acquire(ctx, userID); err != nil {
+		return nil, err
+	}
+	var once sync.Once
+	return func() { once.Do(l.slots.release) }, nil
+}
+
+// Stats возвращает счётчики отказов и загрузку слотов.
+func (l *RateLimiter) Stats() RateLimitStats {
+	l.mu.Lock()
+	stats := RateLimitStats{
+		ThrottledUsers: l.throttled[RateScopeUser],
+		ThrottledChats: l.throttled[RateScopeChat],
+		MaxConcurrent:  l.policy.MaxConcurrent,
+	}
+	l.mu.Unlock()
+
+	stats.Running, stats.Queued = l.slots.load()
+	return stats
+}
+
+// rateBucket — ведро вместе с ограничением, по которому оно наполняется.
+type rateBucket struct {
+	bucket *entity.TokenBucket
+	limit  entity.RateLimit
+	warned bool // об отказе уже сообщили, пока ведро пусто
+}
+
+// prune забывает наполнившиеся вёдра, чтобы карты не росли бесконечно.
+func (l *RateLimiter) prune(now time.Time) {
+	for _, buckets := range []map[int64]*rateBucket{l.users, l.chats} {
+		for id, b := range buckets {
+			if b.bucket.Full(b.limit, now) {
+				delete(buckets, id)
+			}
+		}
+	}
+}
+
+// check проверяет, есть ли токен в ведре, не забирая его, и считает отказ.
+func (l *RateLimiter) check(buckets map[int64]*rateBucket, id int64, limit entity.RateLimit, scope string, now time.Time) error {
+	b, ok := buckets[id]
+	if !ok || !limit.Enabled() {
+		return nil
+	}
+	probe := *b.bucket
+	if ok, wait := probe.Take(limit, now); !ok {
+		l.throttled[scope]++
+		notify := !b.warned
+		b.warned = true
+		return &RateLimitError{Scope: scope, RetryAfter: wait, Notify: notify}
+	}
+	return nil
+}
+
+// take забирает токен, создавая ведро при первом событии.
+func take(buckets map[int64]*rateBucket, id int64, limit entity.RateLimit, now time.Time) {
+	if !limit.Enabled() {
+		return
+	}
+	b, ok := buckets[id]
+	if !ok {
+		b = &rateBucket{bucket: entity.NewTokenBucket(limit, now)}
+		buckets[id] = b
+	}
+	// Роль пользователя могла смениться: дальше ведро живёт по новому ограничению.
+	b.limit = limit
+	b.warned = false
+	b.bucket.Take(limit, now)
+}
+
+// fairSlots — семафор с круговой очередью по пользователям.
+type fairSlots struct {
+	mu       sync.Mutex
+	capacity int
+	running  int
+	waiting  map[int64][]chan struct{}
+	order    []int64 // пользователи с ожидающими проверками в порядке обслуживания
+}
+
+func newFairSlots(capacity int) *fairSlots {
+	return &fairSlots{capacity: capacity, waiting: make(map[int64][]chan struct{})}
+}
+
+func (s *fairSlots) acquire(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	if s.capacity <= 0 || (s.running < s.capacity && len(s.order) == 0) {
+		s.running++
+		s.mu.Unlock()
+		return nil
+	}
+
+	ready := make(chan struct{})
+	if len(s.waiting[userID]) == 0 {
+		s.order = append(s.order, userID)
+	}
+	s.waiting[userID] = append(s.waiting[userID], ready)
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		if !s.abandon(userID, ready) {
+			// Слот успели выдать одновременно с отменой — возвращаем его.
+			s.release()
+		}
+		return ctx.Err()
+	}
+}
+
+// abandon убирает ожидание из очереди; false — слот уже выдан.
+func (s *fairSlots) abandon(userID int64, ready chan struct{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queue := s.waiting[userID]
+	for i, ch := range queue {
+		if ch != ready {
+			continue
+		}
+		queue = append(queue[:i], queue[i+1:]...)
+		if len(queue) > 0 {
+			s.waiting[userID] = queue
+			return true
+		}
+		delete(s.waiting, userID)
+		for j, id := range s.order {
+			if id == userID {
+				s.order = append(s.order[:j], s.order[j+1:]...)
+				break
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// release передаёт слот следующему пользователю в очереди или освобождает его.
+func (s *fairSlots) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.order) == 0 {
+		s.running--
+		return
+	}
+
+	userID := s.order[0]
+	s.order = s.order[1:]
+	queue := s.waiting[userID]
+	next := queue[0]
+	if len(queue) > 1 {
+		s.waiting[userID] = queue[1:]
+		s.order = append(s.order, userID)
+	} else {
+		delete(s.waiting, userID)
+	}
+	close(next)
+}
+
+func (s *fairSlots) load() (running, queued int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, queue := range s.waiting {
+		queued += len(queue)
+	}
+	return s.running, queued
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"vision-bot/internal/domain/entity"
+)
+
+func TestRateLimiter_UserAndChatLimits(t *testing.T) {
+	limiter := NewRateLimiter(entity.RateLimitPolicy{
+		Roles: map[entity.Role]entity.RateLimit{
+			entity.RoleOperator: {Events: 2, Per: time.Minute},
+		},
+		Chat: entity.RateLimit{Events: 3, Per: time.Minute},
+	})
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return start }
+
+	group := func(userID int64) entity.DialogueKey { return entity.DialogueKey{ChatID: -100, UserID: userID} }
+	require.NoError(t, limiter.Allow(group(1), entity.RoleOperator))
+	require.NoError(t, limiter.Allow(group(1), entity.RoleOperator))
+
+	var limitErr *RateLimitError
+	err := limiter.Allow(group(1), entity.RoleOperator)
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, RateScopeUser, limitErr.Scope)
+	require.Equal(t, 30*time.Second, limitErr.RetryAfter)
+	require.True(t, limitErr.Notify)
+
+	// О повторном отказе не сообщаем.
+	require.True(t, errors.As(limiter.Allow(group(1), entity.RoleOperator), &limitErr))
+	require.False(t, limitErr.Notify)
+
+	// Третье фото в группе проходит, четвёртое упирается в лимит чата.
+	require.NoError(t, limiter.Allow(group(2), entity.RoleOperator))
+	require.True(t, errors.As(limiter.Allow(group(2), entity.RoleOperator), &limitErr))
+	require.Equal(t, RateScopeChat, limitErr.Scope)
+
+	// Личный чат ограничен только лимитом пользователя; у админа лимита нет.
+	require.NoError(t, limiter.Allow(entity.PrivateDialogue(2), entity.RoleOperator))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, limiter.Allow(entity.PrivateDialogue(3), entity.RoleAdmin))
+	}
+
+	limiter.now = func() time.Time { return start.Add(time.Minute) }
+	require.NoError(t, limiter.Allow(group(1), entity.RoleOperator))
+
+	stats := limiter.Stats()
+	require.Equal(t, 2, stats.ThrottledUsers)
+	require.Equal(t, 1, stats.ThrottledChats)
+}
+
+func TestRateLimiter_FairConcurrency(t *testing.T) {
+	limiter := NewRateLimiter(entity.RateLimitPolicy{MaxConcurrent: 1})
+	ctx := context.Background()
+
+	release, err := limiter.Acquire(ctx, 1)
+	require.NoError(t, err)
+
+	// Пользователь 1 ставит в очередь партию, пользователь 2 — одну проверку.
+	var (
+		mu    sync.Mutex
+		order []int64
+		wg    sync.WaitGroup
+	)
+	enqueue := func(userID int64, queued int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			done, err := limiter.Acquire(ctx, userID)
+			require.NoError(t, err)
+			mu.Lock()
+			order = append(order, userID)
+			mu.Unlock()
+			done()
+		}()
+		// Ждём, пока горутина встанет в очередь, чтобы порядок был детерминированным.
+		require.Eventually(t, func() bool {
+			return limiter.Stats().Queued == queued
+		}, time.Second, time.Millisecond)
+	}
+	enqueue(1, 1)
+	enqueue(1, 2)
+	enqueue(2, 3)
+
+	release()
+	release() // повторный вызов ничего не освобождает
+	wg.Wait()
+
+	require.Equal(t, []int64{1, 2, 1}, order)
+	require.Equal(t, 0, limiter.Stats().Running)
+
+	// Отменённое ожидание не занимает слот.
+	release, err = limiter.Acquire(ctx, 1)
+	require.NoError(t, err)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = limiter.Acquire(cancelled, 2)
+	require.ErrorIs(t, err, context.Canceled)
+	release()
+	require.Equal(t, RateLimitStats{MaxConcurrent: 1}, limiter.Stats())
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+
+	"vision-bot/internal/domain/entity"
+)
+
+// Stats — сводка для администратора.
+type Stats struct {
+	Users          int                 // известных боту пользователей
+	Roles          map[entity.Role]int // пользователей по действующей роли (без доступа — RoleNone)
+	PendingChecks  int                 // диалогов с незавершённой проверкой
+	ActiveSessions int                 // диалогов с активным эталоном
+	Inspections    entity.InspectionStats
+	RateLimits     RateLimitStats
+}
+
+// StatsService собирает статистику работы бота.
+type StatsService struct {
+	users       *UserService
+	inspections *InspectionService
+	access      *AccessService
+	limiter     *RateLimiter
+}
+
+// NewStatsService создаёт сервис статистики.
+func NewStatsService(users *UserService, inspections *InspectionService, access *AccessService, limiter *RateLimiter) *StatsService {
+	return &StatsService{users: users, inspections: inspections, access: access, limiter: limiter}
+}
+
+// Collect возвращает статистику, если у запросившего есть право её смотреть.
+func (s *StatsService) Collect(ctx context.Context, userID, chatID int64) (*Stats, error) {
+	if err := s.access.Authorize(ctx, userID, chatID, entity.PermViewStats); err != nil {
+		return nil, err
+	}
+
+	users, err := s.users.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inspections, err := s.inspections.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{
+		Roles:          make(map[entity.Role]int),
+		ActiveSessions: s.inspections.ActiveSessions(),
+		Inspections:    inspections,
+		RateLimits:     s.limiter.Stats(),
+	}
+
+	// У пользователя может быть несколько диалогов (личный чат, группы):
+	// в статистику попадает его старшая роль.
+	policy := s.access.Policy()
+	roles := make(map[int64]entity.Role)
+	for _, user := range users {
+		role := policy.RoleFor(user.ID, user.ChatID).Max(user.Role)
+		roles[user.ID] = roles[user.ID].Max(role)
+		if user.HasPendingCheck() {
+			stats.PendingChecks++
+		}
+	}
+	stats.Users = len(roles)
+	for _, role := range roles {
+		stats.Roles[role]++
+	}
+	return stats, nil
+}
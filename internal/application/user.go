@@ -2,6 +2,8 @@ package app
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"vision-bot/internal/domain/entity"
 	"vision-bot/internal/domain/port"
@@ -9,26 +11,35 @@ import (
 
 type UserService struct {
 	repo port.UserRepository
+	// mu сериализует переходы: результат проверки приходит из фоновой горутины.
+	mu  sync.Mutex
+	now func() time.Time
 }
 
 // NewUserService создаёт сервис, который управляет состоянием пользователя.
 func NewUserService(repo port.UserRepository) *UserService {
-	return &UserService{repo: repo}
+	return &UserService{repo: repo, now: time.Now}
 }
 
-// Get возвращает пользователя из репозитория (или создаёт, если его нет).
-func (s *UserService) Get(ctx context.Context, userID, chatID int64) (*entity.User, error) {
-	return s.repo.Get(ctx, userID, chatID)
+// Get возвращает пользователя в диалоге (или создаёт, если его нет).
+func (s *UserService) Get(ctx context.Context, key entity.DialogueKey) (*entity.User, error) {
+	return s.repo.Get(ctx, key)
 }
 
-// SetState меняет состояние пользователя и сохраняет его.
-func (s *UserService) SetState(ctx context.Context, userID, chatID int64, state entity.UserState) (*entity.User, error) {
-	user, err := s.repo.Get(ctx, userID, chatID)
+// Fire применяет событие диалога по таблице переходов и сохраняет пользователя.
+// Недопустимый переход возвращает *entity.TransitionError, состояние не меняется.
+func (s *UserService) Fire(ctx context.Context, key entity.DialogueKey, event entity.Event, guards entity.GuardContext) (*entity.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.repo.Get(ctx, key)
 	if err != nil {
 		return nil, err
 	}
 
-	user.SetState(state)
+	if err := user.Fire(event, guards, s.now()); err != nil {
+		return user, err
+	}
 	if err := s.repo.Save(ctx, user); err != nil {
 		return nil, err
 	}
@@ -37,11 +48,153 @@ func (s *UserService) SetState(ctx context.Context, userID, chatID int64, state
 }
 
 // BeginCheck переводит пользователя в состояние ожидания оригинального фото.
-func (s *UserService) BeginCheck(ctx context.Context, userID, chatID int64) (*entity.User, error) {
-	return s.SetState(ctx, userID, chatID, entity.StateAwaitingOriginalPhoto)
+func (s *UserService) BeginCheck(ctx context.Context, key entity.DialogueKey) (*entity.User, error) {
+	return s.Fire(ctx, key, entity.EventBeginCheck, entity.GuardContext{})
 }
 
 // Cancel сбрасывает состояние пользователя в главное меню.
-func (s *UserService) Cancel(ctx context.Context, userID, chatID int64) (*entity.User, error) {
-	return s.SetState(ctx, userID, chatID, entity.StateMainMenu)
+func (s *UserService) Cancel(ctx context.Context, key entity.DialogueKey) (*entity.User, error) {
+	return s.Fire(ctx, key, entity.EventCancel, entity.GuardContext{})
+}
+
+// Reset принудительно возвращает пользователя в главное меню в обход таблицы переходов.
+// Нужен только для восстановления из неизвестного состояния.
+func (s *UserService) Reset(ctx context.Context, key entity.DialogueKey) (*entity.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.repo.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	user.SetState(entity.StateMainMenu)
+	if err := s.repo.Save(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Profile возвращает запись личного чата, в которой хранятся язык и роль пользователя.
+func (s *UserService) Profile(ctx context.Context, userID int64) (*entity.User, error) {
+	return s.repo.Get(ctx, entity.PrivateDialogue(userID))
+}
+
+// Identify возвращает профиль пользователя и запоминает язык его клиента Telegram.
+// Профиль сохраняется, только если язык изменился.
+func (s *UserService) Identify(ctx context.Context, userID int64, languageCode string) (*entity.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.repo.Get(ctx, entity.PrivateDialogue(userID))
+	if err != nil {
+		return nil, err
+	}
+	if languageCode == "" || user.ClientLanguage == languageCode {
+		return user, nil
+	}
+
+	user.ClientLanguage = languageCode
+	if err := s.repo.Save(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetLanguage сохраняет язык, выбранный пользователем вручную.
+// Пустая строка возвращает язык клиента Telegram.
+func (s *UserService) SetLanguage(ctx context.Context, userID int64, lang string) (*entity.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.repo.Get(ctx, entity.PrivateDialogue(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	user.Language = lang
+	if err := s.repo.Save(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetRole сохраняет роль, выданную администратором.
+func (s *UserService) SetRole(ctx context.Context, userID int64, role entity.Role) (*entity.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.repo.Get(ctx, entity.PrivateDialogue(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	user.Role = role
+	if err := s.repo.Save(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// List возвращает все известные диалоги.
+func (s *UserService) List(ctx context.Context) ([]*entity.User, error) {
+	return s.repo.List(ctx)
+}
+
+// idleAction — что сделать с пользователем, который долго не отвечает.
+type idleAction int
+
+const (
+	idleNone idleAction = iota
+	idleRemind
+	idleExpire
+)
+
+// checkIdle под блокировкой решает, напомнить ли пользователю о проверке или сбросить её.
+// Возвращает копию пользователя до сброса, чтобы бот знал, о чём писать.
+func (s *UserService) checkIdle(ctx context.Context, key entity.DialogueKey, now time.Time, policy entity.IdlePolicy) (idleAction, entity.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.repo.Get(ctx, key)
+	if err != nil {
+		return idleNone, entity.User{}, err
+	}
+	// Обработку ждёт бот, а не пользователь: её завершит фоновая задача.
+	if !user.HasPendingCheck() || user.State == entity.StateProcessing {
+		return idleNone, entity.User{}, nil
+	}
+
+	snapshot := *user.Clone()
+	age := user.StateAge(now)
+	switch {
+	case policy.ExpireAfter > 0 && age >= policy.ExpireAfter:
+		if err := user.Fire(entity.EventTimeout, entity.GuardContext{}, now); err != nil {
+			return idleNone, entity.User{}, err
+		}
+		if err := s.repo.Save(ctx, user); err != nil {
+			return idleNone, entity.User{}, err
+		}
+		return idleExpire, snapshot, nil
+
+	case policy.RemindAfter > 0 && age >= policy.RemindAfter && !user.Reminded:
+		user.Reminded = true
+		if err := s.repo.Save(ctx, user); err != nil {
+			return idleNone, entity.User{}, err
+		}
+		return idleRemind, snapshot, nil
+	}
+
+	return idleNone, entity.User{}, nil
+}
+
+// Flush сбрасывает состояние пользователей в хранилище перед остановкой.
+func (s *UserService) Flush(ctx context.Context) error {
+	return s.repo.Flush(ctx)
+}
+
+// Ping проверяет, что хранилище пользователей доступно.
+func (s *UserService) Ping(ctx context.Context) error {
+	return s.repo.Ping(ctx)
 }
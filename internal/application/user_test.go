@@ -15,21 +15,45 @@ func TestUserService_BeginCheckAndCancel(t *testing.T) {
 	svc := NewUserService(repo)
 	ctx := context.Background()
 
-	user, err := svc.BeginCheck(ctx, 1, 10)
+	user, err := svc.BeginCheck(ctx, testKey)
 	require.NoError(t, err)
 	require.Equal(t, entity.StateAwaitingOriginalPhoto, user.State)
 
-	user, err = svc.Cancel(ctx, 1, 10)
+	user, err = svc.Cancel(ctx, testKey)
 	require.NoError(t, err)
 	require.Equal(t, entity.StateMainMenu, user.State)
 }
 
-func TestUserService_SetState(t *testing.T) {
+func TestUserService_FireRejectsInvalidTransition(t *testing.T) {
 	repo := storage.NewMemoryUserRepository()
 	svc := NewUserService(repo)
 	ctx := context.Background()
 
-	user, err := svc.SetState(ctx, 2, 20, entity.StateAwaitingDefectPhoto)
+	user, err := svc.Fire(ctx, entity.DialogueKey{ChatID: 20, UserID: 2}, entity.EventOriginalReceived, entity.GuardContext{})
+	require.ErrorIs(t, err, entity.ErrInvalidTransition)
+	require.Equal(t, entity.StateMainMenu, user.State)
+
+	user, err = svc.Reset(ctx, entity.DialogueKey{ChatID: 20, UserID: 2})
+	require.NoError(t, err)
+	require.Equal(t, entity.StateMainMenu, user.State)
+}
+
+func TestUserService_Language(t *testing.T) {
+	repo := storage.NewMemoryUserRepository()
+	svc := NewUserService(repo)
+	ctx := context.Background()
+
+	user, err := svc.Identify(ctx, 3, "en-US")
+	require.NoError(t, err)
+	require.Equal(t, "en-US", user.ClientLanguage)
+	require.Empty(t, user.Language)
+
+	// Пустой LanguageCode не затирает известный язык клиента.
+	user, err = svc.Identify(ctx, 3, "")
+	require.NoError(t, err)
+	require.Equal(t, "en-US", user.ClientLanguage)
+
+	user, err = svc.SetLanguage(ctx, 3, "kk")
 	require.NoError(t, err)
-	require.Equal(t, entity.StateAwaitingDefectPhoto, user.State)
+	require.Equal(t, "kk", user.Language)
 }
@@ -0,0 +1,125 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"vision-bot/internal/domain/entity"
+)
+
+// zonesFile — JSON с зонами эталона. Вершины задаются в долях кадра (0–1)
+// или, если указаны width и height, в пикселях снимка такого размера.
+type zonesFile struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	Zones  []struct {
+		Name        string       `json:"name"`
+		Kind        string       `json:"kind"`
+		Sensitivity float64      `json:"sensitivity"`
+		Polygon     [][2]float64 `json:"polygon"`
+	} `json:"zones"`
+}
+
+// ParseZones разбирает и проверяет зоны из JSON. Зонам без имени
+// присваиваются имена zone1, zone2 и т. д. по порядку.
+func ParseZones(data []byte) ([]entity.Zone, error) {
+	var file zonesFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("parse zones: %w", err)
+	}
+	if (file.Width > 0) != (file.Height > 0) || file.Width < 0 || file.Height < 0 {
+		return nil, errors.New("parse zones: width and height must be set together")
+	}
+	if len(file.Zones) == 0 {
+		return nil, errors.New("parse zones: no zones")
+	}
+
+	scaleX, scaleY := 1.0, 1.0
+	if file.Width > 0 {
+		scaleX, scaleY = 1/float64(file.Width), 1/float64(file.Height)
+	}
+	zones := make([]entity.Zone, 0, len(file.Zones))
+	for i, raw := range file.Zones {
+		zone := entity.Zone{
+			Name:        raw.Name,
+			Kind:        entity.ZoneKind(raw.Kind),
+			Sensitivity: raw.Sensitivity,
+			Polygon:     make([]entity.ZonePoint, 0, len(raw.Polygon)),
+		}
+		if zone.Name == "" {
+			zone.Name = fmt.Sprintf("zone%d", i+1)
+		}
+		for _, point := range raw.Polygon {
+			zone.Polygon = append(zone.Polygon, entity.ZonePoint{X: point[0] * scaleX, Y: point[1] * scaleY})
+		}
+		zones = append(zones, zone)
+	}
+	if err := entity.ValidateZones(zones); err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+// Zones возвращает зоны эталона; nil — эталона нет или зоны не заданы.
+func (s *InspectionService) Zones(key entity.DialogueKey) []entity.Zone {
+	_, _, zones := s.referenceModel(key)
+	return slices.Clone(zones)
+}
+
+// SetZones заменяет зоны эталона; nil снимает все зоны. С новым эталоном
+// зоны сбрасываются: они описывают участки именно этого снимка.
+func (s *InspectionService) SetZones(ctx context.Context, key entity.DialogueKey, zones []entity.Zone) error {
+	if err := entity.ValidateZones(zones); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[key]
+	if !ok {
+		return ErrNoReference
+	}
+	session.Zones = slices.Clone(zones)
+	slog.InfoContext(ctx, "Reference zones set", "zones", len(zones))
+	return nil
+}
+
+// CycleZoneCell переключает режим ячейки сетки rows×cols по кругу:
+// без зоны → не проверять → строже → без зоны. Возвращает новые зоны.
+func (s *InspectionService) CycleZoneCell(ctx context.Context, key entity.DialogueKey, row, col, rows, cols int) ([]entity.Zone, error) {
+	if row < 0 || row >= rows || col < 0 || col >= cols {
+		return nil, fmt.Errorf("grid cell %d:%d is outside %dx%d", row, col, rows, cols)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[key]
+	if !ok {
+		return nil, ErrNoReference
+	}
+
+	name := entity.GridCellName(row, col)
+	// Срез сессии не меняется на месте: его копии могли уйти в проверки.
+	zones := slices.Clone(session.Zones)
+	index := slices.IndexFunc(zones, func(z entity.Zone) bool { return z.Name == name })
+	switch {
+	case index < 0:
+		zones = append(zones, entity.GridCell(row, col, rows, cols, entity.ZoneIgnore))
+	case zones[index].Kind == entity.ZoneIgnore:
+		zones[index].Kind = entity.ZoneCritical
+	default:
+		zones = slices.Delete(zones, index, index+1)
+	}
+	session.Zones = zones
+	slog.DebugContext(ctx, "Reference grid cell changed", "cell", name, "zones", len(zones))
+	return slices.Clone(zones), nil
+}
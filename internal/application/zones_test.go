@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/infrastructure/storage"
+)
+
+func TestParseZones(t *testing.T) {
+	zones, err := ParseZones([]byte(`{
+		"width": 200, "height": 100,
+		"zones": [
+			{"name": "serial", "kind": "ignore", "polygon": [[20, 10], [100, 10], [100, 30], [20, 30]]},
+			{"kind": "critical", "sensitivity": 3, "polygon": [[0, 50], [200, 50], [200, 100]]}
+		]
+	}`))
+	require.NoError(t, err)
+	require.Len(t, zones, 2)
+	require.Equal(t, entity.ZonePoint{X: 0.1, Y: 0.1}, zones[0].Polygon[0])
+	require.Equal(t, "zone2", zones[1].Name)
+	require.Equal(t, 3.0, zones[1].Sensitivity)
+
+	_, err = ParseZones([]byte(`{"zones": [{"kind": "ignore", "polygon": [[0, 0], [2, 0], [2, 2]]}]}`))
+	require.ErrorContains(t, err, "outside the frame")
+	_, err = ParseZones([]byte(`{"zones": [{"kind": "ignore", "shape": []}]}`))
+	require.ErrorContains(t, err, "unknown field")
+	_, err = ParseZones([]byte(`{"width": 100, "zones": []}`))
+	require.ErrorContains(t, err, "width and height")
+}
+
+func TestInspectionService_ZonesBelongToReference(t *testing.T) {
+	detector := &goldenDetector{}
+	svc := NewInspectionService(NewUserService(storage.NewMemoryUserRepository()), storage.NewMemoryInspectionRepository(), detector, nil, entity.SessionPolicy{})
+	ctx := context.Background()
+
+	_, err := svc.CycleZoneCell(ctx, testKey, 0, 0, 4, 4)
+	require.ErrorIs(t, err, ErrNoReference)
+
+	_, err = svc.users.BeginCheck(ctx, testKey)
+	require.NoError(t, err)
+	_, err = svc.AcceptOriginalPhoto(ctx, testKey, []byte("orig"))
+	require.NoError(t, err)
+
+	// Ячейка сетки переключается по кругу: не проверять → строже → без зоны.
+	zones, err := svc.CycleZoneCell(ctx, testKey, 1, 2, 4, 4)
+	require.NoError(t, err)
+	require.Equal(t, []entity.Zone{entity.GridCell(1, 2, 4, 4, entity.ZoneIgnore)}, zones)
+	zones, err = svc.CycleZoneCell(ctx, testKey, 1, 2, 4, 4)
+	require.NoError(t, err)
+	require.Equal(t, entity.ZoneCritical, zones[0].Kind)
+
+	_, err = svc.ProcessDefectPhotoDiff(ctx, testKey, []byte("part"), "ru")
+	require.NoError(t, err)
+	require.Equal(t, zones, detector.zones)
+
+	zones, err = svc.CycleZoneCell(ctx, testKey, 1, 2, 4, 4)
+	require.NoError(t, err)
+	require.Empty(t, zones)
+	_, err = svc.CycleZoneCell(ctx, testKey, 4, 0, 4, 4)
+	require.Error(t, err)
+
+	// Зоны относятся к снимку эталона: с новым эталоном они сбрасываются.
+	require.NoError(t, svc.SetZones(ctx, testKey, []entity.Zone{entity.GridCell(0, 0, 2, 2, entity.ZoneIgnore)}))
+	require.Len(t, svc.Zones(testKey), 1)
+	_, err = svc.NewReference(ctx, testKey)
+	require.NoError(t, err)
+	_, err = svc.AcceptOriginalPhoto(ctx, testKey, []byte("orig-2"))
+	require.NoError(t, err)
+	require.Empty(t, svc.Zones(testKey))
+
+	require.Error(t, svc.SetZones(ctx, testKey, []entity.Zone{{Name: "bad", Kind: entity.ZoneIgnore}}))
+}
@@ -1,22 +1,53 @@
 package container
 
 import (
+	"context"
+	"errors"
+
 	app "vision-bot/internal/application"
+	"vision-bot/internal/domain/entity"
 	"vision-bot/internal/domain/port"
 )
 
 type Container struct {
 	UserService       *app.UserService
 	InspectionService *app.InspectionService
+	IdleService       *app.IdleService
+	AccessService     *app.AccessService
+	StatsService      *app.StatsService
+	RateLimiter       *app.RateLimiter
 }
 
 // New собирает все сервисы приложения в одном месте.
-func New(userRepo port.UserRepository, detector port.DefectDetector, describer port.DefectDescriber) *Container {
+func New(userRepo port.UserRepository, inspectionRepo port.InspectionRepository, detector port.DefectDetector, describer port.DefectDescriber, sessionPolicy entity.SessionPolicy, idlePolicy entity.IdlePolicy, accessPolicy entity.AccessPolicy, rateLimitPolicy entity.RateLimitPolicy) *Container {
 	userService := app.NewUserService(userRepo)
-	inspectionService := app.NewInspectionService(userService, detector, describer)
+	inspectionService := app.NewInspectionService(userService, inspectionRepo, detector, describer, sessionPolicy)
+
+	idleService := app.NewIdleService(userService, inspectionService, idlePolicy)
+	accessService := app.NewAccessService(userService, accessPolicy)
+	rateLimiter := app.NewRateLimiter(rateLimitPolicy)
+	statsService := app.NewStatsService(userService, inspectionService, accessService, rateLimiter)
 
 	return &Container{
 		UserService:       userService,
 		InspectionService: inspectionService,
+		IdleService:       idleService,
+		AccessService:     accessService,
+		StatsService:      statsService,
+		RateLimiter:       rateLimiter,
 	}
 }
+
+// Close сбрасывает репозитории при остановке приложения.
+func (c *Container) Close(ctx context.Context) error {
+	userErr := c.UserService.Flush(ctx)
+	historyErr := c.InspectionService.Flush(ctx)
+	return errors.Join(userErr, historyErr)
+}
+
+// Ping проверяет, что репозитории открыты.
+func (c *Container) Ping(ctx context.Context) error {
+	userErr := c.UserService.Ping(ctx)
+	historyErr := c.InspectionService.Ping(ctx)
+	return errors.Join(userErr, historyErr)
+}
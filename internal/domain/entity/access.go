@@ -0,0 +1,116 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Role определяет, какие действия доступны пользователю.
+// Роли вложены: инспектор может всё, что оператор, администратор — всё.
+type Role string
+
+const (
+	RoleNone      Role = ""          // Доступа нет
+	RoleOperator  Role = "operator"  // Запускает проверки
+	RoleInspector Role = "inspector" // Управляет эталонами и отмечает ложные срабатывания
+	RoleAdmin     Role = "admin"     // Управляет пользователями, смотрит статистику, меняет профили
+)
+
+// Permission — отдельное действие, требующее проверки прав.
+type Permission string
+
+const (
+	PermRunCheck          Permission = "run_check"
+	PermManageReference   Permission = "manage_reference"
+	PermMarkFalsePositive Permission = "mark_false_positive"
+	PermManageUsers       Permission = "manage_users"
+	PermViewStats         Permission = "view_stats"
+	PermChangeProfiles    Permission = "change_profiles"
+)
+
+// Roles перечисляет роли от младшей к старшей.
+var Roles = []Role{RoleOperator, RoleInspector, RoleAdmin}
+
+// rolePermissions — права, которые роль добавляет к правам младших ролей.
+var rolePermissions = map[Role][]Permission{
+	RoleOperator:  {PermRunCheck},
+	RoleInspector: {PermManageReference, PermMarkFalsePositive},
+	RoleAdmin:     {PermManageUsers, PermViewStats, PermChangeProfiles},
+}
+
+// ErrUnknownRole возвращается при разборе неизвестной роли.
+var ErrUnknownRole = errors.New("unknown role")
+
+// ParseRole разбирает название роли из команды администратора.
+func ParseRole(raw string) (Role, error) {
+	role := Role(raw)
+	if !slices.Contains(Roles, role) {
+		return RoleNone, fmt.Errorf("%w %q", ErrUnknownRole, raw)
+	}
+	return role, nil
+}
+
+// rank возвращает старшинство роли; у RoleNone и неизвестных ролей оно -1.
+func (r Role) rank() int {
+	return slices.Index(Roles, r)
+}
+
+// Can сообщает, разрешено ли роли действие.
+func (r Role) Can(perm Permission) bool {
+	for _, role := range Roles[:r.rank()+1] {
+		if slices.Contains(rolePermissions[role], perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Max возвращает старшую из двух ролей.
+func (r Role) Max(other Role) Role {
+	if other.rank() > r.rank() {
+		return other
+	}
+	return r
+}
+
+// AccessPolicy задаёт, кто может пользоваться ботом без выданной роли.
+// Если ни один список не задан, бот открыт для всех с ролью OpenRole.
+type AccessPolicy struct {
+	Admins       []int64 // администраторы из конфигурации; их роль нельзя отозвать
+	AllowedUsers []int64 // пользователи, допущенные с ролью DefaultRole
+	AllowedChats []int64 // группы, все участники которых допущены с ролью DefaultRole
+	DefaultRole  Role    // роль для допущенных по спискам; пусто — оператор
+}
+
+// OpenRole — роль каждого пользователя, когда контроль доступа не настроен.
+// Совпадает с возможностями бота до появления ролей.
+const OpenRole = RoleInspector
+
+// Open сообщает, что контроль доступа не настроен.
+func (p AccessPolicy) Open() bool {
+	return len(p.Admins) == 0 && len(p.AllowedUsers) == 0 && len(p.AllowedChats) == 0
+}
+
+// IsAdmin сообщает, назначен ли пользователь администратором в конфигурации.
+func (p AccessPolicy) IsAdmin(userID int64) bool {
+	return slices.Contains(p.Admins, userID)
+}
+
+// RoleFor возвращает роль, которую политика даёт пользователю в чате,
+// без учёта ролей, выданных командами.
+func (p AccessPolicy) RoleFor(userID, chatID int64) Role {
+	switch {
+	case p.IsAdmin(userID):
+		return RoleAdmin
+	case p.Open():
+		return OpenRole
+	case slices.Contains(p.AllowedUsers, userID), slices.Contains(p.AllowedChats, chatID):
+		if p.DefaultRole == RoleNone {
+			return RoleOperator
+		}
+		return p.DefaultRole
+	default:
+		return RoleNone
+	}
+}
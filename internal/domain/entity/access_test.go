@@ -0,0 +1,49 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRole_Can(t *testing.T) {
+	require.False(t, RoleNone.Can(PermRunCheck))
+
+	require.True(t, RoleOperator.Can(PermRunCheck))
+	require.False(t, RoleOperator.Can(PermManageReference))
+	require.False(t, RoleOperator.Can(PermMarkFalsePositive))
+
+	require.True(t, RoleInspector.Can(PermRunCheck))
+	require.True(t, RoleInspector.Can(PermManageReference))
+	require.True(t, RoleInspector.Can(PermMarkFalsePositive))
+	require.False(t, RoleInspector.Can(PermManageUsers))
+
+	for _, perm := range []Permission{PermRunCheck, PermManageReference, PermMarkFalsePositive, PermManageUsers, PermViewStats, PermChangeProfiles} {
+		require.True(t, RoleAdmin.Can(perm), perm)
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	role, err := ParseRole("inspector")
+	require.NoError(t, err)
+	require.Equal(t, RoleInspector, role)
+
+	_, err = ParseRole("root")
+	require.ErrorIs(t, err, ErrUnknownRole)
+}
+
+func TestAccessPolicy_RoleFor(t *testing.T) {
+	require.True(t, AccessPolicy{}.Open())
+	require.Equal(t, OpenRole, AccessPolicy{}.RoleFor(1, 1))
+
+	policy := AccessPolicy{Admins: []int64{1}, AllowedUsers: []int64{2}, AllowedChats: []int64{-100}}
+	require.False(t, policy.Open())
+	require.Equal(t, RoleAdmin, policy.RoleFor(1, 1))
+	require.Equal(t, RoleOperator, policy.RoleFor(2, 2))
+	require.Equal(t, RoleOperator, policy.RoleFor(3, -100))
+	require.Equal(t, RoleNone, policy.RoleFor(3, 3))
+
+	policy.DefaultRole = RoleInspector
+	require.Equal(t, RoleInspector, policy.RoleFor(2, 2))
+	require.Equal(t, RoleInspector, RoleOperator.Max(RoleInspector))
+}
@@ -0,0 +1,112 @@
+package entity
+
+import "math"
+
+// CalibrationSource — откуда взят масштаб снимка.
+type CalibrationSource string
+
+const (
+	// CalibrationManual — инспектор ввёл длину детали на эталоне.
+	CalibrationManual CalibrationSource = "manual"
+	// CalibrationChessboard — в кадре найдена печатная шахматная доска.
+	CalibrationChessboard CalibrationSource = "chessboard"
+	// CalibrationAruco — в кадре найден печатный ArUco-маркер.
+	CalibrationAruco CalibrationSource = "aruco"
+)
+
+// Calibration — масштаб снимка: сколько миллиметров приходится на пиксель.
+// Масштаб относится к снимку ImageWidth×ImageHeight; результат проверки
+// в другом разрешении пересчитывается пропорционально.
+type Calibration struct {
+	MMPerPixel  float64
+	ImageWidth  int
+	ImageHeight int
+	Source      CalibrationSource
+}
+
+// Valid сообщает, можно ли пересчитывать по калибровке пиксели в миллиметры.
+func (c Calibration) Valid() bool {
+	return c.MMPerPixel > 0 && c.ImageWidth > 0 && c.ImageHeight > 0
+}
+
+// Scale возвращает миллиметры на пиксель по осям для снимка width×height.
+func (c Calibration) Scale(width, height int) (x, y float64) {
+	if !c.Valid() || width <= 0 || height <= 0 {
+		return c.MMPerPixel, c.MMPerPixel
+	}
+	return c.MMPerPixel * float64(c.ImageWidth) / float64(width),
+		c.MMPerPixel * float64(c.ImageHeight) / float64(height)
+}
+
+// Measure записывает физический размер каждого дефекта результата.
+func (c Calibration) Measure(result *InspectionResult) {
+	if !c.Valid() || result == nil {
+		return
+	}
+	x, y := c.Scale(result.ImageWidth, result.ImageHeight)
+	for i := range result.Defects {
+		d := &result.Defects[i]
+		width, height := float64(d.Width)*x, float64(d.Height)*y
+		d.Size = PhysicalSize{
+			WidthMM:  width,
+			HeightMM: height,
+			LengthMM: math.Max(width, height),
+			AreaMM2:  float64(d.Area) * x * y,
+		}
+	}
+}
+
+// PhysicalSize — размер дефекта в миллиметрах; нулевой, если снимок не откалиброван.
+type PhysicalSize struct {
+	WidthMM  float64
+	HeightMM float64
+	// LengthMM — наибольший размер рамки: длина царапины, размер скола.
+	LengthMM float64
+	AreaMM2  float64
+}
+
+// Measured сообщает, известен ли физический размер.
+func (s PhysicalSize) Measured() bool {
+	return s.LengthMM > 0
+}
+
+// Tolerance — допуск в миллиметрах: дефекты меньше него не бракуют деталь.
+// Нулевое поле означает, что по этому признаку допуска нет.
+type Tolerance struct {
+	MinLengthMM float64
+	MinAreaMM2  float64
+}
+
+// Allows сообщает, укладывается ли дефект в допуск: он должен быть меньше
+// каждого заданного порога. Длинная тонкая царапина с малой площадью деталь
+// бракует. Дефект без физического размера в допуск не укладывается: без
+// калибровки мерить нечем.
+func (t Tolerance) Allows(d DefectArea) bool {
+	if !d.Size.Measured() || (t.MinLengthMM <= 0 && t.MinAreaMM2 <= 0) {
+		return false
+	}
+	if t.MinLengthMM > 0 && d.Size.LengthMM >= t.MinLengthMM {
+		return false
+	}
+	if t.MinAreaMM2 > 0 && d.Size.AreaMM2 >= t.MinAreaMM2 {
+		return false
+	}
+	return true
+}
+
+// Apply переносит дефекты в пределах допуска в Tolerated и пересчитывает вердикт.
+func (t Tolerance) Apply(result *InspectionResult) {
+	if result == nil || (t.MinLengthMM <= 0 && t.MinAreaMM2 <= 0) {
+		return
+	}
+	kept := make([]DefectArea, 0, len(result.Defects))
+	for _, d := range result.Defects {
+		if t.Allows(d) {
+			result.Tolerated = append(result.Tolerated, d)
+			continue
+		}
+		kept = append(kept, d)
+	}
+	result.Defects = kept
+	result.HasDefects = len(kept) > 0
+}
@@ -0,0 +1,64 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalibration_MeasureScalesToResultFrame(t *testing.T) {
+	// Эталон 2000×1000 при 0,05 мм/пикс, проверка шла в кадре вдвое меньше.
+	calibration := Calibration{MMPerPixel: 0.05, ImageWidth: 2000, ImageHeight: 1000, Source: CalibrationManual}
+	result := &InspectionResult{
+		ImageWidth:  1000,
+		ImageHeight: 500,
+		Defects:     []DefectArea{{Width: 10, Height: 4, Area: 30}},
+		HasDefects:  true,
+	}
+
+	calibration.Measure(result)
+	size := result.Defects[0].Size
+	require.InDelta(t, 1.0, size.WidthMM, 1e-9)
+	require.InDelta(t, 0.4, size.HeightMM, 1e-9)
+	require.InDelta(t, 1.0, size.LengthMM, 1e-9)
+	require.InDelta(t, 0.3, size.AreaMM2, 1e-9)
+
+	// Без калибровки размеры остаются неизвестными.
+	uncalibrated := &InspectionResult{ImageWidth: 100, ImageHeight: 100, Defects: []DefectArea{{Width: 10, Height: 10}}}
+	Calibration{}.Measure(uncalibrated)
+	require.False(t, uncalibrated.Defects[0].Size.Measured())
+}
+
+func TestTolerance_Apply(t *testing.T) {
+	chip := DefectArea{X: 1, Size: PhysicalSize{LengthMM: 0.3, AreaMM2: 0.05}}
+	scratch := DefectArea{X: 2, Size: PhysicalSize{LengthMM: 4, AreaMM2: 0.8}}
+	unmeasured := DefectArea{X: 3}
+	result := &InspectionResult{Defects: []DefectArea{chip, scratch, unmeasured}, HasDefects: true}
+
+	Tolerance{MinLengthMM: 0.5}.Apply(result)
+	require.Equal(t, []DefectArea{scratch, unmeasured}, result.Defects)
+	require.Equal(t, []DefectArea{chip}, result.Tolerated)
+	require.True(t, result.HasDefects)
+
+	// Допуск по площади: всё, что меньше 1 мм², не бракует деталь.
+	result = &InspectionResult{Defects: []DefectArea{chip, scratch}, HasDefects: true}
+	Tolerance{MinAreaMM2: 1}.Apply(result)
+	require.Empty(t, result.Defects)
+	require.Len(t, result.Tolerated, 2)
+	require.False(t, result.HasDefects)
+
+	// Оба порога: в допуске только то, что меньше каждого из них.
+	// Царапина 20 мм с площадью меньше порога деталь бракует.
+	hairline := DefectArea{X: 4, Size: PhysicalSize{LengthMM: 20, AreaMM2: 0.4}}
+	result = &InspectionResult{Defects: []DefectArea{chip, hairline}, HasDefects: true}
+	Tolerance{MinLengthMM: 0.5, MinAreaMM2: 1}.Apply(result)
+	require.Equal(t, []DefectArea{hairline}, result.Defects)
+	require.Equal(t, []DefectArea{chip}, result.Tolerated)
+	require.True(t, result.HasDefects)
+
+	// Нулевой допуск ничего не меняет.
+	result = &InspectionResult{Defects: []DefectArea{chip}, HasDefects: true}
+	Tolerance{}.Apply(result)
+	require.Len(t, result.Defects, 1)
+	require.Nil(t, result.Tolerated)
+}
@@ -1,5 +1,7 @@
 package entity
 
+import "log/slog"
+
 // DefectArea описывает прямоугольную область дефекта на фото.
 type DefectArea struct {
 	X      int // координата X левого верхнего угла
@@ -8,9 +10,73 @@ type DefectArea struct {
 	Height int // высота области в пикселях
 	Area   int // площадь области в пикселях
 	Reason string
+	// Kind — тип дефекта; пусто, если детектор его не определил.
+	Kind DefectKind
+	// Color — средний сдвиг цвета относительно эталона; нулевой, если
+	// дефект найден не по цвету.
+	Color ColorShift
+	// Zone — имя зоны эталона, в которую попал центр дефекта; пусто — вне зон.
+	Zone string
+	// Size — размер в миллиметрах; нулевой, если снимок не откалиброван.
+	Size PhysicalSize
+	// Part — номер детали на снимке с несколькими деталями; 0 — деталь одна.
+	Part int
+}
+
+// DefectKind — тип дефекта по признаку, которым он найден.
+type DefectKind string
+
+const (
+	// DefectStain — пятно: участок заметно темнее эталона (масло, копоть, подгар).
+	DefectStain DefectKind = "stain"
+	// DefectCorrosion — коррозия: цвет ушёл в красный и жёлтый.
+	DefectCorrosion DefectKind = "corrosion"
+	// DefectDiscoloration — изменение цвета, не похожее на пятно или ржавчину.
+	DefectDiscoloration DefectKind = "discoloration"
+)
+
+// ColorShift — средний сдвиг цвета в пространстве CIELAB: DeltaE — расстояние
+// ΔE*76, L, A и B — сдвиг по яркости и осям a*, b*.
+type ColorShift struct {
+	DeltaE float64
+	L      float64
+	A      float64
+	B      float64
 }
 
 // Center возвращает координаты центра дефекта для простого описания положения.
 func (d DefectArea) Center() (x, y int) {
 	return d.X + d.Width/2, d.Y + d.Height/2
 }
+
+// LogValue описывает дефект в структурированных логах.
+func (d DefectArea) LogValue() slog.Value {
+	reason := d.Reason
+	if reason == "" {
+		reason = "not_set"
+	}
+	attrs := []slog.Attr{
+		slog.Int("x", d.X),
+		slog.Int("y", d.Y),
+		slog.Int("w", d.Width),
+		slog.Int("h", d.Height),
+		slog.Int("area", d.Area),
+		slog.String("reason", reason),
+	}
+	if d.Kind != "" {
+		attrs = append(attrs, slog.String("kind", string(d.Kind)))
+	}
+	if d.Zone != "" {
+		attrs = append(attrs, slog.String("zone", d.Zone))
+	}
+	if d.Part > 0 {
+		attrs = append(attrs, slog.Int("part", d.Part))
+	}
+	if d.Size.Measured() {
+		attrs = append(attrs, slog.Float64("length_mm", d.Size.LengthMM), slog.Float64("area_mm2", d.Size.AreaMM2))
+	}
+	if d.Color.DeltaE > 0 {
+		attrs = append(attrs, slog.Float64("delta_e", d.Color.DeltaE))
+	}
+	return slog.GroupValue(attrs...)
+}
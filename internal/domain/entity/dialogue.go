@@ -0,0 +1,151 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Event — событие диалога, которое может перевести пользователя в другое состояние.
+type Event string
+
+const (
+	EventBeginCheck       Event = "begin_check"       // Начать проверку с новым эталоном
+	EventReuseReference   Event = "reuse_reference"   // Продолжить с сохранённым эталоном
+	EventOriginalReceived Event = "original_received" // Получен эталон
+	EventCurrentReceived  Event = "current_received"  // Получено фото проверяемой детали
+	EventProcessingDone   Event = "processing_done"   // Проверка завершена (успешно или с ошибкой)
+	EventCancel           Event = "cancel"            // Отмена пользователем
+	EventTimeout          Event = "timeout"           // Пользователь долго не отвечал
+	EventBeginQuick       Event = "begin_quick"       // Начать проверку без эталона
+	EventQuickReceived    Event = "quick_received"    // Получено фото для проверки без эталона
+	EventQuickDone        Event = "quick_done"        // Проверка без эталона завершена
+)
+
+// GuardContext — факты, от которых зависят условные переходы.
+type GuardContext struct {
+	HasReference  bool // у пользователя сохранён эталон
+	SessionActive bool // сессия с эталоном не исчерпала лимиты
+}
+
+// Guard разрешает или запрещает переход.
+type Guard func(GuardContext) bool
+
+// Transition — строка таблицы переходов.
+type Transition struct {
+	From  UserState
+	Event Event
+	To    UserState
+	Guard Guard // nil — переход безусловный
+}
+
+// ErrInvalidTransition — общая причина для всех недопустимых переходов.
+var ErrInvalidTransition = errors.New("invalid transition")
+
+// TransitionError описывает недопустимый переход: событие не ожидается
+// в этом состоянии или ни один guard его не разрешил.
+type TransitionError struct {
+	From    UserState
+	Event   Event
+	Guarded bool // переход объявлен, но запрещён guard-ом
+}
+
+func (e *TransitionError) Error() string {
+	if e.Guarded {
+		return fmt.Sprintf("invalid transition: event %s in state %s rejected by guard", e.Event, e.From)
+	}
+	return fmt.Sprintf("invalid transition: event %s is not allowed in state %s", e.Event, e.From)
+}
+
+// Is позволяет проверять ошибку через errors.Is(err, ErrInvalidTransition).
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+func hasReference(c GuardContext) bool  { return c.HasReference }
+func sessionActive(c GuardContext) bool { return c.SessionActive }
+func sessionOver(c GuardContext) bool   { return !c.SessionActive }
+
+// DialogueTransitions — таблица переходов диалога проверки детали.
+// Переходы для одной пары (состояние, событие) проверяются по порядку.
+var DialogueTransitions = []Transition{
+	{From: StateMainMenu, Event: EventBeginCheck, To: StateAwaitingOriginalPhoto},
+	{From: StateAwaitingOriginalPhoto, Event: EventBeginCheck, To: StateAwaitingOriginalPhoto},
+	{From: StateAwaitingDefectPhoto, Event: EventBeginCheck, To: StateAwaitingOriginalPhoto},
+
+	{From: StateMainMenu, Event: EventReuseReference, To: StateAwaitingDefectPhoto, Guard: hasReference},
+	{From: StateAwaitingOriginalPhoto, Event: EventReuseReference, To: StateAwaitingDefectPhoto, Guard: hasReference},
+	{From: StateAwaitingDefectPhoto, Event: EventReuseReference, To: StateAwaitingDefectPhoto, Guard: hasReference},
+
+	{From: StateAwaitingOriginalPhoto, Event: EventOriginalReceived, To: StateAwaitingDefectPhoto},
+	{From: StateAwaitingDefectPhoto, Event: EventCurrentReceived, To: StateProcessing, Guard: hasReference},
+
+	{From: StateProcessing, Event: EventProcessingDone, To: StateAwaitingDefectPhoto, Guard: sessionActive},
+	{From: StateProcessing, Event: EventProcessingDone, To: StateMainMenu, Guard: sessionOver},
+
+	// Проверка без эталона не трогает сессию: эталон, если он был, остаётся сохранённым.
+	{From: StateMainMenu, Event: EventBeginQuick, To: StateAwaitingQuickPhoto},
+	{From: StateAwaitingOriginalPhoto, Event: EventBeginQuick, To: StateAwaitingQuickPhoto},
+	{From: StateAwaitingDefectPhoto, Event: EventBeginQuick, To: StateAwaitingQuickPhoto},
+	{From: StateAwaitingQuickPhoto, Event: EventBeginQuick, To: StateAwaitingQuickPhoto},
+	{From: StateAwaitingQuickPhoto, Event: EventBeginCheck, To: StateAwaitingOriginalPhoto},
+	{From: StateAwaitingQuickPhoto, Event: EventReuseReference, To: StateAwaitingDefectPhoto, Guard: hasReference},
+	{From: StateAwaitingQuickPhoto, Event: EventQuickReceived, To: StateProcessing},
+	{From: StateProcessing, Event: EventQuickDone, To: StateMainMenu},
+
+	{From: StateMainMenu, Event: EventCancel, To: StateMainMenu},
+	{From: StateAwaitingOriginalPhoto, Event: EventCancel, To: StateMainMenu},
+	{From: StateAwaitingDefectPhoto, Event: EventCancel, To: StateMainMenu},
+	{From: StateAwaitingQuickPhoto, Event: EventCancel, To: StateMainMenu},
+
+	{From: StateAwaitingOriginalPhoto, Event: EventTimeout, To: StateMainMenu},
+	{From: StateAwaitingDefectPhoto, Event: EventTimeout, To: StateMainMenu},
+	{From: StateAwaitingQuickPhoto, Event: EventTimeout, To: StateMainMenu},
+}
+
+// DialogueStates — все объявленные состояния диалога.
+var DialogueStates = []UserState{
+	StateMainMenu,
+	StateAwaitingOriginalPhoto,
+	StateAwaitingDefectPhoto,
+	StateAwaitingQuickPhoto,
+	StateProcessing,
+}
+
+// DialogueEvents — все объявленные события диалога.
+var DialogueEvents = []Event{
+	EventBeginCheck,
+	EventReuseReference,
+	EventOriginalReceived,
+	EventCurrentReceived,
+	EventProcessingDone,
+	EventCancel,
+	EventTimeout,
+	EventBeginQuick,
+	EventQuickReceived,
+	EventQuickDone,
+}
+
+// NextState ищет переход по таблице и возвращает новое состояние.
+func NextState(from UserState, event Event, guards GuardContext) (UserState, error) {
+	declared := false
+	for _, t := range DialogueTransitions {
+		if t.From != from || t.Event != event {
+			continue
+		}
+		declared = true
+		if t.Guard == nil || t.Guard(guards) {
+			return t.To, nil
+		}
+	}
+	return from, &TransitionError{From: from, Event: event, Guarded: declared}
+}
+
+// IsKnownState сообщает, объявлено ли состояние в диалоге.
+func IsKnownState(state UserState) bool {
+	for _, s := range DialogueStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
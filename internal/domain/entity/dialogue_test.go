@@ -0,0 +1,145 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextState_AllTransitions(t *testing.T) {
+	noRef := GuardContext{}
+	withRef := GuardContext{HasReference: true}
+	inSession := GuardContext{HasReference: true, SessionActive: true}
+
+	type want struct {
+		to      UserState
+		guarded bool // переход объявлен, но запрещён guard-ом
+		invalid bool
+	}
+
+	tests := []struct {
+		name   string
+		from   UserState
+		event  Event
+		guards GuardContext
+		want   want
+	}{
+		{"menu begin_check", StateMainMenu, EventBeginCheck, noRef, want{to: StateAwaitingOriginalPhoto}},
+		{"original begin_check", StateAwaitingOriginalPhoto, EventBeginCheck, noRef, want{to: StateAwaitingOriginalPhoto}},
+		{"defect begin_check", StateAwaitingDefectPhoto, EventBeginCheck, noRef, want{to: StateAwaitingOriginalPhoto}},
+		{"quick begin_check", StateAwaitingQuickPhoto, EventBeginCheck, noRef, want{to: StateAwaitingOriginalPhoto}},
+		{"processing begin_check", StateProcessing, EventBeginCheck, noRef, want{invalid: true}},
+
+		{"menu reuse with ref", StateMainMenu, EventReuseReference, withRef, want{to: StateAwaitingDefectPhoto}},
+		{"menu reuse without ref", StateMainMenu, EventReuseReference, noRef, want{invalid: true, guarded: true}},
+		{"original reuse with ref", StateAwaitingOriginalPhoto, EventReuseReference, withRef, want{to: StateAwaitingDefectPhoto}},
+		{"original reuse without ref", StateAwaitingOriginalPhoto, EventReuseReference, noRef, want{invalid: true, guarded: true}},
+		{"defect reuse with ref", StateAwaitingDefectPhoto, EventReuseReference, withRef, want{to: StateAwaitingDefectPhoto}},
+		{"defect reuse without ref", StateAwaitingDefectPhoto, EventReuseReference, noRef, want{invalid: true, guarded: true}},
+		{"quick reuse with ref", StateAwaitingQuickPhoto, EventReuseReference, withRef, want{to: StateAwaitingDefectPhoto}},
+		{"quick reuse without ref", StateAwaitingQuickPhoto, EventReuseReference, noRef, want{invalid: true, guarded: true}},
+		{"processing reuse", StateProcessing, EventReuseReference, withRef, want{invalid: true}},
+
+		{"menu original_received", StateMainMenu, EventOriginalReceived, noRef, want{invalid: true}},
+		{"original original_received", StateAwaitingOriginalPhoto, EventOriginalReceived, noRef, want{to: StateAwaitingDefectPhoto}},
+		{"defect original_received", StateAwaitingDefectPhoto, EventOriginalReceived, noRef, want{invalid: true}},
+		{"quick original_received", StateAwaitingQuickPhoto, EventOriginalReceived, noRef, want{invalid: true}},
+		{"processing original_received", StateProcessing, EventOriginalReceived, noRef, want{invalid: true}},
+
+		{"menu current_received", StateMainMenu, EventCurrentReceived, withRef, want{invalid: true}},
+		{"original current_received", StateAwaitingOriginalPhoto, EventCurrentReceived, withRef, want{invalid: true}},
+		{"defect current_received with ref", StateAwaitingDefectPhoto, EventCurrentReceived, withRef, want{to: StateProcessing}},
+		{"defect current_received without ref", StateAwaitingDefectPhoto, EventCurrentReceived, noRef, want{invalid: true, guarded: true}},
+		{"quick current_received", StateAwaitingQuickPhoto, EventCurrentReceived, withRef, want{invalid: true}},
+		{"processing current_received", StateProcessing, EventCurrentReceived, withRef, want{invalid: true}},
+
+		{"menu processing_done", StateMainMenu, EventProcessingDone, inSession, want{invalid: true}},
+		{"original processing_done", StateAwaitingOriginalPhoto, EventProcessingDone, inSession, want{invalid: true}},
+		{"defect processing_done", StateAwaitingDefectPhoto, EventProcessingDone, inSession, want{invalid: true}},
+		{"processing done in session", StateProcessing, EventProcessingDone, inSession, want{to: StateAwaitingDefectPhoto}},
+		{"quick processing_done", StateAwaitingQuickPhoto, EventProcessingDone, inSession, want{invalid: true}},
+		{"processing done session over", StateProcessing, EventProcessingDone, withRef, want{to: StateMainMenu}},
+
+		{"menu cancel", StateMainMenu, EventCancel, noRef, want{to: StateMainMenu}},
+		{"original cancel", StateAwaitingOriginalPhoto, EventCancel, noRef, want{to: StateMainMenu}},
+		{"defect cancel", StateAwaitingDefectPhoto, EventCancel, noRef, want{to: StateMainMenu}},
+		{"quick cancel", StateAwaitingQuickPhoto, EventCancel, noRef, want{to: StateMainMenu}},
+		{"processing cancel", StateProcessing, EventCancel, noRef, want{invalid: true}},
+
+		{"menu timeout", StateMainMenu, EventTimeout, noRef, want{invalid: true}},
+		{"original timeout", StateAwaitingOriginalPhoto, EventTimeout, noRef, want{to: StateMainMenu}},
+		{"defect timeout", StateAwaitingDefectPhoto, EventTimeout, noRef, want{to: StateMainMenu}},
+		{"quick timeout", StateAwaitingQuickPhoto, EventTimeout, noRef, want{to: StateMainMenu}},
+		{"processing timeout", StateProcessing, EventTimeout, noRef, want{invalid: true}},
+
+		{"menu begin_quick", StateMainMenu, EventBeginQuick, withRef, want{to: StateAwaitingQuickPhoto}},
+		{"original begin_quick", StateAwaitingOriginalPhoto, EventBeginQuick, noRef, want{to: StateAwaitingQuickPhoto}},
+		{"defect begin_quick", StateAwaitingDefectPhoto, EventBeginQuick, inSession, want{to: StateAwaitingQuickPhoto}},
+		{"quick begin_quick", StateAwaitingQuickPhoto, EventBeginQuick, noRef, want{to: StateAwaitingQuickPhoto}},
+		{"processing begin_quick", StateProcessing, EventBeginQuick, noRef, want{invalid: true}},
+
+		{"menu quick_received", StateMainMenu, EventQuickReceived, noRef, want{invalid: true}},
+		{"original quick_received", StateAwaitingOriginalPhoto, EventQuickReceived, noRef, want{invalid: true}},
+		{"defect quick_received", StateAwaitingDefectPhoto, EventQuickReceived, withRef, want{invalid: true}},
+		{"quick quick_received", StateAwaitingQuickPhoto, EventQuickReceived, noRef, want{to: StateProcessing}},
+		{"processing quick_received", StateProcessing, EventQuickReceived, noRef, want{invalid: true}},
+
+		{"menu quick_done", StateMainMenu, EventQuickDone, noRef, want{invalid: true}},
+		{"original quick_done", StateAwaitingOriginalPhoto, EventQuickDone, noRef, want{invalid: true}},
+		{"defect quick_done", StateAwaitingDefectPhoto, EventQuickDone, noRef, want{invalid: true}},
+		{"quick quick_done", StateAwaitingQuickPhoto, EventQuickDone, noRef, want{invalid: true}},
+		{"processing quick_done in session", StateProcessing, EventQuickDone, inSession, want{to: StateMainMenu}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, err := NextState(tt.from, tt.event, tt.guards)
+			if !tt.want.invalid {
+				require.NoError(t, err)
+				require.Equal(t, tt.want.to, next)
+				return
+			}
+
+			require.ErrorIs(t, err, ErrInvalidTransition)
+			var terr *TransitionError
+			require.True(t, errors.As(err, &terr))
+			require.Equal(t, tt.from, terr.From)
+			require.Equal(t, tt.event, terr.Event)
+			require.Equal(t, tt.want.guarded, terr.Guarded)
+			require.Equal(t, tt.from, next)
+		})
+	}
+
+	// Таблица теста покрывает все пары «состояние × событие».
+	covered := make(map[[2]string]bool)
+	for _, tt := range tests {
+		covered[[2]string{string(tt.from), string(tt.event)}] = true
+	}
+	for _, state := range DialogueStates {
+		for _, event := range DialogueEvents {
+			require.True(t, covered[[2]string{string(state), string(event)}], "missing case %s/%s", state, event)
+		}
+	}
+}
+
+func TestUser_FireRecordsTimestamps(t *testing.T) {
+	u := NewUser(DialogueKey{ChatID: 10, UserID: 1})
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, u.Fire(EventBeginCheck, GuardContext{}, start))
+	require.Equal(t, StateAwaitingOriginalPhoto, u.State)
+	require.Equal(t, start, u.StateEnteredAt)
+
+	later := start.Add(time.Minute)
+	require.NoError(t, u.Fire(EventOriginalReceived, GuardContext{}, later))
+	require.Equal(t, later, u.EnteredAt[StateAwaitingDefectPhoto])
+	require.Equal(t, start, u.EnteredAt[StateAwaitingOriginalPhoto])
+	require.Equal(t, 30*time.Second, u.StateAge(later.Add(30*time.Second)))
+
+	err := u.Fire(EventProcessingDone, GuardContext{}, later)
+	require.ErrorIs(t, err, ErrInvalidTransition)
+	require.Equal(t, StateAwaitingDefectPhoto, u.State)
+	require.Equal(t, later, u.StateEnteredAt)
+}
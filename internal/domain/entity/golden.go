@@ -0,0 +1,35 @@
+package entity
+
+import "math"
+
+// GoldenModel — эталон, собранный из нескольких годных деталей. Все образцы
+// совмещены с первым (Anchor), и для каждого пикселя в этой системе
+// координат известны средняя яркость и её разброс. Поэтому безвредные
+// различия — текстура, блики, небольшой сдвиг — не считаются дефектами там,
+// где они встречались и у годных деталей.
+//
+// Модель не изменяется после создания: дополнение даёт новую модель,
+// и проверки, начатые со старой, доводятся с ней.
+type GoldenModel struct {
+	Samples int // сколько годных деталей вошло в модель
+	Width   int // размер кадра модели
+	Height  int
+	// Anchor — первый образец, к которому совмещаются остальные и проверяемая деталь.
+	Anchor []byte
+	// Mean и M2 — средняя яркость и сумма квадратов отклонений каждого
+	// пикселя (алгоритм Уэлфорда), построчно Width×Height.
+	Mean []float32
+	M2   []float32
+	// Union и Intersection — маски детали (0 или 255): пиксель принадлежит
+	// детали хотя бы на одном образце или на всех.
+	Union        []byte
+	Intersection []byte
+}
+
+// StdDev возвращает разброс яркости пикселя i по выборке образцов.
+func (m *GoldenModel) StdDev(i int) float64 {
+	if m.Samples < 2 {
+		return 0
+	}
+	return math.Sqrt(float64(m.M2[i]) / float64(m.Samples-1))
+}
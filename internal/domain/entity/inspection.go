@@ -1,14 +1,67 @@
 package entity
 
+import "time"
+
 // InspectionResult хранит итог анализа изображения.
 type InspectionResult struct {
 	ImageWidth  int          // ширина изображения
 	ImageHeight int          // высота изображения
 	Defects     []DefectArea // список найденных дефектов
 	HasDefects  bool         // флаг наличия дефектов
+	// Tolerated — отличия в пределах допуска в миллиметрах: найдены, но деталь не бракуют.
+	Tolerated []DefectArea
+	// Parts — детали снимка, проверенные по отдельности; nil — деталь на снимке одна.
+	// Их дефекты лежат в Defects с номером детали в DefectArea.Part.
+	Parts []PartInspection
+	// Trace — как детектор пришёл к результату; нужен для метрик и разбора.
+	Trace InspectionTrace
+}
+
+// InspectionTrace описывает путь проверки через конвейер детектора.
+type InspectionTrace struct {
+	// Branch — ветка, давшая результат: single (без эталона), diff, golden,
+	// broken, broken_fallback или geometry. Пусто, если детектор её не сообщает.
+	Branch string
+	// AlignmentScore — качество совмещения с эталоном; 0 — совмещение не выполнялось.
+	AlignmentScore float64
+	// Rotation — на сколько градусов против часовой стрелки развернули деталь
+	// перед совмещением с эталоном; Flipped — деталь ещё и отразили.
+	Rotation float64
+	Flipped  bool
+	// Stages — длительность стадий в порядке выполнения.
+	Stages []StageTiming
+	// Profile и ProfileVersion — профиль порогов, с которым шла проверка.
+	Profile        string
+	ProfileVersion string
+}
+
+// StageTiming — длительность одной стадии конвейера.
+type StageTiming struct {
+	Stage    string
+	Duration time.Duration
 }
 
 // AiDescription — текстовое описание дефектов от ИИ.
 type AiDescription struct {
 	Text string
 }
+
+// InspectionStats — сводка по истории проверок.
+type InspectionStats struct {
+	Total          int // всего проверок
+	WithDefects    int // из них с найденными дефектами
+	FalsePositives int // из них помечено как ложное срабатывание
+}
+
+// InspectionRecord — запись истории проверок пользователя.
+type InspectionRecord struct {
+	ID            string            // короткий идентификатор для кнопок и истории
+	UserID        int64             // кто запускал проверку
+	CreatedAt     time.Time         // время проверки
+	Result        *InspectionResult // итог сравнения
+	Reference     []byte            // эталонное фото
+	Current       []byte            // проверяемое фото
+	Highlighted   []byte            // фото с подсветкой дефектов (если они найдены)
+	FalsePositive bool              // оператор пометил результат как ложное срабатывание
+	Approved      bool              // инспектор добавил деталь в модель эталона как годную
+}
@@ -0,0 +1,31 @@
+package entity
+
+// PartInspection — итог проверки одной детали на снимке с несколькими деталями.
+type PartInspection struct {
+	// Index — номер детали на снимке, с 1: по строкам сверху вниз, в строке слева направо.
+	Index int
+	// X, Y, Width, Height — рамка детали на проверяемом снимке.
+	X      int
+	Y      int
+	Width  int
+	Height int
+	// Matched — деталь похожа по форме на деталь эталона и сравнена с ней;
+	// иначе она целиком отмечена дефектом part_mismatch.
+	Matched bool
+	// ShapeScore — расстояние формы до ближайшей детали эталона (cv::matchShapes).
+	ShapeScore float64
+	// Trace — ветка, совмещение и разворот этой детали; стадии — в общем Trace.
+	Trace InspectionTrace
+}
+
+// PartDefects возвращает дефекты детали index. Допуск в миллиметрах уже
+// применён: дефекты в пределах допуска деталь не бракуют.
+func (r *InspectionResult) PartDefects(index int) []DefectArea {
+	var defects []DefectArea
+	for _, d := range r.Defects {
+		if d.Part == index {
+			defects = append(defects, d)
+		}
+	}
+	return defects
+}
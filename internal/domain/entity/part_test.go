@@ -0,0 +1,23 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectionResult_PartDefectsAfterTolerance(t *testing.T) {
+	chip := DefectArea{X: 1, Part: 1, Size: PhysicalSize{LengthMM: 0.2}}
+	scratch := DefectArea{X: 2, Part: 2, Size: PhysicalSize{LengthMM: 5}}
+	result := &InspectionResult{
+		Defects:    []DefectArea{chip, scratch},
+		HasDefects: true,
+		Parts:      []PartInspection{{Index: 1, Matched: true}, {Index: 2, Matched: true}},
+	}
+
+	// Скол в допуске: первая деталь годна, вторая — нет.
+	Tolerance{MinLengthMM: 1}.Apply(result)
+	require.Empty(t, result.PartDefects(1))
+	require.Equal(t, []DefectArea{scratch}, result.PartDefects(2))
+	require.Empty(t, result.PartDefects(3))
+}
@@ -0,0 +1,76 @@
+package entity
+
+import (
+	"math"
+	"time"
+)
+
+// RateLimit ограничивает частоту событий: не больше Events за Per,
+// из них до Events подряд. Нулевое значение снимает ограничение.
+type RateLimit struct {
+	Events int           // размер «ведра» и число событий за период
+	Per    time.Duration // за какое время ведро наполняется заново
+}
+
+// Enabled сообщает, задано ли ограничение.
+func (l RateLimit) Enabled() bool {
+	return l.Events > 0 && l.Per > 0
+}
+
+// interval — за сколько восстанавливается одно событие.
+func (l RateLimit) interval() time.Duration {
+	return l.Per / time.Duration(l.Events)
+}
+
+// RateLimitPolicy задаёт ограничения на присылаемые на проверку фото.
+type RateLimitPolicy struct {
+	Roles         map[Role]RateLimit // на пользователя, по его роли
+	Chat          RateLimit          // на групповой чат целиком
+	MaxConcurrent int                // одновременных проверок на весь бот; 0 — без ограничения
+}
+
+// ForRole возвращает ограничение для пользователя с ролью.
+func (p RateLimitPolicy) ForRole(role Role) RateLimit {
+	return p.Roles[role]
+}
+
+// TokenBucket — «ведро токенов»: каждое событие забирает токен,
+// токены восстанавливаются равномерно со скоростью, заданной RateLimit.
+type TokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewTokenBucket создаёт полное ведро.
+func NewTokenBucket(limit RateLimit, now time.Time) *TokenBucket {
+	return &TokenBucket{tokens: float64(limit.Events), updated: now}
+}
+
+// Take забирает токен. Если токенов нет, возвращает false и время,
+// через которое появится следующий.
+func (b *TokenBucket) Take(limit RateLimit, now time.Time) (bool, time.Duration) {
+	if !limit.Enabled() {
+		return true, 0
+	}
+
+	b.refill(limit, now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration(math.Ceil((1 - b.tokens) * float64(limit.interval())))
+	return false, wait
+}
+
+// Full сообщает, что ведро наполнилось и его можно забыть.
+func (b *TokenBucket) Full(limit RateLimit, now time.Time) bool {
+	b.refill(limit, now)
+	return b.tokens >= float64(limit.Events)
+}
+
+func (b *TokenBucket) refill(limit RateLimit, now time.Time) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Events), b.tokens+float64(elapsed)/float64(limit.interval()))
+		b.updated = now
+	}
+}
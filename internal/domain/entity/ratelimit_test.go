@@ -0,0 +1,35 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket_Take(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limit := RateLimit{Events: 3, Per: time.Minute}
+	bucket := NewTokenBucket(limit, start)
+
+	for i := 0; i < 3; i++ {
+		ok, _ := bucket.Take(limit, start)
+		require.True(t, ok)
+	}
+	ok, wait := bucket.Take(limit, start)
+	require.False(t, ok)
+	require.Equal(t, 20*time.Second, wait)
+
+	// Токен восстанавливается за Per/Events.
+	ok, wait = bucket.Take(limit, start.Add(15*time.Second))
+	require.False(t, ok)
+	require.Equal(t, 5*time.Second, wait)
+	ok, _ = bucket.Take(limit, start.Add(20*time.Second))
+	require.True(t, ok)
+
+	require.False(t, bucket.Full(limit, start.Add(time.Minute)))
+	require.True(t, bucket.Full(limit, start.Add(2*time.Minute)))
+
+	ok, _ = bucket.Take(RateLimit{}, start)
+	require.True(t, ok)
+}
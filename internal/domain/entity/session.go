@@ -0,0 +1,54 @@
+package entity
+
+import "time"
+
+// SessionPolicy ограничивает время жизни сессии с одним эталоном.
+// Нулевое значение поля означает отсутствие ограничения.
+type SessionPolicy struct {
+	MaxChecks int           // сколько деталей можно проверить с одним эталоном
+	TTL       time.Duration // сколько эталон остаётся активным после начала сессии
+}
+
+// Session — серия проверок с одним эталоном.
+type Session struct {
+	Reference  []byte    // эталонное фото
+	Thumbnail  []byte    // уменьшенная копия эталона для подсказок (может отсутствовать)
+	UploadedAt time.Time // когда был загружен эталон
+	StartedAt  time.Time // когда началась текущая серия проверок
+	Checks     int       // сколько деталей проверено в текущей серии
+	// Model — модель из нескольких годных деталей; nil — детали сравниваются с одним эталоном.
+	Model *GoldenModel
+	// Zones — зоны эталона: что не проверять и что проверять строже.
+	Zones []Zone
+	// Calibration — масштаб эталона в миллиметрах; nil — размеры только в пикселях.
+	Calibration *Calibration
+	// MarkerSearched — маркер на эталоне уже искали, повторно искать не нужно.
+	MarkerSearched bool
+}
+
+// NewSession начинает сессию с новым эталоном.
+func NewSession(reference, thumbnail []byte, now time.Time) *Session {
+	return &Session{
+		Reference:  reference,
+		Thumbnail:  thumbnail,
+		UploadedAt: now,
+		StartedAt:  now,
+	}
+}
+
+// Active сообщает, можно ли продолжать проверки с этим эталоном.
+func (s *Session) Active(now time.Time, policy SessionPolicy) bool {
+	if policy.MaxChecks > 0 && s.Checks >= policy.MaxChecks {
+		return false
+	}
+	if policy.TTL > 0 && now.Sub(s.StartedAt) >= policy.TTL {
+		return false
+	}
+	return true
+}
+
+// Restart начинает новую серию проверок с тем же эталоном.
+func (s *Session) Restart(now time.Time) {
+	s.StartedAt = now
+	s.Checks = 0
+}
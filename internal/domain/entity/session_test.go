@@ -0,0 +1,25 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession_Active(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := SessionPolicy{MaxChecks: 2, TTL: 10 * time.Minute}
+
+	s := NewSession([]byte("ref"), nil, start)
+	require.True(t, s.Active(start, policy))
+
+	s.Checks = 2
+	require.False(t, s.Active(start, policy))
+
+	s.Restart(start.Add(time.Minute))
+	require.True(t, s.Active(start.Add(5*time.Minute), policy))
+	require.False(t, s.Active(start.Add(11*time.Minute), policy))
+
+	require.True(t, s.Active(start.Add(24*time.Hour), SessionPolicy{}))
+}
@@ -1,32 +1,116 @@
 package entity
 
+import "time"
+
 // UserState описывает этап диалога с пользователем.
 type UserState string
 
 const (
-	StateMainMenu            UserState = "main_menu"               // В главном меню
+	StateMainMenu              UserState = "main_menu"               // В главном меню
 	StateAwaitingOriginalPhoto UserState = "awaiting_original_photo" // Ожидание оригинала фото детали
 	StateAwaitingDefectPhoto   UserState = "awaiting_defect_photo"   // Ожидание фото дефекта
+	StateAwaitingQuickPhoto    UserState = "awaiting_quick_photo"    // Ожидание фото для проверки без эталона
 	StateProcessing            UserState = "processing"              // Обработка изображения
 )
 
-// User представляет пользователя бота и его текущее состояние.
+// DialogueKey определяет диалог: один пользователь в одном чате и теме форума.
+// В группе у каждого оператора свой диалог, не связанный с его личным чатом.
+type DialogueKey struct {
+	ChatID   int64 // Telegram Chat ID
+	UserID   int64 // Telegram User ID
+	ThreadID int   // тема форума (message_thread_id); 0 — чат без тем
+}
+
+// PrivateDialogue возвращает ключ личного чата пользователя с ботом.
+func PrivateDialogue(userID int64) DialogueKey {
+	return DialogueKey{ChatID: userID, UserID: userID}
+}
+
+// User представляет пользователя бота и его состояние в одном диалоге.
+// Язык и роль — настройки человека, а не диалога: они хранятся в записи
+// личного чата (PrivateDialogue) и действуют во всех чатах.
 type User struct {
-	ID     int64     // Telegram User ID
-	ChatID int64     // Telegram Chat ID
-	State  UserState // Текущее состояние пользователя
+	ID             int64                   // Telegram User ID
+	ChatID         int64                   // Telegram Chat ID
+	ThreadID       int                     // Тема форума; 0 — чат без тем
+	State          UserState               // Текущее состояние пользователя
+	StateEnteredAt time.Time               // Когда пользователь перешёл в текущее состояние
+	EnteredAt      map[UserState]time.Time // Когда пользователь последний раз входил в каждое состояние
+	Reminded       bool                    // Напоминание о брошенной проверке уже отправлено в текущем состоянии
+	Language       string                  // Язык, выбранный командой /lang; пусто — язык клиента
+	ClientLanguage string                  // LanguageCode из последнего апдейта Telegram
+	Role           Role                    // Роль, выданная администратором командой /grant
 }
 
-// NewUser создаёт нового пользователя с начальным состоянием.
-func NewUser(userID, chatID int64) *User {
-	return &User{
-		ID:     userID,
-		ChatID: chatID,
-		State:  StateMainMenu,
+// IdlePolicy задаёт, когда напоминать о брошенной проверке и когда её отменять.
+// Нулевое значение поля отключает соответствующий шаг.
+type IdlePolicy struct {
+	RemindAfter time.Duration // через сколько бездействия отправить напоминание
+	ExpireAfter time.Duration // через сколько бездействия сбросить проверку
+}
+
+// NewUser создаёт нового пользователя в диалоге с начальным состоянием.
+func NewUser(key DialogueKey) *User {
+	u := &User{
+		ID:       key.UserID,
+		ChatID:   key.ChatID,
+		ThreadID: key.ThreadID,
 	}
+	u.enter(StateMainMenu, time.Now())
+	return u
+}
+
+// Clone возвращает независимую копию пользователя. Хранилища отдают копии,
+// чтобы фоновая проверка не меняла запись, которую читает обработчик апдейтов.
+func (u *User) Clone() *User {
+	clone := *u
+	if u.EnteredAt != nil {
+		clone.EnteredAt = make(map[UserState]time.Time, len(u.EnteredAt))
+		for state, at := range u.EnteredAt {
+			clone.EnteredAt[state] = at
+		}
+	}
+	return &clone
+}
+
+// Key возвращает ключ диалога, к которому относится состояние.
+func (u *User) Key() DialogueKey {
+	return DialogueKey{ChatID: u.ChatID, UserID: u.ID, ThreadID: u.ThreadID}
 }
 
-// SetState обновляет состояние пользователя.
+// SetState обновляет состояние пользователя в обход таблицы переходов.
+// Используется только для восстановления из хранилища и аварийного сброса.
 func (u *User) SetState(state UserState) {
+	u.enter(state, time.Now())
+}
+
+// Fire применяет событие диалога. При недопустимом переходе состояние
+// не меняется и возвращается *TransitionError.
+func (u *User) Fire(event Event, guards GuardContext, now time.Time) error {
+	next, err := NextState(u.State, event, guards)
+	if err != nil {
+		return err
+	}
+	u.enter(next, now)
+	return nil
+}
+
+// StateAge возвращает, сколько пользователь находится в текущем состоянии.
+func (u *User) StateAge(now time.Time) time.Duration {
+	return now.Sub(u.StateEnteredAt)
+}
+
+func (u *User) enter(state UserState, now time.Time) {
+	if u.EnteredAt == nil {
+		u.EnteredAt = make(map[UserState]time.Time)
+	}
 	u.State = state
+	u.StateEnteredAt = now
+	u.EnteredAt[state] = now
+	u.Reminded = false
+}
+
+// HasPendingCheck сообщает, что пользователь начал проверку и не закончил её.
+func (u *User) HasPendingCheck() bool {
+	return u.State != StateMainMenu
 }
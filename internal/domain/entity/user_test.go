@@ -7,7 +7,7 @@ import (
 )
 
 func TestNewUser_DefaultState(t *testing.T) {
-	u := NewUser(1, 10)
+	u := NewUser(DialogueKey{ChatID: 10, UserID: 1})
 	require.Equal(t, StateMainMenu, u.State)
 	require.Equal(t, int64(1), u.ID)
 	require.Equal(t, int64(10), u.ChatID)
@@ -0,0 +1,112 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ZoneKind — как проверять участок детали.
+type ZoneKind string
+
+const (
+	// ZoneNormal — обычная чувствительность (или заданная зоной).
+	ZoneNormal ZoneKind = "normal"
+	// ZoneIgnore — участок не проверяется: гравировка серийного номера,
+	// маркировка, следы обработки.
+	ZoneIgnore ZoneKind = "ignore"
+	// ZoneCritical — участок проверяется строже: посадочные места, кромки.
+	ZoneCritical ZoneKind = "critical"
+)
+
+// ZonePoint — вершина зоны в долях ширины и высоты кадра (0–1), поэтому
+// зона не зависит от размера снимка.
+type ZonePoint struct {
+	X float64
+	Y float64
+}
+
+// Zone — участок эталона со своим режимом проверки. Зоны накладываются
+// по порядку: следующая перекрывает предыдущую.
+type Zone struct {
+	Name string
+	Kind ZoneKind
+	// Sensitivity — множитель чувствительности: 2 — порог отличий вдвое ниже.
+	// 0 — значение по умолчанию для типа зоны.
+	Sensitivity float64
+	Polygon     []ZonePoint
+}
+
+// Validate проверяет тип, чувствительность и вершины зоны.
+func (z Zone) Validate() error {
+	switch z.Kind {
+	case ZoneNormal, ZoneIgnore, ZoneCritical:
+	default:
+		return fmt.Errorf("zone %q: unknown kind %q", z.Name, z.Kind)
+	}
+	if z.Sensitivity < 0 {
+		return fmt.Errorf("zone %q: sensitivity must not be negative", z.Name)
+	}
+	if len(z.Polygon) < 3 {
+		return fmt.Errorf("zone %q: polygon needs at least 3 points", z.Name)
+	}
+	for _, p := range z.Polygon {
+		if p.X < 0 || p.X > 1 || p.Y < 0 || p.Y > 1 {
+			return fmt.Errorf("zone %q: point (%v, %v) is outside the frame", z.Name, p.X, p.Y)
+		}
+	}
+	return nil
+}
+
+// Contains сообщает, лежит ли точка (в долях кадра) внутри зоны.
+func (z Zone) Contains(x, y float64) bool {
+	inside := false
+	for i, j := 0, len(z.Polygon)-1; i < len(z.Polygon); j, i = i, i+1 {
+		a, b := z.Polygon[i], z.Polygon[j]
+		if (a.Y > y) != (b.Y > y) && x < (b.X-a.X)*(y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// ZoneAt возвращает зону, в которую попадает точка: при перекрытии — последнюю.
+func ZoneAt(zones []Zone, x, y float64) (Zone, bool) {
+	for i := len(zones) - 1; i >= 0; i-- {
+		if zones[i].Contains(x, y) {
+			return zones[i], true
+		}
+	}
+	return Zone{}, false
+}
+
+// ValidateZones проверяет все зоны; имена зон не должны повторяться.
+func ValidateZones(zones []Zone) error {
+	seen := make(map[string]bool, len(zones))
+	var errs []error
+	for _, zone := range zones {
+		if err := zone.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+		if seen[zone.Name] {
+			errs = append(errs, fmt.Errorf("zone %q: duplicate name", zone.Name))
+		}
+		seen[zone.Name] = true
+	}
+	return errors.Join(errs...)
+}
+
+// GridCellName — имя ячейки сетки: буква ряда и номер столбца (A1, B3).
+func GridCellName(row, col int) string {
+	return fmt.Sprintf("%c%d", 'A'+row, col+1)
+}
+
+// GridCell возвращает ячейку сетки rows×cols как прямоугольную зону.
+func GridCell(row, col, rows, cols int, kind ZoneKind) Zone {
+	left, right := float64(col)/float64(cols), float64(col+1)/float64(cols)
+	top, bottom := float64(row)/float64(rows), float64(row+1)/float64(rows)
+	return Zone{
+		Name:    GridCellName(row, col),
+		Kind:    kind,
+		Polygon: []ZonePoint{{left, top}, {right, top}, {right, bottom}, {left, bottom}},
+	}
+}
@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestZone_ContainsAndZoneAt(t *testing.T) {
+	label := Zone{Name: "label", Kind: ZoneIgnore, Polygon: []ZonePoint{{0.1, 0.1}, {0.5, 0.1}, {0.5, 0.3}, {0.1, 0.3}}}
+	triangle := Zone{Name: "seat", Kind: ZoneCritical, Polygon: []ZonePoint{{0.4, 0.2}, {0.9, 0.2}, {0.4, 0.8}}}
+
+	require.True(t, label.Contains(0.2, 0.2))
+	require.False(t, label.Contains(0.6, 0.2))
+	require.True(t, triangle.Contains(0.5, 0.3))
+	require.False(t, triangle.Contains(0.8, 0.7))
+
+	// При перекрытии побеждает зона, объявленная позже
+	zone, ok := ZoneAt([]Zone{label, triangle}, 0.45, 0.25)
+	require.True(t, ok)
+	require.Equal(t, "seat", zone.Name)
+	_, ok = ZoneAt([]Zone{label, triangle}, 0.05, 0.9)
+	require.False(t, ok)
+}
+
+func TestValidateZones(t *testing.T) {
+	cell := GridCell(1, 2, 4, 4, ZoneIgnore)
+	require.Equal(t, "B3", cell.Name)
+	require.Equal(t, []ZonePoint{{0.5, 0.25}, {0.75, 0.25}, {0.75, 0.5}, {0.5, 0.5}}, cell.Polygon)
+	require.NoError(t, ValidateZones([]Zone{cell}))
+
+	err := ValidateZones([]Zone{
+		cell,
+		cell,
+		{Name: "odd", Kind: "strict", Polygon: cell.Polygon},
+		{Name: "line", Kind: ZoneNormal, Polygon: cell.Polygon[:2]},
+		{Name: "outside", Kind: ZoneNormal, Polygon: []ZonePoint{{0, 0}, {1.5, 0}, {1, 1}}},
+	})
+	require.ErrorContains(t, err, `zone "B3": duplicate name`)
+	require.ErrorContains(t, err, `unknown kind "strict"`)
+	require.ErrorContains(t, err, "at least 3 points")
+	require.ErrorContains(t, err, "outside the frame")
+}
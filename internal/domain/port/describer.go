@@ -8,6 +8,7 @@ import (
 
 // DefectDescriber интерфейс описателя дефектов
 type DefectDescriber interface {
-	// Describe генерирует текстовое описание найденных дефектов
-	Describe(ctx context.Context, result *entity.InspectionResult) (*entity.AiDescription, error)
+	// Describe генерирует текстовое описание найденных дефектов на языке locale
+	// (код языка каталога сообщений, например "ru" или "en")
+	Describe(ctx context.Context, result *entity.InspectionResult, locale string) (*entity.AiDescription, error)
 }
@@ -12,8 +12,37 @@ type DefectDetector interface {
 	Inspect(ctx context.Context, imageData []byte) (*entity.InspectionResult, error)
 
 	// InspectDiff сравнивает эталон и текущее изображение и ищет отличия
-	InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte) (*entity.InspectionResult, error)
+	// с учётом зон эталона (nil — вся деталь проверяется одинаково)
+	InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error)
 
 	// HighlightDefects создаёт изображение с подсветкой дефектов
 	HighlightDefects(imageData []byte, result *entity.InspectionResult) ([]byte, error)
 }
+
+// GoldenModelDetector — детектор, который умеет сравнивать деталь не с одним
+// эталоном, а с моделью, собранной из нескольких годных деталей.
+type GoldenModelDetector interface {
+	DefectDetector
+
+	// BuildGoldenModel совмещает годные образцы с первым и собирает модель
+	BuildGoldenModel(ctx context.Context, samples [][]byte) (*entity.GoldenModel, error)
+
+	// ExtendGoldenModel возвращает новую модель, дополненную ещё одним годным образцом
+	ExtendGoldenModel(ctx context.Context, model *entity.GoldenModel, sample []byte) (*entity.GoldenModel, error)
+
+	// InspectGolden ищет отличия текущего изображения от модели с учётом зон эталона
+	InspectGolden(ctx context.Context, model *entity.GoldenModel, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error)
+}
+
+// Calibrator переводит пиксели снимка в миллиметры: по известной длине детали
+// или по печатному маркеру в кадре.
+type Calibrator interface {
+	// CalibrateByPart измеряет деталь на снимке по её большей стороне длиной lengthMM
+	CalibrateByPart(ctx context.Context, image []byte, lengthMM float64) (*entity.Calibration, error)
+
+	// CalibrateByMarker ищет в кадре маркер профиля; nil без ошибки — маркера нет
+	CalibrateByMarker(ctx context.Context, image []byte) (*entity.Calibration, error)
+
+	// Tolerance возвращает допуск профиля: какие дефекты не бракуют деталь
+	Tolerance() entity.Tolerance
+}
@@ -0,0 +1,28 @@
+package port
+
+import (
+	"context"
+
+	"vision-bot/internal/domain/entity"
+)
+
+// InspectionRepository интерфейс хранилища истории проверок
+type InspectionRepository interface {
+	// Save сохраняет или обновляет запись о проверке
+	Save(ctx context.Context, record *entity.InspectionRecord) error
+
+	// Get возвращает запись по ID или nil, если она не найдена
+	Get(ctx context.Context, id string) (*entity.InspectionRecord, error)
+
+	// ListByUser возвращает последние проверки пользователя, новые первыми
+	ListByUser(ctx context.Context, userID int64, limit int) ([]*entity.InspectionRecord, error)
+
+	// Stats возвращает сводку по всем сохранённым проверкам
+	Stats(ctx context.Context) (entity.InspectionStats, error)
+
+	// Flush сбрасывает накопленные изменения в постоянное хранилище
+	Flush(ctx context.Context) error
+
+	// Ping проверяет, что хранилище открыто и отвечает
+	Ping(ctx context.Context) error
+}
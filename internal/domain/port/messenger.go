@@ -0,0 +1,52 @@
+package port
+
+import "context"
+
+// Button кнопка inline-клавиатуры
+type Button struct {
+	Text string // подпись кнопки
+	Data string // данные, которые вернутся в callback
+}
+
+// Keyboard inline-клавиатура: список рядов кнопок
+type Keyboard [][]Button
+
+// Photo изображение с подписью для отправки альбомом
+type Photo struct {
+	Data    []byte
+	Caption string
+}
+
+// Recipient адрес исходящего сообщения
+type Recipient struct {
+	ChatID   int64
+	ThreadID int // тема форума (message_thread_id); 0 — без темы
+	ReplyTo  int // ID сообщения, на которое отвечаем; 0 — без ответа
+}
+
+// Messenger интерфейс транспорта сообщений (Telegram или тестовая реализация)
+type Messenger interface {
+	// SendText отправляет текстовое сообщение и возвращает его ID
+	SendText(ctx context.Context, to Recipient, text string) (int, error)
+
+	// SendPhoto отправляет изображение с подписью и возвращает ID сообщения
+	SendPhoto(ctx context.Context, to Recipient, photo []byte, caption string) (int, error)
+
+	// SendAlbum отправляет несколько изображений одним или несколькими альбомами
+	SendAlbum(ctx context.Context, to Recipient, photos []Photo) error
+
+	// SendKeyboard отправляет текст с inline-клавиатурой и возвращает ID сообщения
+	SendKeyboard(ctx context.Context, to Recipient, text string, keyboard Keyboard) (int, error)
+
+	// EditText заменяет текст ранее отправленного сообщения
+	EditText(ctx context.Context, chatID int64, messageID int, text string) error
+
+	// EditKeyboard заменяет текст и inline-клавиатуру ранее отправленного сообщения
+	EditKeyboard(ctx context.Context, chatID int64, messageID int, text string, keyboard Keyboard) error
+
+	// AnswerCallback подтверждает нажатие inline-кнопки; text показывается всплывающей подсказкой
+	AnswerCallback(ctx context.Context, callbackID string, text string) error
+
+	// GetFile скачивает файл по его ID
+	GetFile(ctx context.Context, fileID string) ([]byte, error)
+}
@@ -6,14 +6,23 @@ import (
 	"vision-bot/internal/domain/entity"
 )
 
-// UserRepository интерфейс хранилища пользователей
+// UserRepository интерфейс хранилища пользователей; состояние хранится по ключу диалога
 type UserRepository interface {
-	// Get возвращает пользователя по ID, создаёт нового если не найден
-	Get(ctx context.Context, userID, chatID int64) (*entity.User, error)
+	// Get возвращает пользователя в диалоге, создаёт нового если не найден
+	Get(ctx context.Context, key entity.DialogueKey) (*entity.User, error)
 
 	// Save сохраняет состояние пользователя
 	Save(ctx context.Context, user *entity.User) error
 
-	// UpdateState обновляет состояние пользователя
-	UpdateState(ctx context.Context, userID int64, state entity.UserState) error
+	// UpdateState обновляет состояние пользователя в диалоге
+	UpdateState(ctx context.Context, key entity.DialogueKey, state entity.UserState) error
+
+	// List возвращает все известные диалоги
+	List(ctx context.Context) ([]*entity.User, error)
+
+	// Flush сбрасывает накопленные изменения в постоянное хранилище
+	Flush(ctx context.Context) error
+
+	// Ping проверяет, что хранилище открыто и отвечает
+	Ping(ctx context.Context) error
 }
@@ -0,0 +1,173 @@
+// Package i18n хранит каталог сообщений бота на нескольких языках.
+//
+// Каталог загружается из YAML-файлов вида <lang>.yaml. Значение ключа —
+// либо строка, либо набор плюральных форм (one/few/many/other). В тексте
+// можно подставлять именованные аргументы: {count}, {x}, {y}; числа
+// форматируются по правилам языка.
+package i18n
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLanguage используется, если язык пользователя не поддерживается.
+const DefaultLanguage = "ru"
+
+//go:embed locales/*.yaml
+var embedded embed.FS
+
+// Args — именованные аргументы для подстановки в сообщение.
+type Args map[string]any
+
+// message — одно сообщение: обычный текст или плюральные формы.
+type message struct {
+	text   string
+	plural map[PluralForm]string
+}
+
+func (m *message) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Decode(&m.text)
+	case yaml.MappingNode:
+		var forms map[string]string
+		if err := node.Decode(&forms); err != nil {
+			return err
+		}
+		m.plural = make(map[PluralForm]string, len(forms))
+		for form, text := range forms {
+			if !isPluralForm(PluralForm(form)) {
+				return fmt.Errorf("line %d: unknown plural form %q", node.Line, form)
+			}
+			m.plural[PluralForm(form)] = text
+		}
+		if _, ok := m.plural[PluralOther]; !ok {
+			return fmt.Errorf("line %d: plural message must define %q", node.Line, PluralOther)
+		}
+		return nil
+	default:
+		return fmt.Errorf("line %d: message must be a string or a map of plural forms", node.Line)
+	}
+}
+
+// Catalog — набор сообщений для всех загруженных языков.
+type Catalog struct {
+	fallback string
+	messages map[string]map[string]message
+}
+
+// Load читает все файлы *.yaml из fsys; имя файла задаёт язык.
+// fallback должен быть среди загруженных языков.
+func Load(fsys fs.FS, fallback string) (*Catalog, error) {
+	files, err := fs.Glob(fsys, "*.yaml")
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Catalog{fallback: fallback, messages: make(map[string]map[string]message)}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		var messages map[string]message
+		if err := yaml.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", file, err)
+		}
+		lang := strings.TrimSuffix(path.Base(file), ".yaml")
+		c.messages[lang] = messages
+	}
+
+	if _, ok := c.messages[fallback]; !ok {
+		return nil, fmt.Errorf("fallback language %q is not loaded", fallback)
+	}
+	return c, nil
+}
+
+// Default загружает каталог, встроенный в бинарник.
+func Default() (*Catalog, error) {
+	locales, err := fs.Sub(embedded, "locales")
+	if err != nil {
+		return nil, err
+	}
+	return Load(locales, DefaultLanguage)
+}
+
+// MustDefault как Default, но паникует при ошибке. Встроенные файлы проверяются
+// тестами, поэтому ошибка здесь означает испорченную сборку.
+func MustDefault() *Catalog {
+	c, err := Default()
+	if err != nil {
+		panic(fmt.Sprintf("i18n: load embedded catalog: %v", err))
+	}
+	return c
+}
+
+// Languages возвращает загруженные языки в алфавитном порядке.
+func (c *Catalog) Languages() []string {
+	langs := make([]string, 0, len(c.messages))
+	for lang := range c.messages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Has сообщает, загружен ли язык.
+func (c *Catalog) Has(lang string) bool {
+	_, ok := c.messages[lang]
+	return ok
+}
+
+// Keys возвращает все ключи языка в алфавитном порядке.
+func (c *Catalog) Keys(lang string) []string {
+	keys := make([]string, 0, len(c.messages[lang]))
+	for key := range c.messages[lang] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Match выбирает первый поддерживаемый язык из списка кодов
+// (например, "en-US" из Telegram превращается в "en").
+func (c *Catalog) Match(codes ...string) string {
+	for _, code := range codes {
+		code = strings.ToLower(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+		if base, _, ok := strings.Cut(code, "-"); ok {
+			code = base
+		}
+		if c.Has(code) {
+			return code
+		}
+	}
+	return c.fallback
+}
+
+// Translator возвращает переводчик для языка; неизвестный язык заменяется основным.
+func (c *Catalog) Translator(lang string) Translator {
+	if !c.Has(lang) {
+		lang = c.fallback
+	}
+	return Translator{catalog: c, lang: lang}
+}
+
+// lookup ищет сообщение в языке, затем в основном языке.
+func (c *Catalog) lookup(lang, key string) (message, bool) {
+	if msg, ok := c.messages[lang][key]; ok {
+		return msg, true
+	}
+	msg, ok := c.messages[c.fallback][key]
+	return msg, ok
+}
@@ -0,0 +1,87 @@
+package i18n
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefault_AllLocalesHaveSameKeys(t *testing.T) {
+	c, err := Default()
+	require.NoError(t, err)
+	require.Equal(t, []string{"en", "kk", "ru"}, c.Languages())
+
+	base := c.Keys(DefaultLanguage)
+	for _, lang := range c.Languages() {
+		require.Equal(t, base, c.Keys(lang), "locale %s", lang)
+	}
+}
+
+func TestTranslator_PluralAndTemplating(t *testing.T) {
+	c, err := Load(fstest.MapFS{
+		"ru.yaml": {Data: []byte(`
+defects:
+  one: "{count} дефект в точке ({x}; {y})"
+  few: "{count} дефекта"
+  many: "{count} дефектов"
+  other: "{count} дефекта"
+plain: "Привет, {name}!"
+`)},
+		"en.yaml": {Data: []byte(`
+defects:
+  one: "{count} defect"
+  other: "{count} defects"
+`)},
+	}, "ru")
+	require.NoError(t, err)
+
+	ru := c.Translator("ru")
+	require.Equal(t, "1 дефект в точке (10; 1234)", ru.N("defects", 1, Args{"x": 10, "y": 1234}))
+	require.Equal(t, "3 дефекта", ru.N("defects", 3))
+	require.Equal(t, "11 дефектов", ru.N("defects", 11))
+	require.Equal(t, "22 дефекта", ru.N("defects", 22))
+	require.Equal(t, "Привет, Оля!", ru.T("plain", Args{"name": "Оля"}))
+
+	en := c.Translator("en")
+	require.Equal(t, "1 defect", en.N("defects", 1))
+	require.Equal(t, "2 defects", en.N("defects", 2))
+	// Ключа нет в английском — берётся из основного языка.
+	require.Equal(t, "Привет, Bob!", en.T("plain", Args{"name": "Bob"}))
+	require.Equal(t, "missing", en.T("missing"))
+}
+
+func TestTranslator_Number(t *testing.T) {
+	c, err := Default()
+	require.NoError(t, err)
+
+	require.Equal(t, "1234", c.Translator("ru").Number(1234))
+	require.Equal(t, "12\u00a0345", c.Translator("ru").Number(12345))
+	require.Equal(t, "1,234", c.Translator("en").Number(1234))
+	require.Equal(t, "0,5", c.Translator("kk").Number(0.5))
+	require.Equal(t, "2.75", c.Translator("en").Number(2.75))
+}
+
+func TestCatalog_Match(t *testing.T) {
+	c, err := Default()
+	require.NoError(t, err)
+
+	require.Equal(t, "en", c.Match("", "en-US"))
+	require.Equal(t, "kk", c.Match("kk"))
+	require.Equal(t, "en", c.Match("EN"))
+	require.Equal(t, DefaultLanguage, c.Match("de"))
+	require.Equal(t, DefaultLanguage, c.Match())
+}
+
+func TestPluralFormFor(t *testing.T) {
+	require.Equal(t, PluralOne, PluralFormFor("ru", 21))
+	require.Equal(t, PluralMany, PluralFormFor("ru", 12))
+	require.Equal(t, PluralFew, PluralFormFor("ru", 104))
+	require.Equal(t, PluralMany, PluralFormFor("ru", 0))
+	require.Equal(t, PluralOther, PluralFormFor("kk", 5))
+}
+
+func TestLoad_RejectsPluralWithoutOther(t *testing.T) {
+	_, err := Load(fstest.MapFS{"ru.yaml": {Data: []byte("x:\n  one: a\n")}}, "ru")
+	require.Error(t, err)
+}
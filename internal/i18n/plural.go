@@ -0,0 +1,45 @@
+package i18n
+
+// PluralForm — категория множественного числа по CLDR.
+type PluralForm string
+
+const (
+	PluralOne   PluralForm = "one"
+	PluralFew   PluralForm = "few"
+	PluralMany  PluralForm = "many"
+	PluralOther PluralForm = "other"
+)
+
+func isPluralForm(form PluralForm) bool {
+	switch form {
+	case PluralOne, PluralFew, PluralMany, PluralOther:
+		return true
+	}
+	return false
+}
+
+// PluralFormFor выбирает форму для целого числа n в языке lang.
+func PluralFormFor(lang string, n int64) PluralForm {
+	if n < 0 {
+		n = -n
+	}
+
+	switch lang {
+	case "ru":
+		mod10, mod100 := n%10, n%100
+		switch {
+		case mod10 == 1 && mod100 != 11:
+			return PluralOne
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return PluralFew
+		default:
+			return PluralMany
+		}
+	default:
+		// en, kk и большинство других языков различают только «один» и «остальные».
+		if n == 1 {
+			return PluralOne
+		}
+		return PluralOther
+	}
+}
@@ -0,0 +1,168 @@
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Translator переводит сообщения на один язык.
+type Translator struct {
+	catalog *Catalog
+	lang    string
+}
+
+// Lang возвращает язык переводчика.
+func (t Translator) Lang() string {
+	return t.lang
+}
+
+// T возвращает сообщение с подставленными аргументами. Для плюральных
+// сообщений форма выбирается по аргументу "count". Неизвестный ключ
+// возвращается как есть, чтобы пропуск в каталоге был заметен.
+func (t Translator) T(key string, args ...Args) string {
+	merged := mergeArgs(args)
+	if t.catalog == nil {
+		return key
+	}
+
+	msg, ok := t.catalog.lookup(t.lang, key)
+	if !ok {
+		return key
+	}
+
+	text := msg.text
+	if msg.plural != nil {
+		count, _ := toInt(merged["count"])
+		text = msg.plural[PluralFormFor(t.lang, count)]
+		if text == "" {
+			text = msg.plural[PluralOther]
+		}
+	}
+	return t.format(text, merged)
+}
+
+// N — сокращение для плюрального сообщения с аргументом count.
+func (t Translator) N(key string, count int, args ...Args) string {
+	merged := mergeArgs(args)
+	merged["count"] = count
+	return t.T(key, merged)
+}
+
+// Number форматирует число по правилам языка: разделитель тысяч и десятичный знак.
+func (t Translator) Number(value any) string {
+	// В русском и казахском четырёхзначные числа не разбивают: 1234, но 12 345.
+	thousands, decimal, minGrouped := ",", ".", 4
+	if t.lang != "en" {
+		thousands, decimal, minGrouped = "\u00a0", ",", 5
+	}
+
+	switch v := value.(type) {
+	case float32:
+		return formatFloat(float64(v), thousands, decimal, minGrouped)
+	case float64:
+		return formatFloat(v, thousands, decimal, minGrouped)
+	}
+	if n, ok := toInt(value); ok {
+		return groupDigits(strconv.FormatInt(n, 10), thousands, minGrouped)
+	}
+	return fmt.Sprint(value)
+}
+
+// format подставляет {name} из args.
+func (t Translator) format(text string, args Args) string {
+	if len(args) == 0 || !strings.Contains(text, "{") {
+		return text
+	}
+
+	pairs := make([]string, 0, len(args)*2)
+	for name, value := range args {
+		var formatted string
+		switch value.(type) {
+		case int, int32, int64, uint, uint32, uint64, float32, float64:
+			formatted = t.Number(value)
+		default:
+			formatted = fmt.Sprint(value)
+		}
+		pairs = append(pairs, "{"+name+"}", formatted)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+func mergeArgs(args []Args) Args {
+	merged := make(Args)
+	for _, a := range args {
+		for k, v := range a {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+func toInt(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+func formatFloat(v float64, thousands, decimal string, minGrouped int) string {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	intPart, frac, hasFrac := strings.Cut(s, ".")
+	out := groupDigits(intPart, thousands, minGrouped)
+	if hasFrac {
+		out += decimal + frac
+	}
+	return out
+}
+
+// groupDigits разбивает целое число на группы по три цифры,
+// если в нём не меньше minGrouped цифр.
+func groupDigits(s, sep string, minGrouped int) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	if len(s) < minGrouped {
+		return sign + s
+	}
+
+	var b strings.Builder
+	head := len(s) % 3
+	if head > 0 {
+		b.WriteString(s[:head])
+	}
+	for i := head; i < len(s); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(s[i : i+3])
+	}
+	return sign + b.String()
+}
+
+type translatorKey struct{}
+
+// WithTranslator кладёт переводчик в контекст обработки запроса.
+func WithTranslator(ctx context.Context, t Translator) context.Context {
+	return context.WithValue(ctx, translatorKey{}, t)
+}
+
+// FromContext достаёт переводчик из контекста. Без переводчика сообщения
+// возвращаются в виде ключей.
+func FromContext(ctx context.Context) Translator {
+	t, _ := ctx.Value(translatorKey{}).(Translator)
+	return t
+}
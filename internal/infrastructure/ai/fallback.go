@@ -0,0 +1,45 @@
+package ai
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/domain/port"
+)
+
+// FallbackDescriber берёт описание у основного описателя, а если тот
+// недоступен или ответил пустым текстом — у запасного.
+type FallbackDescriber struct {
+	primary  port.DefectDescriber
+	fallback port.DefectDescriber
+}
+
+// NewFallbackDescriber создаёт описатель с запасным вариантом.
+func NewFallbackDescriber(primary, fallback port.DefectDescriber) *FallbackDescriber {
+	return &FallbackDescriber{primary: primary, fallback: fallback}
+}
+
+// Describe возвращает описание основного описателя или запасного.
+func (d *FallbackDescriber) Describe(ctx context.Context, result *entity.InspectionResult, locale string) (*entity.AiDescription, error) {
+	description, err := d.primary.Describe(ctx, result, locale)
+	if err == nil && description != nil && strings.TrimSpace(description.Text) != "" {
+		return description, nil
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Describer failed, using fallback", "err", err)
+	}
+	return d.fallback.Describe(ctx, result, locale)
+}
+
+// Ping проверяет основной описатель: запасной работает без внешних сервисов.
+func (d *FallbackDescriber) Ping(ctx context.Context) error {
+	if pinger, ok := d.primary.(interface{ Ping(context.Context) error }); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// Проверка реализации интерфейса
+var _ port.DefectDescriber = (*FallbackDescriber)(nil)
@@ -0,0 +1,110 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/domain/port"
+)
+
+// OllamaDescriber описывает дефекты языковой моделью через Ollama.
+type OllamaDescriber struct {
+	baseURL string
+	model   string
+	timeout time.Duration
+	client  *http.Client
+}
+
+// NewOllamaDescriber создаёт описатель для сервера Ollama по адресу baseURL
+// (например, http://localhost:11434) и модели model (например, qwen2.5:7b).
+// timeout ограничивает один запрос к модели.
+func NewOllamaDescriber(baseURL, model string, timeout time.Duration) *OllamaDescriber {
+	return &OllamaDescriber{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+		timeout: timeout,
+		client:  &http.Client{},
+	}
+}
+
+type ollamaRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+	Stream bool   `json:"stream"`
+}
+
+type ollamaResponse struct {
+	Response string `json:"response"`
+}
+
+// Describe просит модель описать дефекты на языке locale.
+func (o *OllamaDescriber) Describe(ctx context.Context, result *entity.InspectionResult, locale string) (*entity.AiDescription, error) {
+	if result == nil || len(result.Defects) == 0 {
+		return &entity.AiDescription{}, nil
+	}
+
+	body, err := json.Marshal(ollamaRequest{Model: o.model, Prompt: buildPrompt(result, locale)})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := o.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ollama generate: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ollama generate: %s", resp.Status)
+	}
+
+	var decoded ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("ollama generate: decode response: %w", err)
+	}
+	return &entity.AiDescription{Text: strings.TrimSpace(decoded.Response)}, nil
+}
+
+// Ping проверяет, что сервер Ollama отвечает.
+func (o *OllamaDescriber) Ping(ctx context.Context) error {
+	ctx, cancel := o.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.baseURL+"/api/tags", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ollama: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ollama: %s", resp.Status)
+	}
+	return nil
+}
+
+func (o *OllamaDescriber) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, o.timeout)
+}
+
+// Проверка реализации интерфейса
+var _ port.DefectDescriber = (*OllamaDescriber)(nil)
@@ -0,0 +1,61 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/i18n"
+)
+
+func defectResult() *entity.InspectionResult {
+	return &entity.InspectionResult{
+		ImageWidth:  300,
+		ImageHeight: 300,
+		HasDefects:  true,
+		Defects:     []entity.DefectArea{{X: 10, Y: 10, Width: 20, Height: 10, Area: 200}},
+	}
+}
+
+func TestOllamaDescriber_Describe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/tags" {
+			_, _ = w.Write([]byte(`{"models":[]}`))
+			return
+		}
+		require.Equal(t, "/api/generate", r.URL.Path)
+		var req ollamaRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "qwen2.5:7b", req.Model)
+		require.False(t, req.Stream)
+		require.Contains(t, req.Prompt, "Отвечай на английском языке.")
+		require.Contains(t, req.Prompt, "Дефект 1: позиция (10, 10), размер 20x10")
+		_ = json.NewEncoder(w).Encode(ollamaResponse{Response: " One small defect at the top left. \n"})
+	}))
+	defer server.Close()
+
+	d := NewOllamaDescriber(server.URL+"/", "qwen2.5:7b", time.Second)
+	description, err := d.Describe(context.Background(), defectResult(), "en")
+	require.NoError(t, err)
+	require.Equal(t, "One small defect at the top left.", description.Text)
+	require.NoError(t, d.Ping(context.Background()))
+}
+
+func TestFallbackDescriber_UsesTemplateWhenOllamaFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	d := NewFallbackDescriber(NewOllamaDescriber(server.URL, "missing", time.Second), NewTemplateDescriber(i18n.MustDefault()))
+	description, err := d.Describe(context.Background(), defectResult(), "en")
+	require.NoError(t, err)
+	require.Equal(t, "1. top left: 20×10 px, centre (20, 15)", description.Text)
+	require.Error(t, d.Ping(context.Background()))
+}
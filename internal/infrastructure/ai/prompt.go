@@ -0,0 +1,70 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+
+	"vision-bot/internal/domain/entity"
+)
+
+const systemPrompt = `Ты инженер по контролю качества деталей.
+Тебе передают размеры изображения и список дефектных областей с координатами.
+Твоя задача — кратко описать найденные дефекты:
+- сколько дефектов обнаружено;
+- где они расположены (верх/низ, слева/справа относительно центра);
+- какие из них крупнее по площади;
+- какого они типа, если тип указан (пятно, коррозия, изменение цвета).
+- если указаны размеры в миллиметрах, называй размеры в миллиметрах, а не в пикселях.
+Ответ должен быть 2-4 предложения, понятных человеку, без разметки.`
+
+// kindNames — названия типов дефектов в запросе к модели.
+var kindNames = map[entity.DefectKind]string{
+	entity.DefectStain:         "пятно",
+	entity.DefectCorrosion:     "коррозия",
+	entity.DefectDiscoloration: "изменение цвета",
+}
+
+// answerLanguages — на каком языке просить ответ для кодов каталога сообщений.
+var answerLanguages = map[string]string{
+	"ru": "русском",
+	"en": "английском",
+	"kk": "казахском",
+}
+
+// buildPrompt описывает результат проверки для языковой модели.
+func buildPrompt(result *entity.InspectionResult, locale string) string {
+	language, ok := answerLanguages[locale]
+	if !ok {
+		language = answerLanguages["ru"]
+	}
+
+	var sb strings.Builder
+	sb.WriteString(systemPrompt)
+	fmt.Fprintf(&sb, "\nОтвечай на %s языке.\n\n", language)
+	fmt.Fprintf(&sb, "Размер изображения: %d x %d пикселей\n", result.ImageWidth, result.ImageHeight)
+	if len(result.Parts) > 0 {
+		fmt.Fprintf(&sb, "Деталей на снимке: %d, дефекты указаны по номеру детали\n", len(result.Parts))
+	}
+	fmt.Fprintf(&sb, "Количество дефектов: %d\n\n", len(result.Defects))
+	for i, d := range result.Defects {
+		fmt.Fprintf(&sb, "Дефект %d: позиция (%d, %d), размер %dx%d, площадь %d пикселей",
+			i+1, d.X, d.Y, d.Width, d.Height, d.Area)
+		if name, ok := kindNames[d.Kind]; ok {
+			fmt.Fprintf(&sb, ", тип: %s, сдвиг цвета ΔE %.1f (L %+.1f, a %+.1f, b %+.1f)",
+				name, d.Color.DeltaE, d.Color.L, d.Color.A, d.Color.B)
+		}
+		if d.Size.Measured() {
+			fmt.Fprintf(&sb, ", в миллиметрах %.2fx%.2f, площадь %.2f мм²",
+				d.Size.WidthMM, d.Size.HeightMM, d.Size.AreaMM2)
+		}
+		if d.Zone != "" {
+			fmt.Fprintf(&sb, ", зона эталона: %s", d.Zone)
+		}
+		if d.Part > 0 {
+			fmt.Fprintf(&sb, ", деталь №%d", d.Part)
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\nОпиши эти дефекты кратко и понятно:")
+	return sb.String()
+}
@@ -0,0 +1,108 @@
+package ai
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/i18n"
+)
+
+// TemplateDescriber описывает дефекты по шаблонам каталога сообщений:
+// для каждого дефекта — зона кадра, размер (в миллиметрах, если снимок
+// откалиброван) и центр, для цветовых — ещё тип и ΔE, на снимке с несколькими
+// деталями — номер детали.
+// Работает без внешних сервисов.
+type TemplateDescriber struct {
+	catalog *i18n.Catalog
+}
+
+// NewTemplateDescriber создаёт описатель на основе каталога сообщений.
+func NewTemplateDescriber(catalog *i18n.Catalog) *TemplateDescriber {
+	return &TemplateDescriber{catalog: catalog}
+}
+
+// Describe возвращает по строке на дефект на языке locale.
+// Неизвестный язык заменяется языком каталога по умолчанию.
+func (d *TemplateDescriber) Describe(ctx context.Context, result *entity.InspectionResult, locale string) (*entity.AiDescription, error) {
+	if result == nil || len(result.Defects) == 0 {
+		return &entity.AiDescription{}, nil
+	}
+
+	tr := d.catalog.Translator(d.catalog.Match(locale))
+	lines := make([]string, 0, len(result.Defects))
+	for i, defect := range result.Defects {
+		x, y := defect.Center()
+		args := i18n.Args{
+			"index":  i + 1,
+			"zone":   tr.T(zoneKey(x, y, result.ImageWidth, result.ImageHeight)),
+			"width":  defect.Width,
+			"height": defect.Height,
+			"x":      x,
+			"y":      y,
+		}
+		key := "description_item"
+		// С калибровкой размер называется в миллиметрах: по ним пишутся критерии приёмки.
+		if defect.Size.Measured() {
+			key = "description_item_mm"
+			args["width"] = roundMM(defect.Size.WidthMM)
+			args["height"] = roundMM(defect.Size.HeightMM)
+			args["area"] = roundMM(defect.Size.AreaMM2)
+		}
+		line := tr.T(key, args)
+		if defect.Kind != "" {
+			line += tr.T("description_color", i18n.Args{
+				"kind":    tr.T("defect_kind_" + string(defect.Kind)),
+				"delta_e": math.Round(defect.Color.DeltaE*10) / 10,
+			})
+		}
+		if defect.Zone != "" {
+			line += tr.T("description_zone", i18n.Args{"zone": defect.Zone})
+		}
+		if defect.Part > 0 {
+			line += tr.T("description_part", i18n.Args{"part": defect.Part})
+		}
+		lines = append(lines, line)
+	}
+	return &entity.AiDescription{Text: strings.Join(lines, "\n")}, nil
+}
+
+// roundMM округляет миллиметры до сотых.
+func roundMM(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// zoneKey делит кадр на сетку 3×3 и возвращает ключ зоны, в которую попал центр дефекта.
+// Без размеров кадра зона считается центральной.
+func zoneKey(x, y, width, height int) string {
+	if width <= 0 || height <= 0 {
+		return "zone_center"
+	}
+
+	rows := [3]string{"top", "", "bottom"}
+	cols := [3]string{"left", "", "right"}
+	row, col := rows[third(y, height)], cols[third(x, width)]
+	switch {
+	case row == "" && col == "":
+		return "zone_center"
+	case row == "":
+		return "zone_" + col
+	case col == "":
+		return "zone_" + row
+	default:
+		return "zone_" + row + "_" + col
+	}
+}
+
+// third возвращает номер трети отрезка [0, size), в которую попадает v.
+func third(v, size int) int {
+	switch {
+	case v < size/3:
+		return 0
+	case v < size*2/3:
+		return 1
+	default:
+		return 2
+	}
+}
@@ -0,0 +1,85 @@
+package ai
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/i18n"
+)
+
+func TestTemplateDescriber_Describe(t *testing.T) {
+	d := NewTemplateDescriber(i18n.MustDefault())
+	result := &entity.InspectionResult{
+		ImageWidth:  300,
+		ImageHeight: 300,
+		HasDefects:  true,
+		Defects: []entity.DefectArea{
+			{X: 10, Y: 10, Width: 20, Height: 10},
+			{X: 140, Y: 140, Width: 20, Height: 20},
+		},
+	}
+
+	en, err := d.Describe(context.Background(), result, "en")
+	require.NoError(t, err)
+	require.Equal(t, "1. top left: 20×10 px, centre (20, 15)\n2. centre: 20×20 px, centre (150, 150)", en.Text)
+
+	ru, err := d.Describe(context.Background(), result, "fr")
+	require.NoError(t, err)
+	require.Contains(t, ru.Text, "1. вверху слева: 20×10 пикс., центр (20; 15)")
+}
+
+func TestTemplateDescriber_DescribesColorDefects(t *testing.T) {
+	d := NewTemplateDescriber(i18n.MustDefault())
+	result := &entity.InspectionResult{
+		ImageWidth:  300,
+		ImageHeight: 300,
+		HasDefects:  true,
+		Defects: []entity.DefectArea{
+			{X: 140, Y: 140, Width: 20, Height: 20, Kind: entity.DefectCorrosion, Color: entity.ColorShift{DeltaE: 14.26, A: 9, B: 11}},
+		},
+	}
+
+	en, err := d.Describe(context.Background(), result, "en")
+	require.NoError(t, err)
+	require.Equal(t, "1. centre: 20×20 px, centre (150, 150), corrosion (ΔE 14.3)", en.Text)
+}
+
+func TestTemplateDescriber_NamesReferenceZoneAndPart(t *testing.T) {
+	d := NewTemplateDescriber(i18n.MustDefault())
+	result := &entity.InspectionResult{
+		ImageWidth:  300,
+		ImageHeight: 300,
+		HasDefects:  true,
+		Defects:     []entity.DefectArea{{X: 140, Y: 140, Width: 20, Height: 20, Zone: "seat", Part: 2}},
+	}
+
+	ru, err := d.Describe(context.Background(), result, "ru")
+	require.NoError(t, err)
+	require.Contains(t, ru.Text, "центр (150; 150), зона «seat», деталь №2")
+}
+
+func TestTemplateDescriber_UsesMillimetresWhenCalibrated(t *testing.T) {
+	d := NewTemplateDescriber(i18n.MustDefault())
+	result := &entity.InspectionResult{
+		ImageWidth:  300,
+		ImageHeight: 300,
+		HasDefects:  true,
+		Defects: []entity.DefectArea{
+			{X: 140, Y: 140, Width: 20, Height: 10, Size: entity.PhysicalSize{WidthMM: 1.004, HeightMM: 0.5, LengthMM: 1.004, AreaMM2: 0.456}},
+		},
+	}
+
+	en, err := d.Describe(context.Background(), result, "en")
+	require.NoError(t, err)
+	require.Equal(t, "1. centre: 1×0.5 mm (0.46 mm²), centre (150, 145)", en.Text)
+}
+
+func TestZoneKey(t *testing.T) {
+	require.Equal(t, "zone_top_right", zoneKey(290, 10, 300, 300))
+	require.Equal(t, "zone_bottom", zoneKey(150, 290, 300, 300))
+	require.Equal(t, "zone_left", zoneKey(10, 150, 300, 300))
+	require.Equal(t, "zone_center", zoneKey(10, 10, 0, 0))
+}
@@ -0,0 +1,191 @@
+package messenger
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"vision-bot/internal/domain/port"
+)
+
+// Виды исходящих сообщений, которые записывает FakeMessenger.
+const (
+	KindText     = "text"
+	KindPhoto    = "photo"
+	KindAlbum    = "album"
+	KindKeyboard = "keyboard"
+	KindEdit     = "edit"
+	KindCallback = "callback"
+)
+
+// ErrFileNotFound возвращается, если файл не был добавлен через AddFile
+var ErrFileNotFound = errors.New("file not found")
+
+// SentMessage исходящее сообщение, записанное FakeMessenger
+type SentMessage struct {
+	Kind       string
+	CallbackID string // только для KindCallback
+	ChatID     int64
+	ThreadID   int // тема форума, в которую ушло сообщение
+	ReplyTo    int // сообщение, на которое ответил бот
+	MessageID  int
+	Text       string
+	Photos     []port.Photo
+	Keyboard   port.Keyboard
+}
+
+// FakeMessenger in-process реализация port.Messenger для тестов:
+// записывает исходящие сообщения и отдаёт файлы из памяти
+type FakeMessenger struct {
+	mu      sync.Mutex
+	files   map[string][]byte
+	sent    []SentMessage
+	nextID  int
+	sendErr error
+}
+
+// NewFakeMessenger создаёт пустой тестовый мессенджер
+func NewFakeMessenger() *FakeMessenger {
+	return &FakeMessenger{
+		files: make(map[string][]byte),
+	}
+}
+
+// AddFile регистрирует файл, который будет отдан по fileID
+func (m *FakeMessenger) AddFile(fileID string, data []byte) {
+	m.mu.Lock()
+	m.files[fileID] = data
+	m.mu.Unlock()
+}
+
+// FailSends заставляет все последующие отправки возвращать err (nil — отключить)
+func (m *FakeMessenger) FailSends(err error) {
+	m.mu.Lock()
+	m.sendErr = err
+	m.mu.Unlock()
+}
+
+// Sent возвращает копию всех записанных сообщений
+func (m *FakeMessenger) Sent() []SentMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]SentMessage, len(m.sent))
+	copy(out, m.sent)
+	return out
+}
+
+// Texts возвращает тексты сообщений, отправленных в чат (включая подписи и клавиатуры)
+func (m *FakeMessenger) Texts(chatID int64) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var texts []string
+	for _, msg := range m.sent {
+		if msg.ChatID == chatID && msg.Text != "" {
+			texts = append(texts, msg.Text)
+		}
+	}
+	return texts
+}
+
+// Reset очищает список записанных сообщений
+func (m *FakeMessenger) Reset() {
+	m.mu.Lock()
+	m.sent = nil
+	m.mu.Unlock()
+}
+
+// SendText записывает текстовое сообщение
+func (m *FakeMessenger) SendText(ctx context.Context, to port.Recipient, text string) (int, error) {
+	return m.record(to, SentMessage{Kind: KindText, Text: text})
+}
+
+// SendPhoto записывает изображение с подписью
+func (m *FakeMessenger) SendPhoto(ctx context.Context, to port.Recipient, photo []byte, caption string) (int, error) {
+	return m.record(to, SentMessage{Kind: KindPhoto, Text: caption, Photos: []port.Photo{{Data: photo, Caption: caption}}})
+}
+
+// SendAlbum записывает альбом
+func (m *FakeMessenger) SendAlbum(ctx context.Context, to port.Recipient, photos []port.Photo) error {
+	_, err := m.record(to, SentMessage{Kind: KindAlbum, Photos: photos})
+	return err
+}
+
+// SendKeyboard записывает сообщение с клавиатурой
+func (m *FakeMessenger) SendKeyboard(ctx context.Context, to port.Recipient, text string, keyboard port.Keyboard) (int, error) {
+	return m.record(to, SentMessage{Kind: KindKeyboard, Text: text, Keyboard: keyboard})
+}
+
+// EditText записывает редактирование сообщения
+func (m *FakeMessenger) EditText(ctx context.Context, chatID int64, messageID int, text string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sendErr != nil {
+		return m.sendErr
+	}
+	m.sent = append(m.sent, SentMessage{Kind: KindEdit, ChatID: chatID, MessageID: messageID, Text: text})
+	return nil
+}
+
+// EditKeyboard записывает редактирование сообщения вместе с клавиатурой
+func (m *FakeMessenger) EditKeyboard(ctx context.Context, chatID int64, messageID int, text string, keyboard port.Keyboard) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sendErr != nil {
+		return m.sendErr
+	}
+	m.sent = append(m.sent, SentMessage{Kind: KindEdit, ChatID: chatID, MessageID: messageID, Text: text, Keyboard: keyboard})
+	return nil
+}
+
+// AnswerCallback записывает ответ на нажатие кнопки
+func (m *FakeMessenger) AnswerCallback(ctx context.Context, callbackID string, text string) error {
+	_, err := m.record(port.Recipient{}, SentMessage{Kind: KindCallback, CallbackID: callbackID, Text: text})
+	return err
+}
+
+// Callbacks возвращает ответы на нажатия кнопок в порядке отправки
+func (m *FakeMessenger) Callbacks() []SentMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []SentMessage
+	for _, msg := range m.sent {
+		if msg.Kind == KindCallback {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// GetFile отдаёт файл, добавленный через AddFile
+func (m *FakeMessenger) GetFile(ctx context.Context, fileID string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, ok := m.files[fileID]
+	if !ok {
+		return nil, ErrFileNotFound
+	}
+	return data, nil
+}
+
+func (m *FakeMessenger) record(to port.Recipient, msg SentMessage) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	msg.ChatID, msg.ThreadID, msg.ReplyTo = to.ChatID, to.ThreadID, to.ReplyTo
+	if m.sendErr != nil {
+		return 0, m.sendErr
+	}
+	m.nextID++
+	msg.MessageID = m.nextID
+	m.sent = append(m.sent, msg)
+	return msg.MessageID, nil
+}
+
+// Проверка реализации интерфейса
+var _ port.Messenger = (*FakeMessenger)(nil)
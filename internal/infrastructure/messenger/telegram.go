@@ -0,0 +1,227 @@
+package messenger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"vision-bot/internal/domain/port"
+)
+
+// maxAlbumSize — ограничение Telegram на число элементов в одном альбоме.
+const maxAlbumSize = 10
+
+// TelegramMessenger реализует port.Messenger поверх Telegram Bot API
+type TelegramMessenger struct {
+	api    *tgbotapi.BotAPI
+	client *http.Client
+}
+
+// NewTelegramMessenger создаёт адаптер для отправки сообщений через Telegram
+func NewTelegramMessenger(api *tgbotapi.BotAPI) *TelegramMessenger {
+	return &TelegramMessenger{
+		api:    api,
+		client: http.DefaultClient,
+	}
+}
+
+// SendText отправляет текстовое сообщение
+func (m *TelegramMessenger) SendText(ctx context.Context, to port.Recipient, text string) (int, error) {
+	params := recipientParams(to)
+	params["text"] = text
+
+	id, err := m.send("sendMessage", params, nil)
+	if err != nil {
+		return 0, fmt.Errorf("send message: %w", err)
+	}
+	return id, nil
+}
+
+// SendPhoto отправляет изображение с подписью
+func (m *TelegramMessenger) SendPhoto(ctx context.Context, to port.Recipient, photo []byte, caption string) (int, error) {
+	params := recipientParams(to)
+	params.AddNonEmpty("caption", caption)
+
+	id, err := m.send("sendPhoto", params, []tgbotapi.RequestFile{{
+		Name: "photo",
+		Data: tgbotapi.FileBytes{Name: "result.jpg", Bytes: photo},
+	}})
+	if err != nil {
+		return 0, fmt.Errorf("send photo: %w", err)
+	}
+	return id, nil
+}
+
+// SendAlbum отправляет изображения альбомами по 10 штук
+func (m *TelegramMessenger) SendAlbum(ctx context.Context, to port.Recipient, photos []port.Photo) error {
+	for start := 0; start < len(photos); start += maxAlbumSize {
+		end := start + maxAlbumSize
+		if end > len(photos) {
+			end = len(photos)
+		}
+		chunk := photos[start:end]
+
+		// Альбом из одного элемента Telegram не принимает.
+		if len(chunk) == 1 {
+			if _, err := m.SendPhoto(ctx, to, chunk[0].Data, chunk[0].Caption); err != nil {
+				return err
+			}
+			continue
+		}
+
+		media := make([]albumItem, 0, len(chunk))
+		files := make([]tgbotapi.RequestFile, 0, len(chunk))
+		for i, photo := range chunk {
+			name := fmt.Sprintf("file-%d", i)
+			media = append(media, albumItem{Type: "photo", Media: "attach://" + name, Caption: photo.Caption})
+			files = append(files, tgbotapi.RequestFile{
+				Name: name,
+				Data: tgbotapi.FileBytes{Name: fmt.Sprintf("photo_%d.jpg", start+i+1), Bytes: photo.Data},
+			})
+		}
+
+		params := recipientParams(to)
+		if err := params.AddInterface("media", media); err != nil {
+			return fmt.Errorf("send album: %w", err)
+		}
+		if _, err := m.api.UploadFiles("sendMediaGroup", params, files); err != nil {
+			return fmt.Errorf("send album: %w", err)
+		}
+	}
+	return nil
+}
+
+// SendKeyboard отправляет текст с inline-клавиатурой
+func (m *TelegramMessenger) SendKeyboard(ctx context.Context, to port.Recipient, text string, keyboard port.Keyboard) (int, error) {
+	params := recipientParams(to)
+	params["text"] = text
+	if err := params.AddInterface("reply_markup", inlineKeyboard(keyboard)); err != nil {
+		return 0, fmt.Errorf("send keyboard: %w", err)
+	}
+
+	id, err := m.send("sendMessage", params, nil)
+	if err != nil {
+		return 0, fmt.Errorf("send keyboard: %w", err)
+	}
+	return id, nil
+}
+
+// EditText заменяет текст ранее отправленного сообщения
+func (m *TelegramMessenger) EditText(ctx context.Context, chatID int64, messageID int, text string) error {
+	if _, err := m.api.Request(tgbotapi.NewEditMessageText(chatID, messageID, text)); err != nil {
+		return fmt.Errorf("edit message: %w", err)
+	}
+	return nil
+}
+
+// EditKeyboard заменяет текст и inline-клавиатуру ранее отправленного сообщения
+func (m *TelegramMessenger) EditKeyboard(ctx context.Context, chatID int64, messageID int, text string, keyboard port.Keyboard) error {
+	if _, err := m.api.Request(tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, inlineKeyboard(keyboard))); err != nil {
+		return fmt.Errorf("edit keyboard: %w", err)
+	}
+	return nil
+}
+
+// AnswerCallback подтверждает нажатие inline-кнопки
+func (m *TelegramMessenger) AnswerCallback(ctx context.Context, callbackID string, text string) error {
+	if _, err := m.api.Request(tgbotapi.NewCallback(callbackID, text)); err != nil {
+		return fmt.Errorf("answer callback: %w", err)
+	}
+	return nil
+}
+
+// GetFile скачивает файл из Telegram по его ID
+func (m *TelegramMessenger) GetFile(ctx context.Context, fileID string) ([]byte, error) {
+	file, err := m.api.GetFile(tgbotapi.FileConfig{FileID: fileID})
+	if err != nil {
+		return nil, fmt.Errorf("get file: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, file.Link(m.api.Token), nil)
+	if err != nil {
+		return nil, fmt.Errorf("download file: %w", err)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download file: unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	return data, nil
+}
+
+// albumItem — элемент sendMediaGroup; файл передаётся вложением attach://.
+type albumItem struct {
+	Type    string `json:"type"`
+	Media   string `json:"media"`
+	Caption string `json:"caption,omitempty"`
+}
+
+// send вызывает метод отправки и возвращает ID отправленного сообщения.
+// Конфиги tgbotapi v5.5.1 не знают message_thread_id, поэтому параметры
+// собираются вручную.
+func (m *TelegramMessenger) send(endpoint string, params tgbotapi.Params, files []tgbotapi.RequestFile) (int, error) {
+	var (
+		resp *tgbotapi.APIResponse
+		err  error
+	)
+	if len(files) > 0 {
+		resp, err = m.api.UploadFiles(endpoint, params, files)
+	} else {
+		resp, err = m.api.MakeRequest(endpoint, params)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var sent struct {
+		MessageID int `json:"message_id"`
+	}
+	if err := json.Unmarshal(resp.Result, &sent); err != nil {
+		return 0, fmt.Errorf("decode %s response: %w", endpoint, err)
+	}
+	return sent.MessageID, nil
+}
+
+// recipientParams задаёт чат, тему форума и сообщение, на которое отвечает бот.
+// Если исходное сообщение успели удалить, ответ всё равно отправляется.
+func recipientParams(to port.Recipient) tgbotapi.Params {
+	params := tgbotapi.Params{}
+	params.AddNonZero64("chat_id", to.ChatID)
+	params.AddNonZero("message_thread_id", to.ThreadID)
+	if to.ReplyTo != 0 {
+		params.AddNonZero("reply_to_message_id", to.ReplyTo)
+		params.AddBool("allow_sending_without_reply", true)
+	}
+	return params
+}
+
+// inlineKeyboard переводит клавиатуру порта в разметку Telegram.
+func inlineKeyboard(keyboard port.Keyboard) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(keyboard))
+	for _, row := range keyboard {
+		buttons := make([]tgbotapi.InlineKeyboardButton, 0, len(row))
+		for _, button := range row {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(button.Text, button.Data))
+		}
+		rows = append(rows, buttons)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// Проверка реализации интерфейса
+var _ port.Messenger = (*TelegramMessenger)(nil)
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/domain/port"
+)
+
+// MemoryInspectionRepository in-memory хранилище истории проверок
+type MemoryInspectionRepository struct {
+	mu        sync.RWMutex
+	records   map[string]*entity.InspectionRecord
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewMemoryInspectionRepository создаёт новое in-memory хранилище истории
+func NewMemoryInspectionRepository() *MemoryInspectionRepository {
+	return &MemoryInspectionRepository{
+		records: make(map[string]*entity.InspectionRecord),
+		now:     time.Now,
+	}
+}
+
+// SetRetention задаёт, сколько хранить записи вместе со снимками (0 — бессрочно).
+// Устаревшие записи удаляются при сохранении новых и не выдаются при чтении.
+func (r *MemoryInspectionRepository) SetRetention(retention time.Duration) {
+	r.mu.Lock()
+	r.retention = retention
+	r.mu.Unlock()
+}
+
+// Save сохраняет или обновляет запись о проверке
+func (r *MemoryInspectionRepository) Save(ctx context.Context, record *entity.InspectionRecord) error {
+	r.mu.Lock()
+	r.records[record.ID] = record
+	r.expire()
+	r.mu.Unlock()
+
+	return nil
+}
+
+// expire удаляет записи старше срока хранения; вызывается под блокировкой.
+func (r *MemoryInspectionRepository) expire() {
+	if r.retention <= 0 {
+		return
+	}
+	for id, record := range r.records {
+		if r.expired(record) {
+			delete(r.records, id)
+		}
+	}
+}
+
+// expired сообщает, что срок хранения записи истёк; вызывается под блокировкой.
+func (r *MemoryInspectionRepository) expired(record *entity.InspectionRecord) bool {
+	return r.retention > 0 && record.CreatedAt.Before(r.now().Add(-r.retention))
+}
+
+// Get возвращает запись по ID или nil, если она не найдена
+func (r *MemoryInspectionRepository) Get(ctx context.Context, id string) (*entity.InspectionRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	record, ok := r.records[id]
+	if !ok || r.expired(record) {
+		return nil, nil
+	}
+	return record, nil
+}
+
+// ListByUser возвращает последние проверки пользователя, новые первыми
+func (r *MemoryInspectionRepository) ListByUser(ctx context.Context, userID int64, limit int) ([]*entity.InspectionRecord, error) {
+	r.mu.RLock()
+	records := make([]*entity.InspectionRecord, 0)
+	for _, record := range r.records {
+		if record.UserID == userID && !r.expired(record) {
+			records = append(records, record)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CreatedAt.After(records[j].CreatedAt)
+	})
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+
+	return records, nil
+}
+
+// Stats возвращает сводку по всем сохранённым проверкам
+func (r *MemoryInspectionRepository) Stats(ctx context.Context) (entity.InspectionStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var stats entity.InspectionStats
+	for _, record := range r.records {
+		if r.expired(record) {
+			continue
+		}
+		stats.Total++
+		if record.Result != nil && record.Result.HasDefects {
+			stats.WithDefects++
+		}
+		if record.FalsePositive {
+			stats.FalsePositives++
+		}
+	}
+	return stats, nil
+}
+
+// Flush ничего не делает: in-memory хранилищу нечего сбрасывать
+func (r *MemoryInspectionRepository) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Ping всегда успешен: in-memory хранилище доступно, пока жив процесс
+func (r *MemoryInspectionRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Проверка реализации интерфейса
+var _ port.InspectionRepository = (*MemoryInspectionRepository)(nil)
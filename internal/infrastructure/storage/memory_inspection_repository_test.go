@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"vision-bot/internal/domain/entity"
+)
+
+func TestMemoryInspectionRepository_RetentionReleasesImages(t *testing.T) {
+	repo := NewMemoryInspectionRepository()
+	repo.SetRetention(time.Hour)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	require.NoError(t, repo.Save(ctx, &entity.InspectionRecord{
+		ID:        "old",
+		UserID:    1,
+		CreatedAt: now,
+		Result:    &entity.InspectionResult{HasDefects: true},
+		Reference: make([]byte, 1<<20),
+		Current:   make([]byte, 1<<20),
+	}))
+	record, err := repo.Get(ctx, "old")
+	require.NoError(t, err)
+	require.NotNil(t, record)
+
+	// Просроченная запись уже не выдаётся, даже если новых проверок ещё не было.
+	now = now.Add(2 * time.Hour)
+	record, err = repo.Get(ctx, "old")
+	require.NoError(t, err)
+	require.Nil(t, record)
+	records, err := repo.ListByUser(ctx, 1, 0)
+	require.NoError(t, err)
+	require.Empty(t, records)
+	stats, err := repo.Stats(ctx)
+	require.NoError(t, err)
+	require.Zero(t, stats.Total)
+
+	// Следующее сохранение удаляет запись, и снимки освобождаются.
+	require.NoError(t, repo.Save(ctx, &entity.InspectionRecord{ID: "new", UserID: 1, CreatedAt: now}))
+	require.Len(t, repo.records, 1)
+	require.NotContains(t, repo.records, "old")
+}
@@ -8,59 +8,87 @@ import (
 	"vision-bot/internal/domain/port"
 )
 
-// MemoryUserRepository in-memory хранилище пользователей
+// MemoryUserRepository in-memory хранилище пользователей.
+// Хранит и отдаёт копии: изменения видны другим только после Save.
 type MemoryUserRepository struct {
 	mu    sync.RWMutex
-	users map[int64]*entity.User
+	users map[entity.DialogueKey]*entity.User
 }
 
 // NewMemoryUserRepository создаёт новое in-memory хранилище
 func NewMemoryUserRepository() *MemoryUserRepository {
 	return &MemoryUserRepository{
-		users: make(map[int64]*entity.User),
+		users: make(map[entity.DialogueKey]*entity.User),
 	}
 }
 
-// Get возвращает пользователя по ID, создаёт нового если не найден
-func (r *MemoryUserRepository) Get(ctx context.Context, userID, chatID int64) (*entity.User, error) {
+// Get возвращает копию пользователя в диалоге, создаёт нового если не найден
+func (r *MemoryUserRepository) Get(ctx context.Context, key entity.DialogueKey) (*entity.User, error) {
 	r.mu.RLock()
-	user, exists := r.users[userID]
+	user, exists := r.users[key]
+	if exists {
+		user = user.Clone()
+	}
 	r.mu.RUnlock()
 
 	if exists {
 		return user, nil
 	}
 
-	// Создаём нового пользователя
-	newUser := entity.NewUser(userID, chatID)
-
 	r.mu.Lock()
-	r.users[userID] = newUser
-	r.mu.Unlock()
+	defer r.mu.Unlock()
 
-	return newUser, nil
+	// Пока блокировка была снята, пользователя мог создать другой апдейт.
+	user, exists = r.users[key]
+	if !exists {
+		user = entity.NewUser(key)
+		r.users[key] = user
+	}
+	return user.Clone(), nil
 }
 
-// Save сохраняет состояние пользователя
+// Save сохраняет копию состояния пользователя
 func (r *MemoryUserRepository) Save(ctx context.Context, user *entity.User) error {
 	r.mu.Lock()
-	r.users[user.ID] = user
+	r.users[user.Key()] = user.Clone()
 	r.mu.Unlock()
 
 	return nil
 }
 
-// UpdateState обновляет состояние пользователя
-func (r *MemoryUserRepository) UpdateState(ctx context.Context, userID int64, state entity.UserState) error {
+// UpdateState обновляет состояние пользователя в диалоге
+func (r *MemoryUserRepository) UpdateState(ctx context.Context, key entity.DialogueKey, state entity.UserState) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if user, exists := r.users[userID]; exists {
+	if user, exists := r.users[key]; exists {
 		user.SetState(state)
 	}
 
 	return nil
 }
 
+// List возвращает копии всех известных диалогов
+func (r *MemoryUserRepository) List(ctx context.Context) ([]*entity.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]*entity.User, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, user.Clone())
+	}
+	return users, nil
+}
+
+// Flush ничего не делает: in-memory хранилищу нечего сбрасывать
+func (r *MemoryUserRepository) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Ping всегда успешен: in-memory хранилище доступно, пока жив процесс
+func (r *MemoryUserRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
 // Проверка реализации интерфейса
 var _ port.UserRepository = (*MemoryUserRepository)(nil)
@@ -0,0 +1,93 @@
+package vision
+
+import (
+	"math"
+
+	"vision-bot/internal/domain/entity"
+)
+
+// Печатные маркеры калибровки.
+const (
+	markerChessboard = "chessboard"
+	markerAruco      = "aruco"
+)
+
+// arucoDictionaries — имена словарей ArUco в порядке кодов OpenCV:
+// индекс в срезе совпадает с gocv.ArucoDictionaryCode.
+var arucoDictionaries = []string{
+	"4x4_50", "4x4_100", "4x4_250", "4x4_1000",
+	"5x5_50", "5x5_100", "5x5_250", "5x5_1000",
+	"6x6_50", "6x6_100", "6x6_250", "6x6_1000",
+	"7x7_50", "7x7_100", "7x7_250", "7x7_1000",
+	"original",
+}
+
+// Tolerance возвращает допуск профиля в миллиметрах.
+func (d *GoCVDetector) Tolerance() entity.Tolerance {
+	return entity.Tolerance{
+		MinLengthMM: d.Calibration.MinDefectLengthMM,
+		MinAreaMM2:  d.Calibration.MinDefectAreaMM2,
+	}
+}
+
+// gridStep возвращает средний шаг между соседними углами сетки cols×rows,
+// заданной построчно, как её возвращает поиск углов шахматной доски.
+func gridStep(points [][2]float64, cols, rows int) float64 {
+	if cols < 2 || rows < 2 || len(points) != cols*rows {
+		return 0
+	}
+	var sum float64
+	var n int
+	for row := range rows {
+		for col := range cols {
+			p := points[row*cols+col]
+			if col+1 < cols {
+				sum += pointDistance(p, points[row*cols+col+1])
+				n++
+			}
+			if row+1 < rows {
+				sum += pointDistance(p, points[(row+1)*cols+col])
+				n++
+			}
+		}
+	}
+	return sum / float64(n)
+}
+
+// meanQuadSide возвращает среднюю длину сторон четырёхугольников — углов
+// найденных ArUco-маркеров.
+func meanQuadSide(quads [][][2]float64) float64 {
+	var sum float64
+	var n int
+	for _, quad := range quads {
+		if len(quad) != 4 {
+			continue
+		}
+		for i := range quad {
+			sum += pointDistance(quad[i], quad[(i+1)%4])
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+func pointDistance(a, b [2]float64) float64 {
+	return math.Hypot(a[0]-b[0], a[1]-b[1])
+}
+
+// newCalibration собирает калибровку снимка width×height, на котором
+// отрезок lengthPx пикселей имеет длину lengthMM. Нулевая длина — мерить нечем.
+func newCalibration(lengthMM, lengthPx float64, width, height int, source entity.CalibrationSource) *entity.Calibration {
+	if lengthMM <= 0 || lengthPx <= 0 {
+		return nil
+	}
+	return &entity.Calibration{
+		MMPerPixel:  lengthMM / lengthPx,
+		ImageWidth:  width,
+		ImageHeight: height,
+		Source:      source,
+	}
+}
@@ -0,0 +1,128 @@
+//go:build gocv
+// +build gocv
+
+package vision
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"log/slog"
+	"math"
+	"slices"
+
+	"gocv.io/x/gocv"
+
+	"vision-bot/internal/domain/entity"
+)
+
+// CalibrateByPart измеряет деталь по большей стороне описанного повёрнутого
+// прямоугольника и сопоставляет её с длиной lengthMM, которую ввёл инспектор.
+// Масштаб относится к снимку в исходном разрешении.
+func (d *GoCVDetector) CalibrateByPart(ctx context.Context, imageData []byte, lengthMM float64) (*entity.Calibration, error) {
+	if lengthMM <= 0 {
+		return nil, errors.New("part length must be positive")
+	}
+	mat, err := decodeToMat(imageData)
+	if err != nil {
+		return nil, err
+	}
+	defer mat.Close()
+
+	mask := d.buildPartMask(mat)
+	defer mask.Close()
+	// Без найденной детали маска закрывает весь кадр.
+	if gocv.CountNonZero(mask) >= mat.Rows()*mat.Cols() {
+		return nil, errors.New("part is not detected")
+	}
+
+	contours := gocv.FindContours(mask, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+	largest, largestArea := -1, 0.0
+	for i := 0; i < contours.Size(); i++ {
+		if area := gocv.ContourArea(contours.At(i)); area > largestArea {
+			largest, largestArea = i, area
+		}
+	}
+	if largest < 0 {
+		return nil, errors.New("part is not detected")
+	}
+
+	rect := gocv.MinAreaRect2f(contours.At(largest))
+	lengthPx := math.Max(float64(rect.Width), float64(rect.Height))
+	slog.DebugContext(ctx, "Part measured for calibration", "length_px", lengthPx, "length_mm", lengthMM)
+	return newCalibration(lengthMM, lengthPx, mat.Cols(), mat.Rows(), entity.CalibrationManual), nil
+}
+
+// CalibrateByMarker ищет в кадре маркер из секции calibration профиля.
+// Без маркера в профиле или в кадре возвращает nil без ошибки.
+func (d *GoCVDetector) CalibrateByMarker(ctx context.Context, imageData []byte) (*entity.Calibration, error) {
+	if d.Calibration.Marker == "" {
+		return nil, nil
+	}
+	mat, err := decodeToMat(imageData)
+	if err != nil {
+		return nil, err
+	}
+	defer mat.Close()
+
+	gray := gocv.NewMat()
+	defer gray.Close()
+	gocv.CvtColor(mat, &gray, gocv.ColorBGRToGray)
+
+	var stepPx float64
+	var source entity.CalibrationSource
+	switch d.Calibration.Marker {
+	case markerChessboard:
+		stepPx, source = d.chessboardStep(gray), entity.CalibrationChessboard
+	case markerAruco:
+		stepPx, source = d.arucoSide(gray), entity.CalibrationAruco
+	default:
+		return nil, fmt.Errorf("unknown calibration marker %q", d.Calibration.Marker)
+	}
+	if stepPx <= 0 {
+		slog.DebugContext(ctx, "Calibration marker not found", "marker", d.Calibration.Marker)
+		return nil, nil
+	}
+	slog.DebugContext(ctx, "Calibration marker found", "marker", d.Calibration.Marker, "step_px", stepPx)
+	return newCalibration(d.Calibration.MarkerSizeMM, stepPx, mat.Cols(), mat.Rows(), source), nil
+}
+
+// chessboardStep возвращает шаг клетки шахматной доски в пикселях; 0 — доска не найдена.
+func (d *GoCVDetector) chessboardStep(gray gocv.Mat) float64 {
+	cols, rows := d.Calibration.ChessboardCols, d.Calibration.ChessboardRows
+	corners := gocv.NewMat()
+	defer corners.Close()
+	if !gocv.FindChessboardCorners(gray, image.Pt(cols, rows), &corners, gocv.CalibCBAdaptiveThresh|gocv.CalibCBNormalizeImage) {
+		return 0
+	}
+
+	points := make([][2]float64, 0, corners.Rows())
+	for i := 0; i < corners.Rows(); i++ {
+		v := corners.GetVecfAt(i, 0)
+		points = append(points, [2]float64{float64(v[0]), float64(v[1])})
+	}
+	return gridStep(points, cols, rows)
+}
+
+// arucoSide возвращает среднюю сторону найденных ArUco-маркеров в пикселях; 0 — маркеров нет.
+func (d *GoCVDetector) arucoSide(gray gocv.Mat) float64 {
+	code := slices.Index(arucoDictionaries, d.Calibration.ArucoDictionary)
+	if code < 0 {
+		return 0
+	}
+	detector := gocv.NewArucoDetectorWithParams(gocv.GetPredefinedDictionary(gocv.ArucoDictionaryCode(code)), gocv.NewArucoDetectorParameters())
+	defer detector.Close()
+
+	corners, _, _ := detector.DetectMarkers(gray)
+	quads := make([][][2]float64, 0, len(corners))
+	for _, marker := range corners {
+		quad := make([][2]float64, 0, len(marker))
+		for _, p := range marker {
+			quad = append(quad, [2]float64{float64(p.X), float64(p.Y)})
+		}
+		quads = append(quads, quad)
+	}
+	return meanQuadSide(quads)
+}
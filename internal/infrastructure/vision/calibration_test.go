@@ -0,0 +1,36 @@
+package vision
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"vision-bot/internal/domain/entity"
+)
+
+func TestGridStep(t *testing.T) {
+	// Доска 3×2 внутренних угла с шагом 20 пикселей
+	points := [][2]float64{{10, 10}, {30, 10}, {50, 10}, {10, 30}, {30, 30}, {50, 30}}
+	assert.InDelta(t, 20, gridStep(points, 3, 2), 1e-9)
+	assert.Zero(t, gridStep(points[:5], 3, 2), "incomplete grid")
+}
+
+func TestMeanQuadSide(t *testing.T) {
+	quads := [][][2]float64{
+		{{0, 0}, {40, 0}, {40, 40}, {0, 40}},
+		{{100, 100}, {160, 100}, {160, 160}, {100, 160}},
+		{{0, 0}, {1, 1}},
+	}
+	assert.InDelta(t, 50, meanQuadSide(quads), 1e-9)
+	assert.Zero(t, meanQuadSide(nil))
+}
+
+func TestNewCalibrationAndTolerance(t *testing.T) {
+	calibration := newCalibration(25, 500, 1600, 1200, entity.CalibrationAruco)
+	assert.Equal(t, &entity.Calibration{MMPerPixel: 0.05, ImageWidth: 1600, ImageHeight: 1200, Source: entity.CalibrationAruco}, calibration)
+	assert.Nil(t, newCalibration(25, 0, 1600, 1200, entity.CalibrationAruco))
+
+	d := NewGoCVDetector(0)
+	d.Calibration.MinDefectLengthMM = 0.5
+	assert.Equal(t, entity.Tolerance{MinLengthMM: 0.5}, d.Tolerance())
+}
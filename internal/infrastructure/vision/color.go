@@ -0,0 +1,102 @@
+package vision
+
+import (
+	"image"
+	"math"
+
+	"vision-bot/internal/domain/entity"
+)
+
+// labScaleL переводит канал L 8-битного Lab OpenCV (0–255) в единицы L* (0–100).
+// Каналы a и b в 8-битном Lab смещены на 128, но в масштабе a*, b*.
+const labScaleL = 100.0 / 255
+
+// labShifts возвращает попиксельный сдвиг цвета current относительно base по
+// каналам L*, a*, b*. Снимки — 8-битный Lab OpenCV, каналы пикселя идут подряд.
+// Общий для детали сдвиг (медиана по roi) вычитается: другое освещение или
+// баланс белого не должны превращать в дефект всю деталь.
+func labShifts(base, current, roi []byte) [3][]float32 {
+	var shifts [3][]float32
+	for c := range shifts {
+		shifts[c] = make([]float32, len(roi))
+		for i := range roi {
+			shifts[c][i] = float32(int(current[i*3+c]) - int(base[i*3+c]))
+		}
+	}
+	for i := range shifts[0] {
+		shifts[0][i] *= labScaleL
+	}
+
+	for c := range shifts {
+		median, _, ok := robustCenter(shifts[c], roi)
+		if !ok {
+			break
+		}
+		for i := range shifts[c] {
+			shifts[c][i] -= float32(median)
+		}
+	}
+	return shifts
+}
+
+// colorShiftMask отмечает 255 пиксели roi, где ΔE*76 сдвига больше threshold,
+// делённого на множитель зоны из sensitivity (nil — множитель везде 1).
+func colorShiftMask(shifts [3][]float32, roi []byte, sensitivity []float32, threshold float64) []byte {
+	out := make([]byte, len(roi))
+	for i, m := range roi {
+		if m == 0 {
+			continue
+		}
+		limit := threshold
+		if sensitivity != nil {
+			if sensitivity[i] == 0 {
+				continue
+			}
+			limit /= float64(sensitivity[i])
+		}
+		l, a, b := float64(shifts[0][i]), float64(shifts[1][i]), float64(shifts[2][i])
+		if l*l+a*a+b*b > limit*limit {
+			out[i] = 255
+		}
+	}
+	return out
+}
+
+// meanColorShift усредняет сдвиг цвета по пикселям mask внутри rect;
+// width — ширина кадра.
+func meanColorShift(shifts [3][]float32, mask []byte, width int, rect image.Rectangle) entity.ColorShift {
+	var sum [3]float64
+	count := 0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			i := y*width + x
+			if mask[i] == 0 {
+				continue
+			}
+			for c := range sum {
+				sum[c] += float64(shifts[c][i])
+			}
+			count++
+		}
+	}
+	if count == 0 {
+		return entity.ColorShift{}
+	}
+
+	l, a, b := sum[0]/float64(count), sum[1]/float64(count), sum[2]/float64(count)
+	return entity.ColorShift{DeltaE: math.Sqrt(l*l + a*a + b*b), L: l, A: a, B: b}
+}
+
+// classifyColorShift определяет тип цветового дефекта по среднему сдвигу:
+// ржавчина уводит цвет в красный и жёлтый, пятно прежде всего темнее детали,
+// остальное — изменение цвета (побежалость, выцветание, краска).
+func classifyColorShift(shift entity.ColorShift, profile ColorProfile) entity.DefectKind {
+	switch {
+	case shift.A >= profile.CorrosionMinShift && shift.B >= profile.CorrosionMinShift:
+		return entity.DefectCorrosion
+	case -shift.L >= profile.StainMinDarkening && -shift.L >= math.Hypot(shift.A, shift.B):
+		return entity.DefectStain
+	default:
+		return entity.DefectDiscoloration
+	}
+}
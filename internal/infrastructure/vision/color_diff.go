@@ -0,0 +1,68 @@
+//go:build gocv
+// +build gocv
+
+package vision
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+
+	"gocv.io/x/gocv"
+
+	"vision-bot/internal/domain/entity"
+)
+
+// colorDiffDefects ищет дефекты по цвету: ΔE*76 между эталоном и совмещённой
+// деталью внутри roiMask; порог делится на множитель зоны из sensitivity.
+// Тип дефекта определяется по среднему сдвигу цвета под его маской.
+func (d *GoCVDetector) colorDiffDefects(ctx context.Context, baseMat, currentMat, roiMask gocv.Mat, sensitivity []float32) ([]entity.DefectArea, error) {
+	if roiMask.Empty() {
+		return nil, nil
+	}
+	width, height := baseMat.Cols(), baseMat.Rows()
+	roi := roiMask.ToBytes()
+	baseLab := d.labBytes(baseMat)
+	currentLab := d.labBytes(currentMat)
+	if len(baseLab) != 3*len(roi) || len(currentLab) != len(baseLab) {
+		return nil, errors.New("unexpected Lab image layout")
+	}
+
+	shifts := labShifts(baseLab, currentLab, roi)
+	mask, err := maskFromBytes(height, width, colorShiftMask(shifts, roi, sensitivity, d.Color.DeltaEThreshold))
+	if err != nil {
+		return nil, fmt.Errorf("color mask: %w", err)
+	}
+	defer mask.Close()
+	cleaned := d.postProcessDiffMask(mask)
+	defer cleaned.Close()
+	d.dump(ctx, "color_mask", cleaned)
+
+	contours := gocv.FindContours(cleaned, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+	defects := d.extractDefectsFromContours(ctx, contours, width, height, "color_contour")
+
+	pixels := cleaned.ToBytes()
+	for i := range defects {
+		defect := &defects[i]
+		rect := image.Rect(defect.X, defect.Y, defect.X+defect.Width, defect.Y+defect.Height)
+		defect.Color = meanColorShift(shifts, pixels, width, rect)
+		defect.Kind = classifyColorShift(defect.Color, d.Color)
+		defect.Reason = appendReason(defect.Reason, fmt.Sprintf("kind=%s delta_e=%.1f", defect.Kind, defect.Color.DeltaE))
+	}
+	return defects, nil
+}
+
+// labBytes сглаживает снимок и переводит его в 8-битный Lab.
+func (d *GoCVDetector) labBytes(mat gocv.Mat) []byte {
+	size := normalizeKernelSize(d.Color.BlurKernel, 1)
+	smoothed := gocv.NewMat()
+	defer smoothed.Close()
+	gocv.GaussianBlur(mat, &smoothed, image.Pt(size, size), 0, 0, gocv.BorderDefault)
+
+	lab := gocv.NewMat()
+	defer lab.Close()
+	gocv.CvtColor(smoothed, &lab, gocv.ColorBGRToLab)
+	return lab.ToBytes()
+}
@@ -0,0 +1,38 @@
+package vision
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"vision-bot/internal/domain/entity"
+)
+
+func TestLabShifts_RemoveCommonShiftAndMarkColorSpots(t *testing.T) {
+	// Вся деталь стала светлее на 10 уровней, а последний пиксель ещё и порыжел
+	base := []byte{100, 128, 128, 100, 128, 128, 100, 128, 128, 100, 128, 128}
+	current := []byte{110, 128, 128, 110, 128, 128, 110, 128, 128, 110, 148, 150}
+	roi := []byte{255, 255, 255, 255}
+
+	shifts := labShifts(base, current, roi)
+	assert.Equal(t, []float32{0, 0, 0, 0}, shifts[0], "common lighting shift is removed")
+	assert.Equal(t, []float32{0, 0, 0, 20}, shifts[1])
+	assert.Equal(t, []byte{0, 0, 0, 255}, colorShiftMask(shifts, roi, nil, 12))
+	assert.Equal(t, []byte{0, 0, 0, 0}, colorShiftMask(shifts, []byte{255, 255, 255, 0}, nil, 12))
+	assert.Equal(t, []byte{0, 0, 0, 0}, colorShiftMask(shifts, roi, []float32{1, 1, 1, 0}, 12), "ignored zone")
+	assert.Equal(t, []byte{0, 0, 0, 255}, colorShiftMask(shifts, roi, []float32{1, 1, 1, 2}, 40), "sensitive zone")
+
+	shift := meanColorShift(shifts, []byte{0, 0, 0, 255}, 2, image.Rect(0, 0, 2, 2))
+	assert.Equal(t, 20.0, shift.A)
+	assert.Equal(t, 22.0, shift.B)
+	assert.InDelta(t, 29.73, shift.DeltaE, 0.01)
+	assert.Equal(t, entity.ColorShift{}, meanColorShift(shifts, make([]byte, 4), 2, image.Rect(0, 0, 2, 2)))
+}
+
+func TestClassifyColorShift(t *testing.T) {
+	profile := defaultColorProfile()
+	assert.Equal(t, entity.DefectCorrosion, classifyColorShift(entity.ColorShift{L: -10, A: 12, B: 18}, profile))
+	assert.Equal(t, entity.DefectStain, classifyColorShift(entity.ColorShift{L: -25, A: 2, B: 3}, profile))
+	assert.Equal(t, entity.DefectDiscoloration, classifyColorShift(entity.ColorShift{L: 2, A: -6, B: -14}, profile))
+}
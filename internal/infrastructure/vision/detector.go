@@ -11,57 +11,85 @@ import (
 	"image"
 	"image/color"
 	"image/jpeg"
-	"log"
+	"log/slog"
 	"math"
+	"path/filepath"
 	"sort"
+	"time"
 
 	"gocv.io/x/gocv"
 
 	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/logging"
 )
 
+// Enabled сообщает, что детектор собран с OpenCV (тег gocv).
+const Enabled = true
+
 type GoCVDetector struct {
-	MinAreaRatio                   float64
-	MaxAspectRatio                 float64
-	MinAspectRatio                 float64
-	MaxSide                        int
-	MinImageSide                   int
-	MinSharpnessEdgeRatio          float64
-	MaxOverexposedRatio            float64
-	MaxUnderexposedRatio           float64
-	MaxGlareRatio                  float64
-	DiffMaxGlareRatio              float64
-	MinPartAreaRatio               float64
-	PartSecondaryAreaRatio         float64
-	PartSecondaryRelRatio          float64
-	ROIMarginKernel                int
-	EnableRegistration             bool
-	MinAlignmentScore              float64
-	DiffMinThreshold               float32
-	DiffOpenKernel                 int
-	DiffCloseKernel                int
-	MinContourAreaRatio            float64
-	MinFillRatio                   float64
-	NMSIoUThreshold                float64
-	NMSContainmentRatio            float64
-	BrokenMinComponentRatio        float64
-	BrokenAreaLossRatio            float64
-	BrokenFocusExpand              int
-	BrokenMinOverlapRatio          float64
-	BrokenMergeDistance            int
-	BrokenSplitKernel              int
-	BrokenSecondRelMin             float64
-	BrokenDominantMinRatio         float64
-	EnableGeometryCheck            bool
-	GeometryMatchMaxScore          float64
-	GeometryMinConcavity           int
-	GeometryMinConcavityGap        int
-	GeometryPolygonVertexGap       int
-	GeometryPolygonMinCircularity  float64
-	GeometryPolygonMinExtent       float64
-	GeometryRoundMinCircularity    float64
-	GeometryRoundMaxCircularityGap float64
-	GeometryRingKernel             int
+	MinAreaRatio                   float64 `yaml:"min_area_ratio"`
+	MaxAspectRatio                 float64 `yaml:"max_aspect_ratio"`
+	MinAspectRatio                 float64 `yaml:"min_aspect_ratio"`
+	MaxSide                        int     `yaml:"max_side"`
+	MinImageSide                   int     `yaml:"min_image_side"`
+	MinSharpnessEdgeRatio          float64 `yaml:"min_sharpness_edge_ratio"`
+	MaxOverexposedRatio            float64 `yaml:"max_overexposed_ratio"`
+	MaxUnderexposedRatio           float64 `yaml:"max_underexposed_ratio"`
+	MaxGlareRatio                  float64 `yaml:"max_glare_ratio"`
+	DiffMaxGlareRatio              float64 `yaml:"diff_max_glare_ratio"`
+	MinPartAreaRatio               float64 `yaml:"min_part_area_ratio"`
+	PartSecondaryAreaRatio         float64 `yaml:"part_secondary_area_ratio"`
+	PartSecondaryRelRatio          float64 `yaml:"part_secondary_rel_ratio"`
+	ROIMarginKernel                int     `yaml:"roi_margin_kernel"`
+	EnableRegistration             bool    `yaml:"enable_registration"`
+	MinAlignmentScore              float64 `yaml:"min_alignment_score"`
+	DiffMinThreshold               float32 `yaml:"diff_min_threshold"`
+	DiffOpenKernel                 int     `yaml:"diff_open_kernel"`
+	DiffCloseKernel                int     `yaml:"diff_close_kernel"`
+	MinContourAreaRatio            float64 `yaml:"min_contour_area_ratio"`
+	MinFillRatio                   float64 `yaml:"min_fill_ratio"`
+	NMSIoUThreshold                float64 `yaml:"nms_iou_threshold"`
+	NMSContainmentRatio            float64 `yaml:"nms_containment_ratio"`
+	BrokenMinComponentRatio        float64 `yaml:"broken_min_component_ratio"`
+	BrokenAreaLossRatio            float64 `yaml:"broken_area_loss_ratio"`
+	BrokenFocusExpand              int     `yaml:"broken_focus_expand"`
+	BrokenMinOverlapRatio          float64 `yaml:"broken_min_overlap_ratio"`
+	BrokenMergeDistance            int     `yaml:"broken_merge_distance"`
+	BrokenSplitKernel              int     `yaml:"broken_split_kernel"`
+	BrokenSecondRelMin             float64 `yaml:"broken_second_rel_min"`
+	BrokenDominantMinRatio         float64 `yaml:"broken_dominant_min_ratio"`
+	EnableGeometryCheck            bool    `yaml:"enable_geometry_check"`
+	GeometryMatchMaxScore          float64 `yaml:"geometry_match_max_score"`
+	GeometryMinConcavity           int     `yaml:"geometry_min_concavity"`
+	GeometryMinConcavityGap        int     `yaml:"geometry_min_concavity_gap"`
+	GeometryPolygonVertexGap       int     `yaml:"geometry_polygon_vertex_gap"`
+	GeometryPolygonMinCircularity  float64 `yaml:"geometry_polygon_min_circularity"`
+	GeometryPolygonMinExtent       float64 `yaml:"geometry_polygon_min_extent"`
+	GeometryRoundMinCircularity    float64 `yaml:"geometry_round_min_circularity"`
+	GeometryRoundMaxCircularityGap float64 `yaml:"geometry_round_max_circularity_gap"`
+	GeometryRingKernel             int     `yaml:"geometry_ring_kernel"`
+	// GoldenZThreshold — во сколько разбросов годных образцов яркость
+	// пикселя может отклониться от средней, прежде чем он станет дефектом.
+	GoldenZThreshold float64 `yaml:"golden_z_threshold"`
+	// GoldenMinSigma — нижняя граница разброса яркости (в уровнях 0–255).
+	GoldenMinSigma float64 `yaml:"golden_min_sigma"`
+	// CriticalZoneSensitivity — множитель чувствительности критичных зон
+	// эталона, если зона не задаёт свой: во сколько раз ниже в них порог отличий.
+	CriticalZoneSensitivity float64 `yaml:"critical_zone_sensitivity"`
+	// Quick — пороги проверки по одному снимку, без эталона.
+	Quick QuickProfile `yaml:"quick"`
+	// Color — пороги цветовой ветки сравнения с эталоном.
+	Color ColorProfile `yaml:"color"`
+	// Calibration — перевод пикселей в миллиметры и допуск в миллиметрах.
+	Calibration CalibrationProfile `yaml:"calibration"`
+	// Orientation — поиск поворота и отражения детали относительно эталона.
+	Orientation OrientationProfile `yaml:"orientation"`
+	// MultiPart — проверка снимка с несколькими деталями.
+	MultiPart MultiPartProfile `yaml:"multi_part"`
+
+	// DebugDumpDir — каталог для промежуточных масок каждой проверки
+	// (пусто — не сохранять). Задаётся конфигурацией, а не профилем.
+	DebugDumpDir string `yaml:"-"`
 }
 
 type maskComponent struct {
@@ -132,78 +160,27 @@ func NewGoCVDetector(minArea int) *GoCVDetector {
 		GeometryRoundMinCircularity:    0.82,
 		GeometryRoundMaxCircularityGap: 0.10,
 		GeometryRingKernel:             41,
+		GoldenZThreshold:               4,
+		GoldenMinSigma:                 6,
+		CriticalZoneSensitivity:        2,
+		Quick:                          defaultQuickProfile(),
+		Color:                          defaultColorProfile(),
+		Calibration:                    defaultCalibrationProfile(),
+		Orientation:                    defaultOrientationProfile(),
+		MultiPart:                      defaultMultiPartProfile(),
 	}
 }
 
-// Inspect запускает анализ изображения и возвращает найденные дефекты.
-func (d *GoCVDetector) Inspect(ctx context.Context, imageData []byte) (*entity.InspectionResult, error) {
-	_ = ctx
-	mat, err := decodeToMat(imageData)
-	if err != nil {
-		return nil, err
-	}
-	defer mat.Close()
-
-	if mat.Empty() {
-		return nil, errors.New("empty image")
-	}
-	if err := d.checkImageQuality(mat, "image", d.MaxGlareRatio); err != nil {
+// InspectDiff ищет отличия между эталоном и текущим изображением. Игнорируемые
+// зоны эталона не проверяются, в остальных порог отличий делится на множитель
+// чувствительности зоны; каждый дефект получает имя зоны, куда попал его центр.
+// С секцией multi_part профиля детали на снимке проверяются по отдельности.
+func (d *GoCVDetector) InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	var clock stageClock
+	if err := clock.enter(ctx, "decode"); err != nil {
 		return nil, err
 	}
 
-	// Приводим изображение к стандартному размеру для стабильных порогов.
-	if mat.Cols() > d.MaxSide || mat.Rows() > d.MaxSide {
-		scale := float64(d.MaxSide) / float64(maxInt(mat.Cols(), mat.Rows()))
-		newW := int(float64(mat.Cols()) * scale)
-		newH := int(float64(mat.Rows()) * scale)
-		resized := gocv.NewMat()
-		gocv.Resize(mat, &resized, image.Pt(newW, newH), 0, 0, gocv.InterpolationArea)
-		mat.Close()
-		mat = resized
-	}
-
-	gray := gocv.NewMat()
-	defer gray.Close()
-	gocv.CvtColor(mat, &gray, gocv.ColorBGRToGray)
-
-	partMask := d.buildPartMask(mat)
-	defer partMask.Close()
-	roiMask := d.buildInteriorMask(partMask)
-	defer roiMask.Close()
-
-	blur := gocv.NewMat()
-	defer blur.Close()
-	gocv.GaussianBlur(gray, &blur, image.Pt(5, 5), 0, 0, gocv.BorderDefault)
-
-	edges := gocv.NewMat()
-	defer edges.Close()
-	gocv.Canny(blur, &edges, 50, 150)
-	edgeInput := edges
-	maskedEdges := gocv.NewMat()
-	defer maskedEdges.Close()
-	if !roiMask.Empty() {
-		gocv.BitwiseAnd(edges, roiMask, &maskedEdges)
-		edgeInput = maskedEdges
-	}
-
-	contours := gocv.FindContours(edgeInput, gocv.RetrievalExternal, gocv.ChainApproxSimple)
-	defer contours.Close()
-
-	defects := d.extractDefectsFromContours(contours, mat.Cols(), mat.Rows(), "edge_contour")
-	d.logDefects("inspect", defects)
-
-	return &entity.InspectionResult{
-		ImageWidth:  mat.Cols(),
-		ImageHeight: mat.Rows(),
-		Defects:     defects,
-		HasDefects:  len(defects) > 0,
-	}, nil
-}
-
-// InspectDiff ищет отличия между эталоном и текущим изображением.
-func (d *GoCVDetector) InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte) (*entity.InspectionResult, error) {
-	_ = ctx
-
 	baseMat, err := decodeToMat(baseImage)
 	if err != nil {
 		return nil, err
@@ -225,6 +202,9 @@ func (d *GoCVDetector) InspectDiff(ctx context.Context, baseImage []byte, curren
 	if err := d.checkImageQuality(currentMat, "current image", d.DiffMaxGlareRatio); err != nil {
 		return nil, err
 	}
+	if err := clock.enter(ctx, "part_mask"); err != nil {
+		return nil, err
+	}
 
 	// Приводим оба изображения к одному размеру (минимальный из двух).
 	targetW := minInt(baseMat.Cols(), currentMat.Cols())
@@ -246,14 +226,56 @@ func (d *GoCVDetector) InspectDiff(ctx context.Context, baseImage []byte, curren
 	defer baseMask.Close()
 	currentMask := d.buildPartMask(currentMat)
 	defer currentMask.Close()
+	d.dump(ctx, "base_mask", baseMask)
+	d.dump(ctx, "current_mask", currentMask)
+
+	// На снимке с несколькими деталями каждая сравнивается с эталоном отдельно.
+	if d.MultiPart.Enabled {
+		if parts := d.segmentParts(currentMask); len(parts) > 1 {
+			return d.inspectParts(ctx, &clock, baseMat, baseMask, currentMat, parts, zones)
+		}
+	}
 
+	sensitivity := zoneSensitivity(zones, targetW, targetH, d.CriticalZoneSensitivity)
+	result, _, err := d.diffAligned(ctx, &clock, baseMat, baseMask, currentMat, currentMask, sensitivity)
+	if err != nil {
+		return nil, err
+	}
+	annotateZones(result.Defects, zones, targetW, targetH)
+	return result, nil
+}
+
+// diffAligned разворачивает и совмещает деталь с эталоном, сравнивает их
+// по яркости и цвету и разбирает отличия. Снимки и маски — одного кадра
+// эталона или его вырезки; sensitivity — множители зон этого кадра (nil — зон нет).
+// Найденный разворот возвращается, чтобы перевести дефекты обратно
+// в координаты проверяемого снимка; nil — деталь не разворачивалась.
+func (d *GoCVDetector) diffAligned(
+	ctx context.Context,
+	clock *stageClock,
+	baseMat, baseMask, currentMat, currentMask gocv.Mat,
+	sensitivity []float32,
+) (*entity.InspectionResult, *orientationFit, error) {
+	width, height := baseMat.Cols(), baseMat.Rows()
+	if err := clock.enter(ctx, "registration"); err != nil {
+		return nil, nil, err
+	}
 	currentForDiff := currentMat
 	currentMaskForROI := currentMask
+	var alignment float64
+	var rotation *orientationFit
 	if d.EnableRegistration {
-		alignedCurrent, alignedMask, alignmentScore, err := d.alignCurrentToBase(baseMat, currentMat, baseMask, currentMask)
+		// Повёрнутую деталь сначала разворачиваем: совмещение по рамкам её бы растянуло.
+		if oriented, orientedMask, fit := d.normalizeOrientation(ctx, baseMask, currentMat, currentMask); fit != nil {
+			defer oriented.Close()
+			defer orientedMask.Close()
+			currentForDiff, currentMaskForROI, rotation = oriented, orientedMask, fit
+		}
+		alignedCurrent, alignedMask, alignmentScore, err := d.alignCurrentToBase(baseMat, currentForDiff, baseMask, currentMaskForROI)
 		if err == nil {
 			defer alignedCurrent.Close()
 			defer alignedMask.Close()
+			alignment = alignmentScore
 			if alignmentScore >= d.MinAlignmentScore {
 				currentForDiff = alignedCurrent
 				currentMaskForROI = alignedMask
@@ -261,6 +283,10 @@ func (d *GoCVDetector) InspectDiff(ctx context.Context, baseImage []byte, curren
 		}
 	}
 
+	if err := clock.enter(ctx, "diff"); err != nil {
+		return nil, nil, err
+	}
+
 	// Переводим в серый и считаем абсолютную разницу.
 	baseGray := gocv.NewMat()
 	defer baseGray.Close()
@@ -273,7 +299,12 @@ func (d *GoCVDetector) InspectDiff(ctx context.Context, baseImage []byte, curren
 	roiMask := gocv.NewMat()
 	defer roiMask.Close()
 	gocv.BitwiseAnd(baseMask, currentMaskForROI, &roiMask)
-	innerROIMask := d.buildInteriorMask(roiMask)
+	interiorMask := d.buildInteriorMask(roiMask)
+	defer interiorMask.Close()
+	innerROIMask, err := zoneROI(interiorMask, sensitivity)
+	if err != nil {
+		return nil, nil, err
+	}
 	defer innerROIMask.Close()
 
 	diff := gocv.NewMat()
@@ -292,12 +323,78 @@ func (d *GoCVDetector) InspectDiff(ctx context.Context, baseImage []byte, curren
 	if otsuThreshold < d.DiffMinThreshold {
 		gocv.Threshold(blur, &thresh, d.DiffMinThreshold, 255, gocv.ThresholdBinary)
 	}
+	if sensitivity != nil {
+		zoned, err := maskFromBytes(height, width, zoneThresholdMask(blur.ToBytes(), sensitivity, float64(max(otsuThreshold, d.DiffMinThreshold))))
+		if err != nil {
+			return nil, nil, fmt.Errorf("zone threshold: %w", err)
+		}
+		zoned.CopyTo(&thresh)
+		zoned.Close()
+	}
 
 	cleanedThresh := d.postProcessDiffMask(thresh)
 	defer cleanedThresh.Close()
+	d.dump(ctx, "diff_mask", cleanedThresh)
+
+	// Ржавчина и пятна бывают той же яркости, что и деталь: серая разница
+	// их не видит, поэтому цвет сравнивается отдельно.
+	var colorDefects []entity.DefectArea
+	if d.Color.Enabled {
+		if err := clock.enter(ctx, "color_diff"); err != nil {
+			return nil, nil, err
+		}
+		colorDefects, err = d.colorDiffDefects(ctx, baseMat, currentForDiff, innerROIMask, sensitivity)
+		if err != nil {
+			slog.WarnContext(ctx, "Detector color branch skipped", "err", err)
+		}
+	}
+
+	result, err := d.classifyDiff(ctx, clock, baseMask, currentMaskForROI, innerROIMask, cleanedThresh, colorDefects, alignment, "diff")
+	if err != nil {
+		return nil, nil, err
+	}
+	if rotation != nil {
+		result.Trace.Rotation, result.Trace.Flipped = rotation.Angle, rotation.Flipped
+	}
+	return result, rotation, nil
+}
+
+// zoneROI сужает внутреннюю маску детали по зонам эталона: игнорируемые
+// участки не проверяются. Возвращает новую Mat.
+func zoneROI(interiorMask gocv.Mat, sensitivity []float32) (gocv.Mat, error) {
+	if sensitivity == nil || interiorMask.Empty() {
+		return interiorMask.Clone(), nil
+	}
+	mask, err := maskFromBytes(interiorMask.Rows(), interiorMask.Cols(), restrictToZones(interiorMask.ToBytes(), sensitivity))
+	if err != nil {
+		return gocv.NewMat(), fmt.Errorf("zone mask: %w", err)
+	}
+	return mask, nil
+}
 
-	brokenMode, structuralMask := d.detectBrokenPartMask(baseMask, currentMaskForROI)
+// classifyDiff разбирает маску отличий детали от эталона: сначала ищет
+// отломанную часть и несовпадение формы по маскам детали, затем дефекты
+// внутри детали. Дефекты цветовой ветки colorDefects объединяются с найденными
+// по яркости. diffBranch — имя ветки для обычных отличий.
+func (d *GoCVDetector) classifyDiff(
+	ctx context.Context,
+	clock *stageClock,
+	baseMask, currentMask, innerROIMask, diffMask gocv.Mat,
+	colorDefects []entity.DefectArea,
+	alignment float64,
+	diffBranch string,
+) (*entity.InspectionResult, error) {
+	width, height := baseMask.Cols(), baseMask.Rows()
+
+	if err := clock.enter(ctx, "structural_check"); err != nil {
+		return nil, err
+	}
+
+	brokenMode, structuralMask := d.detectBrokenPartMask(baseMask, currentMask)
 	defer structuralMask.Close()
+	if brokenMode {
+		d.dump(ctx, "structural_mask", structuralMask)
+	}
 	structuralInput := structuralMask
 	maskedStructural := gocv.NewMat()
 	defer maskedStructural.Close()
@@ -305,9 +402,10 @@ func (d *GoCVDetector) InspectDiff(ctx context.Context, baseImage []byte, curren
 		gocv.BitwiseAnd(structuralMask, innerROIMask, &maskedStructural)
 		structuralInput = maskedStructural
 	}
-	geometryMode, geometryMask, geometryReason := d.detectGeometryMismatch(baseMask, currentMaskForROI)
+	geometryMode, geometryMask, geometryReason := d.detectGeometryMismatch(ctx, baseMask, currentMask)
 	defer geometryMask.Close()
 	if geometryMode && !brokenMode {
+		d.dump(ctx, "geometry_mask", geometryMask)
 		geometryInput := geometryMask
 		maskedGeometry := gocv.NewMat()
 		defer maskedGeometry.Close()
@@ -318,65 +416,74 @@ func (d *GoCVDetector) InspectDiff(ctx context.Context, baseImage []byte, curren
 			}
 		}
 
-		defects := d.buildGeometryMismatchDefects(geometryInput, targetW, targetH, geometryReason)
-		d.logDefects("inspect_diff_geometry", defects)
+		defects := d.buildGeometryMismatchDefects(geometryInput, width, height, geometryReason)
+		d.logDefects(ctx, "inspect_diff_geometry", defects)
 		return &entity.InspectionResult{
-			ImageWidth:  targetW,
-			ImageHeight: targetH,
+			ImageWidth:  width,
+			ImageHeight: height,
 			Defects:     defects,
 			HasDefects:  len(defects) > 0,
+			Trace:       entity.InspectionTrace{Branch: "geometry", AlignmentScore: alignment, Stages: clock.finish()},
 		}, nil
 	}
-	threshInput := cleanedThresh
+	threshInput := diffMask
 	maskedThresh := gocv.NewMat()
 	defer maskedThresh.Close()
 	if !innerROIMask.Empty() {
-		gocv.BitwiseAnd(cleanedThresh, innerROIMask, &maskedThresh)
+		gocv.BitwiseAnd(diffMask, innerROIMask, &maskedThresh)
 		threshInput = maskedThresh
 	}
 
+	if err := clock.enter(ctx, "diff_contour"); err != nil {
+		return nil, err
+	}
 	contours := gocv.FindContours(threshInput, gocv.RetrievalExternal, gocv.ChainApproxSimple)
 	defer contours.Close()
 
-	defects := d.extractDefectsFromContours(contours, targetW, targetH, "diff_contour")
-	log.Printf(
-		"detector.diff candidates stage=diff_contour count=%d broken_mode=%t",
-		len(defects),
-		brokenMode,
-	)
+	defects := d.extractDefectsFromContours(ctx, contours, width, height, "diff_contour")
+	slog.DebugContext(ctx, "Detector diff candidates", "stage", "diff_contour", "branch", diffBranch, "count", len(defects), "broken_mode", brokenMode)
+	branch := diffBranch
 	if brokenMode {
+		branch = "broken"
 		beforeOverlap := len(defects)
-		defects = d.filterDefectsByMaskOverlap(defects, structuralInput, d.BrokenMinOverlapRatio)
+		defects = d.filterDefectsByMaskOverlap(ctx, defects, structuralInput, d.BrokenMinOverlapRatio)
 		afterOverlap := len(defects)
 		defects = d.mergeNearbyDefects(defects, d.BrokenMergeDistance)
 		afterMerge := len(defects)
-		defects = d.keepDominantBrokenDefects(defects, d.BrokenDominantMinRatio)
+		defects = d.keepDominantBrokenDefects(ctx, defects, d.BrokenDominantMinRatio)
 		afterDominant := len(defects)
-		log.Printf(
-			"detector.diff broken_filter before_overlap=%d after_overlap=%d after_merge=%d after_dominant=%d",
-			beforeOverlap,
-			afterOverlap,
-			afterMerge,
-			afterDominant,
+		slog.DebugContext(ctx, "Detector broken filter",
+			"before_overlap", beforeOverlap,
+			"after_overlap", afterOverlap,
+			"after_merge", afterMerge,
+			"after_dominant", afterDominant,
 		)
 		if len(defects) == 0 {
-			defects = d.defectsFromMask(structuralInput, targetW, targetH, "broken_structural_mask")
+			branch = "broken_fallback"
+			defects = d.defectsFromMask(ctx, structuralInput, width, height, "broken_structural_mask")
 			defects = d.mergeNearbyDefects(defects, d.BrokenMergeDistance)
-			defects = d.keepDominantBrokenDefects(defects, d.BrokenDominantMinRatio)
-			log.Printf("detector.diff broken_fallback stage=broken_structural_mask count=%d", len(defects))
+			defects = d.keepDominantBrokenDefects(ctx, defects, d.BrokenDominantMinRatio)
+			slog.DebugContext(ctx, "Detector broken fallback", "stage", "broken_structural_mask", "count", len(defects))
 		}
+	} else if len(colorDefects) > 0 {
+		grayCount := len(defects)
+		defects = d.suppressDuplicateDefects(append(defects, colorDefects...))
+		slog.DebugContext(ctx, "Detector color fusion", "gray", grayCount, "color", len(colorDefects), "kept", len(defects))
 	}
-	d.logDefects("inspect_diff", defects)
+	d.logDefects(ctx, "inspect_diff", defects)
 
 	return &entity.InspectionResult{
-		ImageWidth:  targetW,
-		ImageHeight: targetH,
+		ImageWidth:  width,
+		ImageHeight: height,
 		Defects:     defects,
 		HasDefects:  len(defects) > 0,
+		Trace:       entity.InspectionTrace{Branch: branch, AlignmentScore: alignment, Stages: clock.finish()},
 	}, nil
 }
 
 // HighlightDefects рисует прямоугольники вокруг дефектов и возвращает новую картинку.
+// На снимке с несколькими деталями это обзор: каждая деталь обведена зелёным
+// или, с дефектами, красным и подписана номером, дефекты — жёлтые.
 func (d *GoCVDetector) HighlightDefects(imageData []byte, result *entity.InspectionResult) ([]byte, error) {
 	mat, err := decodeToMat(imageData)
 	if err != nil {
@@ -388,10 +495,37 @@ func (d *GoCVDetector) HighlightDefects(imageData []byte, result *entity.Inspect
 		return nil, errors.New("empty image")
 	}
 
+	// Координаты результата — в кадре сравнения, он бывает меньше снимка.
+	scale := func(x, y, w, h int) image.Rectangle {
+		if result.ImageWidth <= 0 || result.ImageHeight <= 0 {
+			return image.Rect(x, y, x+w, y+h)
+		}
+		sx := float64(mat.Cols()) / float64(result.ImageWidth)
+		sy := float64(mat.Rows()) / float64(result.ImageHeight)
+		return image.Rect(int(float64(x)*sx), int(float64(y)*sy), int(float64(x+w)*sx), int(float64(y+h)*sy))
+	}
+
 	green := color.RGBA{G: 255, A: 255}
+	defectColor := green
+	if len(result.Parts) > 0 {
+		defectColor = color.RGBA{R: 255, G: 255, A: 255}
+		red := color.RGBA{R: 255, A: 255}
+		fontScale := math.Max(0.6, float64(maxInt(mat.Cols(), mat.Rows()))/1200)
+		for _, part := range result.Parts {
+			rect := scale(part.X, part.Y, part.Width, part.Height)
+			partColor := green
+			if !part.Matched || len(result.PartDefects(part.Index)) > 0 {
+				partColor = red
+			}
+			gocv.Rectangle(&mat, rect, partColor, 3)
+			label := fmt.Sprintf("#%d", part.Index)
+			size := gocv.GetTextSize(label, gocv.FontHersheySimplex, fontScale, 2)
+			origin := image.Pt(rect.Min.X+4, maxInt(rect.Min.Y-6, size.Y+2))
+			gocv.PutText(&mat, label, origin, gocv.FontHersheySimplex, fontScale, partColor, 2)
+		}
+	}
 	for _, defect := range result.Defects {
-		rect := image.Rect(defect.X, defect.Y, defect.X+defect.Width, defect.Y+defect.Height)
-		gocv.Rectangle(&mat, rect, green, 2)
+		gocv.Rectangle(&mat, scale(defect.X, defect.Y, defect.Width, defect.Height), defectColor, 2)
 	}
 
 	img, err := mat.ToImage()
@@ -407,6 +541,57 @@ func (d *GoCVDetector) HighlightDefects(imageData []byte, result *entity.Inspect
 	return buf.Bytes(), nil
 }
 
+// checkCancelled прерывает конвейер между стадиями, если проверка отменена.
+func checkCancelled(ctx context.Context, stage string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("inspection cancelled before %s: %w", stage, err)
+	}
+	return nil
+}
+
+// dump сохраняет промежуточную маску для разбора проверки, если задан DebugDumpDir.
+// Файлы называются по ID проверки, чтобы их можно было сопоставить с логами.
+func (d *GoCVDetector) dump(ctx context.Context, stage string, mat gocv.Mat) {
+	if d.DebugDumpDir == "" || mat.Empty() {
+		return
+	}
+	id := logging.InspectionID(ctx)
+	if id == "" {
+		id = "unknown"
+	}
+	path := filepath.Join(d.DebugDumpDir, id+"-"+stage+".png")
+	if !gocv.IMWrite(path, mat) {
+		slog.WarnContext(ctx, "Debug dump failed", "path", path)
+	}
+}
+
+// stageClock замеряет стадии конвейера и прерывает его между ними,
+// если проверка отменена.
+type stageClock struct {
+	stages  []entity.StageTiming
+	current string
+	started time.Time
+}
+
+// enter завершает текущую стадию и начинает stage.
+func (c *stageClock) enter(ctx context.Context, stage string) error {
+	c.finish()
+	if err := checkCancelled(ctx, stage); err != nil {
+		return err
+	}
+	c.current, c.started = stage, time.Now()
+	return nil
+}
+
+// finish завершает текущую стадию и возвращает замеры.
+func (c *stageClock) finish() []entity.StageTiming {
+	if c.current != "" {
+		c.stages = append(c.stages, entity.StageTiming{Stage: c.current, Duration: time.Since(c.started)})
+		c.current = ""
+	}
+	return c.stages
+}
+
 // decodeToMat превращает байты изображения в gocv.Mat.
 func decodeToMat(imageData []byte) (gocv.Mat, error) {
 	mat, err := gocv.IMDecode(imageData, gocv.IMReadColor)
@@ -419,6 +604,39 @@ func decodeToMat(imageData []byte) (gocv.Mat, error) {
 	return gocv.NewMat(), errors.New("failed to decode image")
 }
 
+// decodeFrame декодирует снимок, проверяет его качество и приводит к кадру
+// width×height. Без заданного кадра (width = 0) большая сторона уменьшается
+// до MaxSide, чтобы пороги в пикселях вели себя одинаково.
+func (d *GoCVDetector) decodeFrame(imageData []byte, label string, glareLimit float64, width, height int) (gocv.Mat, error) {
+	mat, err := decodeToMat(imageData)
+	if err != nil {
+		return mat, err
+	}
+	if mat.Empty() {
+		return mat, errors.New("empty image")
+	}
+	if err := d.checkImageQuality(mat, label, glareLimit); err != nil {
+		mat.Close()
+		return gocv.NewMat(), err
+	}
+
+	if width == 0 || height == 0 {
+		width, height = mat.Cols(), mat.Rows()
+		if d.MaxSide > 0 && maxInt(width, height) > d.MaxSide {
+			scale := float64(d.MaxSide) / float64(maxInt(width, height))
+			width = maxInt(1, int(float64(width)*scale))
+			height = maxInt(1, int(float64(height)*scale))
+		}
+	}
+	if mat.Cols() == width && mat.Rows() == height {
+		return mat, nil
+	}
+	resized := gocv.NewMat()
+	gocv.Resize(mat, &resized, image.Pt(width, height), 0, 0, gocv.InterpolationArea)
+	mat.Close()
+	return resized, nil
+}
+
 func maxInt(a, b int) int {
 	if a > b {
 		return a
@@ -466,7 +684,7 @@ func maxFloat(values ...float64) float64 {
 	return maxValue
 }
 
-func (d *GoCVDetector) extractDefectsFromContours(contours gocv.PointsVector, imageWidth, imageHeight int, reasonTag string) []entity.DefectArea {
+func (d *GoCVDetector) extractDefectsFromContours(ctx context.Context, contours gocv.PointsVector, imageWidth, imageHeight int, reasonTag string) []entity.DefectArea {
 	if reasonTag == "" {
 		reasonTag = "contour"
 	}
@@ -530,16 +748,15 @@ func (d *GoCVDetector) extractDefectsFromContours(contours gocv.PointsVector, im
 	}
 
 	filtered := d.suppressDuplicateDefects(candidates)
-	log.Printf(
-		"detector.filter stage=%s contours=%d kept=%d dropped_rect=%d dropped_h=%d dropped_contour=%d dropped_aspect=%d dropped_fill=%d",
-		reasonTag,
-		contours.Size(),
-		len(filtered),
-		droppedByRectArea,
-		droppedByInvalidHeight,
-		droppedByContourArea,
-		droppedByAspect,
-		droppedByFill,
+	slog.DebugContext(ctx, "Detector contour filter",
+		"stage", reasonTag,
+		"contours", contours.Size(),
+		"kept", len(filtered),
+		"dropped_rect", droppedByRectArea,
+		"dropped_h", droppedByInvalidHeight,
+		"dropped_contour", droppedByContourArea,
+		"dropped_aspect", droppedByAspect,
+		"dropped_fill", droppedByFill,
 	)
 	return filtered
 }
@@ -556,11 +773,16 @@ func (d *GoCVDetector) suppressDuplicateDefects(defects []entity.DefectArea) []e
 	kept := make([]entity.DefectArea, 0, len(defects))
 	for _, candidate := range defects {
 		drop := false
-		for _, existing := range kept {
-			iou := boxIoU(candidate, existing)
-			containment := boxContainment(candidate, existing)
+		for i := range kept {
+			iou := boxIoU(candidate, kept[i])
+			containment := boxContainment(candidate, kept[i])
 			if iou >= d.NMSIoUThreshold || containment >= d.NMSContainmentRatio {
 				drop = true
+				// Рамка без типа поглотила цветового кандидата: тип и сдвиг цвета сохраняем.
+				if kept[i].Kind == "" && candidate.Kind != "" {
+					kept[i].Kind = candidate.Kind
+					kept[i].Color = candidate.Color
+				}
 				break
 			}
 		}
@@ -681,7 +903,7 @@ func (d *GoCVDetector) detectBrokenPartMask(baseMask, currentMask gocv.Mat) (boo
 	return true, focusedStructural
 }
 
-func (d *GoCVDetector) detectGeometryMismatch(baseMask, currentMask gocv.Mat) (bool, gocv.Mat, string) {
+func (d *GoCVDetector) detectGeometryMismatch(ctx context.Context, baseMask, currentMask gocv.Mat) (bool, gocv.Mat, string) {
 	if !d.EnableGeometryCheck {
 		return false, gocv.NewMat(), ""
 	}
@@ -743,19 +965,19 @@ func (d *GoCVDetector) detectGeometryMismatch(baseMask, currentMask gocv.Mat) (b
 
 	mismatch := familyMismatch || mismatchByToothed || mismatchByPolygon || mismatchByRound || mismatchByToothedShape
 	if !mismatch {
-		log.Printf(
-			"detector.geometry mismatch=false family_base=%s family_current=%s shape_score=%.4f concavity_base=%d concavity_current=%d vertices_base=%d vertices_current=%d circularity_base=%.4f circularity_current=%.4f extent_base=%.4f extent_current=%.4f",
-			baseShape.family,
-			currentShape.family,
-			shapeScore,
-			baseShape.concavity,
-			currentShape.concavity,
-			baseShape.vertices,
-			currentShape.vertices,
-			baseShape.circularity,
-			currentShape.circularity,
-			baseShape.extent,
-			currentShape.extent,
+		slog.DebugContext(ctx, "Detector geometry",
+			"mismatch", false,
+			"family_base", baseShape.family,
+			"family_current", currentShape.family,
+			"shape_score", shapeScore,
+			"concavity_base", baseShape.concavity,
+			"concavity_current", currentShape.concavity,
+			"vertices_base", baseShape.vertices,
+			"vertices_current", currentShape.vertices,
+			"circularity_base", baseShape.circularity,
+			"circularity_current", currentShape.circularity,
+			"extent_base", baseShape.extent,
+			"extent_current", currentShape.extent,
 		)
 		return false, gocv.NewMat(), ""
 	}
@@ -800,11 +1022,11 @@ func (d *GoCVDetector) detectGeometryMismatch(baseMask, currentMask gocv.Mat) (b
 	outerRingMask := d.buildOuterRingMask(baseMask, currentMask)
 	defer outerRingMask.Close()
 	if outerRingMask.Empty() || gocv.CountNonZero(outerRingMask) == 0 {
-		log.Printf(
-			"detector.geometry mismatch=true reason=%s family_base=%s family_current=%s",
-			reasonCode,
-			baseShape.family,
-			currentShape.family,
+		slog.DebugContext(ctx, "Detector geometry",
+			"mismatch", true,
+			"reason", reasonCode,
+			"family_base", baseShape.family,
+			"family_current", currentShape.family,
 		)
 		return true, cleanedShapeMask, reason
 	}
@@ -814,20 +1036,21 @@ func (d *GoCVDetector) detectGeometryMismatch(baseMask, currentMask gocv.Mat) (b
 	cleanedShapeMask.Close()
 	if focusedShapeMask.Empty() || gocv.CountNonZero(focusedShapeMask) == 0 {
 		focusedShapeMask.Close()
-		log.Printf(
-			"detector.geometry mismatch=true reason=%s family_base=%s family_current=%s fallback=empty_focus",
-			reasonCode,
-			baseShape.family,
-			currentShape.family,
+		slog.DebugContext(ctx, "Detector geometry",
+			"mismatch", true,
+			"reason", reasonCode,
+			"family_base", baseShape.family,
+			"family_current", currentShape.family,
+			"fallback", "empty_focus",
 		)
 		return true, gocv.NewMat(), reason
 	}
 
-	log.Printf(
-		"detector.geometry mismatch=true reason=%s family_base=%s family_current=%s",
-		reasonCode,
-		baseShape.family,
-		currentShape.family,
+	slog.DebugContext(ctx, "Detector geometry",
+		"mismatch", true,
+		"reason", reasonCode,
+		"family_base", baseShape.family,
+		"family_current", currentShape.family,
 	)
 	return true, focusedShapeMask, reason
 }
@@ -1060,7 +1283,7 @@ func expandRect(rect image.Rectangle, margin int, maxW int, maxH int) image.Rect
 	return expanded.Intersect(bounds)
 }
 
-func (d *GoCVDetector) filterDefectsByMaskOverlap(defects []entity.DefectArea, mask gocv.Mat, minOverlap float64) []entity.DefectArea {
+func (d *GoCVDetector) filterDefectsByMaskOverlap(ctx context.Context, defects []entity.DefectArea, mask gocv.Mat, minOverlap float64) []entity.DefectArea {
 	if len(defects) == 0 || mask.Empty() || gocv.CountNonZero(mask) == 0 {
 		return defects
 	}
@@ -1072,15 +1295,12 @@ func (d *GoCVDetector) filterDefectsByMaskOverlap(defects []entity.DefectArea, m
 			filtered = append(filtered, defect)
 			continue
 		}
-		log.Printf(
-			"detector.reject stage=broken_overlap idx=%d overlap=%.3f min=%.3f bbox=(x=%d y=%d w=%d h=%d)",
-			idx,
-			overlap,
-			minOverlap,
-			defect.X,
-			defect.Y,
-			defect.Width,
-			defect.Height,
+		slog.DebugContext(ctx, "Detector reject",
+			"stage", "broken_overlap",
+			"idx", idx,
+			"overlap", overlap,
+			"min", minOverlap,
+			"defect", defect,
 		)
 	}
 	return filtered
@@ -1135,7 +1355,7 @@ func (d *GoCVDetector) mergeNearbyDefects(defects []entity.DefectArea, distance
 	return d.mergeNearbyDefects(merged, distance)
 }
 
-func (d *GoCVDetector) keepDominantBrokenDefects(defects []entity.DefectArea, minRatio float64) []entity.DefectArea {
+func (d *GoCVDetector) keepDominantBrokenDefects(ctx context.Context, defects []entity.DefectArea, minRatio float64) []entity.DefectArea {
 	if len(defects) < 2 {
 		return defects
 	}
@@ -1164,16 +1384,12 @@ func (d *GoCVDetector) keepDominantBrokenDefects(defects []entity.DefectArea, mi
 			kept = append(kept, defect)
 			continue
 		}
-		log.Printf(
-			"detector.reject stage=broken_dominant idx=%d ratio=%.3f min=%.3f bbox=(x=%d y=%d w=%d h=%d area=%d)",
-			idx,
-			ratio,
-			minRatio,
-			defect.X,
-			defect.Y,
-			defect.Width,
-			defect.Height,
-			defect.Area,
+		slog.DebugContext(ctx, "Detector reject",
+			"stage", "broken_dominant",
+			"idx", idx,
+			"ratio", ratio,
+			"min", minRatio,
+			"defect", defect,
 		)
 	}
 
@@ -1212,13 +1428,13 @@ func unionDefectAreas(a, b entity.DefectArea) entity.DefectArea {
 	}
 }
 
-func (d *GoCVDetector) defectsFromMask(mask gocv.Mat, imageWidth int, imageHeight int, reasonTag string) []entity.DefectArea {
+func (d *GoCVDetector) defectsFromMask(ctx context.Context, mask gocv.Mat, imageWidth int, imageHeight int, reasonTag string) []entity.DefectArea {
 	if mask.Empty() || gocv.CountNonZero(mask) == 0 {
 		return nil
 	}
 	contours := gocv.FindContours(mask, gocv.RetrievalExternal, gocv.ChainApproxSimple)
 	defer contours.Close()
-	return d.extractDefectsFromContours(contours, imageWidth, imageHeight, reasonTag)
+	return d.extractDefectsFromContours(ctx, contours, imageWidth, imageHeight, reasonTag)
 }
 
 func (d *GoCVDetector) buildGeometryMismatchDefects(mask gocv.Mat, imageWidth int, imageHeight int, reason string) []entity.DefectArea {
@@ -1758,26 +1974,16 @@ func combineReasons(a, b string) string {
 	return a + " | " + b
 }
 
-func (d *GoCVDetector) logDefects(stage string, defects []entity.DefectArea) {
-	if len(defects) == 0 {
-		log.Printf("detector.defects stage=%s count=0", stage)
-		return
-	}
+// defectLogSampler прореживает построчный вывод дефектов: на сильно
+// повреждённой детали их бывают сотни.
+var defectLogSampler = logging.Sampler{First: 5, Thereafter: 10}
+
+func (d *GoCVDetector) logDefects(ctx context.Context, stage string, defects []entity.DefectArea) {
+	slog.DebugContext(ctx, "Detector defects", "stage", stage, "count", len(defects))
 	for i, defect := range defects {
-		reason := defect.Reason
-		if reason == "" {
-			reason = "not_set"
+		if !defectLogSampler.Keep(i) {
+			continue
 		}
-		log.Printf(
-			"detector.defect stage=%s idx=%d bbox=(x=%d y=%d w=%d h=%d area=%d) reason=%s",
-			stage,
-			i,
-			defect.X,
-			defect.Y,
-			defect.Width,
-			defect.Height,
-			defect.Area,
-			reason,
-		)
+		slog.DebugContext(ctx, "Detector defect", "stage", stage, "idx", i, "defect", defect)
 	}
 }
@@ -10,48 +10,73 @@ import (
 	"vision-bot/internal/domain/entity"
 )
 
+// Enabled сообщает, что детектор собран без OpenCV и проверки недоступны.
+const Enabled = false
+
 type GoCVDetector struct {
-	MinAreaRatio                   float64
-	MaxAspectRatio                 float64
-	MinAspectRatio                 float64
-	MaxSide                        int
-	MinImageSide                   int
-	MinSharpnessEdgeRatio          float64
-	MaxOverexposedRatio            float64
-	MaxUnderexposedRatio           float64
-	MaxGlareRatio                  float64
-	DiffMaxGlareRatio              float64
-	MinPartAreaRatio               float64
-	PartSecondaryAreaRatio         float64
-	PartSecondaryRelRatio          float64
-	ROIMarginKernel                int
-	EnableRegistration             bool
-	MinAlignmentScore              float64
-	DiffMinThreshold               float32
-	DiffOpenKernel                 int
-	DiffCloseKernel                int
-	MinContourAreaRatio            float64
-	MinFillRatio                   float64
-	NMSIoUThreshold                float64
-	NMSContainmentRatio            float64
-	BrokenMinComponentRatio        float64
-	BrokenAreaLossRatio            float64
-	BrokenFocusExpand              int
-	BrokenMinOverlapRatio          float64
-	BrokenMergeDistance            int
-	BrokenSplitKernel              int
-	BrokenSecondRelMin             float64
-	BrokenDominantMinRatio         float64
-	EnableGeometryCheck            bool
-	GeometryMatchMaxScore          float64
-	GeometryMinConcavity           int
-	GeometryMinConcavityGap        int
-	GeometryPolygonVertexGap       int
-	GeometryPolygonMinCircularity  float64
-	GeometryPolygonMinExtent       float64
-	GeometryRoundMinCircularity    float64
-	GeometryRoundMaxCircularityGap float64
-	GeometryRingKernel             int
+	MinAreaRatio                   float64 `yaml:"min_area_ratio"`
+	MaxAspectRatio                 float64 `yaml:"max_aspect_ratio"`
+	MinAspectRatio                 float64 `yaml:"min_aspect_ratio"`
+	MaxSide                        int     `yaml:"max_side"`
+	MinImageSide                   int     `yaml:"min_image_side"`
+	MinSharpnessEdgeRatio          float64 `yaml:"min_sharpness_edge_ratio"`
+	MaxOverexposedRatio            float64 `yaml:"max_overexposed_ratio"`
+	MaxUnderexposedRatio           float64 `yaml:"max_underexposed_ratio"`
+	MaxGlareRatio                  float64 `yaml:"max_glare_ratio"`
+	DiffMaxGlareRatio              float64 `yaml:"diff_max_glare_ratio"`
+	MinPartAreaRatio               float64 `yaml:"min_part_area_ratio"`
+	PartSecondaryAreaRatio         float64 `yaml:"part_secondary_area_ratio"`
+	PartSecondaryRelRatio          float64 `yaml:"part_secondary_rel_ratio"`
+	ROIMarginKernel                int     `yaml:"roi_margin_kernel"`
+	EnableRegistration             bool    `yaml:"enable_registration"`
+	MinAlignmentScore              float64 `yaml:"min_alignment_score"`
+	DiffMinThreshold               float32 `yaml:"diff_min_threshold"`
+	DiffOpenKernel                 int     `yaml:"diff_open_kernel"`
+	DiffCloseKernel                int     `yaml:"diff_close_kernel"`
+	MinContourAreaRatio            float64 `yaml:"min_contour_area_ratio"`
+	MinFillRatio                   float64 `yaml:"min_fill_ratio"`
+	NMSIoUThreshold                float64 `yaml:"nms_iou_threshold"`
+	NMSContainmentRatio            float64 `yaml:"nms_containment_ratio"`
+	BrokenMinComponentRatio        float64 `yaml:"broken_min_component_ratio"`
+	BrokenAreaLossRatio            float64 `yaml:"broken_area_loss_ratio"`
+	BrokenFocusExpand              int     `yaml:"broken_focus_expand"`
+	BrokenMinOverlapRatio          float64 `yaml:"broken_min_overlap_ratio"`
+	BrokenMergeDistance            int     `yaml:"broken_merge_distance"`
+	BrokenSplitKernel              int     `yaml:"broken_split_kernel"`
+	BrokenSecondRelMin             float64 `yaml:"broken_second_rel_min"`
+	BrokenDominantMinRatio         float64 `yaml:"broken_dominant_min_ratio"`
+	EnableGeometryCheck            bool    `yaml:"enable_geometry_check"`
+	GeometryMatchMaxScore          float64 `yaml:"geometry_match_max_score"`
+	GeometryMinConcavity           int     `yaml:"geometry_min_concavity"`
+	GeometryMinConcavityGap        int     `yaml:"geometry_min_concavity_gap"`
+	GeometryPolygonVertexGap       int     `yaml:"geometry_polygon_vertex_gap"`
+	GeometryPolygonMinCircularity  float64 `yaml:"geometry_polygon_min_circularity"`
+	GeometryPolygonMinExtent       float64 `yaml:"geometry_polygon_min_extent"`
+	GeometryRoundMinCircularity    float64 `yaml:"geometry_round_min_circularity"`
+	GeometryRoundMaxCircularityGap float64 `yaml:"geometry_round_max_circularity_gap"`
+	GeometryRingKernel             int     `yaml:"geometry_ring_kernel"`
+	// GoldenZThreshold — во сколько разбросов годных образцов яркость
+	// пикселя может отклониться от средней, прежде чем он станет дефектом.
+	GoldenZThreshold float64 `yaml:"golden_z_threshold"`
+	// GoldenMinSigma — нижняя граница разброса яркости (в уровнях 0–255).
+	GoldenMinSigma float64 `yaml:"golden_min_sigma"`
+	// CriticalZoneSensitivity — множитель чувствительности критичных зон
+	// эталона, если зона не задаёт свой: во сколько раз ниже в них порог отличий.
+	CriticalZoneSensitivity float64 `yaml:"critical_zone_sensitivity"`
+	// Quick — пороги проверки по одному снимку, без эталона.
+	Quick QuickProfile `yaml:"quick"`
+	// Color — пороги цветовой ветки сравнения с эталоном.
+	Color ColorProfile `yaml:"color"`
+	// Calibration — перевод пикселей в миллиметры и допуск в миллиметрах.
+	Calibration CalibrationProfile `yaml:"calibration"`
+	// Orientation — поиск поворота и отражения детали относительно эталона.
+	Orientation OrientationProfile `yaml:"orientation"`
+	// MultiPart — проверка снимка с несколькими деталями.
+	MultiPart MultiPartProfile `yaml:"multi_part"`
+
+	// DebugDumpDir — каталог для промежуточных масок каждой проверки
+	// (пусто — не сохранять). Задаётся конфигурацией, а не профилем.
+	DebugDumpDir string `yaml:"-"`
 }
 
 // NewGoCVDetector создаёт детектор-заглушку (без OpenCV).
@@ -98,6 +123,14 @@ func NewGoCVDetector(minArea int) *GoCVDetector {
 		GeometryRoundMinCircularity:    0.82,
 		GeometryRoundMaxCircularityGap: 0.10,
 		GeometryRingKernel:             41,
+		GoldenZThreshold:               4,
+		GoldenMinSigma:                 6,
+		CriticalZoneSensitivity:        2,
+		Quick:                          defaultQuickProfile(),
+		Color:                          defaultColorProfile(),
+		Calibration:                    defaultCalibrationProfile(),
+		Orientation:                    defaultOrientationProfile(),
+		MultiPart:                      defaultMultiPartProfile(),
 	}
 }
 
@@ -109,7 +142,7 @@ func (d *GoCVDetector) Inspect(ctx context.Context, imageData []byte) (*entity.I
 }
 
 // InspectDiff возвращает ошибку, если сборка без тега gocv.
-func (d *GoCVDetector) InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte) (*entity.InspectionResult, error) {
+func (d *GoCVDetector) InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
 	_ = ctx
 	_ = baseImage
 	_ = currentImage
@@ -122,3 +155,28 @@ func (d *GoCVDetector) HighlightDefects(imageData []byte, result *entity.Inspect
 	_ = result
 	return nil, errors.New("gocv build tag is not enabled")
 }
+
+// BuildGoldenModel возвращает ошибку, если сборка без тега gocv.
+func (d *GoCVDetector) BuildGoldenModel(ctx context.Context, samples [][]byte) (*entity.GoldenModel, error) {
+	return nil, errors.New("gocv build tag is not enabled")
+}
+
+// ExtendGoldenModel возвращает ошибку, если сборка без тега gocv.
+func (d *GoCVDetector) ExtendGoldenModel(ctx context.Context, model *entity.GoldenModel, sample []byte) (*entity.GoldenModel, error) {
+	return nil, errors.New("gocv build tag is not enabled")
+}
+
+// InspectGolden возвращает ошибку, если сборка без тега gocv.
+func (d *GoCVDetector) InspectGolden(ctx context.Context, model *entity.GoldenModel, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	return nil, errors.New("gocv build tag is not enabled")
+}
+
+// CalibrateByPart возвращает ошибку, если сборка без тега gocv.
+func (d *GoCVDetector) CalibrateByPart(ctx context.Context, imageData []byte, lengthMM float64) (*entity.Calibration, error) {
+	return nil, errors.New("gocv build tag is not enabled")
+}
+
+// CalibrateByMarker возвращает ошибку, если сборка без тега gocv.
+func (d *GoCVDetector) CalibrateByMarker(ctx context.Context, imageData []byte) (*entity.Calibration, error) {
+	return nil, errors.New("gocv build tag is not enabled")
+}
@@ -0,0 +1,299 @@
+//go:build gocv
+// +build gocv
+
+package vision
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"log/slog"
+	"math"
+
+	"gocv.io/x/gocv"
+
+	"vision-bot/internal/domain/entity"
+)
+
+// minGoldenSamples — сколько годных деталей нужно, чтобы оценить разброс.
+const minGoldenSamples = 2
+
+// BuildGoldenModel совмещает годные образцы с первым и собирает модель.
+func (d *GoCVDetector) BuildGoldenModel(ctx context.Context, samples [][]byte) (*entity.GoldenModel, error) {
+	if len(samples) < minGoldenSamples {
+		return nil, fmt.Errorf("golden model needs at least %d samples, got %d", minGoldenSamples, len(samples))
+	}
+
+	model, err := d.newGoldenModel(samples[0])
+	if err != nil {
+		return nil, fmt.Errorf("golden sample 1: %w", err)
+	}
+	for i, sample := range samples[1:] {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if model, err = d.ExtendGoldenModel(ctx, model, sample); err != nil {
+			return nil, fmt.Errorf("golden sample %d: %w", i+2, err)
+		}
+	}
+	return model, nil
+}
+
+// newGoldenModel начинает модель с одного образца: он задаёт кадр и систему координат.
+func (d *GoCVDetector) newGoldenModel(anchor []byte) (*entity.GoldenModel, error) {
+	mat, err := d.decodeFrame(anchor, "golden sample", d.DiffMaxGlareRatio, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer mat.Close()
+
+	mask := d.buildPartMask(mat)
+	defer mask.Close()
+	if gocv.CountNonZero(mask) == 0 {
+		return nil, errors.New("part is not detected")
+	}
+	gray := goldenGray(mat)
+	defer gray.Close()
+
+	pixels := gray.ToBytes()
+	model := &entity.GoldenModel{
+		Samples:      1,
+		Width:        mat.Cols(),
+		Height:       mat.Rows(),
+		Anchor:       anchor,
+		Mean:         make([]float32, len(pixels)),
+		M2:           make([]float32, len(pixels)),
+		Union:        mask.ToBytes(),
+		Intersection: mask.ToBytes(),
+	}
+	for i, v := range pixels {
+		model.Mean[i] = float32(v)
+	}
+	return model, nil
+}
+
+// ExtendGoldenModel совмещает образец с первым образцом модели и возвращает
+// новую модель со средним и разбросом, дополненными по Уэлфорду. Образец,
+// который не удалось уверенно совместить, отклоняется: он испортил бы разброс.
+func (d *GoCVDetector) ExtendGoldenModel(ctx context.Context, model *entity.GoldenModel, sample []byte) (*entity.GoldenModel, error) {
+	if model == nil {
+		return d.newGoldenModel(sample)
+	}
+
+	anchor, anchorMask, err := d.goldenAnchor(model)
+	if err != nil {
+		return nil, err
+	}
+	defer anchor.Close()
+	defer anchorMask.Close()
+
+	current, err := d.decodeFrame(sample, "golden sample", d.DiffMaxGlareRatio, model.Width, model.Height)
+	if err != nil {
+		return nil, err
+	}
+	defer current.Close()
+	currentMask := d.buildPartMask(current)
+	defer currentMask.Close()
+
+	sampleMat, sampleMask := current, currentMask
+	if oriented, orientedMask, fit := d.normalizeOrientation(ctx, anchorMask, current, currentMask); fit != nil {
+		defer oriented.Close()
+		defer orientedMask.Close()
+		sampleMat, sampleMask = oriented, orientedMask
+	}
+	aligned, alignedMask, score, err := d.alignCurrentToBase(anchor, sampleMat, anchorMask, sampleMask)
+	if err != nil {
+		return nil, err
+	}
+	defer aligned.Close()
+	defer alignedMask.Close()
+	if score < d.MinAlignmentScore {
+		return nil, fmt.Errorf("alignment failed: score %.2f is below %.2f", score, d.MinAlignmentScore)
+	}
+
+	gray := goldenGray(aligned)
+	defer gray.Close()
+	pixels := gray.ToBytes()
+	mask := alignedMask.ToBytes()
+	if len(pixels) != len(model.Mean) || len(mask) != len(model.Union) {
+		return nil, errors.New("golden sample does not match the model frame")
+	}
+
+	next := &entity.GoldenModel{
+		Samples:      model.Samples + 1,
+		Width:        model.Width,
+		Height:       model.Height,
+		Anchor:       model.Anchor,
+		Mean:         make([]float32, len(model.Mean)),
+		M2:           make([]float32, len(model.M2)),
+		Union:        make([]byte, len(model.Union)),
+		Intersection: make([]byte, len(model.Intersection)),
+	}
+	n := float32(next.Samples)
+	for i, v := range pixels {
+		x := float32(v)
+		delta := x - model.Mean[i]
+		next.Mean[i] = model.Mean[i] + delta/n
+		next.M2[i] = model.M2[i] + delta*(x-next.Mean[i])
+		next.Union[i] = model.Union[i] | mask[i]
+		next.Intersection[i] = model.Intersection[i] & mask[i]
+	}
+	slog.DebugContext(ctx, "Golden model extended", "samples", next.Samples, "alignment_score", score)
+	return next, nil
+}
+
+// InspectGolden ищет на детали пиксели, яркость которых выходит за разброс
+// годных образцов: |x − mean| / σ > GoldenZThreshold. Дальше маска отличий
+// разбирается так же, как в InspectDiff, а части детали сравниваются
+// с пересечением масок образцов.
+func (d *GoCVDetector) InspectGolden(ctx context.Context, model *entity.GoldenModel, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	if model == nil || model.Samples < minGoldenSamples {
+		return nil, errors.New("golden model is not built")
+	}
+
+	var clock stageClock
+	if err := clock.enter(ctx, "decode"); err != nil {
+		return nil, err
+	}
+	anchor, anchorMask, err := d.goldenAnchor(model)
+	if err != nil {
+		return nil, err
+	}
+	defer anchor.Close()
+	defer anchorMask.Close()
+
+	current, err := d.decodeFrame(currentImage, "current image", d.DiffMaxGlareRatio, model.Width, model.Height)
+	if err != nil {
+		return nil, err
+	}
+	defer current.Close()
+
+	if err := clock.enter(ctx, "part_mask"); err != nil {
+		return nil, err
+	}
+	currentMask := d.buildPartMask(current)
+	defer currentMask.Close()
+	d.dump(ctx, "current_mask", currentMask)
+
+	if err := clock.enter(ctx, "registration"); err != nil {
+		return nil, err
+	}
+	currentForDiff := current
+	currentMaskForROI := currentMask
+	var alignment float64
+	var rotation orientation
+	if d.EnableRegistration {
+		if oriented, orientedMask, fit := d.normalizeOrientation(ctx, anchorMask, current, currentMask); fit != nil {
+			defer oriented.Close()
+			defer orientedMask.Close()
+			currentForDiff, currentMaskForROI, rotation = oriented, orientedMask, fit.orientation
+		}
+		aligned, alignedMask, score, err := d.alignCurrentToBase(anchor, currentForDiff, anchorMask, currentMaskForROI)
+		if err == nil {
+			defer aligned.Close()
+			defer alignedMask.Close()
+			alignment = score
+			if score >= d.MinAlignmentScore {
+				currentForDiff = aligned
+				currentMaskForROI = alignedMask
+			}
+		}
+	}
+
+	if err := clock.enter(ctx, "golden_score"); err != nil {
+		return nil, err
+	}
+	gray := goldenGray(currentForDiff)
+	defer gray.Close()
+	sensitivity := zoneSensitivity(zones, model.Width, model.Height, d.CriticalZoneSensitivity)
+	zMask, err := d.goldenZMask(model, gray.ToBytes(), sensitivity)
+	if err != nil {
+		return nil, err
+	}
+	defer zMask.Close()
+	cleaned := d.postProcessDiffMask(zMask)
+	defer cleaned.Close()
+	d.dump(ctx, "golden_z_mask", cleaned)
+
+	modelMask, err := maskFromBytes(model.Height, model.Width, model.Intersection)
+	if err != nil {
+		return nil, fmt.Errorf("golden model mask: %w", err)
+	}
+	defer modelMask.Close()
+	roiMask := gocv.NewMat()
+	defer roiMask.Close()
+	gocv.BitwiseAnd(modelMask, currentMaskForROI, &roiMask)
+	interiorMask := d.buildInteriorMask(roiMask)
+	defer interiorMask.Close()
+	innerROIMask, err := zoneROI(interiorMask, sensitivity)
+	if err != nil {
+		return nil, err
+	}
+	defer innerROIMask.Close()
+
+	result, err := d.classifyDiff(ctx, &clock, modelMask, currentMaskForROI, innerROIMask, cleaned, nil, alignment, "golden")
+	if err != nil {
+		return nil, err
+	}
+	annotateZones(result.Defects, zones, model.Width, model.Height)
+	result.Trace.Rotation, result.Trace.Flipped = rotation.Angle, rotation.Flipped
+	return result, nil
+}
+
+// goldenZMask отмечает пиксели, выходящие за разброс годных образцов.
+// Разброс снизу ограничен GoldenMinSigma: там, где образцы совпали почти
+// точно, иначе дефектом стал бы любой шум камеры. Порог делится на
+// множитель зоны из sensitivity (nil — множитель везде 1).
+func (d *GoCVDetector) goldenZMask(model *entity.GoldenModel, pixels []byte, sensitivity []float32) (gocv.Mat, error) {
+	if len(pixels) != len(model.Mean) {
+		return gocv.NewMat(), errors.New("current image does not match the golden model frame")
+	}
+
+	mask := make([]byte, len(pixels))
+	for i, v := range pixels {
+		threshold := d.GoldenZThreshold
+		if sensitivity != nil {
+			if sensitivity[i] == 0 {
+				continue
+			}
+			threshold /= float64(sensitivity[i])
+		}
+		sigma := math.Max(model.StdDev(i), d.GoldenMinSigma)
+		if math.Abs(float64(v)-float64(model.Mean[i]))/sigma > threshold {
+			mask[i] = 255
+		}
+	}
+	return maskFromBytes(model.Height, model.Width, mask)
+}
+
+// maskFromBytes копирует маску в новую Mat: срез Go не должен жить
+// дольше неё внутри OpenCV.
+func maskFromBytes(rows, cols int, data []byte) (gocv.Mat, error) {
+	view, err := gocv.NewMatFromBytes(rows, cols, gocv.MatTypeCV8U, data)
+	if err != nil {
+		return gocv.NewMat(), err
+	}
+	defer view.Close()
+	return view.Clone(), nil
+}
+
+// goldenAnchor декодирует первый образец модели и строит его маску детали.
+func (d *GoCVDetector) goldenAnchor(model *entity.GoldenModel) (gocv.Mat, gocv.Mat, error) {
+	anchor, err := d.decodeFrame(model.Anchor, "golden anchor", d.DiffMaxGlareRatio, model.Width, model.Height)
+	if err != nil {
+		return gocv.NewMat(), gocv.NewMat(), fmt.Errorf("golden model anchor: %w", err)
+	}
+	return anchor, d.buildPartMask(anchor), nil
+}
+
+// goldenGray переводит снимок в оттенки серого и сглаживает шум так же,
+// как перед порогом в InspectDiff.
+func goldenGray(mat gocv.Mat) gocv.Mat {
+	gray := gocv.NewMat()
+	gocv.CvtColor(mat, &gray, gocv.ColorBGRToGray)
+	blurred := gocv.NewMat()
+	gocv.GaussianBlur(gray, &blurred, image.Pt(5, 5), 0, 0, gocv.BorderDefault)
+	gray.Close()
+	return blurred
+}
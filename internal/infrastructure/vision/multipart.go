@@ -0,0 +1,125 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"vision-bot/internal/domain/entity"
+)
+
+// partOrder возвращает порядок рамок деталей для нумерации: по строкам сверху
+// вниз, в строке слева направо. Детали стоят в одной строке, если их центры
+// по вертикали ближе половины высоты меньшей из них.
+func partOrder(rects []image.Rectangle) []int {
+	centerY := func(i int) int { return (rects[i].Min.Y + rects[i].Max.Y) / 2 }
+	centerX := func(i int) int { return (rects[i].Min.X + rects[i].Max.X) / 2 }
+
+	byY := make([]int, len(rects))
+	for i := range byY {
+		byY[i] = i
+	}
+	sort.SliceStable(byY, func(a, b int) bool { return centerY(byY[a]) < centerY(byY[b]) })
+
+	order := make([]int, 0, len(rects))
+	for start := 0; start < len(byY); {
+		first := byY[start]
+		end := start + 1
+		for end < len(byY) {
+			next := byY[end]
+			if gap := centerY(next) - centerY(first); 2*max(gap, -gap) >= min(rects[first].Dy(), rects[next].Dy()) {
+				break
+			}
+			end++
+		}
+		row := byY[start:end]
+		sort.SliceStable(row, func(a, b int) bool { return centerX(row[a]) < centerX(row[b]) })
+		order = append(order, row...)
+		start = end
+	}
+	return order
+}
+
+// partMargin — поле вокруг детали при вырезании: доля ratio большей стороны рамки.
+func partMargin(rect image.Rectangle, ratio float64) int {
+	return int(math.Ceil(float64(max(rect.Dx(), rect.Dy())) * ratio))
+}
+
+// cropSensitivity вырезает из множителей зон кадра шириной width участок rect.
+func cropSensitivity(sensitivity []float32, width int, rect image.Rectangle) []float32 {
+	if sensitivity == nil {
+		return nil
+	}
+	cropped := make([]float32, 0, rect.Dx()*rect.Dy())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		cropped = append(cropped, sensitivity[y*width+rect.Min.X:y*width+rect.Max.X]...)
+	}
+	return cropped
+}
+
+// rectMapping — аффинная матрица, которая растягивает рамку from в рамку to.
+func rectMapping(from, to image.Rectangle) [6]float64 {
+	sx := float64(to.Dx()) / float64(max(from.Dx(), 1))
+	sy := float64(to.Dy()) / float64(max(from.Dy(), 1))
+	return [6]float64{
+		sx, 0, float64(to.Min.X) - sx*float64(from.Min.X),
+		0, sy, float64(to.Min.Y) - sy*float64(from.Min.Y),
+	}
+}
+
+// invertAffine обращает аффинную матрицу; false — матрица вырождена.
+func invertAffine(m [6]float64) ([6]float64, bool) {
+	det := m[0]*m[4] - m[1]*m[3]
+	if math.Abs(det) < 1e-12 {
+		return [6]float64{}, false
+	}
+	a, b, c, d := m[4]/det, -m[1]/det, -m[3]/det, m[0]/det
+	return [6]float64{
+		a, b, -a*m[2] - b*m[5],
+		c, d, -c*m[2] - d*m[5],
+	}, true
+}
+
+// shiftAffine дополняет матрицу переносами: сначала точка сдвигается на before,
+// после преобразования — на after.
+func shiftAffine(m [6]float64, before, after image.Point) [6]float64 {
+	bx, by := float64(before.X), float64(before.Y)
+	return [6]float64{
+		m[0], m[1], m[0]*bx + m[1]*by + m[2] + float64(after.X),
+		m[3], m[4], m[3]*bx + m[4]*by + m[5] + float64(after.Y),
+	}
+}
+
+// scaleAffine дополняет матрицу растяжением результата: по x в sx раз, по y в sy раз.
+func scaleAffine(m [6]float64, sx, sy float64) [6]float64 {
+	return [6]float64{
+		m[0] * sx, m[1] * sx, m[2] * sx,
+		m[3] * sy, m[4] * sy, m[5] * sy,
+	}
+}
+
+// mapDefect переносит дефект матрицей m в кадр width×height: рамка — описанная
+// вокруг перенесённых углов, площадь меняется вместе с масштабом.
+func mapDefect(m [6]float64, defect entity.DefectArea, width, height int) entity.DefectArea {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, corner := range [4][2]float64{
+		{float64(defect.X), float64(defect.Y)},
+		{float64(defect.X + defect.Width), float64(defect.Y)},
+		{float64(defect.X), float64(defect.Y + defect.Height)},
+		{float64(defect.X + defect.Width), float64(defect.Y + defect.Height)},
+	} {
+		// Округление гасит погрешность синуса и косинуса прямых углов.
+		x := math.Round((m[0]*corner[0]+m[1]*corner[1]+m[2])*1e6) / 1e6
+		y := math.Round((m[3]*corner[0]+m[4]*corner[1]+m[5])*1e6) / 1e6
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	rect := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))).
+		Intersect(image.Rect(0, 0, width, height))
+
+	mapped := defect
+	mapped.X, mapped.Y, mapped.Width, mapped.Height = rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy()
+	mapped.Area = int(math.Round(float64(defect.Area) * math.Abs(m[0]*m[4]-m[1]*m[3])))
+	return mapped
+}
@@ -0,0 +1,264 @@
+//go:build gocv
+// +build gocv
+
+package vision
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"log/slog"
+	"sort"
+
+	"gocv.io/x/gocv"
+
+	"vision-bot/internal/domain/entity"
+)
+
+// partInstance — одна деталь на снимке: внешний контур маски, рамка и площадь.
+type partInstance struct {
+	contour []image.Point
+	rect    image.Rectangle
+	area    float64
+}
+
+// segmentParts делит маску на отдельные детали. Компоненты меньше
+// MinRelativeArea от крупнейшей считаются обрезками маски. Детали
+// возвращаются в порядке нумерации, не больше MaxParts: лишние — самые мелкие.
+func (d *GoCVDetector) segmentParts(mask gocv.Mat) []partInstance {
+	if mask.Empty() {
+		return nil
+	}
+	contours := gocv.FindContours(mask, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+
+	parts := make([]partInstance, 0, contours.Size())
+	for i := 0; i < contours.Size(); i++ {
+		c := contours.At(i)
+		parts = append(parts, partInstance{contour: c.ToPoints(), rect: gocv.BoundingRect(c), area: gocv.ContourArea(c)})
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	sort.SliceStable(parts, func(i, j int) bool { return parts[i].area > parts[j].area })
+	minArea := parts[0].area * d.MultiPart.MinRelativeArea
+	kept := parts[:0]
+	for _, part := range parts {
+		if part.area >= minArea && len(kept) < d.MultiPart.MaxParts {
+			kept = append(kept, part)
+		}
+	}
+
+	rects := make([]image.Rectangle, len(kept))
+	for i, part := range kept {
+		rects[i] = part.rect
+	}
+	ordered := make([]partInstance, 0, len(kept))
+	for _, i := range partOrder(rects) {
+		ordered = append(ordered, kept[i])
+	}
+	return ordered
+}
+
+// inspectParts проверяет каждую деталь снимка отдельно: деталь сопоставляется
+// по форме с деталью эталона, обе вырезаются и сравниваются так же, как
+// одиночная деталь. Дефекты переводятся в координаты проверяемого снимка
+// и получают номер детали; деталь, не похожая на эталон, отмечается целиком.
+func (d *GoCVDetector) inspectParts(
+	ctx context.Context,
+	clock *stageClock,
+	baseMat, baseMask, currentMat gocv.Mat,
+	parts []partInstance,
+	zones []entity.Zone,
+) (*entity.InspectionResult, error) {
+	references := d.segmentParts(baseMask)
+	if len(references) == 0 {
+		return nil, errors.New("reference part is not detected")
+	}
+	width, height := currentMat.Cols(), currentMat.Rows()
+	sensitivity := zoneSensitivity(zones, baseMat.Cols(), baseMat.Rows(), d.CriticalZoneSensitivity)
+	slog.DebugContext(ctx, "Detector multi-part frame", "parts", len(parts), "reference_parts", len(references))
+
+	result := &entity.InspectionResult{
+		ImageWidth:  width,
+		ImageHeight: height,
+		Parts:       make([]entity.PartInspection, 0, len(parts)),
+	}
+	var alignment float64
+	for i, part := range parts {
+		index := i + 1
+		if err := clock.enter(ctx, "part_match"); err != nil {
+			return nil, err
+		}
+		reference, score, matched := d.matchPart(part, references)
+		info := entity.PartInspection{
+			Index:      index,
+			X:          part.rect.Min.X,
+			Y:          part.rect.Min.Y,
+			Width:      part.rect.Dx(),
+			Height:     part.rect.Dy(),
+			Matched:    matched,
+			ShapeScore: score,
+		}
+		if !matched {
+			info.Trace.Branch = "part_mismatch"
+			result.Defects = append(result.Defects, entity.DefectArea{
+				X:      part.rect.Min.X,
+				Y:      part.rect.Min.Y,
+				Width:  part.rect.Dx(),
+				Height: part.rect.Dy(),
+				Area:   int(part.area),
+				Reason: fmt.Sprintf("part_mismatch shape_score=%.4f", score),
+				Part:   index,
+			})
+			result.Parts = append(result.Parts, info)
+			continue
+		}
+
+		partResult, err := d.inspectPart(ctx, clock, baseMat, references[reference], currentMat, part, zones, sensitivity)
+		if err != nil {
+			return nil, fmt.Errorf("part %d: %w", index, err)
+		}
+		for _, defect := range partResult.Defects {
+			defect.Part = index
+			result.Defects = append(result.Defects, defect)
+		}
+		info.Trace = partResult.Trace
+		info.Trace.Stages = nil
+		// Общее качество совмещения — по хуже всего совмещённой детали.
+		if score := partResult.Trace.AlignmentScore; score > 0 && (alignment == 0 || score < alignment) {
+			alignment = score
+		}
+		result.Parts = append(result.Parts, info)
+	}
+
+	result.HasDefects = len(result.Defects) > 0
+	result.Trace = entity.InspectionTrace{Branch: "multi_part", AlignmentScore: alignment, Stages: clock.finish()}
+	d.logDefects(ctx, "inspect_parts", result.Defects)
+	return result, nil
+}
+
+// matchPart находит деталь эталона, ближайшую по форме: того же семейства
+// (describeContourShape) и с наименьшим расстоянием cv::matchShapes.
+// false — ни одна деталь эталона не ближе MaxShapeScore.
+func (d *GoCVDetector) matchPart(part partInstance, references []partInstance) (int, float64, bool) {
+	contour := gocv.NewPointVectorFromPoints(part.contour)
+	defer contour.Close()
+	shape := d.partShape(contour)
+
+	best, bestScore := -1, 0.0
+	for i, reference := range references {
+		referenceContour := gocv.NewPointVectorFromPoints(reference.contour)
+		referenceShape := d.partShape(referenceContour)
+		score := gocv.MatchShapes(referenceContour, contour, gocv.ContoursMatchI2, 0)
+		referenceContour.Close()
+
+		if shape.family != shapeFamilyUnknown && referenceShape.family != shapeFamilyUnknown && shape.family != referenceShape.family {
+			continue
+		}
+		if best < 0 || score < bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best, bestScore, best >= 0 && bestScore <= d.MultiPart.MaxShapeScore
+}
+
+// partShape описывает форму контура детали порогами проверки геометрии.
+func (d *GoCVDetector) partShape(contour gocv.PointVector) shapeDescriptor {
+	return describeContourShape(
+		contour,
+		d.GeometryMinConcavity,
+		d.GeometryRoundMinCircularity,
+		d.GeometryPolygonMinCircularity,
+		d.GeometryPolygonMinExtent,
+	)
+}
+
+// inspectPart вырезает деталь и деталь эталона с полем вокруг, сравнивает
+// вырезки через diffAligned и возвращает дефекты в координатах проверяемого снимка.
+func (d *GoCVDetector) inspectPart(
+	ctx context.Context,
+	clock *stageClock,
+	baseMat gocv.Mat,
+	reference partInstance,
+	currentMat gocv.Mat,
+	part partInstance,
+	zones []entity.Zone,
+	sensitivity []float32,
+) (*entity.InspectionResult, error) {
+	baseRect := expandRect(reference.rect, partMargin(reference.rect, d.MultiPart.CropMargin), baseMat.Cols(), baseMat.Rows())
+	currentRect := expandRect(part.rect, partMargin(part.rect, d.MultiPart.CropMargin), currentMat.Cols(), currentMat.Rows())
+
+	baseCrop := cropMat(baseMat, baseRect)
+	defer baseCrop.Close()
+	baseCropMask := partMask(reference, baseRect)
+	defer baseCropMask.Close()
+	// Без совмещения diffAligned сравнивает вырезки попиксельно, поэтому
+	// вырезка детали приводится к размеру вырезки эталона.
+	currentCrop := resizeMat(cropMat(currentMat, currentRect), baseRect, gocv.InterpolationArea)
+	defer currentCrop.Close()
+	currentCropMask := resizeMat(partMask(part, currentRect), baseRect, gocv.InterpolationNearestNeighbor)
+	defer currentCropMask.Close()
+	scaleX := float64(currentRect.Dx()) / float64(baseRect.Dx())
+	scaleY := float64(currentRect.Dy()) / float64(baseRect.Dy())
+
+	result, fit, err := d.diffAligned(ctx, clock, baseCrop, baseCropMask, currentCrop, currentCropMask, cropSensitivity(sensitivity, baseMat.Cols(), baseRect))
+	if err != nil {
+		return nil, err
+	}
+
+	// Зоны заданы в кадре эталона: имя зоны дефект получает до переноса.
+	for i := range result.Defects {
+		result.Defects[i].X += baseRect.Min.X
+		result.Defects[i].Y += baseRect.Min.Y
+	}
+	annotateZones(result.Defects, zones, baseMat.Cols(), baseMat.Rows())
+
+	// Обратно на снимок: через найденный разворот или, без него, по рамкам деталей.
+	toCurrent := rectMapping(reference.rect, part.rect)
+	if fit != nil {
+		if inverse, ok := invertAffine(fit.warp()); ok {
+			toCurrent = shiftAffine(scaleAffine(inverse, scaleX, scaleY), baseRect.Min.Mul(-1), currentRect.Min)
+		}
+	}
+	for i := range result.Defects {
+		result.Defects[i] = mapDefect(toCurrent, result.Defects[i], currentMat.Cols(), currentMat.Rows())
+	}
+	return result, nil
+}
+
+// cropMat копирует участок rect в новую Mat.
+func cropMat(mat gocv.Mat, rect image.Rectangle) gocv.Mat {
+	region := mat.Region(rect)
+	defer region.Close()
+	return region.Clone()
+}
+
+// resizeMat приводит mat к размеру rect; исходная Mat закрывается, если пришлось
+// её пересоздать.
+func resizeMat(mat gocv.Mat, rect image.Rectangle, interpolation gocv.InterpolationFlags) gocv.Mat {
+	if mat.Cols() == rect.Dx() && mat.Rows() == rect.Dy() {
+		return mat
+	}
+	defer mat.Close()
+	resized := gocv.NewMat()
+	gocv.Resize(mat, &resized, image.Pt(rect.Dx(), rect.Dy()), 0, 0, interpolation)
+	return resized
+}
+
+// partMask рисует маску одной детали в вырезке rect: соседние детали в неё не попадают.
+func partMask(part partInstance, rect image.Rectangle) gocv.Mat {
+	shifted := make([]image.Point, len(part.contour))
+	for i, p := range part.contour {
+		shifted[i] = p.Sub(rect.Min)
+	}
+	polygon := gocv.NewPointsVectorFromPoints([][]image.Point{shifted})
+	defer polygon.Close()
+
+	mask := gocv.NewMatWithSize(rect.Dy(), rect.Dx(), gocv.MatTypeCV8U)
+	mask.SetTo(gocv.NewScalar(0, 0, 0, 0))
+	gocv.FillPoly(&mask, polygon, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	return mask
+}
@@ -0,0 +1,63 @@
+//go:build gocv
+// +build gocv
+
+package vision
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// partsFrame рисует кадр 640×480 с деталями в рамках rects: серые
+// прямоугольники в клетку на тёмном фоне.
+func partsFrame(t *testing.T, rects ...image.Rectangle) []byte {
+	t.Helper()
+	frame := image.NewRGBA(image.Rect(0, 0, 640, 480))
+	for y := 0; y < 480; y++ {
+		for x := 0; x < 640; x++ {
+			frame.Set(x, y, color.RGBA{R: 50, G: 50, B: 50, A: 255})
+		}
+	}
+	for _, rect := range rects {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				shade := uint8(170)
+				if ((x-rect.Min.X)/10+(y-rect.Min.Y)/10)%2 == 0 {
+					shade = 210
+				}
+				frame.Set(x, y, color.RGBA{R: shade, G: shade, B: shade, A: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, frame))
+	return buf.Bytes()
+}
+
+func TestInspectParts_UnalignedPartOfAnotherSize(t *testing.T) {
+	d := NewGoCVDetector(0)
+	d.MultiPart.Enabled = true
+	// Совмещение не проходит ни у одной детали: вырезки сравниваются как есть.
+	d.MinAlignmentScore = 1.1
+
+	base := partsFrame(t, image.Rect(60, 60, 260, 180))
+	// Вторая деталь крупнее эталона в полтора раза — её вырезка другого размера.
+	current := partsFrame(t, image.Rect(40, 40, 240, 160), image.Rect(300, 200, 600, 380))
+
+	result, err := d.InspectDiff(context.Background(), base, current, nil)
+	require.NoError(t, err)
+	require.Equal(t, "multi_part", result.Trace.Branch)
+	require.Len(t, result.Parts, 2)
+	for _, part := range result.Parts {
+		require.True(t, part.Matched, "part %d", part.Index)
+	}
+	for _, defect := range result.Defects {
+		require.True(t, image.Rect(defect.X, defect.Y, defect.X+defect.Width, defect.Y+defect.Height).In(image.Rect(0, 0, 640, 480)))
+	}
+}
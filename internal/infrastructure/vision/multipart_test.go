@@ -0,0 +1,61 @@
+package vision
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"vision-bot/internal/domain/entity"
+)
+
+func TestPartOrder(t *testing.T) {
+	// Две строки по три детали; вторая строка чуть перекошена.
+	rects := []image.Rectangle{
+		image.Rect(420, 300, 520, 400), // строка 2, справа
+		image.Rect(220, 20, 320, 120),  // строка 1, в центре
+		image.Rect(20, 290, 120, 390),  // строка 2, слева
+		image.Rect(20, 10, 120, 110),   // строка 1, слева
+		image.Rect(420, 30, 520, 130),  // строка 1, справа
+		image.Rect(220, 310, 320, 410), // строка 2, в центре
+	}
+	require.Equal(t, []int{3, 1, 4, 2, 5, 0}, partOrder(rects))
+	require.Empty(t, partOrder(nil))
+}
+
+func TestCropSensitivity(t *testing.T) {
+	// Кадр 4×3, множитель — номер пикселя.
+	sensitivity := make([]float32, 12)
+	for i := range sensitivity {
+		sensitivity[i] = float32(i)
+	}
+	require.Equal(t, []float32{5, 6, 9, 10}, cropSensitivity(sensitivity, 4, image.Rect(1, 1, 3, 3)))
+	require.Nil(t, cropSensitivity(nil, 4, image.Rect(1, 1, 3, 3)))
+}
+
+func TestPartBackMapping(t *testing.T) {
+	// Деталь снимка вдвое меньше детали эталона и смещена.
+	m := rectMapping(image.Rect(100, 100, 300, 200), image.Rect(10, 20, 110, 70))
+	defect := mapDefect(m, entity.DefectArea{X: 200, Y: 150, Width: 20, Height: 10, Area: 150, Reason: "diff_contour"}, 640, 480)
+	require.Equal(t, entity.DefectArea{X: 60, Y: 45, Width: 10, Height: 5, Area: 38, Reason: "diff_contour"}, defect)
+
+	// Обратный разворот на 90°: вырезка эталона начинается в (50, 60),
+	// вырезка снимка — в (300, 0).
+	warp := orientationWarp(orientation{Angle: 90}, 1, [2]float64{40, 40}, [2]float64{100, 100})
+	inverse, ok := invertAffine(warp)
+	require.True(t, ok)
+	back := shiftAffine(inverse, image.Pt(-50, -60), image.Pt(300, 0))
+	// Центр детали эталона (150, 160) переходит в центр детали снимка (340, 40),
+	// ширина дефекта становится высотой.
+	defect = mapDefect(back, entity.DefectArea{X: 150, Y: 160, Width: 20, Height: 10, Area: 200}, 640, 480)
+	require.Equal(t, entity.DefectArea{X: 330, Y: 40, Width: 10, Height: 20, Area: 200}, defect)
+
+	// Вырезка снимка вдвое больше вырезки эталона и была сжата до её размера:
+	// совмещение тождественное, масштаб возвращается растяжением.
+	back = shiftAffine(scaleAffine([6]float64{1, 0, 0, 0, 1, 0}, 2, 2), image.Pt(-50, -60), image.Pt(300, 0))
+	defect = mapDefect(back, entity.DefectArea{X: 60, Y: 70, Width: 10, Height: 10, Area: 100}, 640, 480)
+	require.Equal(t, entity.DefectArea{X: 320, Y: 20, Width: 20, Height: 20, Area: 400}, defect)
+
+	_, ok = invertAffine([6]float64{})
+	require.False(t, ok)
+}
@@ -0,0 +1,99 @@
+package vision
+
+import "math"
+
+// orientation — как развернуть деталь на проверяемом снимке, чтобы она легла
+// так же, как на эталоне.
+type orientation struct {
+	// Angle — поворот в градусах против часовой стрелки, (-180, 180].
+	Angle float64
+	// Flipped — перед поворотом деталь отражается по горизонтали.
+	Flipped bool
+}
+
+// orientationFit — найденный разворот и перенос детали к детали эталона:
+// поворот вокруг центра масс from, масштаб scale и перенос from в to.
+type orientationFit struct {
+	orientation
+	scale float64
+	from  [2]float64
+	to    [2]float64
+}
+
+// warp возвращает матрицу переноса детали в кадр эталона.
+func (f orientationFit) warp() [6]float64 {
+	return orientationWarp(f.orientation, f.scale, f.from, f.to)
+}
+
+// negligible сообщает, что разворот меньше minAngle и без отражения: такой
+// сдвиг доводит совмещение по ECC.
+func (o orientation) negligible(minAngle float64) bool {
+	return !o.Flipped && math.Abs(normalizeAngle(o.Angle)) < minAngle
+}
+
+// principalAngle возвращает угол главной оси маски по центральным моментам:
+// градусы от оси x к оси y снимка (y направлена вниз), [-90, 90].
+func principalAngle(mu20, mu02, mu11 float64) float64 {
+	return 0.5 * math.Atan2(2*mu11, mu20-mu02) * 180 / math.Pi
+}
+
+// normalizeAngle приводит угол к промежутку (-180, 180].
+func normalizeAngle(angle float64) float64 {
+	angle = math.Mod(angle, 360)
+	switch {
+	case angle > 180:
+		angle -= 360
+	case angle <= -180:
+		angle += 360
+	}
+	return angle
+}
+
+// orientationCandidates перечисляет развороты для грубого перебора: совмещение
+// главных осей (ось не различает направления, поэтому и со сдвигом на 180°)
+// и повороты через 90°. С allowFlip каждый разворот пробуется и с отражением.
+// Первый кандидат — деталь без разворота.
+func orientationCandidates(baseAxis, currentAxis float64, allowFlip bool) []orientation {
+	var candidates []orientation
+	add := func(o orientation) {
+		o.Angle = normalizeAngle(o.Angle)
+		for _, c := range candidates {
+			if c.Flipped == o.Flipped && math.Abs(normalizeAngle(c.Angle-o.Angle)) < 0.5 {
+				return
+			}
+		}
+		candidates = append(candidates, o)
+	}
+
+	flips := []bool{false}
+	if allowFlip {
+		flips = append(flips, true)
+	}
+	for _, flipped := range flips {
+		// Отражение по горизонтали переводит ось φ в −φ.
+		axis := currentAxis
+		if flipped {
+			axis = -currentAxis
+		}
+		for _, angle := range []float64{0, 90, 180, 270, axis - baseAxis, axis - baseAxis + 180} {
+			add(orientation{Angle: angle, Flipped: flipped})
+		}
+	}
+	return candidates
+}
+
+// orientationWarp собирает аффинную матрицу 2×3 (построчно), которая отражает
+// и поворачивает деталь вокруг точки from, масштабирует её в scale раз
+// и переносит from в to. Поворот — как у cv::getRotationMatrix2D.
+func orientationWarp(o orientation, scale float64, from, to [2]float64) [6]float64 {
+	rad := o.Angle * math.Pi / 180
+	cos, sin := scale*math.Cos(rad), scale*math.Sin(rad)
+	a, b, c, d := cos, sin, -sin, cos
+	if o.Flipped {
+		a, c = -a, -c
+	}
+	return [6]float64{
+		a, b, to[0] - a*from[0] - b*from[1],
+		c, d, to[1] - c*from[0] - d*from[1],
+	}
+}
@@ -0,0 +1,76 @@
+//go:build gocv
+// +build gocv
+
+package vision
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"log/slog"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// normalizeOrientation ищет, как развернуть деталь на текущем снимке, чтобы она
+// легла так же, как на эталоне: кандидаты из главных осей масок и поворотов
+// через 90° оцениваются по IoU масок. Деталь переносится центром масс в центр
+// эталонной и приводится к её площади — точнее совместит alignCurrentToBase.
+// Если разворот не нужен, возвращает nil; иначе — новые Mat кадра эталона.
+func (d *GoCVDetector) normalizeOrientation(ctx context.Context, baseMask, current, currentMask gocv.Mat) (gocv.Mat, gocv.Mat, *orientationFit) {
+	if !d.Orientation.Enabled || baseMask.Empty() || currentMask.Empty() {
+		return gocv.Mat{}, gocv.Mat{}, nil
+	}
+	baseMoments := gocv.Moments(baseMask, true)
+	currentMoments := gocv.Moments(currentMask, true)
+	if baseMoments["m00"] <= 0 || currentMoments["m00"] <= 0 {
+		return gocv.Mat{}, gocv.Mat{}, nil
+	}
+	baseCenter := [2]float64{baseMoments["m10"] / baseMoments["m00"], baseMoments["m01"] / baseMoments["m00"]}
+	currentCenter := [2]float64{currentMoments["m10"] / currentMoments["m00"], currentMoments["m01"] / currentMoments["m00"]}
+	scale := math.Sqrt(baseMoments["m00"] / currentMoments["m00"])
+	size := image.Pt(baseMask.Cols(), baseMask.Rows())
+
+	candidates := orientationCandidates(
+		principalAngle(baseMoments["mu20"], baseMoments["mu02"], baseMoments["mu11"]),
+		principalAngle(currentMoments["mu20"], currentMoments["mu02"], currentMoments["mu11"]),
+		d.Orientation.AllowFlip,
+	)
+	var best orientation
+	bestScore, identityScore := -1.0, 0.0
+	for i, candidate := range candidates {
+		mask := warpOrientation(currentMask, orientationWarp(candidate, scale, currentCenter, baseCenter), size, gocv.InterpolationNearestNeighbor)
+		score := maskIoU(baseMask, mask)
+		mask.Close()
+		if i == 0 {
+			identityScore = score
+		}
+		if score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	if best.negligible(d.Orientation.MinAngle) || bestScore < identityScore+d.Orientation.MinGain {
+		slog.DebugContext(ctx, "Detector orientation kept", "best_angle", best.Angle, "best_flipped", best.Flipped, "score", bestScore, "identity_score", identityScore)
+		return gocv.Mat{}, gocv.Mat{}, nil
+	}
+
+	fit := &orientationFit{orientation: best, scale: scale, from: currentCenter, to: baseCenter}
+	oriented := warpOrientation(current, fit.warp(), size, gocv.InterpolationLinear)
+	orientedMask := warpOrientation(currentMask, fit.warp(), size, gocv.InterpolationNearestNeighbor)
+	slog.DebugContext(ctx, "Detector orientation normalized", "angle", best.Angle, "flipped", best.Flipped, "score", bestScore, "identity_score", identityScore)
+	return oriented, orientedMask, fit
+}
+
+// warpOrientation применяет к src матрицу orientationWarp и возвращает новую Mat размера size.
+func warpOrientation(src gocv.Mat, coefficients [6]float64, size image.Point, interpolation gocv.InterpolationFlags) gocv.Mat {
+	warp := gocv.NewMatWithSize(2, 3, gocv.MatTypeCV64F)
+	defer warp.Close()
+	for i, v := range coefficients {
+		warp.SetDoubleAt(i/3, i%3, v)
+	}
+
+	dst := gocv.NewMat()
+	gocv.WarpAffineWithParams(src, &dst, warp, size, interpolation, gocv.BorderConstant, color.RGBA{})
+	return dst
+}
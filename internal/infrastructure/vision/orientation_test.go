@@ -0,0 +1,55 @@
+package vision
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrincipalAngle(t *testing.T) {
+	// Вытянутая по x маска, по y и по диагонали вниз-вправо.
+	require.InDelta(t, 0, principalAngle(100, 10, 0), 1e-9)
+	require.InDelta(t, 90, principalAngle(10, 100, 0), 1e-9)
+	require.InDelta(t, 45, principalAngle(50, 50, 40), 1e-9)
+}
+
+func TestOrientationCandidates(t *testing.T) {
+	// Эталон лежит вдоль x, деталь — вдоль y: главные оси дают ±90°.
+	candidates := orientationCandidates(0, 90, false)
+	require.Equal(t, orientation{}, candidates[0])
+	require.Equal(t, []orientation{{Angle: 0}, {Angle: 90}, {Angle: 180}, {Angle: -90}}, candidates)
+
+	candidates = orientationCandidates(0, 30, true)
+	require.Len(t, candidates, 12)
+	require.Contains(t, candidates, orientation{Angle: 30})
+	require.Contains(t, candidates, orientation{Angle: -150})
+	require.Contains(t, candidates, orientation{Angle: -30, Flipped: true})
+}
+
+func TestOrientationWarp(t *testing.T) {
+	apply := func(m [6]float64, x, y float64) [2]float64 {
+		return [2]float64{m[0]*x + m[1]*y + m[2], m[3]*x + m[4]*y + m[5]}
+	}
+	from, to := [2]float64{10, 10}, [2]float64{50, 40}
+
+	// Центр переносится в to, точка справа от центра после поворота
+	// на 90° против часовой стрелки оказывается сверху (ось y вниз).
+	m := orientationWarp(orientation{Angle: 90}, 2, from, to)
+	requirePoint(t, to, apply(m, 10, 10))
+	requirePoint(t, [2]float64{50, 38}, apply(m, 11, 10))
+
+	// Отражение: точка справа уходит влево.
+	m = orientationWarp(orientation{Flipped: true}, 1, from, to)
+	requirePoint(t, [2]float64{49, 40}, apply(m, 11, 10))
+	requirePoint(t, [2]float64{50, 41}, apply(m, 10, 11))
+
+	require.True(t, orientation{Angle: 4}.negligible(10))
+	require.False(t, orientation{Angle: 4, Flipped: true}.negligible(10))
+	require.False(t, orientation{Angle: -179}.negligible(10))
+}
+
+func requirePoint(t *testing.T, want, got [2]float64) {
+	t.Helper()
+	require.InDelta(t, want[0], got[0], 1e-9)
+	require.InDelta(t, want[1], got[1], 1e-9)
+}
@@ -0,0 +1,377 @@
+package vision
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BuiltinVersion — версия профиля из встроенных порогов.
+const BuiltinVersion = "builtin"
+
+// Profile — пороги детектора под именем и с версией.
+type Profile struct {
+	Name string
+	// Version — первые 12 символов SHA-256 файла профиля или BuiltinVersion.
+	Version  string
+	Detector *GoCVDetector
+}
+
+// QuickProfile — пороги проверки по одному снимку, без эталона (секция quick
+// профиля). Обычное значение признака задаёт сама деталь: медиана и MAD по её
+// маске. Дефект — участок, где признак уходит от медианы дальше порога.
+type QuickProfile struct {
+	// VarianceWindow — сторона окна, в котором считается разброс яркости, пиксели.
+	VarianceWindow int `yaml:"variance_window"`
+	// VarianceZThreshold — во сколько MAD локальный разброс яркости может
+	// отклониться от медианного по детали.
+	VarianceZThreshold float64 `yaml:"variance_z_threshold"`
+	// VarianceMinSigma — нижняя граница MAD разброса яркости, уровни 0–255:
+	// на гладкой детали иначе дефектом стал бы шум камеры.
+	VarianceMinSigma float64 `yaml:"variance_min_sigma"`
+	// StructureMinLengthRatio — кромка длиннее этой доли большей стороны
+	// снимка считается элементом детали (отверстие, уступ) и не проверяется
+	// на разброс яркости.
+	StructureMinLengthRatio float64 `yaml:"structure_min_length_ratio"`
+	// ColorZThreshold — во сколько MAD цвет (каналы a и b пространства Lab)
+	// может отклониться от медианного по детали.
+	ColorZThreshold float64 `yaml:"color_z_threshold"`
+	// ColorMinSigma — нижняя граница MAD цвета, единицы Lab.
+	ColorMinSigma float64 `yaml:"color_min_sigma"`
+	// ScratchKernel — линия уже этого размера (пиксели) считается царапиной,
+	// шире — собственным элементом детали.
+	ScratchKernel int `yaml:"scratch_kernel"`
+	// ScratchMinContrast — насколько царапина темнее или светлее окружения, уровни 0–255.
+	ScratchMinContrast float64 `yaml:"scratch_min_contrast"`
+	// ScratchMinLengthRatio — минимальная длина царапины относительно большей стороны снимка.
+	ScratchMinLengthRatio float64 `yaml:"scratch_min_length_ratio"`
+	// ScratchMaxGap — разрыв, через который отрезки одной царапины склеиваются, пиксели.
+	ScratchMaxGap int `yaml:"scratch_max_gap"`
+}
+
+// ColorProfile — пороги цветовой ветки сравнения с эталоном (секция color
+// профиля). Снимки сравниваются в пространстве CIELAB: ржавчина и пятна той же
+// яркости, что и деталь, в сером изображении не видны.
+type ColorProfile struct {
+	// Enabled включает цветовую ветку.
+	Enabled bool `yaml:"enabled"`
+	// DeltaEThreshold — пиксель, цвет которого отличается от эталона больше
+	// этого ΔE*76, становится кандидатом в дефект. Общий для всей детали сдвиг
+	// (другое освещение, баланс белого) вычитается заранее.
+	DeltaEThreshold float64 `yaml:"delta_e_threshold"`
+	// BlurKernel — размер сглаживания перед переводом в Lab, пиксели.
+	BlurKernel int `yaml:"blur_kernel"`
+	// CorrosionMinShift — на сколько единиц Lab должны вырасти и a*, и b*
+	// (сдвиг в красный и жёлтый), чтобы пятно считалось коррозией.
+	CorrosionMinShift float64 `yaml:"corrosion_min_shift"`
+	// StainMinDarkening — на сколько единиц L* участок должен потемнеть,
+	// чтобы считаться пятном, если потемнение сильнее сдвига цвета.
+	StainMinDarkening float64 `yaml:"stain_min_darkening"`
+}
+
+// CalibrationProfile — перевод пикселей в миллиметры и допуск в миллиметрах
+// (секция calibration профиля). Масштаб берётся из печатного маркера в кадре
+// эталона или из длины детали, которую ввёл инспектор.
+type CalibrationProfile struct {
+	// Marker — печатный маркер в кадре: chessboard, aruco или пусто — маркера нет.
+	Marker string `yaml:"marker"`
+	// MarkerSizeMM — сторона клетки шахматной доски или ArUco-маркера, мм.
+	MarkerSizeMM float64 `yaml:"marker_size_mm"`
+	// ChessboardCols и ChessboardRows — число внутренних углов доски
+	// по горизонтали и по вертикали.
+	ChessboardCols int `yaml:"chessboard_cols"`
+	ChessboardRows int `yaml:"chessboard_rows"`
+	// ArucoDictionary — словарь ArUco: 4x4_50, 5x5_100, 6x6_250 и т. д.
+	ArucoDictionary string `yaml:"aruco_dictionary"`
+	// MinDefectLengthMM — дефекты меньшего размера не бракуют деталь (0 — допуска нет).
+	MinDefectLengthMM float64 `yaml:"min_defect_length_mm"`
+	// MinDefectAreaMM2 — дефекты меньшей площади не бракуют деталь (0 — допуска нет).
+	// Если заданы оба порога, дефект должен быть меньше каждого из них.
+	MinDefectAreaMM2 float64 `yaml:"min_defect_area_mm2"`
+}
+
+// OrientationProfile — поиск разворота детали перед совмещением с эталоном
+// (секция orientation профиля). Деталь, положенную на 90° или 180° иначе,
+// совмещение по рамкам растягивает, и вся она уходит в несовпадение формы.
+type OrientationProfile struct {
+	// Enabled включает поиск разворота.
+	Enabled bool `yaml:"enabled"`
+	// AllowFlip разрешает отражение: деталь положили другой стороной.
+	AllowFlip bool `yaml:"allow_flip"`
+	// MinAngle — меньшие повороты без отражения оставляются совмещению по ECC, градусы.
+	MinAngle float64 `yaml:"min_angle"`
+	// MinGain — на сколько IoU масок с эталоном после разворота должно
+	// превысить IoU без него, чтобы разворот применился.
+	MinGain float64 `yaml:"min_gain"`
+}
+
+// MultiPartProfile — проверка снимка с несколькими деталями (секция multi_part
+// профиля): каждая деталь сопоставляется по форме с деталью эталона
+// и сравнивается с ней отдельно.
+type MultiPartProfile struct {
+	// Enabled включает режим; снимок с одной деталью проверяется как обычно.
+	Enabled bool `yaml:"enabled"`
+	// MaxParts — сколько деталей проверяется на снимке; лишние, самые мелкие, отбрасываются.
+	MaxParts int `yaml:"max_parts"`
+	// MinRelativeArea — компонент маски меньше этой доли крупнейшей детали
+	// считается обрезком маски, а не деталью.
+	MinRelativeArea float64 `yaml:"min_relative_area"`
+	// MaxShapeScore — деталь, расстояние формы которой до детали эталона
+	// (cv::matchShapes, I2) больше порога, считается чужой.
+	MaxShapeScore float64 `yaml:"max_shape_score"`
+	// CropMargin — поле вокруг детали при вырезании, доля большей стороны рамки.
+	CropMargin float64 `yaml:"crop_margin"`
+}
+
+// defaultMultiPartProfile возвращает встроенные настройки: режим выключен.
+func defaultMultiPartProfile() MultiPartProfile {
+	return MultiPartProfile{
+		MaxParts:        8,
+		MinRelativeArea: 0.3,
+		MaxShapeScore:   0.5,
+		CropMargin:      0.1,
+	}
+}
+
+// defaultOrientationProfile возвращает встроенные настройки поиска разворота.
+func defaultOrientationProfile() OrientationProfile {
+	return OrientationProfile{
+		Enabled:   true,
+		AllowFlip: true,
+		MinAngle:  10,
+		MinGain:   0.05,
+	}
+}
+
+// defaultCalibrationProfile возвращает встроенные настройки калибровки: маркера нет.
+func defaultCalibrationProfile() CalibrationProfile {
+	return CalibrationProfile{
+		ChessboardCols:  9,
+		ChessboardRows:  6,
+		ArucoDictionary: "4x4_50",
+	}
+}
+
+// defaultColorProfile возвращает встроенные пороги цветовой ветки.
+func defaultColorProfile() ColorProfile {
+	return ColorProfile{
+		Enabled:           true,
+		DeltaEThreshold:   12,
+		BlurKernel:        5,
+		CorrosionMinShift: 4,
+		StainMinDarkening: 8,
+	}
+}
+
+// defaultQuickProfile возвращает встроенные пороги проверки без эталона.
+func defaultQuickProfile() QuickProfile {
+	return QuickProfile{
+		VarianceWindow:          15,
+		VarianceZThreshold:      6,
+		VarianceMinSigma:        1.5,
+		StructureMinLengthRatio: 0.1,
+		ColorZThreshold:         6,
+		ColorMinSigma:           2,
+		ScratchKernel:           9,
+		ScratchMinContrast:      25,
+		ScratchMinLengthRatio:   0.04,
+		ScratchMaxGap:           5,
+	}
+}
+
+// BuiltinProfile возвращает профиль со встроенными порогами.
+func BuiltinProfile(name string) Profile {
+	return Profile{Name: name, Version: BuiltinVersion, Detector: NewGoCVDetector(0)}
+}
+
+// ProfilePath возвращает путь к файлу профиля name в каталоге dir.
+func ProfilePath(dir, name string) string {
+	return filepath.Join(dir, name+".yaml")
+}
+
+// LoadProfile читает профиль name из каталога dir.
+func LoadProfile(dir, name string) (Profile, error) {
+	data, err := os.ReadFile(ProfilePath(dir, name))
+	if err != nil {
+		return Profile{}, fmt.Errorf("read detector profile: %w", err)
+	}
+	return ParseProfile(name, data)
+}
+
+// ParseProfile разбирает профиль из YAML: пороги из файла ложатся поверх
+// встроенных. Неизвестный ключ — ошибка, чтобы опечатка в имени порога
+// не превращалась молча в значение по умолчанию.
+func ParseProfile(name string, data []byte) (Profile, error) {
+	detector := NewGoCVDetector(0)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(detector); err != nil && !errors.Is(err, io.EOF) {
+		return Profile{}, fmt.Errorf("parse detector profile %s: %w", name, err)
+	}
+	if err := validateProfile(detector); err != nil {
+		return Profile{}, fmt.Errorf("detector profile %s: %w", name, err)
+	}
+	return Profile{Name: name, Version: profileVersion(data), Detector: detector}, nil
+}
+
+// profileVersion — версия профиля по содержимому файла.
+func profileVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// validateProfile отсекает значения, с которыми конвейер сломается
+// или молча перестанет находить дефекты.
+func validateProfile(d *GoCVDetector) error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if d.MaxSide <= 0 || d.MinImageSide <= 0 {
+		fail("max_side and min_image_side must be positive")
+	}
+	if d.MinAspectRatio <= 0 || d.MinAspectRatio >= d.MaxAspectRatio {
+		fail("min_aspect_ratio must be positive and below max_aspect_ratio")
+	}
+	for name, value := range map[string]float64{
+		"min_area_ratio":         d.MinAreaRatio,
+		"min_part_area_ratio":    d.MinPartAreaRatio,
+		"min_alignment_score":    d.MinAlignmentScore,
+		"min_contour_area_ratio": d.MinContourAreaRatio,
+		"min_fill_ratio":         d.MinFillRatio,
+		"nms_iou_threshold":      d.NMSIoUThreshold,
+		"nms_containment_ratio":  d.NMSContainmentRatio,
+		"max_overexposed_ratio":  d.MaxOverexposedRatio,
+		"max_underexposed_ratio": d.MaxUnderexposedRatio,
+		"max_glare_ratio":        d.MaxGlareRatio,
+		"diff_max_glare_ratio":   d.DiffMaxGlareRatio,
+		"broken_area_loss_ratio": d.BrokenAreaLossRatio,
+	} {
+		if value < 0 || value > 1 {
+			fail("%s %v: expected a value from 0 to 1", name, value)
+		}
+	}
+	if d.GoldenZThreshold <= 0 || d.GoldenMinSigma <= 0 {
+		fail("golden_z_threshold and golden_min_sigma must be positive")
+	}
+	if d.CriticalZoneSensitivity <= 0 {
+		fail("critical_zone_sensitivity must be positive")
+	}
+	if d.DiffMinThreshold < 0 || d.DiffMinThreshold > 255 {
+		fail("diff_min_threshold %v: expected a value from 0 to 255", d.DiffMinThreshold)
+	}
+	validateQuickProfile(d.Quick, fail)
+	validateColorProfile(d.Color, fail)
+	validateCalibrationProfile(d.Calibration, fail)
+	validateOrientationProfile(d.Orientation, fail)
+	validateMultiPartProfile(d.MultiPart, fail)
+	for name, value := range map[string]int{
+		"roi_margin_kernel":    d.ROIMarginKernel,
+		"diff_open_kernel":     d.DiffOpenKernel,
+		"diff_close_kernel":    d.DiffCloseKernel,
+		"broken_split_kernel":  d.BrokenSplitKernel,
+		"geometry_ring_kernel": d.GeometryRingKernel,
+	} {
+		if value < 0 {
+			fail("%s must not be negative", name)
+		}
+	}
+	// Порядок ошибок из карт случаен; сортируем, чтобы сообщение было стабильным.
+	slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return errors.Join(errs...)
+}
+
+// validateQuickProfile проверяет секцию quick; имена порогов в ошибках — с префиксом секции.
+func validateQuickProfile(q QuickProfile, fail func(format string, args ...any)) {
+	if q.VarianceWindow < 3 || q.ScratchKernel < 3 {
+		fail("quick.variance_window and quick.scratch_kernel must be at least 3")
+	}
+	if q.VarianceZThreshold <= 0 || q.ColorZThreshold <= 0 || q.VarianceMinSigma <= 0 || q.ColorMinSigma <= 0 {
+		fail("quick z thresholds and min sigmas must be positive")
+	}
+	if q.ScratchMinContrast <= 0 || q.ScratchMinContrast > 255 {
+		fail("quick.scratch_min_contrast %v: expected a value from 0 to 255", q.ScratchMinContrast)
+	}
+	for name, value := range map[string]float64{
+		"quick.structure_min_length_ratio": q.StructureMinLengthRatio,
+		"quick.scratch_min_length_ratio":   q.ScratchMinLengthRatio,
+	} {
+		if value <= 0 || value > 1 {
+			fail("%s %v: expected a value from 0 to 1", name, value)
+		}
+	}
+	if q.ScratchMaxGap < 0 {
+		fail("quick.scratch_max_gap must not be negative")
+	}
+}
+
+// validateColorProfile проверяет секцию color; имена порогов в ошибках — с префиксом секции.
+func validateColorProfile(c ColorProfile, fail func(format string, args ...any)) {
+	if c.DeltaEThreshold <= 0 {
+		fail("color.delta_e_threshold must be positive")
+	}
+	if c.BlurKernel < 0 {
+		fail("color.blur_kernel must not be negative")
+	}
+	if c.CorrosionMinShift < 0 || c.StainMinDarkening < 0 {
+		fail("color.corrosion_min_shift and color.stain_min_darkening must not be negative")
+	}
+}
+
+// validateCalibrationProfile проверяет секцию calibration; имена полей в ошибках — с префиксом секции.
+func validateCalibrationProfile(c CalibrationProfile, fail func(format string, args ...any)) {
+	switch c.Marker {
+	case "":
+	case markerChessboard, markerAruco:
+		if c.MarkerSizeMM <= 0 {
+			fail("calibration.marker_size_mm must be positive for marker %q", c.Marker)
+		}
+	default:
+		fail("calibration.marker %q: expected chessboard, aruco or empty", c.Marker)
+	}
+	if c.ChessboardCols < 2 || c.ChessboardRows < 2 {
+		fail("calibration.chessboard_cols and calibration.chessboard_rows must be at least 2")
+	}
+	if !slices.Contains(arucoDictionaries, c.ArucoDictionary) {
+		fail("calibration.aruco_dictionary %q is unknown", c.ArucoDictionary)
+	}
+	if c.MinDefectLengthMM < 0 || c.MinDefectAreaMM2 < 0 {
+		fail("calibration.min_defect_length_mm and calibration.min_defect_area_mm2 must not be negative")
+	}
+}
+
+// validateOrientationProfile проверяет секцию orientation; имена полей в ошибках — с префиксом секции.
+func validateOrientationProfile(o OrientationProfile, fail func(format string, args ...any)) {
+	if o.MinAngle < 0 || o.MinAngle >= 180 {
+		fail("orientation.min_angle %v: expected a value from 0 to 180", o.MinAngle)
+	}
+	if o.MinGain < 0 || o.MinGain > 1 {
+		fail("orientation.min_gain %v: expected a value from 0 to 1", o.MinGain)
+	}
+}
+
+// validateMultiPartProfile проверяет секцию multi_part; имена полей в ошибках — с префиксом секции.
+func validateMultiPartProfile(m MultiPartProfile, fail func(format string, args ...any)) {
+	if m.MaxParts < 2 {
+		fail("multi_part.max_parts must be at least 2")
+	}
+	if m.MinRelativeArea <= 0 || m.MinRelativeArea > 1 {
+		fail("multi_part.min_relative_area %v: expected a value from 0 to 1", m.MinRelativeArea)
+	}
+	if m.MaxShapeScore <= 0 {
+		fail("multi_part.max_shape_score must be positive")
+	}
+	if m.CropMargin < 0 || m.CropMargin > 1 {
+		fail("multi_part.crop_margin %v: expected a value from 0 to 1", m.CropMargin)
+	}
+}
@@ -0,0 +1,268 @@
+//go:build gocv
+// +build gocv
+
+package vision
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"gocv.io/x/gocv"
+
+	"vision-bot/internal/domain/entity"
+)
+
+// Inspect ищет дефекты на одном снимке, без эталона. Деталь сравнивается
+// сама с собой: по её маске считается обычный уровень трёх признаков —
+// разброса яркости, цвета и тонких линий, — а дефектом становятся участки,
+// которые выходят за него. Контур детали в разбор не попадает: проверяется
+// только её внутренняя часть.
+func (d *GoCVDetector) Inspect(ctx context.Context, imageData []byte) (*entity.InspectionResult, error) {
+	var clock stageClock
+	if err := clock.enter(ctx, "decode"); err != nil {
+		return nil, err
+	}
+	mat, err := d.decodeFrame(imageData, "image", d.MaxGlareRatio, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer mat.Close()
+	width, height := mat.Cols(), mat.Rows()
+
+	if err := clock.enter(ctx, "part_mask"); err != nil {
+		return nil, err
+	}
+	partMask := d.buildPartMask(mat)
+	defer partMask.Close()
+	roiMask := d.buildInteriorMask(partMask)
+	defer roiMask.Close()
+	if gocv.CountNonZero(roiMask) == 0 {
+		return nil, errors.New("part is not detected")
+	}
+	d.dump(ctx, "quick_roi", roiMask)
+
+	gray := gocv.NewMat()
+	defer gray.Close()
+	gocv.CvtColor(mat, &gray, gocv.ColorBGRToGray)
+
+	if err := clock.enter(ctx, "texture"); err != nil {
+		return nil, err
+	}
+	textureMask, err := d.quickTextureMask(gray, roiMask)
+	if err != nil {
+		return nil, err
+	}
+	defer textureMask.Close()
+	d.dump(ctx, "quick_texture", textureMask)
+	defects := d.quickMaskDefects(ctx, textureMask, width, height, "texture")
+
+	if err := clock.enter(ctx, "color"); err != nil {
+		return nil, err
+	}
+	colorMask, err := d.quickColorMask(mat, roiMask)
+	if err != nil {
+		return nil, err
+	}
+	defer colorMask.Close()
+	d.dump(ctx, "quick_color", colorMask)
+	defects = append(defects, d.quickMaskDefects(ctx, colorMask, width, height, "color")...)
+
+	if err := clock.enter(ctx, "scratch"); err != nil {
+		return nil, err
+	}
+	scratchMask := d.quickScratchMask(gray, roiMask)
+	defer scratchMask.Close()
+	d.dump(ctx, "quick_scratch", scratchMask)
+	defects = append(defects, scratchDefects(scratchMask)...)
+
+	defects = d.suppressDuplicateDefects(defects)
+	d.logDefects(ctx, "inspect", defects)
+
+	return &entity.InspectionResult{
+		ImageWidth:  width,
+		ImageHeight: height,
+		Defects:     defects,
+		HasDefects:  len(defects) > 0,
+		Trace:       entity.InspectionTrace{Branch: "single", Stages: clock.finish()},
+	}, nil
+}
+
+// quickTextureMask отмечает участки, где локальный разброс яркости выходит
+// за обычный для детали: вмятины и пятна на гладкой поверхности, гладкие
+// проплешины на шероховатой. Длинные кромки самой детали из расчёта исключены.
+func (d *GoCVDetector) quickTextureMask(gray, roiMask gocv.Mat) (gocv.Mat, error) {
+	window := image.Pt(d.Quick.VarianceWindow, d.Quick.VarianceWindow)
+
+	values := gocv.NewMat()
+	defer values.Close()
+	gray.ConvertTo(&values, gocv.MatTypeCV32F)
+	mean := gocv.NewMat()
+	defer mean.Close()
+	gocv.BoxFilter(values, &mean, -1, window)
+	squares := gocv.NewMat()
+	defer squares.Close()
+	gocv.Multiply(values, values, &squares)
+	meanOfSquares := gocv.NewMat()
+	defer meanOfSquares.Close()
+	gocv.BoxFilter(squares, &meanOfSquares, -1, window)
+	squaredMean := gocv.NewMat()
+	defer squaredMean.Close()
+	gocv.Multiply(mean, mean, &squaredMean)
+	variance := gocv.NewMat()
+	defer variance.Close()
+	gocv.Subtract(meanOfSquares, squaredMean, &variance)
+
+	data, err := variance.DataPtrFloat32()
+	if err != nil {
+		return gocv.NewMat(), fmt.Errorf("local variance: %w", err)
+	}
+	deviation := make([]float32, len(data))
+	for i, v := range data {
+		deviation[i] = float32(math.Sqrt(math.Max(float64(v), 0)))
+	}
+
+	structure := d.quickStructureMask(gray)
+	defer structure.Close()
+	free := gocv.NewMat()
+	defer free.Close()
+	gocv.BitwiseNot(structure, &free)
+	mask := gocv.NewMat()
+	defer mask.Close()
+	gocv.BitwiseAnd(roiMask, free, &mask)
+
+	outliers := outlierMask([][]float32{deviation}, mask.ToBytes(), d.Quick.VarianceZThreshold, d.Quick.VarianceMinSigma)
+	return maskFromBytes(gray.Rows(), gray.Cols(), outliers)
+}
+
+// quickStructureMask отмечает окрестность длинных кромок: отверстия, уступы
+// и другие элементы детали дают большой разброс яркости, но дефектом не являются.
+func (d *GoCVDetector) quickStructureMask(gray gocv.Mat) gocv.Mat {
+	blurred := gocv.NewMat()
+	defer blurred.Close()
+	gocv.GaussianBlur(gray, &blurred, image.Pt(5, 5), 0, 0, gocv.BorderDefault)
+	edges := gocv.NewMat()
+	defer edges.Close()
+	gocv.Canny(blurred, &edges, 50, 150)
+
+	contours := gocv.FindContours(edges, gocv.RetrievalList, gocv.ChainApproxSimple)
+	defer contours.Close()
+	minLength := d.Quick.StructureMinLengthRatio * float64(maxInt(gray.Cols(), gray.Rows()))
+	mask := gocv.Zeros(gray.Rows(), gray.Cols(), gocv.MatTypeCV8U)
+	for i := 0; i < contours.Size(); i++ {
+		// Открытая кромка обходится контуром дважды.
+		if gocv.ArcLength(contours.At(i), false)/2 >= minLength {
+			gocv.DrawContours(&mask, contours, i, color.RGBA{255, 255, 255, 0}, d.Quick.VarianceWindow)
+		}
+	}
+	return mask
+}
+
+// quickColorMask отмечает пятна, цвет которых (каналы a и b пространства Lab)
+// далёк от обычного для детали: ржавчина, следы краски, подгар.
+func (d *GoCVDetector) quickColorMask(mat, roiMask gocv.Mat) (gocv.Mat, error) {
+	lab := gocv.NewMat()
+	defer lab.Close()
+	gocv.CvtColor(mat, &lab, gocv.ColorBGRToLab)
+	planes := gocv.Split(lab)
+	defer func() {
+		for i := range planes {
+			planes[i].Close()
+		}
+	}()
+	if len(planes) != 3 {
+		return gocv.NewMat(), errors.New("unexpected Lab image layout")
+	}
+
+	channels := make([][]float32, 0, 2)
+	for _, plane := range planes[1:] {
+		smoothed := gocv.NewMat()
+		gocv.GaussianBlur(plane, &smoothed, image.Pt(5, 5), 0, 0, gocv.BorderDefault)
+		pixels := smoothed.ToBytes()
+		smoothed.Close()
+
+		values := make([]float32, len(pixels))
+		for i, v := range pixels {
+			values[i] = float32(v)
+		}
+		channels = append(channels, values)
+	}
+
+	outliers := outlierMask(channels, roiMask.ToBytes(), d.Quick.ColorZThreshold, d.Quick.ColorMinSigma)
+	return maskFromBytes(mat.Rows(), mat.Cols(), outliers)
+}
+
+// quickScratchMask находит царапины: тонкие тёмные или светлые линии.
+// Морфологический top-hat и black-hat выделяют только то, что уже
+// ScratchKernel, поэтому ступенька яркости на краю элемента детали
+// откликается слабо, а линия — сильно. Из отклика остаются прямые отрезки
+// не короче ScratchMinLengthRatio.
+func (d *GoCVDetector) quickScratchMask(gray, roiMask gocv.Mat) gocv.Mat {
+	size := d.Quick.ScratchKernel
+	kernel := gocv.GetStructuringElement(gocv.MorphEllipse, image.Pt(size, size))
+	defer kernel.Close()
+
+	bright := gocv.NewMat()
+	defer bright.Close()
+	gocv.MorphologyEx(gray, &bright, gocv.MorphTophat, kernel)
+	dark := gocv.NewMat()
+	defer dark.Close()
+	gocv.MorphologyEx(gray, &dark, gocv.MorphBlackhat, kernel)
+	response := gocv.NewMat()
+	defer response.Close()
+	gocv.Max(bright, dark, &response)
+
+	thresholded := gocv.NewMat()
+	defer thresholded.Close()
+	gocv.Threshold(response, &thresholded, float32(d.Quick.ScratchMinContrast), 255, gocv.ThresholdBinary)
+	candidates := gocv.NewMat()
+	defer candidates.Close()
+	gocv.BitwiseAnd(thresholded, roiMask, &candidates)
+
+	minLength := d.Quick.ScratchMinLengthRatio * float64(maxInt(gray.Cols(), gray.Rows()))
+	lines := gocv.NewMat()
+	defer lines.Close()
+	gocv.HoughLinesPWithParams(candidates, &lines, 1, math.Pi/180, maxInt(1, int(minLength/2)), float32(minLength), float32(d.Quick.ScratchMaxGap))
+
+	mask := gocv.Zeros(gray.Rows(), gray.Cols(), gocv.MatTypeCV8U)
+	for i := 0; i < lines.Rows(); i++ {
+		line := lines.GetVeciAt(i, 0)
+		gocv.Line(&mask, image.Pt(int(line[0]), int(line[1])), image.Pt(int(line[2]), int(line[3])), color.RGBA{255, 255, 255, 0}, 3)
+	}
+	return mask
+}
+
+// quickMaskDefects превращает маску признака в дефекты с теми же фильтрами
+// площади и формы, что и при сравнении с эталоном.
+func (d *GoCVDetector) quickMaskDefects(ctx context.Context, mask gocv.Mat, width, height int, reasonTag string) []entity.DefectArea {
+	cleaned := d.postProcessDiffMask(mask)
+	defer cleaned.Close()
+
+	contours := gocv.FindContours(cleaned, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+	return d.extractDefectsFromContours(ctx, contours, width, height, reasonTag)
+}
+
+// scratchDefects описывает каждую царапину рамкой. Фильтр пропорций
+// extractDefectsFromContours здесь не подходит: царапина и есть длинная узкая рамка.
+func scratchDefects(mask gocv.Mat) []entity.DefectArea {
+	contours := gocv.FindContours(mask, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+
+	defects := make([]entity.DefectArea, 0, contours.Size())
+	for i := 0; i < contours.Size(); i++ {
+		rect := gocv.BoundingRect(contours.At(i))
+		defects = append(defects, entity.DefectArea{
+			X:      rect.Min.X,
+			Y:      rect.Min.Y,
+			Width:  rect.Dx(),
+			Height: rect.Dy(),
+			Area:   rect.Dx() * rect.Dy(),
+			Reason: fmt.Sprintf("scratch length=%.1f", math.Hypot(float64(rect.Dx()), float64(rect.Dy()))),
+		})
+	}
+	return defects
+}
@@ -0,0 +1,89 @@
+package vision
+
+import (
+	"math"
+	"slices"
+)
+
+// robustSampleLimit — сколько пикселей маски достаточно для медианы и MAD:
+// на снимке в мегапиксель сортировать всё незачем.
+const robustSampleLimit = 1 << 16
+
+// madScale переводит MAD в оценку стандартного отклонения для нормального распределения.
+const madScale = 1.4826
+
+// robustCenter возвращает медиану и масштаб (MAD × madScale) значений
+// под маской. ok = false, если маска пуста.
+func robustCenter(values []float32, mask []byte) (median, scale float64, ok bool) {
+	count := 0
+	for _, m := range mask {
+		if m != 0 {
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, 0, false
+	}
+
+	step := max(1, count/robustSampleLimit)
+	samples := make([]float64, 0, min(count, robustSampleLimit+1))
+	seen := 0
+	for i, m := range mask {
+		if m == 0 {
+			continue
+		}
+		if seen%step == 0 {
+			samples = append(samples, float64(values[i]))
+		}
+		seen++
+	}
+
+	median = medianOf(samples)
+	for i, v := range samples {
+		samples[i] = math.Abs(v - median)
+	}
+	return median, medianOf(samples) * madScale, true
+}
+
+// medianOf возвращает медиану; порядок values меняется.
+func medianOf(values []float64) float64 {
+	slices.Sort(values)
+	mid := len(values) / 2
+	if len(values)%2 == 1 {
+		return values[mid]
+	}
+	return (values[mid-1] + values[mid]) / 2
+}
+
+// outlierMask отмечает 255 пиксели маски, где признак далеко от обычного
+// для детали: корень из суммы квадратов z по каналам больше threshold.
+// z канала — |x − медиана| / масштаб, масштаб не меньше minSigma.
+func outlierMask(channels [][]float32, mask []byte, threshold, minSigma float64) []byte {
+	out := make([]byte, len(mask))
+	medians := make([]float64, len(channels))
+	scales := make([]float64, len(channels))
+	for c, values := range channels {
+		median, scale, ok := robustCenter(values, mask)
+		if !ok {
+			return out
+		}
+		medians[c] = median
+		scales[c] = math.Max(scale, minSigma)
+	}
+
+	limit := threshold * threshold
+	for i, m := range mask {
+		if m == 0 {
+			continue
+		}
+		sum := 0.0
+		for c, values := range channels {
+			z := (float64(values[i]) - medians[c]) / scales[c]
+			sum += z * z
+		}
+		if sum > limit {
+			out[i] = 255
+		}
+	}
+	return out
+}
@@ -0,0 +1,35 @@
+package vision
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRobustCenter_IgnoresOutliersAndMaskedPixels(t *testing.T) {
+	values := []float32{10, 11, 9, 10, 200, 0}
+	mask := []byte{255, 255, 255, 255, 255, 0}
+
+	median, scale, ok := robustCenter(values, mask)
+	require.True(t, ok)
+	assert.Equal(t, 10.0, median)
+	assert.InDelta(t, 1.4826, scale, 1e-9)
+
+	_, _, ok = robustCenter(values, make([]byte, len(values)))
+	assert.False(t, ok)
+}
+
+func TestOutlierMask(t *testing.T) {
+	// Ровная деталь: разброс нулевой, порог держит minSigma
+	flat := []float32{50, 50, 50, 50, 53, 90}
+	mask := []byte{255, 255, 255, 255, 255, 255}
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 255}, outlierMask([][]float32{flat}, mask, 6, 2))
+
+	// По двум каналам расстояние складывается: каждый по отдельности не выходит за порог
+	a := []float32{0, 0, 0, 0, 5}
+	b := []float32{0, 0, 0, 0, 5}
+	mask = []byte{255, 255, 255, 255, 255}
+	assert.Equal(t, []byte{0, 0, 0, 0, 255}, outlierMask([][]float32{a, b}, mask, 6, 1))
+	assert.Equal(t, []byte{0, 0, 0, 0, 0}, outlierMask([][]float32{a}, mask, 6, 1))
+}
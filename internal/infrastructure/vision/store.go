@@ -0,0 +1,198 @@
+package vision
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/domain/port"
+)
+
+// ProfileStore отдаёт детектору действующий профиль и подменяет его,
+// когда меняется файл. Проверка берёт профиль в начале и доводится с ним,
+// даже если профиль тем временем перезагрузили.
+type ProfileStore struct {
+	dir     string
+	current atomic.Pointer[Profile]
+
+	mu sync.Mutex
+	// rejected — версия отклонённого файла: при опросе о нём не сообщаем повторно.
+	rejected string
+}
+
+// ProfileReload — итог перезагрузки профиля.
+type ProfileReload struct {
+	// Profile — профиль, который действует после перезагрузки.
+	Profile Profile
+	// Previous — версия, действовавшая до перезагрузки.
+	Previous string
+	// Err — файл не прочитался или не прошёл проверку; действует прежний профиль.
+	Err error
+}
+
+// Changed сообщает, что профиль подменён.
+func (r ProfileReload) Changed() bool {
+	return r.Err == nil && r.Profile.Version != r.Previous
+}
+
+// NewProfileStore создаёт хранилище с профилем initial, файл которого
+// лежит в dir. Пустой dir — встроенные пороги без перезагрузки.
+func NewProfileStore(dir string, initial Profile) *ProfileStore {
+	s := &ProfileStore{dir: dir}
+	s.current.Store(&initial)
+	return s
+}
+
+// Current возвращает действующий профиль.
+func (s *ProfileStore) Current() Profile {
+	return *s.current.Load()
+}
+
+// Versions возвращает версию действующего профиля по его имени.
+func (s *ProfileStore) Versions() map[string]string {
+	profile := s.Current()
+	return map[string]string{profile.Name: profile.Version}
+}
+
+// Reload перечитывает файл профиля. Новый профиль заменяет действующий,
+// только если разобрался и прошёл проверку.
+func (s *ProfileStore) Reload() ProfileReload {
+	reload, _ := s.reload(true)
+	return reload
+}
+
+// reload перечитывает файл; ok — есть о чём сообщить. Без force неизменный
+// файл и уже отклонённая версия не сообщаются.
+func (s *ProfileStore) reload(force bool) (ProfileReload, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.Current()
+	reload := ProfileReload{Profile: current, Previous: current.Version}
+	if s.dir == "" {
+		return reload, force
+	}
+
+	data, err := os.ReadFile(ProfilePath(s.dir, current.Name))
+	if err != nil {
+		reload.Err = fmt.Errorf("read detector profile: %w", err)
+		return reload, s.reject(err.Error(), force)
+	}
+	version := profileVersion(data)
+	if version == current.Version {
+		s.rejected = ""
+		return reload, force
+	}
+
+	next, err := ParseProfile(current.Name, data)
+	if err != nil {
+		reload.Err = err
+		return reload, s.reject(version, force)
+	}
+	next.Detector.DebugDumpDir = current.Detector.DebugDumpDir
+	s.rejected = ""
+	s.current.Store(&next)
+	reload.Profile = next
+	return reload, true
+}
+
+// reject запоминает отклонённую версию и решает, сообщать ли о ней.
+func (s *ProfileStore) reject(version string, force bool) bool {
+	repeated := s.rejected == version
+	s.rejected = version
+	return force || !repeated
+}
+
+// Watch перечитывает профиль каждые interval (0 — только по сигналу)
+// и по каждому сигналу из signals. report получает смену профиля,
+// отклонённый файл, а при сигнале — и неизменный профиль.
+// Завершается при отмене ctx.
+func (s *ProfileStore) Watch(ctx context.Context, interval time.Duration, signals <-chan os.Signal, report func(ProfileReload)) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		force := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-signals:
+			force = true
+		}
+		if reload, ok := s.reload(force); ok {
+			report(reload)
+		}
+	}
+}
+
+// Inspect проверяет изображение с действующим профилем.
+func (s *ProfileStore) Inspect(ctx context.Context, imageData []byte) (*entity.InspectionResult, error) {
+	profile := s.Current()
+	return profile.stamp(profile.Detector.Inspect(ctx, imageData))
+}
+
+// InspectDiff сравнивает эталон и деталь с действующим профилем.
+func (s *ProfileStore) InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	profile := s.Current()
+	return profile.stamp(profile.Detector.InspectDiff(ctx, baseImage, currentImage, zones))
+}
+
+// HighlightDefects подсвечивает дефекты; пороги профиля здесь не участвуют.
+func (s *ProfileStore) HighlightDefects(imageData []byte, result *entity.InspectionResult) ([]byte, error) {
+	return s.Current().Detector.HighlightDefects(imageData, result)
+}
+
+// BuildGoldenModel собирает модель годных деталей с действующим профилем.
+func (s *ProfileStore) BuildGoldenModel(ctx context.Context, samples [][]byte) (*entity.GoldenModel, error) {
+	return s.Current().Detector.BuildGoldenModel(ctx, samples)
+}
+
+// ExtendGoldenModel дополняет модель годной деталью с действующим профилем.
+func (s *ProfileStore) ExtendGoldenModel(ctx context.Context, model *entity.GoldenModel, sample []byte) (*entity.GoldenModel, error) {
+	return s.Current().Detector.ExtendGoldenModel(ctx, model, sample)
+}
+
+// InspectGolden сравнивает деталь с моделью годных деталей с действующим профилем.
+func (s *ProfileStore) InspectGolden(ctx context.Context, model *entity.GoldenModel, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	profile := s.Current()
+	return profile.stamp(profile.Detector.InspectGolden(ctx, model, currentImage, zones))
+}
+
+// CalibrateByPart измеряет деталь эталона с действующим профилем.
+func (s *ProfileStore) CalibrateByPart(ctx context.Context, imageData []byte, lengthMM float64) (*entity.Calibration, error) {
+	return s.Current().Detector.CalibrateByPart(ctx, imageData, lengthMM)
+}
+
+// CalibrateByMarker ищет маркер, заданный действующим профилем.
+func (s *ProfileStore) CalibrateByMarker(ctx context.Context, imageData []byte) (*entity.Calibration, error) {
+	return s.Current().Detector.CalibrateByMarker(ctx, imageData)
+}
+
+// Tolerance возвращает допуск действующего профиля.
+func (s *ProfileStore) Tolerance() entity.Tolerance {
+	return s.Current().Detector.Tolerance()
+}
+
+// stamp записывает в результат профиль, с которым шла проверка.
+func (p Profile) stamp(result *entity.InspectionResult, err error) (*entity.InspectionResult, error) {
+	if result != nil {
+		result.Trace.Profile = p.Name
+		result.Trace.ProfileVersion = p.Version
+	}
+	return result, err
+}
+
+// Проверка реализации интерфейса
+var (
+	_ port.GoldenModelDetector = (*ProfileStore)(nil)
+	_ port.Calibrator          = (*ProfileStore)(nil)
+)
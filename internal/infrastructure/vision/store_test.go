@@ -0,0 +1,109 @@
+package vision
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeProfile(t *testing.T, dir, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(ProfilePath(dir, "default"), []byte(content), 0o600))
+}
+
+func TestParseProfile_RejectsUnknownAndInvalidValues(t *testing.T) {
+	profile, err := ParseProfile("default", []byte("min_alignment_score: 0.4\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 0.4, profile.Detector.MinAlignmentScore)
+	assert.Equal(t, 1024, profile.Detector.MaxSide, "unset thresholds keep built-in values")
+	assert.Len(t, profile.Version, 12)
+
+	// Секция quick дополняет встроенные пороги, а не заменяет их целиком
+	profile, err = ParseProfile("default", []byte("quick:\n  color_z_threshold: 8\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 8.0, profile.Detector.Quick.ColorZThreshold)
+	assert.Equal(t, 15, profile.Detector.Quick.VarianceWindow)
+
+	_, err = ParseProfile("default", []byte("quick:\n  scratch_kernel: 1\n  scratch_min_length: 0.1\n"))
+	assert.ErrorContains(t, err, "scratch_min_length")
+
+	_, err = ParseProfile("default", []byte("min_alignment_scor: 0.4\n"))
+	assert.ErrorContains(t, err, "min_alignment_scor")
+
+	_, err = ParseProfile("default", []byte("min_alignment_score: 4\nmax_side: 0\nquick:\n  scratch_kernel: 1\n"))
+	assert.ErrorContains(t, err, "min_alignment_score 4")
+	assert.ErrorContains(t, err, "max_side")
+	assert.ErrorContains(t, err, "quick.scratch_kernel")
+
+	profile, err = ParseProfile("default", []byte("color:\n  enabled: false\n"))
+	require.NoError(t, err)
+	assert.False(t, profile.Detector.Color.Enabled)
+	assert.Equal(t, 12.0, profile.Detector.Color.DeltaEThreshold)
+
+	_, err = ParseProfile("default", []byte("color:\n  delta_e_threshold: 0\n"))
+	assert.ErrorContains(t, err, "color.delta_e_threshold")
+
+	_, err = ParseProfile("default", []byte("critical_zone_sensitivity: 0\n"))
+	assert.ErrorContains(t, err, "critical_zone_sensitivity")
+
+	_, err = ParseProfile("default", []byte("calibration:\n  marker: aruco\n  aruco_dictionary: 3x3_10\n"))
+	assert.ErrorContains(t, err, "calibration.marker_size_mm")
+	assert.ErrorContains(t, err, "calibration.aruco_dictionary")
+
+	profile, err = ParseProfile("default", []byte("orientation:\n  allow_flip: false\n"))
+	require.NoError(t, err)
+	assert.True(t, profile.Detector.Orientation.Enabled)
+	assert.False(t, profile.Detector.Orientation.AllowFlip)
+
+	_, err = ParseProfile("default", []byte("orientation:\n  min_angle: 180\n"))
+	assert.ErrorContains(t, err, "orientation.min_angle")
+
+	profile, err = ParseProfile("default", []byte("multi_part:\n  enabled: true\n"))
+	require.NoError(t, err)
+	assert.True(t, profile.Detector.MultiPart.Enabled)
+	assert.Equal(t, 8, profile.Detector.MultiPart.MaxParts)
+
+	_, err = ParseProfile("default", []byte("multi_part:\n  max_parts: 1\n"))
+	assert.ErrorContains(t, err, "multi_part.max_parts")
+}
+
+func TestProfileStore_Reload(t *testing.T) {
+	dir := t.TempDir()
+	writeProfile(t, dir, "min_alignment_score: 0.3\n")
+	initial, err := LoadProfile(dir, "default")
+	require.NoError(t, err)
+	initial.Detector.DebugDumpDir = "/tmp/dump"
+	store := NewProfileStore(dir, initial)
+
+	// Файл не менялся: при опросе сообщать не о чем
+	_, ok := store.reload(false)
+	assert.False(t, ok)
+
+	writeProfile(t, dir, "min_alignment_score: 0.5\n")
+	reload, ok := store.reload(false)
+	require.True(t, ok)
+	require.NoError(t, reload.Err)
+	assert.True(t, reload.Changed())
+	assert.Equal(t, initial.Version, reload.Previous)
+	assert.Equal(t, 0.5, store.Current().Detector.MinAlignmentScore)
+	assert.Equal(t, "/tmp/dump", store.Current().Detector.DebugDumpDir)
+
+	// Проверка, начатая до перезагрузки, держит свой снимок
+	snapshot := store.Current()
+
+	writeProfile(t, dir, "min_alignment_score: [\n")
+	reload, ok = store.reload(false)
+	require.True(t, ok)
+	assert.Error(t, reload.Err)
+	assert.False(t, reload.Changed())
+	assert.Equal(t, snapshot.Version, store.Current().Version, "broken file keeps the previous profile")
+
+	// О том же сломанном файле опрос не сообщает повторно, сигнал — сообщает
+	_, ok = store.reload(false)
+	assert.False(t, ok)
+	assert.Error(t, store.Reload().Err)
+
+	assert.Equal(t, 0.5, snapshot.Detector.MinAlignmentScore)
+}
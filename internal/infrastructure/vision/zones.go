@@ -0,0 +1,91 @@
+package vision
+
+import (
+	"math"
+	"slices"
+
+	"vision-bot/internal/domain/entity"
+)
+
+// zoneSensitivity раскладывает зоны эталона по пикселям кадра width×height:
+// множитель чувствительности каждого пикселя, 0 — пиксель не проверяется.
+// Зоны накладываются по порядку. nil — зон нет, множитель везде 1.
+func zoneSensitivity(zones []entity.Zone, width, height int, critical float64) []float32 {
+	if len(zones) == 0 || width <= 0 || height <= 0 {
+		return nil
+	}
+
+	sensitivity := make([]float32, width*height)
+	for i := range sensitivity {
+		sensitivity[i] = 1
+	}
+	for _, zone := range zones {
+		value := float32(zoneMultiplier(zone, critical))
+		minX, minY, maxX, maxY := 1.0, 1.0, 0.0, 0.0
+		for _, p := range zone.Polygon {
+			minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+			minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+		}
+		// Пиксель принадлежит зоне, если в неё попал его центр.
+		for y := int(minY * float64(height)); y < min(height, int(math.Ceil(maxY*float64(height)))); y++ {
+			fy := (float64(y) + 0.5) / float64(height)
+			for x := int(minX * float64(width)); x < min(width, int(math.Ceil(maxX*float64(width)))); x++ {
+				if zone.Contains((float64(x)+0.5)/float64(width), fy) {
+					sensitivity[y*width+x] = value
+				}
+			}
+		}
+	}
+	return sensitivity
+}
+
+// zoneMultiplier — множитель чувствительности зоны: явно заданный или
+// по умолчанию для её типа. У игнорируемой зоны — 0.
+func zoneMultiplier(zone entity.Zone, critical float64) float64 {
+	switch {
+	case zone.Kind == entity.ZoneIgnore:
+		return 0
+	case zone.Sensitivity > 0:
+		return zone.Sensitivity
+	case zone.Kind == entity.ZoneCritical:
+		return critical
+	default:
+		return 1
+	}
+}
+
+// restrictToZones снимает с маски roi пиксели игнорируемых зон.
+func restrictToZones(roi []byte, sensitivity []float32) []byte {
+	out := slices.Clone(roi)
+	for i, s := range sensitivity {
+		if s == 0 {
+			out[i] = 0
+		}
+	}
+	return out
+}
+
+// zoneThresholdMask — бинарный порог с учётом зон: пиксель отмечается 255,
+// если значение больше threshold, делённого на множитель его зоны.
+func zoneThresholdMask(values []byte, sensitivity []float32, threshold float64) []byte {
+	out := make([]byte, len(values))
+	for i, v := range values {
+		if s := sensitivity[i]; s > 0 && float64(v) > threshold/float64(s) {
+			out[i] = 255
+		}
+	}
+	return out
+}
+
+// annotateZones записывает в дефекты имя зоны, в которую попал их центр.
+func annotateZones(defects []entity.DefectArea, zones []entity.Zone, width, height int) {
+	if len(zones) == 0 || width <= 0 || height <= 0 {
+		return
+	}
+	for i := range defects {
+		x, y := defects[i].Center()
+		if zone, ok := entity.ZoneAt(zones, (float64(x)+0.5)/float64(width), (float64(y)+0.5)/float64(height)); ok {
+			defects[i].Zone = zone.Name
+		}
+	}
+}
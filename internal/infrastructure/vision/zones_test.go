@@ -0,0 +1,40 @@
+package vision
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"vision-bot/internal/domain/entity"
+)
+
+func TestZoneSensitivity(t *testing.T) {
+	assert.Nil(t, zoneSensitivity(nil, 4, 2, 2))
+
+	// Кадр 4×2: левая половина — критичная зона, правый верхний угол — маркировка.
+	zones := []entity.Zone{
+		{Name: "seat", Kind: entity.ZoneCritical, Polygon: []entity.ZonePoint{{X: 0, Y: 0}, {X: 0.5, Y: 0}, {X: 0.5, Y: 1}, {X: 0, Y: 1}}},
+		{Name: "label", Kind: entity.ZoneIgnore, Sensitivity: 5, Polygon: []entity.ZonePoint{{X: 0.75, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 0.5}, {X: 0.75, Y: 0.5}}},
+	}
+	sensitivity := zoneSensitivity(zones, 4, 2, 2)
+	assert.Equal(t, []float32{2, 2, 1, 0, 2, 2, 1, 1}, sensitivity)
+
+	// Явный множитель зоны важнее умолчания для типа.
+	zones[0].Sensitivity = 3
+	assert.Equal(t, float32(3), zoneSensitivity(zones, 4, 2, 2)[0])
+
+	assert.Equal(t, []byte{255, 255, 255, 0, 0, 255, 255, 255}, restrictToZones([]byte{255, 255, 255, 255, 0, 255, 255, 255}, sensitivity))
+	assert.Equal(t, []byte{255, 0, 0, 0, 0, 0, 0, 255}, zoneThresholdMask([]byte{15, 9, 15, 90, 0, 0, 19, 21}, sensitivity, 20))
+}
+
+func TestAnnotateZones(t *testing.T) {
+	zones := []entity.Zone{entity.GridCell(0, 1, 2, 2, entity.ZoneCritical)}
+	defects := []entity.DefectArea{
+		{X: 60, Y: 10, Width: 10, Height: 10},
+		{X: 10, Y: 60, Width: 10, Height: 10},
+	}
+
+	annotateZones(defects, zones, 100, 100)
+	assert.Equal(t, "A2", defects[0].Zone)
+	assert.Empty(t, defects[1].Zone)
+}
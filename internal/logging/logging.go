@@ -0,0 +1,140 @@
+// Package logging настраивает структурированные логи (log/slog) и переносит
+// через context идентификатор проверки, чтобы все строки одной проверки
+// можно было найти одним grep.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Форматы вывода логов.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// InspectionIDKey — имя атрибута с идентификатором проверки.
+const InspectionIDKey = "inspection_id"
+
+// New создаёт логгер с форматом format (text или json) и уровнем level
+// (debug, info, warn, error). Идентификатор проверки из ctx добавляется
+// к каждой записи автоматически.
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		handler = slog.NewTextHandler(w, options)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return nil, fmt.Errorf("unknown log format %q: expected text or json", format)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// ParseLevel разбирает уровень логирования.
+func ParseLevel(level string) (slog.Level, error) {
+	var lvl slog.Level
+	if level == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return lvl, fmt.Errorf("unknown log level %q: expected debug, info, warn or error", level)
+	}
+	return lvl, nil
+}
+
+type inspectionIDKey struct{}
+
+// WithInspectionID сохраняет в ctx идентификатор проверки.
+func WithInspectionID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, inspectionIDKey{}, id)
+}
+
+// InspectionID возвращает идентификатор проверки из ctx или пустую строку.
+func InspectionID(ctx context.Context) string {
+	id, _ := ctx.Value(inspectionIDKey{}).(string)
+	return id
+}
+
+// NewInspectionID генерирует короткий идентификатор проверки.
+func NewInspectionID() string {
+	buf := make([]byte, 6)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+type attrsKey struct{}
+
+// With добавляет атрибуты (пары ключ-значение, как в slog.Logger.With)
+// ко всем записям, сделанным с этим ctx.
+func With(ctx context.Context, args ...any) context.Context {
+	attrs := append([]slog.Attr(nil), attrsFrom(ctx)...)
+	attrs = append(attrs, slog.Group("", args...).Value.Group()...)
+	return context.WithValue(ctx, attrsKey{}, attrs)
+}
+
+func attrsFrom(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+// Carry переносит атрибуты логов и идентификатор проверки из from в ctx —
+// например, в фоновую задачу, которая переживает обработку запроса.
+func Carry(ctx, from context.Context) context.Context {
+	if attrs := attrsFrom(from); len(attrs) > 0 {
+		ctx = context.WithValue(ctx, attrsKey{}, attrs)
+	}
+	if id := InspectionID(from); id != "" {
+		ctx = WithInspectionID(ctx, id)
+	}
+	return ctx
+}
+
+// contextHandler добавляет к записи атрибуты из context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	record.AddAttrs(attrsFrom(ctx)...)
+	if id := InspectionID(ctx); id != "" {
+		record.AddAttrs(slog.String(InspectionIDKey, id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// Sampler прореживает однотипные подробные строки: пропускает первые First,
+// затем каждую Thereafter-ю (0 — больше ни одной).
+type Sampler struct {
+	First      int
+	Thereafter int
+}
+
+// Keep сообщает, логировать ли n-ю (с нуля) строку серии.
+func (s Sampler) Keep(n int) bool {
+	if n < s.First {
+		return true
+	}
+	return s.Thereafter > 0 && (n-s.First)%s.Thereafter == 0
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_AddsInspectionID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, "debug")
+	require.NoError(t, err)
+
+	ctx := With(context.Background(), "user_id", int64(7))
+	ctx = Carry(context.Background(), WithInspectionID(ctx, "a1b2c3"))
+	logger.With("component", "detector").DebugContext(ctx, "stage finished", "stage", "diff")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	require.Equal(t, "a1b2c3", entry[InspectionIDKey])
+	require.Equal(t, "detector", entry["component"])
+	require.Equal(t, "diff", entry["stage"])
+	require.Equal(t, float64(7), entry["user_id"])
+
+	buf.Reset()
+	logger.InfoContext(context.Background(), "no inspection")
+	require.NotContains(t, buf.String(), InspectionIDKey)
+}
+
+func TestNew_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatText, "warn")
+	require.NoError(t, err)
+
+	logger.Info("hidden")
+	logger.Warn("shown")
+	require.NotContains(t, buf.String(), "hidden")
+	require.Contains(t, buf.String(), "shown")
+
+	_, err = New(&buf, "xml", "info")
+	require.Error(t, err)
+	_, err = New(&buf, FormatText, "loud")
+	require.Error(t, err)
+
+	lvl, err := ParseLevel("")
+	require.NoError(t, err)
+	require.Equal(t, slog.LevelInfo, lvl)
+}
+
+func TestSampler_Keep(t *testing.T) {
+	sampler := Sampler{First: 2, Thereafter: 3}
+	var kept []int
+	for i := 0; i < 10; i++ {
+		if sampler.Keep(i) {
+			kept = append(kept, i)
+		}
+	}
+	require.Equal(t, []int{0, 1, 2, 5, 8}, kept)
+	require.False(t, Sampler{First: 1}.Keep(1))
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/domain/port"
+)
+
+// describer замеряет описатель дефектов и считает отказы.
+type describer struct {
+	next    port.DefectDescriber
+	metrics *Metrics
+}
+
+// InstrumentDescriber оборачивает описатель метриками.
+func (m *Metrics) InstrumentDescriber(next port.DefectDescriber) port.DefectDescriber {
+	return &describer{next: next, metrics: m}
+}
+
+func (d *describer) Describe(ctx context.Context, result *entity.InspectionResult, locale string) (*entity.AiDescription, error) {
+	started := time.Now()
+	description, err := d.next.Describe(ctx, result, locale)
+	d.metrics.describeSeconds.Observe(time.Since(started).Seconds())
+	if err != nil {
+		// Дальше ответ берётся у запасного описателя или состоит только из числа дефектов.
+		d.metrics.describeFallbacks.Inc()
+	}
+	return description, err
+}
+
+// Ping передаёт проверку доступности обёрнутому описателю, если она у него есть.
+func (d *describer) Ping(ctx context.Context) error {
+	if pinger, ok := d.next.(interface{ Ping(context.Context) error }); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
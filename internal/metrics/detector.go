@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	app "vision-bot/internal/application"
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/domain/port"
+)
+
+// detector считает проверки и замеряет конвейер детектора.
+type detector struct {
+	next    port.DefectDetector
+	metrics *Metrics
+}
+
+// goldenDetector — detector для детекторов с моделью из нескольких годных деталей.
+type goldenDetector struct {
+	*detector
+	golden port.GoldenModelDetector
+}
+
+// InstrumentDetector оборачивает детектор метриками. Если детектор умеет
+// работать с моделью годных деталей, обёртка тоже это умеет.
+func (m *Metrics) InstrumentDetector(next port.DefectDetector) port.DefectDetector {
+	d := &detector{next: next, metrics: m}
+	if golden, ok := next.(port.GoldenModelDetector); ok {
+		return &goldenDetector{detector: d, golden: golden}
+	}
+	return d
+}
+
+func (d *detector) Inspect(ctx context.Context, imageData []byte) (*entity.InspectionResult, error) {
+	started := time.Now()
+	result, err := d.next.Inspect(ctx, imageData)
+	d.metrics.observeInspection(time.Since(started), result, err)
+	return result, err
+}
+
+func (d *detector) InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	started := time.Now()
+	result, err := d.next.InspectDiff(ctx, baseImage, currentImage, zones)
+	d.metrics.observeInspection(time.Since(started), result, err)
+	return result, err
+}
+
+func (d *detector) HighlightDefects(imageData []byte, result *entity.InspectionResult) ([]byte, error) {
+	return d.next.HighlightDefects(imageData, result)
+}
+
+func (d *goldenDetector) BuildGoldenModel(ctx context.Context, samples [][]byte) (*entity.GoldenModel, error) {
+	return d.golden.BuildGoldenModel(ctx, samples)
+}
+
+func (d *goldenDetector) ExtendGoldenModel(ctx context.Context, model *entity.GoldenModel, sample []byte) (*entity.GoldenModel, error) {
+	return d.golden.ExtendGoldenModel(ctx, model, sample)
+}
+
+func (d *goldenDetector) InspectGolden(ctx context.Context, model *entity.GoldenModel, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	started := time.Now()
+	result, err := d.golden.InspectGolden(ctx, model, currentImage, zones)
+	d.metrics.observeInspection(time.Since(started), result, err)
+	return result, err
+}
+
+func (m *Metrics) observeInspection(elapsed time.Duration, result *entity.InspectionResult, err error) {
+	m.inspectionSeconds.Observe(elapsed.Seconds())
+	if err != nil || result == nil {
+		m.inspections.Inc("error", "none", app.ClassifyInspectionError(err))
+		return
+	}
+
+	verdict := "ok"
+	if result.HasDefects {
+		verdict = "defects"
+	}
+	branch := result.Trace.Branch
+	if branch == "" {
+		branch = "unknown"
+	}
+	m.inspections.Inc(verdict, branch, "none")
+	m.defects.Observe(float64(len(result.Defects)))
+	if result.Trace.AlignmentScore > 0 {
+		m.alignmentScore.Observe(result.Trace.AlignmentScore)
+	}
+	for _, stage := range result.Trace.Stages {
+		m.stageSeconds.Observe(stage.Duration.Seconds(), stage.Stage)
+	}
+}
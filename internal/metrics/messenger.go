@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"context"
+
+	"vision-bot/internal/domain/port"
+)
+
+// messenger считает неудачные вызовы Telegram.
+type messenger struct {
+	next    port.Messenger
+	metrics *Metrics
+}
+
+// InstrumentMessenger оборачивает транспорт сообщений метриками.
+func (m *Metrics) InstrumentMessenger(next port.Messenger) port.Messenger {
+	return &messenger{next: next, metrics: m}
+}
+
+func (m *messenger) SendText(ctx context.Context, to port.Recipient, text string) (int, error) {
+	id, err := m.next.SendText(ctx, to, text)
+	m.fail("send_text", err)
+	return id, err
+}
+
+func (m *messenger) SendPhoto(ctx context.Context, to port.Recipient, photo []byte, caption string) (int, error) {
+	id, err := m.next.SendPhoto(ctx, to, photo, caption)
+	m.fail("send_photo", err)
+	return id, err
+}
+
+func (m *messenger) SendAlbum(ctx context.Context, to port.Recipient, photos []port.Photo) error {
+	err := m.next.SendAlbum(ctx, to, photos)
+	m.fail("send_album", err)
+	return err
+}
+
+func (m *messenger) SendKeyboard(ctx context.Context, to port.Recipient, text string, keyboard port.Keyboard) (int, error) {
+	id, err := m.next.SendKeyboard(ctx, to, text, keyboard)
+	m.fail("send_keyboard", err)
+	return id, err
+}
+
+func (m *messenger) EditText(ctx context.Context, chatID int64, messageID int, text string) error {
+	err := m.next.EditText(ctx, chatID, messageID, text)
+	m.fail("edit_text", err)
+	return err
+}
+
+func (m *messenger) EditKeyboard(ctx context.Context, chatID int64, messageID int, text string, keyboard port.Keyboard) error {
+	err := m.next.EditKeyboard(ctx, chatID, messageID, text, keyboard)
+	m.fail("edit_keyboard", err)
+	return err
+}
+
+func (m *messenger) AnswerCallback(ctx context.Context, callbackID string, text string) error {
+	err := m.next.AnswerCallback(ctx, callbackID, text)
+	m.fail("answer_callback", err)
+	return err
+}
+
+func (m *messenger) GetFile(ctx context.Context, fileID string) ([]byte, error) {
+	data, err := m.next.GetFile(ctx, fileID)
+	m.fail("download", err)
+	return data, err
+}
+
+func (m *messenger) fail(op string, err error) {
+	if err != nil {
+		m.metrics.telegramFailures.Inc(op)
+	}
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	app "vision-bot/internal/application"
+)
+
+// Границы корзин гистограмм.
+var (
+	latencyBuckets   = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	alignmentBuckets = []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1}
+	defectBuckets    = []float64{0, 1, 2, 3, 5, 8, 13, 20, 50}
+)
+
+// Metrics — набор метрик бота. Пишут в них декораторы портов,
+// поэтому ядро приложения о метриках не знает.
+type Metrics struct {
+	registry *Registry
+
+	inspections       *Counter
+	inspectionSeconds *Histogram
+	stageSeconds      *Histogram
+	alignmentScore    *Histogram
+	defects           *Histogram
+	describeSeconds   *Histogram
+	describeFallbacks *Counter
+	telegramFailures  *Counter
+}
+
+// New регистрирует метрики бота в registry.
+func New(registry *Registry) *Metrics {
+	return &Metrics{
+		registry: registry,
+		inspections: registry.NewCounter("vision_bot_inspections_total",
+			"Inspections by verdict (ok, defects, error), detector branch and error class.",
+			"verdict", "branch", "error"),
+		inspectionSeconds: registry.NewHistogram("vision_bot_inspection_duration_seconds",
+			"Total detector time per inspection.", latencyBuckets),
+		stageSeconds: registry.NewHistogram("vision_bot_detector_stage_duration_seconds",
+			"Detector pipeline stage latency.", latencyBuckets, "stage"),
+		alignmentScore: registry.NewHistogram("vision_bot_alignment_score",
+			"Alignment score of the part photo against the reference.", alignmentBuckets),
+		defects: registry.NewHistogram("vision_bot_defects_per_inspection",
+			"Number of defects found per successful inspection.", defectBuckets),
+		describeSeconds: registry.NewHistogram("vision_bot_describer_duration_seconds",
+			"Defect description latency.", latencyBuckets),
+		describeFallbacks: registry.NewCounter("vision_bot_describer_fallbacks_total",
+			"Describer failures answered by the fallback description or the plain defect count."),
+		telegramFailures: registry.NewCounter("vision_bot_telegram_failures_total",
+			"Failed Telegram API calls by operation.",
+			"op"),
+	}
+}
+
+// RateLimitSource отдаёт состояние ограничителя нагрузки.
+type RateLimitSource interface {
+	Stats() app.RateLimitStats
+}
+
+// ObserveRateLimiter публикует очередь проверок и число отказов по лимитам.
+func (m *Metrics) ObserveRateLimiter(source RateLimitSource) {
+	m.registry.NewGaugeFunc("vision_bot_checks_running",
+		"Inspections running right now.",
+		func() float64 { return float64(source.Stats().Running) })
+	m.registry.NewGaugeFunc("vision_bot_checks_queued",
+		"Inspections waiting for a free slot.",
+		func() float64 { return float64(source.Stats().Queued) })
+	m.registry.NewCounterFunc("vision_bot_photos_throttled_total",
+		"Photos rejected by rate limits, by scope (user, chat).",
+		"scope", func() map[string]float64 {
+			stats := source.Stats()
+			return map[string]float64{
+				app.RateScopeUser: float64(stats.ThrottledUsers),
+				app.RateScopeChat: float64(stats.ThrottledChats),
+			}
+		})
+}
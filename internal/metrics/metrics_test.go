@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	app "vision-bot/internal/application"
+	"vision-bot/internal/domain/entity"
+	"vision-bot/internal/domain/port"
+)
+
+type stubDetector struct {
+	result *entity.InspectionResult
+	err    error
+}
+
+func (d *stubDetector) Inspect(ctx context.Context, imageData []byte) (*entity.InspectionResult, error) {
+	return d.result, d.err
+}
+
+func (d *stubDetector) InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	return d.result, d.err
+}
+
+func (d *stubDetector) HighlightDefects(imageData []byte, result *entity.InspectionResult) ([]byte, error) {
+	return nil, nil
+}
+
+type stubGoldenDetector struct {
+	stubDetector
+}
+
+func (d *stubGoldenDetector) BuildGoldenModel(ctx context.Context, samples [][]byte) (*entity.GoldenModel, error) {
+	return &entity.GoldenModel{Samples: len(samples)}, nil
+}
+
+func (d *stubGoldenDetector) ExtendGoldenModel(ctx context.Context, model *entity.GoldenModel, sample []byte) (*entity.GoldenModel, error) {
+	return &entity.GoldenModel{Samples: model.Samples + 1}, nil
+}
+
+func (d *stubGoldenDetector) InspectGolden(ctx context.Context, model *entity.GoldenModel, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	return d.result, d.err
+}
+
+type stubLimiter struct {
+	stats app.RateLimitStats
+}
+
+func (l stubLimiter) Stats() app.RateLimitStats {
+	return l.stats
+}
+
+func exposition(t *testing.T, registry *Registry) string {
+	t.Helper()
+	var out strings.Builder
+	_, err := registry.WriteTo(&out)
+	require.NoError(t, err)
+	return out.String()
+}
+
+func TestRegistry_TextFormat(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounter("test_total", "Test counter.", "kind")
+	histogram := registry.NewHistogram("test_seconds", "Test histogram.", []float64{1, 0.5})
+	registry.NewGaugeFunc("test_gauge", "Test gauge.", func() float64 { return 3 })
+
+	counter.Inc(`a"b`)
+	counter.Add(2, "plain")
+	histogram.Observe(0.2)
+	histogram.Observe(0.7)
+	histogram.Observe(5)
+
+	require.Equal(t, `# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{kind="a\"b"} 1
+test_total{kind="plain"} 2
+# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.5"} 1
+test_seconds_bucket{le="1"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 5.9
+test_seconds_count 3
+# HELP test_gauge Test gauge.
+# TYPE test_gauge gauge
+test_gauge 3
+`, exposition(t, registry))
+}
+
+func TestRegistry_DuplicateNamePanics(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("dup_total", "First.")
+	require.Panics(t, func() { registry.NewCounter("dup_total", "Second.") })
+}
+
+func TestInstrumentDetector_CountsVerdictsAndStages(t *testing.T) {
+	m := New(NewRegistry())
+	found := &stubDetector{result: &entity.InspectionResult{
+		Defects:    []entity.DefectArea{{Width: 4, Height: 4}},
+		HasDefects: true,
+		Trace: entity.InspectionTrace{
+			Branch:         "broken",
+			AlignmentScore: 0.8,
+			Stages:         []entity.StageTiming{{Stage: "decode", Duration: time.Millisecond}, {Stage: "diff", Duration: 2 * time.Millisecond}},
+		},
+	}}
+	failed := &stubDetector{err: errors.New("quality gate failed for base image: too dark")}
+
+	_, _ = m.InstrumentDetector(found).InspectDiff(context.Background(), nil, nil, nil)
+	_, _ = m.InstrumentDetector(found).InspectDiff(context.Background(), nil, nil, nil)
+	_, err := m.InstrumentDetector(failed).InspectDiff(context.Background(), nil, nil, nil)
+	require.Error(t, err)
+
+	require.Equal(t, 2.0, m.inspections.Value("defects", "broken", "none"))
+	require.Equal(t, 1.0, m.inspections.Value("error", "none", "quality_gate"))
+	require.Equal(t, uint64(2), m.stageSeconds.Count("decode"))
+	require.Equal(t, uint64(2), m.alignmentScore.Count())
+	require.Equal(t, uint64(3), m.inspectionSeconds.Count())
+}
+
+func TestInstrumentDetector_KeepsGoldenModelSupport(t *testing.T) {
+	m := New(NewRegistry())
+	_, ok := m.InstrumentDetector(&stubDetector{}).(port.GoldenModelDetector)
+	require.False(t, ok)
+
+	golden, ok := m.InstrumentDetector(&stubGoldenDetector{stubDetector{result: &entity.InspectionResult{
+		Trace: entity.InspectionTrace{Branch: "golden"},
+	}}}).(port.GoldenModelDetector)
+	require.True(t, ok)
+
+	model, err := golden.BuildGoldenModel(context.Background(), [][]byte{nil, nil})
+	require.NoError(t, err)
+	_, err = golden.InspectGolden(context.Background(), model, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, m.inspections.Value("ok", "golden", "none"))
+}
+
+func TestObserveRateLimiter_ExportsQueueAndThrottles(t *testing.T) {
+	registry := NewRegistry()
+	m := New(registry)
+	m.ObserveRateLimiter(stubLimiter{stats: app.RateLimitStats{ThrottledUsers: 4, ThrottledChats: 1, Running: 2, Queued: 3}})
+
+	out := exposition(t, registry)
+	require.Contains(t, out, "vision_bot_checks_queued 3\n")
+	require.Contains(t, out, "vision_bot_checks_running 2\n")
+	require.Contains(t, out, `vision_bot_photos_throttled_total{scope="chat"} 1`)
+	require.Contains(t, out, `vision_bot_photos_throttled_total{scope="user"} 4`)
+}
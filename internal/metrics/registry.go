@@ -0,0 +1,333 @@
+// Package metrics собирает метрики бота и отдаёт их в текстовом формате
+// Prometheus. Реализация намеренно маленькая: счётчики и гистограммы с метками
+// и значения, которые вычисляются в момент опроса.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry хранит метрики и выводит их в порядке регистрации.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+	names      map[string]bool
+}
+
+// NewRegistry создаёт пустой реестр.
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+type collector interface {
+	name() string
+	write(w io.Writer) error
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[c.name()] {
+		panic(fmt.Sprintf("metrics: %s registered twice", c.name()))
+	}
+	r.names[c.name()] = true
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteTo выводит все метрики в текстовом формате Prometheus.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	for _, c := range collectors {
+		if err := c.write(cw); err != nil {
+			return cw.n, err
+		}
+	}
+	return cw.n, nil
+}
+
+// Handler отдаёт метрики по HTTP для опроса Prometheus.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = r.WriteTo(w)
+	})
+}
+
+// Counter — монотонный счётчик с метками.
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter регистрирует счётчик с именами меток labels.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{metric: name, help: help, labels: labels}, values: make(map[string]float64)}
+	r.register(c)
+	return c
+}
+
+// Inc увеличивает счётчик для значений меток values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add увеличивает счётчик на delta; отрицательные значения игнорируются.
+func (c *Counter) Add(delta float64, values ...string) {
+	if delta < 0 {
+		return
+	}
+	key := c.key(values)
+	c.mu.Lock()
+	c.values[key] += delta
+	c.mu.Unlock()
+}
+
+// Value возвращает текущее значение счётчика.
+func (c *Counter) Value(values ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[c.key(values)]
+}
+
+func (c *Counter) write(w io.Writer) error {
+	c.mu.Lock()
+	keys := sortedKeys(c.values)
+	values := make([]float64, len(keys))
+	for i, key := range keys {
+		values[i] = c.values[key]
+	}
+	c.mu.Unlock()
+
+	if err := c.header(w, "counter"); err != nil {
+		return err
+	}
+	for i, key := range keys {
+		if err := c.sample(w, c.metric, key, "", values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Histogram считает распределение наблюдений по корзинам.
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // по корзинам, не накопительно
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram регистрирует гистограмму с верхними границами корзин buckets.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	h := &Histogram{
+		desc:    desc{metric: name, help: help, labels: labels},
+		buckets: sorted,
+		series:  make(map[string]*histogramSeries),
+	}
+	r.register(h)
+	return h
+}
+
+// Observe учитывает значение v для значений меток values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	key := h.key(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// Count возвращает число наблюдений для значений меток values.
+func (h *Histogram) Count(values ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[h.key(values)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	h.mu.Lock()
+	keys := make([]string, 0, len(h.series))
+	series := make(map[string]histogramSeries, len(h.series))
+	for key, s := range h.series {
+		keys = append(keys, key)
+		series[key] = histogramSeries{counts: append([]uint64(nil), s.counts...), count: s.count, sum: s.sum}
+	}
+	h.mu.Unlock()
+	sort.Strings(keys)
+
+	if err := h.header(w, "histogram"); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		s := series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			le := `le="` + formatFloat(bound) + `"`
+			if err := h.sample(w, h.metric+"_bucket", key, le, float64(cumulative)); err != nil {
+				return err
+			}
+		}
+		if err := h.sample(w, h.metric+"_bucket", key, `le="+Inf"`, float64(s.count)); err != nil {
+			return err
+		}
+		if err := h.sample(w, h.metric+"_sum", key, "", s.sum); err != nil {
+			return err
+		}
+		if err := h.sample(w, h.metric+"_count", key, "", float64(s.count)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// valueFunc — метрика, значения которой ведутся в другом месте и читаются
+// при каждом опросе. Ключ карты — значение единственной метки или пустая
+// строка, если меток нет.
+type valueFunc struct {
+	desc
+	kind   string
+	values func() map[string]float64
+}
+
+// NewGaugeFunc регистрирует показатель, который вычисляется при каждом опросе.
+func (r *Registry) NewGaugeFunc(name, help string, value func() float64) {
+	r.register(&valueFunc{desc: desc{metric: name, help: help}, kind: "gauge", values: single(value)})
+}
+
+// NewCounterFunc регистрирует счётчик с меткой label, который ведётся
+// в другом месте: values возвращает значения по значениям метки.
+func (r *Registry) NewCounterFunc(name, help, label string, values func() map[string]float64) {
+	r.register(&valueFunc{desc: desc{metric: name, help: help, labels: []string{label}}, kind: "counter", values: values})
+}
+
+func single(value func() float64) func() map[string]float64 {
+	return func() map[string]float64 {
+		return map[string]float64{"": value()}
+	}
+}
+
+func (f *valueFunc) write(w io.Writer) error {
+	values := f.values()
+	if err := f.header(w, f.kind); err != nil {
+		return err
+	}
+	for _, label := range sortedKeys(values) {
+		key := ""
+		if len(f.labels) > 0 {
+			key = f.key([]string{label})
+		}
+		if err := f.sample(w, f.metric, key, "", values[label]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// desc — имя, описание и имена меток метрики.
+type desc struct {
+	metric string
+	help   string
+	labels []string
+}
+
+func (d *desc) name() string {
+	return d.metric
+}
+
+// key собирает из значений меток готовый фрагмент вида a="1",b="2".
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.metric, len(d.labels), len(values)))
+	}
+	pairs := make([]string, len(values))
+	for i, value := range values {
+		pairs[i] = d.labels[i] + `="` + escapeLabel(value) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (d *desc) header(w io.Writer, kind string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.metric, d.help, d.metric, kind)
+	return err
+}
+
+func (d *desc) sample(w io.Writer, name, labels, extra string, value float64) error {
+	switch {
+	case labels != "" && extra != "":
+		labels = "{" + labels + "," + extra + "}"
+	case labels != "":
+		labels = "{" + labels + "}"
+	case extra != "":
+		labels = "{" + extra + "}"
+	}
+	_, err := fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(value))
+	return err
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
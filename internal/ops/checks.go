@@ -0,0 +1,65 @@
+package ops
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"vision-bot/internal/domain/port"
+)
+
+// selfTestInterval — как часто повторять самопроверку детектора.
+const selfTestInterval = time.Minute
+
+// selfTestImages — эталон и деталь с вырезом и пропущенным отверстием.
+//
+//go:embed selftest/reference.png selftest/part.png
+var selfTestImages embed.FS
+
+// Pinger умеет проверить доступность внешнего сервиса.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// DetectorCheck прогоняет встроенную пару снимков через детектор:
+// конвейер должен пройти целиком без ошибок.
+func DetectorCheck(detector port.DefectDetector) Check {
+	return Check{
+		Name:     "detector",
+		CacheFor: selfTestInterval,
+		Run: func(ctx context.Context) error {
+			if detector == nil {
+				return errors.New("detector is not configured")
+			}
+			reference, err := selfTestImages.ReadFile("selftest/reference.png")
+			if err != nil {
+				return err
+			}
+			part, err := selfTestImages.ReadFile("selftest/part.png")
+			if err != nil {
+				return err
+			}
+			if _, err := detector.InspectDiff(ctx, reference, part, nil); err != nil {
+				return fmt.Errorf("detector self-test: %w", err)
+			}
+			return nil
+		},
+	}
+}
+
+// DescriberCheck проверяет описатель, если он зависит от внешнего сервиса.
+// Отказ не снимает готовность: без описателя бот сообщает только число дефектов.
+func DescriberCheck(describer port.DefectDescriber) Check {
+	return Check{
+		Name:     "describer",
+		Optional: true,
+		Run: func(ctx context.Context) error {
+			if pinger, ok := describer.(Pinger); ok {
+				return pinger.Ping(ctx)
+			}
+			return nil
+		},
+	}
+}
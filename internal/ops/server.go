@@ -0,0 +1,267 @@
+// Package ops — служебный HTTP-сервер для оркестратора и администратора:
+// живость, готовность, состояние сборки и метрики.
+package ops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	app "vision-bot/internal/application"
+)
+
+// checkTimeout ограничивает одну проверку готовности.
+const checkTimeout = 5 * time.Second
+
+// Check — одна проверка готовности.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+	// Optional — отказ не снимает готовность: бот продолжает работать
+	// в запасном режиме, а проверка отмечается как degraded.
+	Optional bool
+	// CacheFor — сколько переиспользовать результат дорогой проверки.
+	CacheFor time.Duration
+}
+
+// Options задаёт, что сервер проверяет и о чём сообщает.
+type Options struct {
+	Version   string
+	BuildTags []string
+	// Profiles возвращает версии действующих профилей детектора.
+	Profiles func() map[string]string
+	// Queue возвращает загрузку слотов проверки.
+	Queue  func() app.RateLimitStats
+	Checks []Check
+	// Metrics отдаёт /metrics; nil — не отдавать.
+	Metrics http.Handler
+}
+
+// Server отдаёт /healthz, /readyz, /status и /metrics.
+type Server struct {
+	opts    Options
+	started time.Time
+	now     func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]CheckResult
+}
+
+// NewServer создаёт сервер; время работы отсчитывается от вызова.
+func NewServer(opts Options) *Server {
+	return &Server{
+		opts:    opts,
+		started: time.Now(),
+		now:     time.Now,
+		cache:   make(map[string]CheckResult),
+	}
+}
+
+// Handler возвращает маршруты сервера.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/readyz", s.handleReady)
+	mux.HandleFunc("/status", s.handleStatus)
+	if s.opts.Metrics != nil {
+		mux.Handle("/metrics", s.opts.Metrics)
+	}
+	return mux
+}
+
+// Run слушает addr до отмены ctx.
+func (s *Server) Run(ctx context.Context, addr string) error {
+	server := &http.Server{Addr: addr, Handler: s.Handler(), ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("Ops server listening", "addr", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Состояния проверки готовности.
+const (
+	CheckOK       = "ok"
+	CheckFailed   = "failed"
+	CheckDegraded = "degraded"
+)
+
+// CheckResult — итог одной проверки готовности.
+type CheckResult struct {
+	Name       string    `json:"name"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// Readiness — ответ /readyz.
+type Readiness struct {
+	Ready  bool          `json:"ready"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// Ready выполняет проверки параллельно и сводит их в ответ.
+func (s *Server) Ready(ctx context.Context) Readiness {
+	results := make([]CheckResult, len(s.opts.Checks))
+	var wg sync.WaitGroup
+	for i, check := range s.opts.Checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = s.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	ready := true
+	for _, result := range results {
+		if result.Status == CheckFailed {
+			ready = false
+		}
+	}
+	return Readiness{Ready: ready, Checks: results}
+}
+
+func (s *Server) run(ctx context.Context, check Check) CheckResult {
+	if check.CacheFor > 0 {
+		s.mu.Lock()
+		cached, ok := s.cache[check.Name]
+		s.mu.Unlock()
+		if ok && s.now().Sub(cached.CheckedAt) < check.CacheFor {
+			return cached
+		}
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	started := s.now()
+	err := check.Run(checkCtx)
+	result := CheckResult{
+		Name:       check.Name,
+		Status:     CheckOK,
+		DurationMS: s.now().Sub(started).Milliseconds(),
+		CheckedAt:  started,
+	}
+	if err != nil {
+		result.Status = CheckFailed
+		if check.Optional {
+			result.Status = CheckDegraded
+		}
+		result.Error = err.Error()
+		slog.WarnContext(ctx, "Readiness check failed", "check", check.Name, "status", result.Status, "err", err)
+	}
+
+	if check.CacheFor > 0 {
+		s.mu.Lock()
+		s.cache[check.Name] = result
+		s.mu.Unlock()
+	}
+	return result
+}
+
+// Status — ответ /status.
+type Status struct {
+	Version   string            `json:"version"`
+	GoVersion string            `json:"go_version"`
+	BuildTags []string          `json:"build_tags"`
+	Profiles  map[string]string `json:"profiles,omitempty"`
+	Queue     QueueStatus       `json:"queue"`
+	StartedAt time.Time         `json:"started_at"`
+	Uptime    string            `json:"uptime"`
+}
+
+// QueueStatus — загрузка слотов проверки.
+type QueueStatus struct {
+	Running       int `json:"running"`
+	Queued        int `json:"queued"`
+	MaxConcurrent int `json:"max_concurrent"`
+}
+
+// Status собирает сведения о сборке и текущей нагрузке.
+func (s *Server) Status() Status {
+	status := Status{
+		Version:   s.opts.Version,
+		GoVersion: runtime.Version(),
+		BuildTags: append([]string{}, s.opts.BuildTags...),
+		StartedAt: s.started,
+		Uptime:    s.now().Sub(s.started).Truncate(time.Second).String(),
+	}
+	if s.opts.Profiles != nil {
+		status.Profiles = s.opts.Profiles()
+	}
+	if s.opts.Queue != nil {
+		stats := s.opts.Queue()
+		status.Queue = QueueStatus{Running: stats.Running, Queued: stats.Queued, MaxConcurrent: stats.MaxConcurrent}
+	}
+	return status
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	readiness := s.Ready(r.Context())
+	code := http.StatusOK
+	if !readiness.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, readiness)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.Status())
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(body)
+}
+
+// Probe запрашивает path у сервера, слушающего addr, и возвращает ошибку,
+// если ответ не 200. Используется для health check контейнера.
+func Probe(ctx context.Context, addr, path string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid ops address %q: %w", addr, err)
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*checkTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+net.JoinHostPort(host, port)+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", path, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package ops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	app "vision-bot/internal/application"
+	"vision-bot/internal/domain/entity"
+)
+
+type stubDetector struct {
+	calls int
+	err   error
+}
+
+func (d *stubDetector) Inspect(ctx context.Context, imageData []byte) (*entity.InspectionResult, error) {
+	return nil, errors.New("not used")
+}
+
+func (d *stubDetector) InspectDiff(ctx context.Context, baseImage []byte, currentImage []byte, zones []entity.Zone) (*entity.InspectionResult, error) {
+	d.calls++
+	if len(baseImage) == 0 || len(currentImage) == 0 {
+		return nil, errors.New("empty self-test image")
+	}
+	return &entity.InspectionResult{}, d.err
+}
+
+func (d *stubDetector) HighlightDefects(imageData []byte, result *entity.InspectionResult) ([]byte, error) {
+	return nil, nil
+}
+
+func get(t *testing.T, handler http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestServer_Healthz(t *testing.T) {
+	rec := get(t, NewServer(Options{}).Handler(), "/healthz")
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestServer_ReadyzFailsOnRequiredCheckOnly(t *testing.T) {
+	describerDown := Check{Name: "describer", Optional: true, Run: func(context.Context) error { return errors.New("ollama is down") }}
+	telegramDown := Check{Name: "telegram", Run: func(context.Context) error { return errors.New("no route to host") }}
+	repositories := Check{Name: "repositories", Run: func(context.Context) error { return nil }}
+
+	rec := get(t, NewServer(Options{Checks: []Check{repositories, describerDown}}).Handler(), "/readyz")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var readiness Readiness
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &readiness))
+	require.True(t, readiness.Ready)
+	require.Equal(t, CheckOK, readiness.Checks[0].Status)
+	require.Equal(t, CheckDegraded, readiness.Checks[1].Status)
+	require.Equal(t, "ollama is down", readiness.Checks[1].Error)
+
+	rec = get(t, NewServer(Options{Checks: []Check{repositories, telegramDown}}).Handler(), "/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestDetectorCheck_RunsEmbeddedPairAndCaches(t *testing.T) {
+	detector := &stubDetector{}
+	server := NewServer(Options{Checks: []Check{DetectorCheck(detector)}})
+
+	require.True(t, server.Ready(context.Background()).Ready)
+	require.True(t, server.Ready(context.Background()).Ready)
+	require.Equal(t, 1, detector.calls, "self-test result is reused within the interval")
+
+	server.now = func() time.Time { return time.Now().Add(2 * selfTestInterval) }
+	detector.err = errors.New("quality gate failed for base image: image is blurry")
+	readiness := server.Ready(context.Background())
+	require.False(t, readiness.Ready)
+	require.Contains(t, readiness.Checks[0].Error, "detector self-test")
+}
+
+func TestServer_Status(t *testing.T) {
+	server := NewServer(Options{
+		Version:   "1.2.3",
+		BuildTags: []string{"gocv"},
+		Profiles:  func() map[string]string { return map[string]string{"default": "builtin"} },
+		Queue:     func() app.RateLimitStats { return app.RateLimitStats{Running: 2, Queued: 5, MaxConcurrent: 4} },
+	})
+
+	rec := get(t, server.Handler(), "/status")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var status Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	require.Equal(t, "1.2.3", status.Version)
+	require.Equal(t, []string{"gocv"}, status.BuildTags)
+	require.Equal(t, "builtin", status.Profiles["default"])
+	require.Equal(t, QueueStatus{Running: 2, Queued: 5, MaxConcurrent: 4}, status.Queue)
+}
@@ -0,0 +1,55 @@
+// Package imgutil содержит вспомогательные функции для работы с изображениями
+// без зависимости от OpenCV.
+package imgutil
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	// Регистрируем декодеры форматов, которые присылает Telegram.
+	_ "image/gif"
+	_ "image/png"
+)
+
+// thumbnailQuality — качество JPEG для превью.
+const thumbnailQuality = 80
+
+// Thumbnail уменьшает изображение так, чтобы большая сторона не превышала maxSide,
+// и возвращает его в формате JPEG.
+func Thumbnail(data []byte, maxSide int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil, fmt.Errorf("empty image")
+	}
+
+	scale := 1.0
+	if side := max(width, height); maxSide > 0 && side > maxSide {
+		scale = float64(maxSide) / float64(side)
+	}
+	dstW := max(1, int(float64(width)*scale))
+	dstH := max(1, int(float64(height)*scale))
+
+	// Для превью достаточно выборки ближайшего пикселя.
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		srcY := bounds.Min.Y + y*height/dstH
+		for x := 0; x < dstW; x++ {
+			srcX := bounds.Min.X + x*width/dstW
+			dst.Set(x, y, src.At(srcX, srcY))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,36 @@
+package imgutil
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThumbnail_ScalesDownLongestSide(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 800, 400))
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 800; x++ {
+			src.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, src))
+
+	thumb, err := Thumbnail(buf.Bytes(), 200)
+	require.NoError(t, err)
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(thumb))
+	require.NoError(t, err)
+	require.Equal(t, "jpeg", format)
+	require.Equal(t, 200, cfg.Width)
+	require.Equal(t, 100, cfg.Height)
+}
+
+func TestThumbnail_RejectsGarbage(t *testing.T) {
+	_, err := Thumbnail([]byte("not an image"), 200)
+	require.Error(t, err)
+}